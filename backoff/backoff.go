@@ -0,0 +1,130 @@
+/*
+Package backoff provides retry and backoff utilities shared across the modules
+in package core. It can also be used directly in user handlers.
+
+	retrier := backoff.Retrier{
+		Strategy:    backoff.Exponential{Base: 100 * time.Millisecond, Max: 5 * time.Second, Jitter: 0.5},
+		MaxAttempts: 5,
+	}
+	err := retrier.Do(ctx, func(ctx context.Context) error {
+		return client.Ping(ctx, nil)
+	})
+*/
+package backoff
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Strategy computes how long to wait before the next attempt. The attempt
+// starts from 1, denoting the wait after the first failure.
+type Strategy interface {
+	Duration(attempt int) time.Duration
+}
+
+// StrategyFunc is a functional Strategy.
+type StrategyFunc func(attempt int) time.Duration
+
+// Duration implements Strategy.
+func (f StrategyFunc) Duration(attempt int) time.Duration {
+	return f(attempt)
+}
+
+// Constant is a Strategy that always waits for the same duration.
+type Constant time.Duration
+
+// Duration implements Strategy.
+func (c Constant) Duration(attempt int) time.Duration {
+	return time.Duration(c)
+}
+
+// Exponential is a Strategy where the wait grows exponentially with the number
+// of attempts, ie. Base * Factor^(attempt-1), capped at Max.
+type Exponential struct {
+	// Base is the wait after the first attempt.
+	Base time.Duration
+	// Max caps the wait. Zero means no cap.
+	Max time.Duration
+	// Factor is the multiplier applied on each attempt. By default it is 2.
+	Factor float64
+	// Jitter randomizes the wait by up to ±Jitter of its value. For example,
+	// 0.5 yields a wait in [0.5d, 1.5d). The randomized wait never exceeds Max.
+	Jitter float64
+	// Min is the floor of the randomized wait. Zero means no floor.
+	Min time.Duration
+}
+
+// Duration implements Strategy.
+func (e Exponential) Duration(attempt int) time.Duration {
+	factor := e.Factor
+	if factor == 0 {
+		factor = 2
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := float64(e.Base) * math.Pow(factor, float64(attempt-1))
+	if e.Jitter > 0 {
+		d = d * (1 - e.Jitter + 2*e.Jitter*rand.Float64())
+	}
+	if e.Max > 0 && d > float64(e.Max) {
+		return e.Max
+	}
+	if d < float64(e.Min) {
+		return e.Min
+	}
+	if d > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
+
+// defaultStrategy is the Strategy of a Retrier without one.
+var defaultStrategy = Exponential{Base: time.Second, Max: time.Minute, Jitter: 0.5, Min: time.Second}
+
+// Retrier calls a function repeatedly until it succeeds, following the
+// backoff Strategy between attempts.
+type Retrier struct {
+	// Strategy decides the wait between attempts. By default, the wait starts
+	// from one second, and doubles with a jitter of 50% up to one minute.
+	Strategy Strategy
+	// MaxAttempts is the maximum number of calls. Zero means unlimited.
+	MaxAttempts int
+	// MaxElapsed is the upper limit of the total time spent. Zero means
+	// unlimited. Retrier gives up if the next wait would exceed the limit.
+	MaxElapsed time.Duration
+}
+
+// Do calls fn until it returns nil, the attempts or the elapsed time are
+// exhausted, or the context is canceled. The last error of fn is returned if
+// Retrier gives up. If the context is canceled, the context error is returned.
+func (r Retrier) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	strategy := r.Strategy
+	if strategy == nil {
+		strategy = defaultStrategy
+	}
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if r.MaxAttempts > 0 && attempt >= r.MaxAttempts {
+			return err
+		}
+		wait := strategy.Duration(attempt)
+		if r.MaxElapsed > 0 && time.Since(start)+wait > r.MaxElapsed {
+			return err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
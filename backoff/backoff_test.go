@@ -0,0 +1,104 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponential_Duration(t *testing.T) {
+	cases := []struct {
+		name     string
+		strategy Exponential
+		attempt  int
+		expected time.Duration
+	}{
+		{"first", Exponential{Base: time.Second}, 1, time.Second},
+		{"third", Exponential{Base: time.Second}, 3, 4 * time.Second},
+		{"factor", Exponential{Base: time.Second, Factor: 3}, 3, 9 * time.Second},
+		{"capped", Exponential{Base: time.Second, Max: 5 * time.Second}, 10, 5 * time.Second},
+		{"zero attempt", Exponential{Base: time.Second}, 0, time.Second},
+		{"overflow", Exponential{Base: time.Second, Max: time.Minute}, 1000, time.Minute},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, c.strategy.Duration(c.attempt))
+		})
+	}
+}
+
+func TestExponential_Jitter(t *testing.T) {
+	strategy := Exponential{Base: time.Second, Max: 3 * time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		d := strategy.Duration(2)
+		assert.GreaterOrEqual(t, int64(d), int64(time.Second))
+		assert.LessOrEqual(t, int64(d), int64(3*time.Second))
+	}
+}
+
+func TestExponential_Min(t *testing.T) {
+	strategy := Exponential{Base: time.Second, Jitter: 0.5, Min: time.Second}
+	for i := 0; i < 100; i++ {
+		assert.GreaterOrEqual(t, int64(strategy.Duration(1)), int64(time.Second))
+	}
+}
+
+func TestRetrier_Do(t *testing.T) {
+	t.Run("succeeds eventually", func(t *testing.T) {
+		var calls int
+		err := Retrier{Strategy: Constant(time.Millisecond)}.Do(context.Background(), func(ctx context.Context) error {
+			calls++
+			if calls < 3 {
+				return errors.New("foo")
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		var calls int
+		err := Retrier{Strategy: Constant(time.Millisecond), MaxAttempts: 2}.Do(context.Background(), func(ctx context.Context) error {
+			calls++
+			return errors.New("foo")
+		})
+		assert.EqualError(t, err, "foo")
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("max elapsed", func(t *testing.T) {
+		var calls int
+		err := Retrier{Strategy: Constant(time.Second), MaxElapsed: 500 * time.Millisecond}.Do(context.Background(), func(ctx context.Context) error {
+			calls++
+			return errors.New("foo")
+		})
+		assert.EqualError(t, err, "foo")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("default strategy", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		var calls int
+		err := Retrier{}.Do(ctx, func(ctx context.Context) error {
+			calls++
+			return errors.New("foo")
+		})
+		assert.Equal(t, context.DeadlineExceeded, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := Retrier{Strategy: Constant(time.Hour)}.Do(ctx, func(ctx context.Context) error {
+			return errors.New("foo")
+		})
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+}
@@ -33,7 +33,8 @@ type C struct {
 	logging.LevelLogger
 	contract.Container
 	contract.Dispatcher
-	di DiContainer
+	di           DiContainer
+	levelControl *logging.LevelControl
 }
 
 // ConfParser models a parser for configuration. For example, yaml.Parser.
@@ -132,6 +133,9 @@ func SetEnvProvider(provider EnvProvider) CoreOption {
 }
 
 // SetLoggerProvider is a CoreOption to replaces the default LoggerProvider.
+// Unless the logger has a LevelControl method, as the one of ProvideLogger
+// does, it is decorated by a *logging.LevelControl that lets all entries
+// through until levels are set.
 func SetLoggerProvider(provider LoggerProvider) CoreOption {
 	return func(values *coreValues) {
 		values.loggerProvider = provider
@@ -171,6 +175,11 @@ func New(opts ...CoreOption) *C {
 	env := values.envProvider(conf)
 	appName := values.appNameProvider(conf)
 	logger := values.loggerProvider(conf, appName, env)
+	if _, ok := logger.(interface{ LevelControl() *logging.LevelControl }); !ok {
+		// Custom loggers are wrapped so that their levels can be adjusted at
+		// runtime too. The empty global level lets all entries through.
+		logger = logging.NewLevelControl("").Filter(logger)
+	}
 	diContainer := values.diProvider(conf)
 	dispatcher := values.eventDispatcherProvider(conf)
 
@@ -182,6 +191,7 @@ func New(opts ...CoreOption) *C {
 		Container:      &container.Container{},
 		Dispatcher:     dispatcher,
 		di:             diContainer,
+		levelControl:   logger.(interface{ LevelControl() *logging.LevelControl }).LevelControl(),
 	}
 	return &c
 }
@@ -316,6 +326,7 @@ func (c *C) ProvideEssentials() {
 		ConfigRouter   contract.ConfigRouter
 		ConfigWatcher  contract.ConfigWatcher
 		Logger         log.Logger
+		LevelControl   *logging.LevelControl
 		Dispatcher     contract.Dispatcher
 		DefaultConfigs []config.ExportedConfig `group:"config,flatten"`
 	}
@@ -327,6 +338,7 @@ func (c *C) ProvideEssentials() {
 			Container:      c.Container,
 			ConfigAccessor: c.ConfigAccessor,
 			Logger:         c.LevelLogger,
+			LevelControl:   c.levelControl,
 			Dispatcher:     c.Dispatcher,
 			DefaultConfigs: provideDefaultConfig(),
 		}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"io/ioutil"
 	"os"
@@ -8,7 +9,11 @@ import (
 	"time"
 
 	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/logging"
 	"github.com/DoNewsCode/core/otgorm"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 )
@@ -39,3 +44,30 @@ func TestC_Default(t *testing.T) {
 	assert.Contains(t, string(output), "database:")
 	os.Remove(f.Name())
 }
+
+func TestC_LevelControl(t *testing.T) {
+	c := New()
+	c.ProvideEssentials()
+	c.Invoke(func(control *logging.LevelControl) {
+		assert.NotNil(t, control)
+		assert.NoError(t, control.SetTagLevel("database", "debug"))
+	})
+	_, tags := c.levelControl.Levels()
+	assert.Equal(t, map[string]string{"database": "debug"}, tags)
+}
+
+func TestC_LevelControl_customLogger(t *testing.T) {
+	var buf bytes.Buffer
+	c := New(SetLoggerProvider(func(conf contract.ConfigAccessor, appName contract.AppName, env contract.Env) log.Logger {
+		return log.NewLogfmtLogger(&buf)
+	}))
+	c.ProvideEssentials()
+	c.Invoke(func(control *logging.LevelControl, logger log.Logger) {
+		assert.NotNil(t, control)
+		assert.NoError(t, control.SetTagLevel("database", "error"))
+		level.Info(log.With(logger, "tag", "database")).Log("msg", "hidden")
+		level.Info(logger).Log("msg", "shown")
+	})
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "shown")
+}
@@ -73,6 +73,10 @@ func NewConfig(options ...Option) (*KoanfAdapter, error) {
 // return early and abort the rest of the reloading.
 func (k KoanfAdapter) Reload() error {
 	for i := len(k.layers) - 1; i >= 0; i-- {
+		if envProvider, ok := k.layers[i].Provider.(*EnvProvider); ok {
+			envProvider.reference = k.K
+			envProvider.delimiter = k.delimiter
+		}
 		err := k.K.Load(k.layers[i].Provider, k.layers[i].Parser)
 		if err != nil {
 			return fmt.Errorf("unable to load config %w", err)
@@ -35,6 +35,15 @@
 // can be build with a rich set of already available provider and parsers in koanf. See
 // https://github.com/knadh/koanf/blob/master/README.md for more info.
 //
+// Environment Variables
+//
+// EnvProvider maps prefixed environment variables onto configuration keys. Each underscore denotes a level of
+// nesting, and a double underscore denotes a literal underscore. Keys are matched case-insensitively against the
+// layers beneath, so environment variables can override any entry exported by the modules:
+//
+//  // APP_QUEUE_DEFAULT_PARALLELISM=3 overrides queue.default.parallelism
+//  c := core.New(core.WithConfigStack(config.NewEnvProvider("APP_"), nil))
+//
 // Integrate
 //
 // Package config is part of the core. When using package core, the config is bootstrapped in the initialization
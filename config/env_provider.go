@@ -0,0 +1,106 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/maps"
+)
+
+// EnvProvider is a koanf.Provider that reads configuration from environment
+// variables. Only variables starting with Prefix are considered. After the
+// prefix is trimmed, each underscore denotes a level of nesting, and a double
+// underscore denotes a literal underscore. For example, with prefix "APP_":
+//
+//  APP_QUEUE_DEFAULT_PARALLELISM=3        -> queue.default.parallelism: 3
+//  APP_GORM_MY__DB_DSN=...                -> gorm.my_db.dsn: ...
+//
+// Environment variables are conventionally upper case while configuration keys
+// are not. When used as a layer of *KoanfAdapter, the keys are resolved
+// case-insensitively against those already loaded by the layers beneath, so
+// APP_QUEUE_DEFAULT_CHECKQUEUELENGTHINTERVALSECOND correctly overrides
+// queue.default.checkQueueLengthIntervalSecond. Keys unknown to the lower
+// layers are lower cased.
+type EnvProvider struct {
+	prefix    string
+	delimiter string
+	reference *koanf.Koanf
+}
+
+// NewEnvProvider creates an *EnvProvider that reads environment variables with
+// the given prefix.
+func NewEnvProvider(prefix string) *EnvProvider {
+	return &EnvProvider{prefix: prefix, delimiter: "."}
+}
+
+// ReadBytes is not supported by the env provider.
+func (e *EnvProvider) ReadBytes() ([]byte, error) {
+	return nil, errors.New("env provider does not support this method")
+}
+
+// Read reads all matching environment variables into a nested map.
+func (e *EnvProvider) Read() (map[string]interface{}, error) {
+	var reference map[string]interface{}
+	if e.reference != nil {
+		reference = e.reference.Raw()
+	}
+	flat := make(map[string]interface{})
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, e.prefix) {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		path := resolvePath(reference, splitEnvKey(strings.TrimPrefix(parts[0], e.prefix)))
+		if len(path) == 0 {
+			continue
+		}
+		flat[strings.Join(path, e.delimiter)] = parts[1]
+	}
+	return maps.Unflatten(flat, e.delimiter), nil
+}
+
+// splitEnvKey splits FOO_BAR__BAZ into [FOO BAR_BAZ].
+func splitEnvKey(key string) []string {
+	var segments []string
+	for i, part := range strings.Split(key, "_") {
+		if part == "" && i > 0 && len(segments) > 0 {
+			segments[len(segments)-1] += "_"
+			continue
+		}
+		if len(segments) > 0 && strings.HasSuffix(segments[len(segments)-1], "_") {
+			segments[len(segments)-1] += part
+			continue
+		}
+		if part == "" {
+			continue
+		}
+		segments = append(segments, part)
+	}
+	return segments
+}
+
+// resolvePath restores the case of each segment by looking it up in the
+// reference map.
+func resolvePath(reference map[string]interface{}, segments []string) []string {
+	path := make([]string, 0, len(segments))
+	current := reference
+	for _, segment := range segments {
+		resolved := strings.ToLower(segment)
+		var next map[string]interface{}
+		for key, value := range current {
+			if strings.EqualFold(key, segment) {
+				resolved = key
+				next, _ = value.(map[string]interface{})
+				break
+			}
+		}
+		path = append(path, resolved)
+		current = next
+	}
+	return path
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"os"
+	gotesting "testing"
+
+	"github.com/knadh/koanf/parsers/json"
+	"github.com/knadh/koanf/providers/rawbytes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvProvider(t *gotesting.T) {
+	os.Setenv("ENVPROVIDERTEST_QUEUE_DEFAULT_CHECKQUEUELENGTHINTERVALSECOND", "3")
+	os.Setenv("ENVPROVIDERTEST_QUEUE_MY__QUEUE_PARALLELISM", "7")
+	defer os.Unsetenv("ENVPROVIDERTEST_QUEUE_DEFAULT_CHECKQUEUELENGTHINTERVALSECOND")
+	defer os.Unsetenv("ENVPROVIDERTEST_QUEUE_MY__QUEUE_PARALLELISM")
+
+	ka, err := NewConfig(
+		WithProviderLayer(NewEnvProvider("ENVPROVIDERTEST_"), nil),
+		WithProviderLayer(rawbytes.Provider([]byte(`{"queue":{"default":{"parallelism":1,"checkQueueLengthIntervalSecond":15}}}`)), json.Parser()),
+	)
+	assert.NoError(t, err)
+
+	var conf map[string]struct {
+		Parallelism                    int `json:"parallelism"`
+		CheckQueueLengthIntervalSecond int `json:"checkQueueLengthIntervalSecond"`
+	}
+	assert.NoError(t, ka.Unmarshal("queue", &conf))
+	assert.Equal(t, 1, conf["default"].Parallelism)
+	assert.Equal(t, 3, conf["default"].CheckQueueLengthIntervalSecond)
+	assert.Equal(t, 7, conf["my_queue"].Parallelism)
+}
+
+func TestSplitEnvKey(t *gotesting.T) {
+	assert.Equal(t, []string{"GORM", "MY_DB", "DSN"}, splitEnvKey("GORM_MY__DB_DSN"))
+	assert.Equal(t, []string{"LOG", "LEVEL"}, splitEnvKey("LOG_LEVEL"))
+}
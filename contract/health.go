@@ -0,0 +1,12 @@
+package contract
+
+import "context"
+
+// HealthChecker checks the health of a dependency, such as a database
+// connection or a queue.
+type HealthChecker interface {
+	// Name identifies the dependency in health reports.
+	Name() string
+	// HealthCheck returns nil if the dependency is healthy.
+	HealthCheck(ctx context.Context) error
+}
@@ -97,7 +97,7 @@ func ProvideLogger(conf contract.ConfigAccessor, appName contract.AppName, env c
 	}
 	logger := logging.NewLogger(format)
 	logger = level.NewInjector(logger, level.DebugValue())
-	return level.NewFilter(logger, logging.LevelFilter(lvl))
+	return logging.NewLevelControl(lvl).Filter(logger)
 }
 
 // ProvideDi is the default DiProvider for package Core.
@@ -1,6 +1,11 @@
 package di
 
-import "sync"
+import (
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+)
 
 // Pair is a tuple representing a connection and a closer function
 type Pair struct {
@@ -8,41 +13,85 @@ type Pair struct {
 	Closer func()
 }
 
+// Stat is the lifecycle metadata of a connection cached in the factory.
+type Stat struct {
+	// CreatedAt is the time when the connection was created.
+	CreatedAt time.Time
+	// LastAccessedAt is the last time the connection was returned by Make.
+	LastAccessedAt time.Time
+	// Reused is the number of times the cached connection was returned by Make
+	// instead of creating a new one.
+	Reused int
+}
+
 // Factory is a concurrent safe, generic factory for databases and connections.
 type Factory struct {
-	mutex       sync.Mutex
-	cache       map[string]Pair
-	constructor func(name string) (Pair, error)
+	mutex            sync.Mutex
+	cache            map[string]Pair
+	stats            map[string]Stat
+	validated        map[string]time.Time
+	constructor      func(name string) (Pair, error)
+	tracer           opentracing.Tracer
+	component        string
+	validate         func(name string, conn interface{}) error
+	validateInterval time.Duration
+	names            []string
 }
 
 // NewFactory creates a new factory.
-func NewFactory(constructor func(name string) (Pair, error)) *Factory {
-	return &Factory{
+func NewFactory(constructor func(name string) (Pair, error), options ...FactoryOption) *Factory {
+	factory := &Factory{
 		mutex:       sync.Mutex{},
 		cache:       make(map[string]Pair),
+		stats:       make(map[string]Stat),
+		validated:   make(map[string]time.Time),
 		constructor: constructor,
 	}
+	for _, option := range options {
+		option(factory)
+	}
+	return factory
 }
 
 // Make creates an instance under the provided name. It an instance is already
-// created and it is not nil, that instance is returned to the caller.
+// created and it is not nil, that instance is returned to the caller. See
+// WithValidator to validate the instance before returning it.
 func (f *Factory) Make(name string) (interface{}, error) {
 	var err error
 
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 
-	if slot, ok := f.cache[name]; ok && slot.Conn != nil {
+	if slot, ok := f.cache[name]; ok && slot.Conn != nil && f.valid(name, slot) {
+		stat := f.stats[name]
+		stat.LastAccessedAt = time.Now()
+		stat.Reused++
+		f.stats[name] = stat
 		return slot.Conn, nil
 	}
 
-	if f.cache[name], err = f.constructor(name); err != nil {
+	if f.cache[name], err = f.construct(name); err != nil {
 		return nil, err
 	}
+	now := time.Now()
+	f.stats[name] = Stat{CreatedAt: now, LastAccessedAt: now}
+	f.validated[name] = now
 
 	return f.cache[name].Conn, nil
 }
 
+// Stats returns a snapshot of the lifecycle metadata of each cached
+// connection, keyed by name. It is useful for diagnosing stale connections.
+func (f *Factory) Stats() map[string]Stat {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	out := make(map[string]Stat, len(f.stats))
+	for name, stat := range f.stats {
+		out[name] = stat
+	}
+	return out
+}
+
 // List lists created instance in the factory.
 func (f *Factory) List() map[string]Pair {
 	f.mutex.Lock()
@@ -77,5 +126,7 @@ func (f *Factory) CloseConn(name string) {
 	if pair, ok := f.cache[name]; ok && pair.Closer != nil {
 		f.cache[name].Closer()
 		delete(f.cache, name)
+		delete(f.stats, name)
+		delete(f.validated, name)
 	}
 }
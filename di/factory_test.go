@@ -1,8 +1,12 @@
 package di
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -40,3 +44,132 @@ func TestFactory(t *testing.T) {
 	f.Close()
 	assert.Contains(t, closed, "foo", "bar")
 }
+
+func TestFactory_Stats(t *testing.T) {
+	t.Parallel()
+	f := NewFactory(func(name string) (Pair, error) {
+		return Pair{Conn: name, Closer: func() {}}, nil
+	})
+
+	_, _ = f.Make("foo")
+	_, _ = f.Make("foo")
+	_, _ = f.Make("foo")
+	_, _ = f.Make("bar")
+
+	stats := f.Stats()
+	assert.Len(t, stats, 2)
+	assert.Equal(t, 2, stats["foo"].Reused)
+	assert.Equal(t, 0, stats["bar"].Reused)
+	assert.False(t, stats["foo"].CreatedAt.IsZero())
+	assert.False(t, stats["foo"].LastAccessedAt.Before(stats["foo"].CreatedAt))
+
+	f.CloseConn("foo")
+	assert.NotContains(t, f.Stats(), "foo")
+}
+
+func TestFactory_WithTracer(t *testing.T) {
+	t.Parallel()
+	tracer := mocktracer.New()
+	f := NewFactory(func(name string) (Pair, error) {
+		if name == "bad" {
+			return Pair{}, errors.New("bad connection")
+		}
+		return Pair{Conn: name}, nil
+	}, WithTracer(tracer, "test"))
+
+	_, err := f.Make("foo")
+	assert.NoError(t, err)
+	_, err = f.Make("foo")
+	assert.NoError(t, err)
+	_, err = f.Make("bad")
+	assert.Error(t, err)
+
+	// cached connections are not traced.
+	spans := tracer.FinishedSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "di.Factory.Make", spans[0].OperationName)
+	assert.Equal(t, "test", spans[0].Tag("component"))
+	assert.Equal(t, "foo", spans[0].Tag("name"))
+	assert.Nil(t, spans[0].Tag("error"))
+	assert.Equal(t, true, spans[1].Tag("error"))
+}
+
+func TestFactory_WithNilTracer(t *testing.T) {
+	t.Parallel()
+	f := NewFactory(func(name string) (Pair, error) {
+		return Pair{Conn: name}, nil
+	}, WithTracer(nil, "test"))
+	conn, err := f.Make("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", conn)
+}
+
+func TestFactory_WithValidator(t *testing.T) {
+	t.Parallel()
+	var (
+		created, closed int
+		healthy         = true
+	)
+	f := NewFactory(func(name string) (Pair, error) {
+		created++
+		conn := created
+		return Pair{Conn: conn, Closer: func() { closed++ }}, nil
+	}, WithValidator(func(name string, conn interface{}) error {
+		if !healthy {
+			return errors.New("connection lost")
+		}
+		return nil
+	}, 0))
+
+	first, _ := f.Make("foo")
+	second, _ := f.Make("foo")
+	assert.Equal(t, first, second)
+
+	healthy = false
+	third, _ := f.Make("foo")
+	assert.NotEqual(t, first, third)
+	assert.Equal(t, 1, closed)
+	assert.Equal(t, 0, f.Stats()["foo"].Reused)
+}
+
+func TestFactory_WithValidator_interval(t *testing.T) {
+	t.Parallel()
+	var validated int
+	f := NewFactory(func(name string) (Pair, error) {
+		return Pair{Conn: name}, nil
+	}, WithValidator(func(name string, conn interface{}) error {
+		validated++
+		return nil
+	}, time.Hour))
+
+	for i := 0; i < 3; i++ {
+		_, _ = f.Make("foo")
+	}
+	assert.Equal(t, 0, validated)
+
+	f.validated["foo"] = time.Now().Add(-2 * time.Hour)
+	_, _ = f.Make("foo")
+	assert.Equal(t, 1, validated)
+}
+
+func TestValidation_Option(t *testing.T) {
+	t.Parallel()
+	var deadline time.Time
+	f := NewFactory(func(name string) (Pair, error) {
+		return Pair{Conn: name}, nil
+	}, (&Validation{Timeout: time.Minute}).Option(func(ctx context.Context, conn interface{}) error {
+		deadline, _ = ctx.Deadline()
+		return nil
+	}))
+	_, _ = f.Make("foo")
+	_, _ = f.Make("foo")
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	var none *Validation
+	f = NewFactory(func(name string) (Pair, error) {
+		return Pair{Conn: name}, nil
+	}, none.Option(nil))
+	_, _ = f.Make("foo")
+	_, _ = f.Make("foo")
+	assert.Nil(t, f.validate)
+}
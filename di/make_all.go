@@ -0,0 +1,50 @@
+package di
+
+import "sort"
+
+// WithNames tells the factory the names of all configured connections, so
+// that they can be created at once with MakeAll.
+func WithNames(names ...string) FactoryOption {
+	return func(factory *Factory) {
+		factory.names = names
+	}
+}
+
+// Names returns the names of all configured connections, sorted. See
+// WithNames.
+func (f *Factory) Names() []string {
+	names := append([]string(nil), f.names...)
+	sort.Strings(names)
+	return names
+}
+
+// MakeAll creates the connections under all configured names concurrently,
+// with at most parallelism connections being created at the same time. By
+// default parallelism is 4. It is useful for pre-warming and health checks.
+//
+// Like Warm, MakeAll doesn't stop at the first error. The connections created
+// are returned keyed by name. If any of the connections failed, a WarmupErrors
+// is returned along with them.
+func (f *Factory) MakeAll(parallelism int) (map[string]interface{}, error) {
+	names := f.Names()
+	errs := make(WarmupErrors)
+	if err := f.Warm(names, parallelism); err != nil {
+		errs = err.(WarmupErrors)
+	}
+	conns := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		if _, ok := errs[name]; ok {
+			continue
+		}
+		conn, err := f.Make(name)
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+		conns[name] = conn
+	}
+	if len(errs) > 0 {
+		return conns, errs
+	}
+	return conns, nil
+}
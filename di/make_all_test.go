@@ -0,0 +1,33 @@
+package di
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFactory_MakeAll(t *testing.T) {
+	t.Parallel()
+	f := NewFactory(func(name string) (Pair, error) {
+		if name == "bad" {
+			return Pair{}, errors.New("boom")
+		}
+		return Pair{Conn: name, Closer: func() {}}, nil
+	}, WithNames("b", "bad", "a"))
+
+	assert.Equal(t, []string{"a", "b", "bad"}, f.Names())
+
+	conns, err := f.MakeAll(0)
+	var warmupErrors WarmupErrors
+	assert.True(t, errors.As(err, &warmupErrors))
+	assert.Len(t, warmupErrors, 1)
+	assert.EqualError(t, warmupErrors["bad"], "boom")
+	assert.Equal(t, map[string]interface{}{"a": "a", "b": "b"}, conns)
+
+	conns, err = NewFactory(func(name string) (Pair, error) {
+		return Pair{Conn: name}, nil
+	}).MakeAll(1)
+	assert.NoError(t, err)
+	assert.Empty(t, conns)
+}
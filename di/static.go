@@ -0,0 +1,27 @@
+package di
+
+import "fmt"
+
+// NewStaticFactory creates a factory that makes the given connections by name,
+// instead of constructing them. It is a test double for the factories of the
+// providers, so that unit tests can inject fakes without real backends:
+//
+//  factory := otmongo.Factory{Factory: di.NewStaticFactory(map[string]interface{}{
+//  	"default": fakeClient,
+//  })}
+//
+// Make returns an error for any other name. The connections are not closed by
+// the factory.
+func NewStaticFactory(conns map[string]interface{}) *Factory {
+	names := make([]string, 0, len(conns))
+	for name := range conns {
+		names = append(names, name)
+	}
+	return NewFactory(func(name string) (Pair, error) {
+		conn, ok := conns[name]
+		if !ok {
+			return Pair{}, fmt.Errorf("connection %s not found", name)
+		}
+		return Pair{Conn: conn}, nil
+	}, WithNames(names...))
+}
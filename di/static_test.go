@@ -0,0 +1,23 @@
+package di
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewStaticFactory(t *testing.T) {
+	t.Parallel()
+	fake := &struct{ name string }{"fake"}
+	f := NewStaticFactory(map[string]interface{}{"default": fake})
+
+	conn, err := f.Make("default")
+	assert.NoError(t, err)
+	assert.Same(t, fake, conn)
+
+	_, err = f.Make("other")
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{"default"}, f.Names())
+	f.Close()
+}
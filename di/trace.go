@@ -0,0 +1,39 @@
+package di
+
+import (
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// FactoryOption configures the Factory.
+type FactoryOption func(*Factory)
+
+// WithTracer makes the factory report the creation of each connection as a
+// span, so that slow connection establishment, typically on cold start, is
+// visible in traces. The component, for example "gorm", is tagged on the
+// span along with the name of the connection. Connections returned from the
+// cache are not traced. If the tracer is nil, the option is a no-op.
+func WithTracer(tracer opentracing.Tracer, component string) FactoryOption {
+	return func(factory *Factory) {
+		factory.tracer = tracer
+		factory.component = component
+	}
+}
+
+// construct calls the constructor, within a span if a tracer is set.
+func (f *Factory) construct(name string) (Pair, error) {
+	if f.tracer == nil {
+		return f.constructor(name)
+	}
+	span := f.tracer.StartSpan("di.Factory.Make")
+	defer span.Finish()
+	ext.Component.Set(span, f.component)
+	span.SetTag("name", name)
+
+	pair, err := f.constructor(name)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("error", err.Error())
+	}
+	return pair, err
+}
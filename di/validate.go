@@ -0,0 +1,83 @@
+package di
+
+import (
+	"context"
+	"time"
+)
+
+// WithValidator makes Make validate a cached connection before returning it,
+// for example by pinging the server. If the validation fails, the connection
+// is closed and a new one is created in its place, so that connections that
+// went bad silently, such as after a topology change, heal by themselves.
+// The connection is closed even if it has been handed out before, so holders
+// of the connection should call Make again rather than keep it.
+//
+// The validation is performed at most once per interval for each connection.
+// A zero interval validates on every call to Make. As Make holds the lock of
+// the factory during the validation, validate should be cheap and bounded in
+// time.
+func WithValidator(validate func(name string, conn interface{}) error, interval time.Duration) FactoryOption {
+	return func(factory *Factory) {
+		factory.validate = validate
+		factory.validateInterval = interval
+	}
+}
+
+// Validation instructs providers to validate the cached connections by
+// pinging the server, as set up by WithValidator. It is an optional
+// dependency of the database providers, such as otgorm, otmongo and otredis.
+//
+//  c.Provide(func() *di.Validation { return &di.Validation{Interval: time.Minute} })
+//
+// The connections that fail the validation are closed, including those
+// already handed out, such as the default connections injected by the
+// providers. With the validation enabled, get the connections from the
+// factories when they are needed instead of holding them.
+type Validation struct {
+	// Interval is the minimum time between two validations of a connection.
+	// By default, the connection is validated on every call to Make.
+	Interval time.Duration
+	// Timeout bounds each validation. By default it is one second.
+	Timeout time.Duration
+}
+
+// Option returns the FactoryOption that validates the connections with
+// ping. On a nil Validation it returns an option that leaves the factory as
+// is, so that providers can pass their optional dependency along.
+func (v *Validation) Option(ping func(ctx context.Context, conn interface{}) error) FactoryOption {
+	if v == nil {
+		return func(factory *Factory) {}
+	}
+	timeout := v.Timeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	return WithValidator(func(name string, conn interface{}) error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return ping(ctx, conn)
+	}, v.Interval)
+}
+
+// valid reports whether the cached connection under name passes the
+// validation. It must be called with the lock held.
+func (f *Factory) valid(name string, pair Pair) bool {
+	if f.validate == nil {
+		return true
+	}
+	now := time.Now()
+	if now.Sub(f.validated[name]) < f.validateInterval {
+		return true
+	}
+	if err := f.validate(name, pair.Conn); err != nil {
+		if pair.Closer != nil {
+			pair.Closer()
+		}
+		delete(f.cache, name)
+		delete(f.stats, name)
+		delete(f.validated, name)
+		return false
+	}
+	f.validated[name] = now
+	return true
+}
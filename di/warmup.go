@@ -0,0 +1,110 @@
+package di
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Warmup instructs providers to create all configured connections at boot,
+// rather than lazily on first use. It is an optional dependency of the
+// database providers, such as otgorm, otmongo and otredis.
+//
+//  c.Provide(func() *di.Warmup { return &di.Warmup{Parallelism: 4} })
+type Warmup struct {
+	// Parallelism bounds the number of connections created at the same time.
+	// By default it is 4.
+	Parallelism int
+	// Fatal makes the provider fail if any connection cannot be created.
+	// Otherwise the failures are only logged. The Provide functions of
+	// otmongo and otredis can't fail, so they log the failures at the error
+	// level. Use their ProvideStrict instead to fail.
+	Fatal bool
+}
+
+// WarmupErrors collects the errors of connections that failed to warm up,
+// keyed by name.
+type WarmupErrors map[string]error
+
+// Error implements error.
+func (w WarmupErrors) Error() string {
+	names := make([]string, 0, len(w))
+	for name := range w {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, 0, len(w))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", name, w[name]))
+	}
+	return "failed to warm up connections: " + strings.Join(msgs, "; ")
+}
+
+// Warm creates the connections under the given names concurrently, with at
+// most parallelism connections being created at the same time. Connections
+// already in the factory are skipped. Unlike Make, Warm doesn't stop at the
+// first error. A WarmupErrors is returned if any of the connections failed.
+func (f *Factory) Warm(names []string, parallelism int) error {
+	if parallelism <= 0 {
+		parallelism = 4
+	}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = make(WarmupErrors)
+		sem  = make(chan struct{}, parallelism)
+	)
+	for _, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(name string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := f.warm(name); err != nil {
+				mu.Lock()
+				errs[name] = err
+				mu.Unlock()
+			}
+		}(name)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// warm is like Make, but the lock is not held while the connection is being
+// constructed, so that multiple connections can be created in parallel.
+func (f *Factory) warm(name string) error {
+	f.mutex.Lock()
+	if slot, ok := f.cache[name]; ok && slot.Conn != nil {
+		f.mutex.Unlock()
+		return nil
+	}
+	f.mutex.Unlock()
+
+	pair, err := f.construct(name)
+	if err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if slot, ok := f.cache[name]; ok && slot.Conn != nil {
+		// Someone else has created the connection in the meantime.
+		if pair.Closer != nil {
+			pair.Closer()
+		}
+		return nil
+	}
+	f.cache[name] = pair
+	now := time.Now()
+	f.stats[name] = Stat{CreatedAt: now, LastAccessedAt: now}
+	f.validated[name] = now
+	return nil
+}
@@ -0,0 +1,59 @@
+package di
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFactory_Warm(t *testing.T) {
+	t.Parallel()
+	var current, peak int32
+	f := NewFactory(func(name string) (Pair, error) {
+		n := atomic.AddInt32(&current, 1)
+		defer atomic.AddInt32(&current, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if name == "bad" {
+			return Pair{}, errors.New("boom")
+		}
+		return Pair{Conn: name, Closer: func() {}}, nil
+	})
+
+	err := f.Warm([]string{"a", "b", "c", "d", "bad"}, 2)
+	assert.Error(t, err)
+	var warmupErrors WarmupErrors
+	assert.True(t, errors.As(err, &warmupErrors))
+	assert.Len(t, warmupErrors, 1)
+	assert.EqualError(t, warmupErrors["bad"], "boom")
+	assert.LessOrEqual(t, atomic.LoadInt32(&peak), int32(2))
+	assert.Len(t, f.List(), 4)
+
+	assert.NoError(t, f.Warm([]string{"a", "b"}, 0))
+	conn, err := f.Make("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", conn)
+}
+
+func TestFactory_Warm_validated(t *testing.T) {
+	t.Parallel()
+	var validated int
+	f := NewFactory(func(name string) (Pair, error) {
+		return Pair{Conn: name}, nil
+	}, WithValidator(func(name string, conn interface{}) error {
+		validated++
+		return nil
+	}, time.Hour))
+
+	assert.NoError(t, f.Warm([]string{"a"}, 1))
+	_, _ = f.Make("a")
+	assert.Equal(t, 0, validated)
+}
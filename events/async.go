@@ -0,0 +1,159 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+)
+
+// ErrDispatcherClosed is returned by AsyncDispatcher.Dispatch after the dispatcher is shut down.
+var ErrDispatcherClosed = errors.New("dispatcher is closed")
+
+// AsyncDispatcher is a contract.Dispatcher implementation that dispatches events asynchronously. Dispatch hands the
+// event to a bounded pool of worker goroutines and returns at once, unless the queue of pending events is full.
+// The listeners of one event are still called sequentially, stopping at the first error, like SyncDispatcher.
+//
+// The listeners receive the values of the dispatching context, but not its deadline and cancellation, as they run
+// after Dispatch returns. Their errors are reported to the error handler. Call Shutdown to wait for the pending
+// events before exiting. AsyncDispatcher is safe for concurrent use.
+type AsyncDispatcher struct {
+	listeners    SyncDispatcher
+	jobs         chan asyncJob
+	poolSize     int
+	queueSize    int
+	errorHandler func(ctx context.Context, event contract.Event, err error)
+	rwLock       sync.RWMutex
+	closed       bool
+	wg           sync.WaitGroup
+}
+
+type asyncJob struct {
+	ctx   context.Context
+	event contract.Event
+}
+
+// AsyncOption is an option that configures the AsyncDispatcher.
+type AsyncOption func(*AsyncDispatcher)
+
+// WithPoolSize sets the number of worker goroutines, runtime.NumCPU by default. Non-positive values are ignored.
+func WithPoolSize(size int) AsyncOption {
+	return func(d *AsyncDispatcher) {
+		if size > 0 {
+			d.poolSize = size
+		}
+	}
+}
+
+// WithQueueSize sets how many events can wait for a worker before Dispatch blocks. It defaults to the pool size.
+// Negative values are ignored.
+func WithQueueSize(size int) AsyncOption {
+	return func(d *AsyncDispatcher) {
+		if size >= 0 {
+			d.queueSize = size
+		}
+	}
+}
+
+// WithErrorHandler sets the function called with the errors returned by the listeners. By default, the errors are
+// dropped.
+func WithErrorHandler(handler func(ctx context.Context, event contract.Event, err error)) AsyncOption {
+	return func(d *AsyncDispatcher) {
+		d.errorHandler = handler
+	}
+}
+
+// NewAsyncDispatcher creates an AsyncDispatcher and starts its workers.
+func NewAsyncDispatcher(opts ...AsyncOption) *AsyncDispatcher {
+	d := &AsyncDispatcher{poolSize: runtime.NumCPU(), queueSize: -1}
+	for _, f := range opts {
+		f(d)
+	}
+	if d.queueSize < 0 {
+		d.queueSize = d.poolSize
+	}
+	d.jobs = make(chan asyncJob, d.queueSize)
+	d.wg.Add(d.poolSize)
+	for i := 0; i < d.poolSize; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// Dispatch queues the event for the workers. It blocks while the queue is full, and returns the error of the context
+// if it is done meanwhile. After Shutdown, ErrDispatcherClosed is returned.
+func (d *AsyncDispatcher) Dispatch(ctx context.Context, event contract.Event) error {
+	d.rwLock.RLock()
+	defer d.rwLock.RUnlock()
+
+	if d.closed {
+		return ErrDispatcherClosed
+	}
+	select {
+	case d.jobs <- asyncJob{ctx: detachedContext{ctx}, event: event}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Subscribe subscribes the listener to the dispatcher.
+func (d *AsyncDispatcher) Subscribe(listener contract.Listener) {
+	d.listeners.Subscribe(listener)
+}
+
+// SubscribedTypes returns the sorted event types that have at least one listener.
+func (d *AsyncDispatcher) SubscribedTypes() []string {
+	return d.listeners.SubscribedTypes()
+}
+
+// Shutdown stops accepting events, and waits until the pending ones are processed or the context is done.
+func (d *AsyncDispatcher) Shutdown(ctx context.Context) error {
+	d.rwLock.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.jobs)
+	}
+	d.rwLock.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *AsyncDispatcher) work() {
+	defer d.wg.Done()
+	for job := range d.jobs {
+		if err := d.listeners.Dispatch(job.ctx, job.event); err != nil && d.errorHandler != nil {
+			d.errorHandler(job.ctx, job.event, err)
+		}
+	}
+}
+
+// detachedContext carries the values of its parent, but not its deadline and cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
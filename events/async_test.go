@@ -0,0 +1,79 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxKey struct{}
+
+func TestAsyncDispatcher(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		errs     []error
+		handled  int32
+		release  = make(chan struct{})
+		errFail  = errors.New("fail")
+		canceled = make(chan error, 10)
+	)
+	dispatcher := NewAsyncDispatcher(WithPoolSize(2), WithQueueSize(8), WithErrorHandler(func(ctx context.Context, event contract.Event, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}))
+	dispatcher.Subscribe(Listen(From(MockEvent{}), func(ctx context.Context, event contract.Event) error {
+		<-release
+		assert.Equal(t, "bar", ctx.Value(ctxKey{}))
+		canceled <- ctx.Err()
+		atomic.AddInt32(&handled, 1)
+		if event.Data().(MockEvent).value < 0 {
+			return errFail
+		}
+		return nil
+	}))
+	assert.Equal(t, []string{Of(MockEvent{}).Type()}, dispatcher.SubscribedTypes())
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "bar"))
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, dispatcher.Dispatch(ctx, Of(MockEvent{value: i - 1})))
+	}
+	cancel()
+	assert.Equal(t, int32(0), atomic.LoadInt32(&handled), "dispatch must not wait for the listeners")
+	close(release)
+
+	assert.NoError(t, dispatcher.Shutdown(context.Background()))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&handled))
+	assert.Equal(t, []error{errFail}, errs)
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, <-canceled)
+	}
+	assert.True(t, errors.Is(dispatcher.Dispatch(context.Background(), Of(MockEvent{})), ErrDispatcherClosed))
+	assert.NoError(t, dispatcher.Shutdown(context.Background()))
+}
+
+func TestAsyncDispatcher_full(t *testing.T) {
+	release := make(chan struct{})
+	dispatcher := NewAsyncDispatcher(WithPoolSize(1), WithQueueSize(0))
+	dispatcher.Subscribe(Listen(From(MockEvent{}), func(ctx context.Context, event contract.Event) error {
+		<-release
+		return nil
+	}))
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), Of(MockEvent{})))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, dispatcher.Dispatch(ctx, Of(MockEvent{})))
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelShutdown()
+	assert.Equal(t, context.DeadlineExceeded, dispatcher.Shutdown(shutdownCtx))
+	close(release)
+	assert.NoError(t, dispatcher.Shutdown(context.Background()))
+}
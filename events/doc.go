@@ -9,6 +9,48 @@ be easily made synchronous.
 The event listeners can also be used as hooks. If the event data is a pointer type,
 listeners may alter the data. This enables plugin/addon style decoupling.
 
+When a slow listener must not block the caller, use an AsyncDispatcher. It hands
+the events to a bounded pool of workers, reports the listener errors to a
+callback, and waits for the pending events on Shutdown.
+
+	dispatcher := events.NewAsyncDispatcher(events.WithPoolSize(4), events.WithErrorHandler(
+		func(ctx context.Context, event contract.Event, err error) {
+			level.Warn(logger).Log("event", event.Type(), "err", err)
+		},
+	))
+	defer dispatcher.Shutdown(ctx)
+
+It can be wrapped by package queue too, but then a persisted event is
+acknowledged once it is handed to the workers, and listener errors no longer
+trigger retries.
+
+Besides the payload, an event can carry Metadata, such as its ID, time, source
+and correlation ID. Create it with New instead of Of. Listeners subscribed to
+the payload type receive it as usual, and read the metadata with MetadataOf.
+The metadata survives persisted dispatch in package queue.
+
+	dispatcher.Dispatch(ctx, events.New(UserCreated{}, events.WithCorrelationID(requestID)))
+
+To exchange events with services written in other languages, Envelope encodes
+them as CloudEvents-like JSON, with configurable field names, and decodes them
+back into events carrying the metadata.
+
+	var envelope events.Envelope
+	envelope.Register("user.created", UserCreated{})
+	data, err := envelope.Encode(event)
+	event, err := envelope.Decode(data)
+
+To catch wiring bugs early, such as a listener subscribed to a misspelled type,
+declare the dispatched types in a Registry and validate them against the
+dispatcher in development or CI. Validate reports the declared types without
+listeners and the subscribed types that are never declared.
+
+	var registry events.Registry
+	registry.Declare(events.Of(UserCreated{}))
+	if err := registry.Validate(dispatcher); err != nil && !env.IsProduction() {
+		level.Warn(logger).Log("err", err)
+	}
+
 Note: Package event focus on events within the system, not events outsource to
 eternal system. For that, use a message queue like kafka.
 */
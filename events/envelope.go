@@ -0,0 +1,185 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+)
+
+// EnvelopeFields are the field names of the JSON envelope. Empty names fall
+// back to DefaultEnvelopeFields.
+type EnvelopeFields struct {
+	ID            string
+	Source        string
+	Type          string
+	Time          string
+	Data          string
+	SpecVersion   string
+	CorrelationID string
+}
+
+// DefaultEnvelopeFields are the field names defined by CloudEvents.
+var DefaultEnvelopeFields = EnvelopeFields{
+	ID:            "id",
+	Source:        "source",
+	Type:          "type",
+	Time:          "time",
+	Data:          "data",
+	SpecVersion:   "specversion",
+	CorrelationID: "correlationid",
+}
+
+// Envelope converts events to and from a CloudEvents-like JSON envelope, so
+// that they can be exchanged with services written in other languages:
+//
+//  {"specversion":"1.0","id":"...","source":"user-service","type":"user.created","time":"...","data":{...}}
+//
+// The payload is serialized as JSON under data. The metadata of the event, if
+// any, fills the other fields. Events without metadata get a random ID and the
+// current time. The type of a payload is its registered name, or else the Go
+// type name returned by contract.Event.
+//
+// Only registered types can be decoded. Register all of them before using the
+// Envelope, as Register is not safe for concurrent use.
+type Envelope struct {
+	// Fields are the field names of the envelope. See DefaultEnvelopeFields.
+	Fields EnvelopeFields
+	// SpecVersion is written to the specversion field. By default it is "1.0".
+	SpecVersion string
+
+	types map[string]reflect.Type
+	names map[reflect.Type]string
+}
+
+// Register maps the type name in the envelope to the Go type of payload. The
+// payload may be a pointer, in which case decoded payloads are pointers too.
+//
+//  envelope.Register("user.created", UserCreated{})
+func (e *Envelope) Register(name string, payload interface{}) {
+	if e.types == nil {
+		e.types = make(map[string]reflect.Type)
+		e.names = make(map[reflect.Type]string)
+	}
+	t := reflect.TypeOf(payload)
+	e.types[name] = t
+	e.names[t] = name
+}
+
+// Encode converts the event to the JSON envelope.
+func (e *Envelope) Encode(event contract.Event) ([]byte, error) {
+	data, err := json.Marshal(event.Data())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the payload of %s: %w", event.Type(), err)
+	}
+	metadata, ok := MetadataOf(event)
+	if !ok {
+		metadata = Metadata{ID: newID(), Time: time.Now()}
+	}
+	name, ok := e.names[reflect.TypeOf(event.Data())]
+	if !ok {
+		name = event.Type()
+	}
+
+	fields := e.fields()
+	out := map[string]interface{}{
+		fields.SpecVersion: e.specVersion(),
+		fields.ID:          metadata.ID,
+		fields.Type:        name,
+		fields.Data:        json.RawMessage(data),
+	}
+	if metadata.Source != "" {
+		out[fields.Source] = metadata.Source
+	}
+	if !metadata.Time.IsZero() {
+		out[fields.Time] = metadata.Time.Format(time.RFC3339Nano)
+	}
+	if metadata.CorrelationID != "" {
+		out[fields.CorrelationID] = metadata.CorrelationID
+	}
+	return json.Marshal(out)
+}
+
+// Decode converts the JSON envelope to an event carrying the metadata. It
+// returns an error if the type is not registered.
+func (e *Envelope) Decode(data []byte) (Message, error) {
+	var in map[string]json.RawMessage
+	if err := json.Unmarshal(data, &in); err != nil {
+		return Message{}, fmt.Errorf("failed to decode the envelope: %w", err)
+	}
+	fields := e.fields()
+
+	var (
+		name     string
+		metadata Metadata
+		t        string
+	)
+	for field, dst := range map[string]*string{
+		fields.Type:          &name,
+		fields.ID:            &metadata.ID,
+		fields.Source:        &metadata.Source,
+		fields.CorrelationID: &metadata.CorrelationID,
+		fields.Time:          &t,
+	} {
+		if raw, ok := in[field]; ok {
+			if err := json.Unmarshal(raw, dst); err != nil {
+				return Message{}, fmt.Errorf("failed to decode the %s field of the envelope: %w", field, err)
+			}
+		}
+	}
+	if t != "" {
+		var err error
+		if metadata.Time, err = time.Parse(time.RFC3339Nano, t); err != nil {
+			return Message{}, fmt.Errorf("failed to decode the %s field of the envelope: %w", fields.Time, err)
+		}
+	}
+
+	typ, ok := e.types[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown event type %q in the envelope", name)
+	}
+	ptr := typ.Kind() == reflect.Ptr
+	if ptr {
+		typ = typ.Elem()
+	}
+	payload := reflect.New(typ)
+	if raw, ok := in[fields.Data]; ok {
+		if err := json.Unmarshal(raw, payload.Interface()); err != nil {
+			return Message{}, fmt.Errorf("failed to decode the payload of %s: %w", name, err)
+		}
+	}
+	if !ptr {
+		payload = payload.Elem()
+	}
+	return New(payload.Interface(), WithMetadata(metadata)), nil
+}
+
+func (e *Envelope) fields() EnvelopeFields {
+	fields := e.Fields
+	for _, f := range []struct {
+		name *string
+		def  string
+	}{
+		{&fields.ID, DefaultEnvelopeFields.ID},
+		{&fields.Source, DefaultEnvelopeFields.Source},
+		{&fields.Type, DefaultEnvelopeFields.Type},
+		{&fields.Time, DefaultEnvelopeFields.Time},
+		{&fields.Data, DefaultEnvelopeFields.Data},
+		{&fields.SpecVersion, DefaultEnvelopeFields.SpecVersion},
+		{&fields.CorrelationID, DefaultEnvelopeFields.CorrelationID},
+	} {
+		if *f.name == "" {
+			*f.name = f.def
+		}
+	}
+	return fields
+}
+
+func (e *Envelope) specVersion() string {
+	if e.SpecVersion == "" {
+		return "1.0"
+	}
+	return e.SpecVersion
+}
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type userCreated struct {
+	Name string `json:"name"`
+}
+
+func TestEnvelope(t *testing.T) {
+	var envelope Envelope
+	envelope.Register("user.created", userCreated{})
+
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	data, err := envelope.Encode(New(userCreated{Name: "foo"}, WithID("1"), WithTime(at), WithSource("test"), WithCorrelationID("2")))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"specversion": "1.0",
+		"id": "1",
+		"source": "test",
+		"type": "user.created",
+		"time": "2021-01-01T00:00:00Z",
+		"correlationid": "2",
+		"data": {"name": "foo"}
+	}`, string(data))
+
+	event, err := envelope.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, Of(userCreated{}).Type(), event.Type())
+	assert.Equal(t, userCreated{Name: "foo"}, event.Data())
+	assert.Equal(t, Metadata{ID: "1", Time: at, Source: "test", CorrelationID: "2"}, event.Metadata())
+}
+
+func TestEnvelope_fields(t *testing.T) {
+	envelope := Envelope{Fields: EnvelopeFields{Type: "eventType", Data: "payload"}, SpecVersion: "2"}
+	envelope.Register("user.created", &userCreated{})
+
+	data, err := envelope.Encode(Of(&userCreated{Name: "foo"}))
+	assert.NoError(t, err)
+	var out map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &out))
+	assert.Equal(t, "user.created", out["eventType"])
+	assert.Equal(t, map[string]interface{}{"name": "foo"}, out["payload"])
+	assert.Equal(t, "2", out["specversion"])
+	assert.NotEmpty(t, out["id"])
+
+	event, err := envelope.Decode([]byte(`{"eventType":"user.created","payload":{"name":"bar"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &userCreated{Name: "bar"}, event.Data())
+}
+
+func TestEnvelope_Decode_invalid(t *testing.T) {
+	var envelope Envelope
+	envelope.Register("user.created", userCreated{})
+	cases := []struct {
+		name string
+		data string
+	}{
+		{"not json", `foo`},
+		{"unknown type", `{"type":"user.deleted","data":{}}`},
+		{"bad time", `{"type":"user.created","time":"yesterday"}`},
+		{"bad data", `{"type":"user.created","data":"foo"}`},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, err := envelope.Decode([]byte(c.data))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+)
+
+// Metadata describes the circumstances of an event, as opposed to its payload.
+type Metadata struct {
+	// ID uniquely identifies the event.
+	ID string
+	// Time is when the event occurred.
+	Time time.Time
+	// Source identifies the producer of the event, eg. the name of a service.
+	Source string
+	// CorrelationID groups the events that belong to the same request or
+	// business transaction.
+	CorrelationID string
+}
+
+// Message is an event carrying a payload and its Metadata. Its type is the
+// type of the payload, so it is delivered to the same listeners as Of(payload).
+type Message struct {
+	Event
+	metadata Metadata
+}
+
+// Metadata returns the metadata of the event.
+func (m Message) Metadata() Metadata {
+	return m.metadata
+}
+
+// MetadataOption is an option for New.
+type MetadataOption func(*Metadata)
+
+// New wraps the payload with metadata, making it a valid contract.Event. By
+// default, ID is random and Time is now.
+//
+//  dispatcher.Dispatch(ctx, events.New(UserCreated{ID: 1}, events.WithSource("user-service")))
+func New(payload interface{}, opts ...MetadataOption) Message {
+	metadata := Metadata{ID: newID(), Time: time.Now()}
+	for _, f := range opts {
+		f(&metadata)
+	}
+	return Message{Event: Of(payload), metadata: metadata}
+}
+
+// WithID is a MetadataOption that sets the ID.
+func WithID(id string) MetadataOption {
+	return func(metadata *Metadata) {
+		metadata.ID = id
+	}
+}
+
+// WithTime is a MetadataOption that sets the time of the event.
+func WithTime(t time.Time) MetadataOption {
+	return func(metadata *Metadata) {
+		metadata.Time = t
+	}
+}
+
+// WithSource is a MetadataOption that sets the source.
+func WithSource(source string) MetadataOption {
+	return func(metadata *Metadata) {
+		metadata.Source = source
+	}
+}
+
+// WithCorrelationID is a MetadataOption that sets the correlation ID.
+func WithCorrelationID(id string) MetadataOption {
+	return func(metadata *Metadata) {
+		metadata.CorrelationID = id
+	}
+}
+
+// WithMetadata is a MetadataOption that replaces the whole metadata, eg. to
+// restore the metadata of an event received from elsewhere.
+func WithMetadata(m Metadata) MetadataOption {
+	return func(metadata *Metadata) {
+		*metadata = m
+	}
+}
+
+// MetadataOf returns the metadata of the event, and false if the event carries
+// none. Listeners use it to inspect the metadata:
+//
+//  func (l listener) Process(ctx context.Context, event contract.Event) error {
+//    if metadata, ok := events.MetadataOf(event); ok {
+//      logger.Log("correlationId", metadata.CorrelationID)
+//    }
+//    // ...
+//  }
+func MetadataOf(event contract.Event) (Metadata, bool) {
+	carrier, ok := event.(interface{ Metadata() Metadata })
+	if !ok {
+		return Metadata{}, false
+	}
+	return carrier.Metadata(), true
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
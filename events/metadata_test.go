@@ -0,0 +1,39 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	event := New(TestE{}, WithID("1"), WithTime(at), WithSource("test"), WithCorrelationID("2"))
+	assert.Equal(t, Of(TestE{}).Type(), event.Type())
+	assert.Equal(t, TestE{}, event.Data())
+	assert.Equal(t, Metadata{ID: "1", Time: at, Source: "test", CorrelationID: "2"}, event.Metadata())
+
+	defaults := New(TestE{}).Metadata()
+	assert.Len(t, defaults.ID, 32)
+	assert.NotEqual(t, defaults.ID, New(TestE{}).Metadata().ID)
+	assert.False(t, defaults.Time.IsZero())
+}
+
+func TestMetadataOf(t *testing.T) {
+	var dispatcher SyncDispatcher
+	var received []Metadata
+	dispatcher.Subscribe(Listen(From(TestE{}), func(ctx context.Context, event contract.Event) error {
+		metadata, ok := MetadataOf(event)
+		if ok {
+			received = append(received, metadata)
+		}
+		return nil
+	}))
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), Of(TestE{})))
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), New(TestE{}, WithCorrelationID("foo"))))
+	assert.Len(t, received, 1)
+	assert.Equal(t, "foo", received[0].CorrelationID)
+}
@@ -0,0 +1,111 @@
+package events
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/DoNewsCode/core/contract"
+)
+
+// SubscribedTypes returns the sorted event types that have at least one listener.
+func (d *SyncDispatcher) SubscribedTypes() []string {
+	d.rwLock.RLock()
+	defer d.rwLock.RUnlock()
+
+	var types []string
+	for t := range d.registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Registry holds the declared event types, ie. the types that the application
+// dispatches. Comparing them with the subscriptions of a dispatcher catches
+// wiring bugs, such as a typo in the event type of a listener:
+//
+//	var registry events.Registry
+//	registry.Declare(events.Of(UserCreated{}), events.Of(OrderPaid{}))
+//	if err := registry.Validate(dispatcher); err != nil {
+//		// warn or fail
+//	}
+//
+// The validation is meant for development and CI, for example in a test that
+// builds the application, or at startup when the environment is not
+// production. Registry is safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	declared map[string]struct{}
+}
+
+// Declare declares the types of the events as dispatched.
+func (r *Registry) Declare(events ...contract.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.declared == nil {
+		r.declared = make(map[string]struct{})
+	}
+	for _, e := range events {
+		r.declared[e.Type()] = struct{}{}
+	}
+}
+
+// Validate compares the declared types with the subscriptions of the
+// dispatcher. It returns a *ValidationError if a declared type has no listener,
+// or if a listener is subscribed to an undeclared type. The dispatcher must
+// implement SubscribedTypes, as SyncDispatcher does.
+func (r *Registry) Validate(dispatcher contract.Dispatcher) error {
+	subscriber, ok := dispatcher.(interface{ SubscribedTypes() []string })
+	if !ok {
+		return fmt.Errorf("dispatcher %T doesn't report its subscribed types", dispatcher)
+	}
+	subscribed := make(map[string]struct{})
+	for _, t := range subscriber.SubscribedTypes() {
+		subscribed[t] = struct{}{}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err ValidationError
+	for t := range r.declared {
+		if _, ok := subscribed[t]; !ok {
+			err.Unhandled = append(err.Unhandled, t)
+		}
+	}
+	for t := range subscribed {
+		if _, ok := r.declared[t]; !ok {
+			err.Undeclared = append(err.Undeclared, t)
+		}
+	}
+	if len(err.Unhandled) == 0 && len(err.Undeclared) == 0 {
+		return nil
+	}
+	sort.Strings(err.Unhandled)
+	sort.Strings(err.Undeclared)
+	return &err
+}
+
+// ValidationError reports the mismatches between the declared event types and
+// the subscriptions.
+type ValidationError struct {
+	// Unhandled are the declared types without listeners.
+	Unhandled []string
+	// Undeclared are the types with listeners that are never declared.
+	Undeclared []string
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	var problems []string
+	if len(e.Unhandled) > 0 {
+		problems = append(problems, fmt.Sprintf("dispatched but unhandled: %s", strings.Join(e.Unhandled, ", ")))
+	}
+	if len(e.Undeclared) > 0 {
+		problems = append(problems, fmt.Sprintf("handled but never dispatched: %s", strings.Join(e.Undeclared, ", ")))
+	}
+	return "invalid event wiring: " + strings.Join(problems, "; ")
+}
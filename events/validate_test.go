@@ -0,0 +1,35 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/stretchr/testify/assert"
+)
+
+type orderPaid struct{}
+
+type orderShipped struct{}
+
+func TestRegistry_Validate(t *testing.T) {
+	var dispatcher SyncDispatcher
+	noop := func(ctx context.Context, event contract.Event) error { return nil }
+	dispatcher.Subscribe(Listen(From(userCreated{}, orderPaid{}), noop))
+
+	var registry Registry
+	registry.Declare(Of(userCreated{}), Of(orderPaid{}))
+	assert.NoError(t, registry.Validate(&dispatcher))
+
+	registry.Declare(Of(orderShipped{}))
+	dispatcher.Subscribe(Listen(From(MockEvent{}), noop))
+	err := registry.Validate(&dispatcher)
+
+	var validationErr *ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []string{Of(orderShipped{}).Type()}, validationErr.Unhandled)
+	assert.Equal(t, []string{Of(MockEvent{}).Type()}, validationErr.Undeclared)
+	assert.Contains(t, err.Error(), "dispatched but unhandled")
+	assert.Contains(t, err.Error(), "handled but never dispatched")
+}
@@ -0,0 +1,29 @@
+/*
+Package health aggregates the readiness of all dependencies into a single
+report.
+
+Introduction
+
+Modules such as otgorm, otmongo and queue provide a contract.HealthChecker for
+each configured connection or queue, under the dig group "health". The
+Aggregator runs them concurrently, each with its own timeout, and combines the
+outcomes into a Report.
+
+Integration
+
+Add the aggregator to core:
+
+	var c *core.C = core.New()
+	c.Provide(otgorm.Provide)
+	c.Provide(health.Provide)
+
+Then mount it as the readiness endpoint in the ProvideHttp method of a module:
+
+	func (m Module) ProvideHttp(router *mux.Router) {
+		router.Handle("/ready", m.aggregator)
+	}
+
+Custom checks can be added to the group by providing a di.Out struct with a
+field tagged `group:"health"`, or by appending to Aggregator.Checkers directly.
+*/
+package health
@@ -0,0 +1,133 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/di"
+)
+
+// Checker is a functional contract.HealthChecker.
+type Checker struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// NewChecker creates a contract.HealthChecker with the given name and check
+// function.
+func NewChecker(name string, check func(ctx context.Context) error) Checker {
+	return Checker{name: name, check: check}
+}
+
+// Name implements contract.HealthChecker.
+func (c Checker) Name() string {
+	return c.name
+}
+
+// HealthCheck implements contract.HealthChecker.
+func (c Checker) HealthCheck(ctx context.Context) error {
+	return c.check(ctx)
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Name     string        `json:"name"`
+	Healthy  bool          `json:"healthy"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Report is the outcome of all checks. Results are sorted by name.
+type Report struct {
+	Healthy bool     `json:"healthy"`
+	Results []Result `json:"results"`
+}
+
+// Aggregator runs a set of contract.HealthChecker concurrently and combines
+// their results into a Report. It is also a http.Handler, responding with the
+// JSON encoded Report and a status of 200 if healthy or 503 otherwise.
+type Aggregator struct {
+	// Checkers are the checks to run.
+	Checkers []contract.HealthChecker
+	// Timeout is the upper limit of each check. By default it is 5 seconds.
+	// A check that exceeds the timeout is reported as unhealthy.
+	Timeout time.Duration
+}
+
+// Check runs all checks concurrently and returns the Report.
+func (a *Aggregator) Check(ctx context.Context) Report {
+	timeout := a.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	var (
+		wg     sync.WaitGroup
+		report = Report{Healthy: true, Results: make([]Result, len(a.Checkers))}
+	)
+	for i, checker := range a.Checkers {
+		wg.Add(1)
+		go func(i int, checker contract.HealthChecker) {
+			defer wg.Done()
+			report.Results[i] = run(ctx, checker, timeout)
+		}(i, checker)
+	}
+	wg.Wait()
+	for _, result := range report.Results {
+		report.Healthy = report.Healthy && result.Healthy
+	}
+	sort.Slice(report.Results, func(i, j int) bool {
+		return report.Results[i].Name < report.Results[j].Name
+	})
+	return report
+}
+
+// ServeHTTP implements http.Handler.
+func (a *Aggregator) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	report := a.Check(request.Context())
+	writer.Header().Set("Content-Type", "application/json")
+	if !report.Healthy {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(writer).Encode(report)
+}
+
+func run(ctx context.Context, checker contract.HealthChecker, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- checker.HealthCheck(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errChan:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := Result{Name: checker.Name(), Healthy: err == nil, Duration: time.Since(start)}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// In is the injection parameter for Provide.
+type In struct {
+	di.In
+
+	Checkers []contract.HealthChecker `group:"health"`
+}
+
+// Provide creates an *Aggregator with all contract.HealthChecker registered
+// under the "health" group. It is a valid dependency for package core.
+func Provide(p In) *Aggregator {
+	return &Aggregator{Checkers: p.Checkers}
+}
@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregator_Check(t *testing.T) {
+	aggregator := &Aggregator{
+		Checkers: []contract.HealthChecker{
+			NewChecker("b", func(ctx context.Context) error { return nil }),
+			NewChecker("a", func(ctx context.Context) error { return errors.New("down") }),
+			NewChecker("c", func(ctx context.Context) error {
+				time.Sleep(time.Second)
+				return nil
+			}),
+		},
+		Timeout: 50 * time.Millisecond,
+	}
+	start := time.Now()
+	report := aggregator.Check(context.Background())
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.False(t, report.Healthy)
+	assert.Len(t, report.Results, 3)
+	assert.Equal(t, Result{Name: "a", Healthy: false, Error: "down", Duration: report.Results[0].Duration}, report.Results[0])
+	assert.True(t, report.Results[1].Healthy)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Results[2].Error)
+}
+
+func TestAggregator_ServeHTTP(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"healthy", nil, http.StatusOK},
+		{"unhealthy", errors.New("down"), http.StatusServiceUnavailable},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			aggregator := &Aggregator{Checkers: []contract.HealthChecker{
+				NewChecker("foo", func(ctx context.Context) error { return c.err }),
+			}}
+			recorder := httptest.NewRecorder()
+			aggregator.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			assert.Equal(t, c.status, recorder.Code)
+			var report Report
+			assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&report))
+			assert.Equal(t, c.err == nil, report.Healthy)
+			assert.Equal(t, "foo", report.Results[0].Name)
+		})
+	}
+}
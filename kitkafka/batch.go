@@ -0,0 +1,199 @@
+package kitkafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/DoNewsCode/core/backoff"
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+)
+
+// BatchHandleFunc is a functional BatchHandler.
+type BatchHandleFunc func(ctx context.Context, msgs []kafka.Message) (int, error)
+
+// HandleBatch deals with the batch of kafka.Message in some way.
+func (h BatchHandleFunc) HandleBatch(ctx context.Context, msgs []kafka.Message) (int, error) {
+	return h(ctx, msgs)
+}
+
+// BatchHandler handles kafka messages in batches. HandleBatch returns the
+// number of messages at the head of the batch that have been processed. If
+// all of them are processed, the returned error must be nil.
+type BatchHandler interface {
+	HandleBatch(ctx context.Context, msgs []kafka.Message) (handled int, err error)
+}
+
+// BatchErrorHandler is called with the rest of a batch that the handler still
+// fails to process once the retries run out, for example to park the messages
+// in a dead letter topic.
+type BatchErrorHandler func(ctx context.Context, msgs []kafka.Message, err error)
+
+// batchRetrier retries the failed batches a few times, before they are handed
+// to the BatchErrorHandler.
+var batchRetrier = backoff.Retrier{
+	Strategy:    backoff.Exponential{Base: time.Second, Max: 10 * time.Second, Jitter: 0.5, Min: time.Second},
+	MaxAttempts: 5,
+}
+
+// batchReader is the subset of *kafka.Reader used by BatchSubscriberServer.
+type batchReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// BatchSubscriberServer is a kafka server that consumes messages in batches.
+// It implements Server. After each batch, the offsets of the processed
+// messages are committed explicitly. If the handler fails in the middle of a
+// batch, only the offsets up to the last processed message are committed, and
+// the rest of the batch is handed to the handler again after a backoff, five
+// times at most by default. Messages are therefore delivered at least once,
+// and in order within a partition.
+//
+// Once the retries run out, the rest of the batch is passed to the
+// BatchErrorHandler, and committed, so that the consumption goes on. Without a
+// BatchErrorHandler, Serve returns the error instead, and the rest of the batch
+// is delivered again when the server restarts.
+type BatchSubscriberServer struct {
+	reader        batchReader
+	handler       BatchHandler
+	batchSize     int
+	batchInterval time.Duration
+	retrier       backoff.Retrier
+	errorHandler  BatchErrorHandler
+}
+
+// Serve starts the Server. It blocks until the context is canceled or kafka
+// returns an error.
+func (s *BatchSubscriberServer) Serve(ctx context.Context) error {
+	for {
+		batch, err := s.fetch(ctx)
+		if err != nil {
+			return err
+		}
+		if err := s.handle(ctx, batch); err != nil {
+			return err
+		}
+	}
+}
+
+// fetch blocks until a message is available, then collects more messages
+// until the batch is full or the batch interval elapses.
+func (s *BatchSubscriberServer) fetch(ctx context.Context) ([]kafka.Message, error) {
+	msg, err := s.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	batch := []kafka.Message{msg}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, s.batchInterval)
+	defer cancel()
+	for len(batch) < s.batchSize {
+		msg, err := s.reader.FetchMessage(fetchCtx)
+		if err != nil {
+			if fetchCtx.Err() != nil && ctx.Err() == nil {
+				break
+			}
+			return nil, err
+		}
+		batch = append(batch, msg)
+	}
+	return batch, nil
+}
+
+// handle calls the handler with the batch, and commits the processed messages,
+// until the whole batch is processed or the retries run out.
+func (s *BatchSubscriberServer) handle(ctx context.Context, batch []kafka.Message) error {
+	err := s.retrier.Do(ctx, func(ctx context.Context) error {
+		handled, err := s.handler.HandleBatch(ctx, batch)
+		if err == nil {
+			handled = len(batch)
+		}
+		if handled > len(batch) {
+			handled = len(batch)
+		}
+		if handled > 0 {
+			if err := s.commit(batch[:handled]); err != nil {
+				return err
+			}
+			batch = batch[handled:]
+		}
+		return err
+	})
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	if s.errorHandler == nil {
+		return errors.Wrap(err, "unable to handle batch")
+	}
+	s.errorHandler(ctx, batch, err)
+	return s.commit(batch)
+}
+
+// commit commits the offsets of the messages. The commit cannot be cancelled by
+// the context of Serve, so that the processed messages are not delivered again.
+func (s *BatchSubscriberServer) commit(msgs []kafka.Message) error {
+	err := commitRetrier.Do(context.Background(), func(ctx context.Context) error {
+		return s.reader.CommitMessages(ctx, msgs...)
+	})
+	return errors.Wrap(err, "unable to commit offsets")
+}
+
+// WithBatchSize configures the maximum number of messages in a batch. It only
+// affects BatchSubscriberServer. By default it is 100.
+func WithBatchSize(size int) ReaderOpt {
+	return func(config *subscriberConfig) {
+		config.batchSize = size
+	}
+}
+
+// WithBatchInterval configures how long BatchSubscriberServer waits for more
+// messages before handling an incomplete batch. By default it is one second.
+func WithBatchInterval(interval time.Duration) ReaderOpt {
+	return func(config *subscriberConfig) {
+		config.batchInterval = interval
+	}
+}
+
+// WithBatchRetrier configures how BatchSubscriberServer retries a failed batch.
+// By default, a batch is handled five times at most, with an exponential
+// backoff starting from one second.
+func WithBatchRetrier(retrier backoff.Retrier) ReaderOpt {
+	return func(config *subscriberConfig) {
+		config.batchRetrier = retrier
+	}
+}
+
+// WithBatchErrorHandler configures the BatchErrorHandler of
+// BatchSubscriberServer. See BatchSubscriberServer for the behavior without one.
+func WithBatchErrorHandler(handler BatchErrorHandler) ReaderOpt {
+	return func(config *subscriberConfig) {
+		config.batchErrorHandler = handler
+	}
+}
+
+// MakeBatchSubscriberServer creates a *BatchSubscriberServer.
+//     name: the key of the configuration entry.
+//     handler: the BatchHandler
+func (k ReaderFactory) MakeBatchSubscriberServer(name string, handler BatchHandler, opt ...ReaderOpt) (*BatchSubscriberServer, error) {
+	var config = subscriberConfig{
+		batchSize:     100,
+		batchInterval: time.Second,
+		batchRetrier:  batchRetrier,
+	}
+	for _, o := range opt {
+		o(&config)
+	}
+	reader, err := k.Make(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to make batch subscriber")
+	}
+	return &BatchSubscriberServer{
+		reader:        reader,
+		handler:       handler,
+		batchSize:     config.batchSize,
+		batchInterval: config.batchInterval,
+		retrier:       config.batchRetrier,
+		errorHandler:  config.batchErrorHandler,
+	}, nil
+}
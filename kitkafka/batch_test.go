@@ -0,0 +1,149 @@
+package kitkafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/backoff"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockReader struct {
+	mu        sync.Mutex
+	msgs      chan kafka.Message
+	committed []int64
+}
+
+func (m *mockReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case msg := <-m.msgs:
+		return msg, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (m *mockReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range msgs {
+		m.committed = append(m.committed, msg.Offset)
+	}
+	return nil
+}
+
+func (m *mockReader) Committed() []int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]int64(nil), m.committed...)
+}
+
+func TestBatchSubscriberServer(t *testing.T) {
+	reader := &mockReader{msgs: make(chan kafka.Message, 10)}
+	for i := 0; i < 5; i++ {
+		reader.msgs <- kafka.Message{Offset: int64(i)}
+	}
+
+	var (
+		batches [][]int64
+		failed  bool
+	)
+	handler := BatchHandleFunc(func(ctx context.Context, msgs []kafka.Message) (int, error) {
+		var offsets []int64
+		for _, msg := range msgs {
+			offsets = append(offsets, msg.Offset)
+		}
+		batches = append(batches, offsets)
+		if !failed {
+			failed = true
+			return 2, errors.New("failed at offset 2")
+		}
+		return len(msgs), nil
+	})
+
+	server := &BatchSubscriberServer{
+		reader:        reader,
+		handler:       handler,
+		batchSize:     4,
+		batchInterval: 10 * time.Millisecond,
+		retrier:       backoff.Retrier{Strategy: backoff.Constant(time.Millisecond)},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Serve(ctx)
+
+	assert.Eventually(t, func() bool {
+		return len(reader.Committed()) == 5
+	}, time.Second, time.Millisecond)
+	cancel()
+
+	// the processed head of the failed batch is committed, and only the rest is retried.
+	assert.Equal(t, []int64{0, 1, 2, 3, 4}, reader.Committed())
+	assert.Equal(t, [][]int64{{0, 1, 2, 3}, {2, 3}, {4}}, batches)
+}
+
+func TestBatchSubscriberServer_errorHandler(t *testing.T) {
+	reader := &mockReader{msgs: make(chan kafka.Message, 10)}
+	for i := 0; i < 3; i++ {
+		reader.msgs <- kafka.Message{Offset: int64(i)}
+	}
+	errFailed := errors.New("failed")
+	var (
+		attempts int
+		parked   []int64
+	)
+	server := &BatchSubscriberServer{
+		reader: reader,
+		handler: BatchHandleFunc(func(ctx context.Context, msgs []kafka.Message) (int, error) {
+			attempts++
+			if msgs[0].Offset == 0 {
+				return 1, errFailed
+			}
+			return 0, errFailed
+		}),
+		batchSize:     3,
+		batchInterval: 10 * time.Millisecond,
+		retrier:       backoff.Retrier{Strategy: backoff.Constant(time.Millisecond), MaxAttempts: 3},
+		errorHandler: func(ctx context.Context, msgs []kafka.Message, err error) {
+			assert.Equal(t, errFailed, err)
+			for _, msg := range msgs {
+				parked = append(parked, msg.Offset)
+			}
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Serve(ctx)
+
+	assert.Eventually(t, func() bool {
+		return len(reader.Committed()) == 3
+	}, time.Second, time.Millisecond)
+	cancel()
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []int64{1, 2}, parked)
+	assert.Equal(t, []int64{0, 1, 2}, reader.Committed())
+}
+
+func TestBatchSubscriberServer_retriesExhausted(t *testing.T) {
+	reader := &mockReader{msgs: make(chan kafka.Message, 1)}
+	reader.msgs <- kafka.Message{}
+	errFailed := errors.New("failed")
+	server := &BatchSubscriberServer{
+		reader: reader,
+		handler: BatchHandleFunc(func(ctx context.Context, msgs []kafka.Message) (int, error) {
+			return 0, errFailed
+		}),
+		batchSize:     1,
+		batchInterval: time.Millisecond,
+		retrier:       backoff.Retrier{Strategy: backoff.Constant(time.Millisecond), MaxAttempts: 2},
+	}
+	err := server.Serve(context.Background())
+	assert.True(t, errors.Is(err, errFailed), err)
+	assert.Empty(t, reader.Committed())
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/DoNewsCode/core/di"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/opentracing/opentracing-go"
 	"github.com/segmentio/kafka-go"
 )
 
@@ -24,8 +25,9 @@ type ReaderMaker interface {
 type KafkaIn struct {
 	di.In
 
-	ReaderInterceptor ReaderInterceptor `optional:"true"`
-	WriterInterceptor WriterInterceptor `optional:"true"`
+	ReaderInterceptor ReaderInterceptor  `optional:"true"`
+	WriterInterceptor WriterInterceptor  `optional:"true"`
+	Tracer            opentracing.Tracer `optional:"true"`
 	Conf              contract.ConfigAccessor
 	Logger            log.Logger
 }
@@ -67,6 +69,11 @@ func ProvideReaderFactory(p KafkaIn) (ReaderFactory, func()) {
 	if err != nil {
 		_ = level.Warn(p.Logger).Log("err", err)
 	}
+	logger := log.With(p.Logger, "tag", "kafka")
+	var names []string
+	for name := range dbConfs {
+		names = append(names, name)
+	}
 	factory := di.NewFactory(func(name string) (di.Pair, error) {
 		var (
 			ok           bool
@@ -77,10 +84,13 @@ func ProvideReaderFactory(p KafkaIn) (ReaderFactory, func()) {
 		}
 
 		// converts to the kafka.ReaderConfig from github.com/segmentio/kafka-go
-		conf := fromReaderConfig(readerConfig)
-		conf.Logger = KafkaLogAdapter{Logging: level.Debug(p.Logger)}
-		conf.ErrorLogger = KafkaLogAdapter{Logging: level.Warn(p.Logger)}
-		if p.WriterInterceptor != nil {
+		conf, err := fromReaderConfig(readerConfig)
+		if err != nil {
+			return di.Pair{}, fmt.Errorf("kafka reader configuration %s not valid: %w", name, err)
+		}
+		conf.Logger = KafkaLogAdapter{Logging: logger, Level: level.DebugValue()}
+		conf.ErrorLogger = KafkaErrorLogAdapter{Logging: logger}
+		if p.ReaderInterceptor != nil {
 			p.ReaderInterceptor(name, &conf)
 		}
 		client := kafka.NewReader(conf)
@@ -90,8 +100,8 @@ func ProvideReaderFactory(p KafkaIn) (ReaderFactory, func()) {
 				_ = client.Close()
 			},
 		}, nil
-	})
-	return ReaderFactory{factory}, factory.Close
+	}, di.WithNames(names...))
+	return ReaderFactory{Factory: factory, tracer: p.Tracer}, factory.Close
 }
 
 // ProvideWriterFactory creates WriterFactory. It is a valid injection
@@ -103,6 +113,11 @@ func ProvideWriterFactory(p KafkaIn) (WriterFactory, func()) {
 	if err != nil {
 		_ = level.Warn(p.Logger).Log("err", err)
 	}
+	logger := log.With(p.Logger, "tag", "kafka")
+	var names []string
+	for name := range dbConfs {
+		names = append(names, name)
+	}
 	factory := di.NewFactory(func(name string) (di.Pair, error) {
 		var (
 			ok           bool
@@ -112,8 +127,8 @@ func ProvideWriterFactory(p KafkaIn) (WriterFactory, func()) {
 			return di.Pair{}, fmt.Errorf("kafka writer configuration %s not valid", name)
 		}
 		writer := fromWriterConfig(writerConfig)
-		writer.Logger = KafkaLogAdapter{Logging: level.Debug(p.Logger)}
-		writer.ErrorLogger = KafkaLogAdapter{Logging: level.Warn(p.Logger)}
+		writer.Logger = KafkaLogAdapter{Logging: logger, Level: level.DebugValue()}
+		writer.ErrorLogger = KafkaErrorLogAdapter{Logging: logger}
 		if p.WriterInterceptor != nil {
 			p.WriterInterceptor(name, &writer)
 		}
@@ -124,6 +139,6 @@ func ProvideWriterFactory(p KafkaIn) (WriterFactory, func()) {
 				_ = writer.Close()
 			},
 		}, nil
-	})
-	return WriterFactory{factory}, factory.Close
+	}, di.WithNames(names...))
+	return WriterFactory{Factory: factory, tracer: p.Tracer}, factory.Close
 }
@@ -33,6 +33,12 @@ kitkafka exports the configuration in this format:
 			- localhost:9092
 		  topic: bar
 		  groupID: bar-group
+		  groupBalancers:
+			- roundRobin
+
+The groupBalancers entry selects the partition assignment strategies of the
+consumer group. Alternatively, leave groupID empty and set partition to
+statically consume a single partition.
 
 For a complete overview of all available options, call the config init command.
 
@@ -43,6 +49,73 @@ To use package kitkafka with package core, add:
 
 The reader and writer factories are bundled into that single provider.
 
+Logging
+
+The readers and writers made by the factories log through the core logger. The
+routine messages of kafka-go, such as rebalances, are logged at the debug level,
+and its errors at the error level. To wire the loggers of a reader or writer
+yourself, use KafkaLogAdapter with the level of your choice, info by default,
+and KafkaErrorLogAdapter.
+
+	conf.Logger = kitkafka.KafkaLogAdapter{Logging: logger, Level: level.DebugValue()}
+	conf.ErrorLogger = kitkafka.KafkaErrorLogAdapter{Logging: logger}
+
+Partition Keys
+
+Messages are ordered within a partition only. To keep the events of an entity
+in order, key them by the entity when publishing. A KeyExtractor derives the
+key from each published event, so that callers don't have to set it manually.
+
+	publisher := kitkafka.NewPublisher(client, encode, kitkafka.PublisherKey(
+		func(event contract.Event) []byte {
+			if order, ok := event.Data().(OrderPlaced); ok {
+				return []byte(order.ID)
+			}
+			return nil
+		},
+	))
+
+Events for which the extractor returns nil are published without a key.
+
+Batch Consumption
+
+To handle messages in batches with precise at-least-once control, make a
+BatchSubscriberServer. The handler reports how many messages at the head of the
+batch it has processed. Their offsets are committed explicitly, and on a
+partial failure only the rest of the batch is handled again.
+
+	server, err := readerFactory.MakeBatchSubscriberServer("bar", kitkafka.BatchHandleFunc(
+		func(ctx context.Context, msgs []kafka.Message) (int, error) {
+			for i, msg := range msgs {
+				if err := save(ctx, msg); err != nil {
+					return i, err
+				}
+			}
+			return len(msgs), nil
+		},
+	), kitkafka.WithBatchSize(500))
+
+A failed batch is retried five times at most by default, see WithBatchRetrier.
+Then the rest of the batch is passed to the handler set by
+WithBatchErrorHandler and skipped, or, without a handler, Serve returns the
+error.
+
+Tracing
+
+When an opentracing.Tracer is available in the container, the clients made by
+WriterFactory.MakeClient write each message in a kafka.produce span, and
+inject the span context into the message headers. The subscriber servers made
+by ReaderFactory.MakeSubscriberServer extract it, and handle each message in a
+kafka.consume span, tagged with the topic, partition and offset. The span is
+available in the context of the endpoint.
+
+To trace handlers built otherwise, wrap them with TraceProducer and
+TraceConsumer:
+
+	handler := kitkafka.TraceConsumer(tracer, kitkafka.NewSubscriber(endpoint, decode))
+
+Batch subscriber servers are not traced, as a batch mixes many traces.
+
 Standalone Usage
 
 In some scenarios, the whole go kit family might be overkill. To directly
@@ -1,9 +1,14 @@
 package kitkafka
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/DoNewsCode/core/backoff"
 	"github.com/DoNewsCode/core/config"
 	"github.com/DoNewsCode/core/di"
 	"github.com/go-kit/kit/endpoint"
+	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"github.com/segmentio/kafka-go"
 )
@@ -15,6 +20,7 @@ import (
 // kafka config rather than an opaque name such as default.
 type ReaderFactory struct {
 	*di.Factory
+	tracer opentracing.Tracer
 }
 
 // Make returns a *kafka.Reader under the provided configuration entry.
@@ -33,6 +39,7 @@ func (k ReaderFactory) Make(name string) (*kafka.Reader, error) {
 // kafka config rather than an opaque name such as default.
 type WriterFactory struct {
 	*di.Factory
+	tracer opentracing.Tracer
 }
 
 // Make returns a *kafka.Writer under the provided configuration entry.
@@ -45,7 +52,8 @@ func (k WriterFactory) Make(name string) (*kafka.Writer, error) {
 }
 
 // MakeClient creates an Handler. This handler can write *kafka.Message to
-// kafka broker. The Handler is mean to be consumed by NewPublisher.
+// kafka broker. The Handler is mean to be consumed by NewPublisher. If a
+// tracer is injected, the messages are written in kafka.produce spans.
 func (k WriterFactory) MakeClient(name string) (*writerHandle, error) {
 	writer, err := k.Make(name)
 	if err != nil {
@@ -53,12 +61,17 @@ func (k WriterFactory) MakeClient(name string) (*writerHandle, error) {
 	}
 	return &writerHandle{
 		Writer: writer,
+		tracer: k.tracer,
 	}, nil
 }
 
 type subscriberConfig struct {
-	parallelism int
-	syncCommit  bool
+	parallelism       int
+	syncCommit        bool
+	batchSize         int
+	batchInterval     time.Duration
+	batchRetrier      backoff.Retrier
+	batchErrorHandler BatchErrorHandler
 }
 
 // ReaderOpt are options that configures the kafka reader.
@@ -79,7 +92,8 @@ func WithSyncCommit() ReaderOpt {
 	}
 }
 
-// MakeSubscriberServer creates a *SubscriberServer.
+// MakeSubscriberServer creates a *SubscriberServer. If a tracer is injected,
+// the messages are handled in kafka.consume spans.
 //     name: the key of the configuration entry.
 //     subscriber: the Handler (go kit transport layer)
 func (k ReaderFactory) MakeSubscriberServer(name string, subscriber Handler, opt ...ReaderOpt) (*SubscriberServer, error) {
@@ -93,6 +107,9 @@ func (k ReaderFactory) MakeSubscriberServer(name string, subscriber Handler, opt
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to make subscriber")
 	}
+	if k.tracer != nil {
+		subscriber = TraceConsumer(k.tracer, subscriber)
+	}
 	return &SubscriberServer{
 		reader:      reader,
 		handler:     subscriber,
@@ -146,12 +163,18 @@ func fromWriterConfig(config WriterConfig) kafka.Writer {
 	}
 }
 
-func fromReaderConfig(config ReaderConfig) kafka.ReaderConfig {
+func fromReaderConfig(config ReaderConfig) (kafka.ReaderConfig, error) {
+	balancers, err := groupBalancers(config.GroupBalancers, config.Rack)
+	if err != nil {
+		return kafka.ReaderConfig{}, err
+	}
 	return kafka.ReaderConfig{
 		Brokers:                config.Brokers,
 		GroupID:                config.GroupID,
 		Topic:                  config.Topic,
-		Partition:              config.MaxAttempts,
+		Partition:              config.Partition,
+		QueueCapacity:          config.QueueCapacity,
+		GroupBalancers:         balancers,
 		MinBytes:               config.MinBytes,
 		MaxBytes:               config.MaxBytes,
 		MaxWait:                config.MaxWait,
@@ -168,5 +191,22 @@ func fromReaderConfig(config ReaderConfig) kafka.ReaderConfig {
 		ReadBackoffMin:         config.ReadBackoffMin,
 		ReadBackoffMax:         config.ReadBackoffMax,
 		MaxAttempts:            config.MaxAttempts,
+	}, nil
+}
+
+func groupBalancers(names []string, rack string) ([]kafka.GroupBalancer, error) {
+	var balancers []kafka.GroupBalancer
+	for _, name := range names {
+		switch name {
+		case "range":
+			balancers = append(balancers, kafka.RangeGroupBalancer{})
+		case "roundRobin":
+			balancers = append(balancers, kafka.RoundRobinGroupBalancer{})
+		case "rackAffinity":
+			balancers = append(balancers, kafka.RackAffinityGroupBalancer{Rack: rack})
+		default:
+			return nil, fmt.Errorf("unsupported group balancer %s, must be one of range, roundRobin or rackAffinity", name)
+		}
 	}
+	return balancers, nil
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/DoNewsCode/core/config"
 	"github.com/DoNewsCode/core/di"
 	"github.com/go-kit/kit/log"
+	"github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -81,3 +82,24 @@ func TestProvideKafka(t *testing.T) {
 	cleanupReader()
 	cleanupWriter()
 }
+
+func TestFromReaderConfig(t *testing.T) {
+	conf, err := fromReaderConfig(ReaderConfig{
+		Partition:      2,
+		GroupBalancers: []string{"rackAffinity", "roundRobin"},
+		Rack:           "us-east-1a",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, conf.Partition)
+	assert.Equal(t, []kafka.GroupBalancer{
+		kafka.RackAffinityGroupBalancer{Rack: "us-east-1a"},
+		kafka.RoundRobinGroupBalancer{},
+	}, conf.GroupBalancers)
+
+	conf, err = fromReaderConfig(ReaderConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, conf.GroupBalancers)
+
+	_, err = fromReaderConfig(ReaderConfig{GroupBalancers: []string{"sticky"}})
+	assert.Error(t, err)
+}
@@ -4,14 +4,35 @@ import (
 	"fmt"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 )
 
-// KafkaLogAdapter is an log adapter bridging kitlog and kafka.
+// KafkaLogAdapter is an log adapter bridging kitlog and kafka. Use it as the
+// Logger of kafka readers and writers, and KafkaErrorLogAdapter as their
+// ErrorLogger.
 type KafkaLogAdapter struct {
 	Logging log.Logger
+	// Level is the level of the log lines, such as level.DebugValue().
+	// Defaults to level.InfoValue().
+	Level level.Value
 }
 
 // Printf implements kafka log interface.
 func (k KafkaLogAdapter) Printf(s string, i ...interface{}) {
-	k.Logging.Log("msg", fmt.Sprintf(s, i...))
+	lvl := k.Level
+	if lvl == nil {
+		lvl = level.InfoValue()
+	}
+	log.WithPrefix(k.Logging, level.Key(), lvl).Log("msg", fmt.Sprintf(s, i...))
+}
+
+// KafkaErrorLogAdapter is an log adapter bridging kitlog and the error logger
+// of kafka. The log lines are at the error level.
+type KafkaErrorLogAdapter struct {
+	Logging log.Logger
+}
+
+// Printf implements kafka log interface.
+func (k KafkaErrorLogAdapter) Printf(s string, i ...interface{}) {
+	level.Error(k.Logging).Log("msg", fmt.Sprintf(s, i...))
 }
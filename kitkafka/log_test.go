@@ -0,0 +1,30 @@
+package kitkafka
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKafkaLogAdapter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.NewLogfmtLogger(&buf)
+
+	KafkaLogAdapter{Logging: logger}.Printf("joined group %s", "app")
+	assert.Equal(t, "level=info msg=\"joined group app\"\n", buf.String())
+
+	buf.Reset()
+	KafkaLogAdapter{Logging: logger, Level: level.DebugValue()}.Printf("rebalancing")
+	assert.Equal(t, "level=debug msg=rebalancing\n", buf.String())
+
+	buf.Reset()
+	KafkaErrorLogAdapter{Logging: logger}.Printf("connection refused")
+	assert.Equal(t, "level=error msg=\"connection refused\"\n", buf.String())
+
+	buf.Reset()
+	KafkaLogAdapter{Logging: level.NewFilter(logger, level.AllowInfo()), Level: level.DebugValue()}.Printf("rebalancing")
+	assert.Empty(t, buf.String())
+}
@@ -24,8 +24,9 @@ func getCarrier(msg *kafka.Message) opentracing.TextMapCarrier {
 }
 
 // ContextToKafka returns an kafka RequestResponseFunc that injects an OpenTracing Span
-// found in `ctx` into the http headers. If no such Span can be found, the
-// RequestFunc is a noop.
+// found in `ctx` into the kafka headers. If no such Span can be found, the
+// RequestFunc is a noop. Use TraceProducer to start a kafka.produce span
+// instead.
 func ContextToKafka(tracer opentracing.Tracer, logger log.Logger) RequestResponseFunc {
 	return func(ctx context.Context, msg *kafka.Message) context.Context {
 		// Try to find a Span in the Context.
@@ -33,18 +34,10 @@ func ContextToKafka(tracer opentracing.Tracer, logger log.Logger) RequestRespons
 			// Add standard OpenTracing tags.
 			ext.SpanKind.Set(span, ext.SpanKindProducerEnum)
 
-			carrier := make(opentracing.TextMapCarrier)
-			err := tracer.Inject(span.Context(), opentracing.TextMap, carrier)
+			err := injectSpan(tracer, span.Context(), msg)
 			if err != nil {
 				level.Warn(logger).Log("err", fmt.Sprintf("unable to inject tracing context: %s", err.Error()))
 			}
-
-			var header kafka.Header
-			for k, v := range carrier {
-				header.Key = k
-				header.Value = []byte(v)
-			}
-			msg.Headers = append(msg.Headers, header)
 		}
 		return ctx
 	}
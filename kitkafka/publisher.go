@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/DoNewsCode/core/contract"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/segmentio/kafka-go"
 )
@@ -16,6 +17,7 @@ type Publisher struct {
 	before  []RequestResponseFunc
 	after   []RequestResponseFunc
 	timeout time.Duration
+	key     KeyExtractor
 }
 
 // NewPublisher constructs a usable Publisher for a single remote method.
@@ -56,6 +58,19 @@ func PublisherTimeout(timeout time.Duration) PublisherOption {
 	return func(p *Publisher) { p.timeout = timeout }
 }
 
+// KeyExtractor extracts the partition key of the message from the published
+// event, such as the ID of the aggregate the event belongs to. Messages with
+// the same key are written to the same partition, and thus consumed in order.
+// A nil key leaves the message unkeyed.
+type KeyExtractor func(event contract.Event) []byte
+
+// PublisherKey sets the KeyExtractor applied to requests that are
+// contract.Event, after they are encoded. If it returns nil, the key set by the
+// encoder, if any, is kept.
+func PublisherKey(key KeyExtractor) PublisherOption {
+	return func(p *Publisher) { p.key = key }
+}
+
 // Endpoint returns a usable endpoint that invokes the remote endpoint.
 func (p Publisher) Endpoint() endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
@@ -68,6 +83,12 @@ func (p Publisher) Endpoint() endpoint.Endpoint {
 			return nil, err
 		}
 
+		if event, ok := request.(contract.Event); ok && p.key != nil {
+			if key := p.key(event); key != nil {
+				outgoing.Key = key
+			}
+		}
+
 		for _, f := range p.before {
 			ctx = f(ctx, &outgoing)
 		}
@@ -0,0 +1,46 @@
+package kitkafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type orderPlaced struct {
+	OrderID string
+}
+
+func TestPublisherKey(t *testing.T) {
+	var published []kafka.Message
+	handler := HandleFunc(func(ctx context.Context, msg kafka.Message) error {
+		published = append(published, msg)
+		return nil
+	})
+	encode := func(ctx context.Context, msg *kafka.Message, request interface{}) error {
+		msg.Value = []byte("payload")
+		return nil
+	}
+	key := func(event contract.Event) []byte {
+		if data, ok := event.Data().(orderPlaced); ok {
+			return []byte(data.OrderID)
+		}
+		return nil
+	}
+	publish := NewPublisher(handler, encode, PublisherKey(key)).Endpoint()
+
+	_, err := publish(context.Background(), events.Of(orderPlaced{OrderID: "42"}))
+	assert.NoError(t, err)
+	_, err = publish(context.Background(), events.Of("unkeyed"))
+	assert.NoError(t, err)
+	_, err = publish(context.Background(), "not an event")
+	assert.NoError(t, err)
+
+	assert.Len(t, published, 3)
+	assert.Equal(t, []byte("42"), published[0].Key)
+	assert.Nil(t, published[1].Key)
+	assert.Nil(t, published[2].Key)
+}
@@ -20,9 +20,25 @@ type ReaderConfig struct {
 	Topic string `json:"topic" yaml:"topic"`
 
 	// Partition to read messages from.  Either Partition or GroupID may
-	// be assigned, but not both
+	// be assigned, but not both. Assigning a partition statically is useful
+	// for specialized consumers that must own a particular partition.
 	Partition int `json:"partition" yaml:"partition"`
 
+	// GroupBalancers is the priority-ordered list of partition assignment
+	// strategies offered to the consumer group. The supported strategies are
+	// "range", "roundRobin" and "rackAffinity". Sticky assignment is not
+	// provided by kafka-go. Custom kafka.GroupBalancer can be installed via
+	// ReaderInterceptor.
+	//
+	// Default: ["range", "roundRobin"]
+	//
+	// Only used when GroupID is set
+	GroupBalancers []string `json:"groupBalancers" yaml:"groupBalancers"`
+
+	// Rack is the rack where this consumer is running. It is required by the
+	// "rackAffinity" balancer.
+	Rack string `json:"rack" yaml:"rack"`
+
 	// The capacity of the internal message queue, defaults to 100 if none is
 	// set.
 	QueueCapacity int `json:"queue_capacity" yaml:"queue_capacity"`
@@ -0,0 +1,88 @@
+package kitkafka
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// TraceProducer wraps a Handler that writes messages to kafka, such as the one
+// returned by WriterFactory.MakeClient. Each message is written in a
+// kafka.produce span, child of the span found in the context if any, and the
+// span context is injected into the message headers.
+func TraceProducer(tracer opentracing.Tracer, next Handler) Handler {
+	return HandleFunc(func(ctx context.Context, msg kafka.Message) error {
+		return traceProduce(ctx, tracer, "", msg, next)
+	})
+}
+
+// TraceConsumer wraps a Handler of a SubscriberServer. Each message is handled
+// in a kafka.consume span, child of the span context extracted from the
+// message headers if any. The span is available in the context of next.
+func TraceConsumer(tracer opentracing.Tracer, next Handler) Handler {
+	return HandleFunc(func(ctx context.Context, msg kafka.Message) error {
+		var opts []opentracing.StartSpanOption
+		spanContext, extractErr := tracer.Extract(opentracing.TextMap, getCarrier(&msg))
+		if extractErr == nil {
+			opts = append(opts, opentracing.ChildOf(spanContext))
+		}
+		span := tracer.StartSpan("kafka.consume", opts...)
+		defer span.Finish()
+		if extractErr != nil && extractErr != opentracing.ErrSpanContextNotFound {
+			span.LogFields(log.String("event", "unable to extract tracing context"), log.Error(extractErr))
+		}
+		ext.SpanKindConsumer.Set(span)
+		ext.MessageBusDestination.Set(span, msg.Topic)
+		span.SetTag("topic", msg.Topic)
+		span.SetTag("partition", msg.Partition)
+		span.SetTag("offset", msg.Offset)
+
+		err := next.Handle(opentracing.ContextWithSpan(ctx, span), msg)
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(log.Error(err))
+		}
+		return err
+	})
+}
+
+// traceProduce writes msg with next in a kafka.produce span. The topic of the
+// message takes precedence over the given one, which is the writer's.
+func traceProduce(ctx context.Context, tracer opentracing.Tracer, topic string, msg kafka.Message, next Handler) error {
+	if msg.Topic != "" {
+		topic = msg.Topic
+	}
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, tracer, "kafka.produce")
+	defer span.Finish()
+	ext.SpanKindProducer.Set(span)
+	ext.MessageBusDestination.Set(span, topic)
+	span.SetTag("topic", topic)
+	if err := injectSpan(tracer, span.Context(), &msg); err != nil {
+		span.LogFields(log.String("event", "unable to inject tracing context"), log.Error(err))
+	}
+
+	err := next.Handle(ctx, msg)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(log.Error(err))
+	}
+	return err
+}
+
+// injectSpan adds a header to msg for each key of the span context. The
+// headers are copied, so that the caller's slice is left untouched.
+func injectSpan(tracer opentracing.Tracer, spanContext opentracing.SpanContext, msg *kafka.Message) error {
+	carrier := make(opentracing.TextMapCarrier)
+	if err := tracer.Inject(spanContext, opentracing.TextMap, carrier); err != nil {
+		return err
+	}
+	headers := msg.Headers[:len(msg.Headers):len(msg.Headers)]
+	for k, v := range carrier {
+		headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	msg.Headers = headers
+	return nil
+}
@@ -0,0 +1,95 @@
+package kitkafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/di"
+	"github.com/go-kit/kit/log"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceProducerAndConsumer(t *testing.T) {
+	tracer := mocktracer.New()
+	parent := tracer.StartSpan("parent")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+	var produced kafka.Message
+	producer := TraceProducer(tracer, HandleFunc(func(ctx context.Context, msg kafka.Message) error {
+		assert.NotNil(t, opentracing.SpanFromContext(ctx))
+		produced = msg
+		return nil
+	}))
+	headers := make([]kafka.Header, 1, 4)
+	headers[0] = kafka.Header{Key: "foo", Value: []byte("bar")}
+	assert.NoError(t, producer.Handle(ctx, kafka.Message{Topic: "orders", Headers: headers}))
+	assert.Len(t, produced.Headers, 4)
+	assert.Equal(t, kafka.Header{}, headers[:cap(headers)][1], "the caller's headers must be untouched")
+
+	produceSpan := tracer.FinishedSpans()[0]
+	assert.Equal(t, "kafka.produce", produceSpan.OperationName)
+	assert.Equal(t, parent.(*mocktracer.MockSpan).SpanContext.SpanID, produceSpan.ParentID)
+	assert.Equal(t, "orders", produceSpan.Tag("topic"))
+
+	consumeErr := errors.New("foo")
+	consumer := TraceConsumer(tracer, HandleFunc(func(ctx context.Context, msg kafka.Message) error {
+		span := opentracing.SpanFromContext(ctx)
+		assert.Equal(t, produceSpan.SpanContext.TraceID, span.Context().(mocktracer.MockSpanContext).TraceID)
+		return consumeErr
+	}))
+	produced.Partition = 3
+	assert.Equal(t, consumeErr, consumer.Handle(context.Background(), produced))
+
+	consumeSpan := tracer.FinishedSpans()[1]
+	assert.Equal(t, "kafka.consume", consumeSpan.OperationName)
+	assert.Equal(t, produceSpan.SpanContext.SpanID, consumeSpan.ParentID)
+	assert.Equal(t, "orders", consumeSpan.Tag("topic"))
+	assert.Equal(t, 3, consumeSpan.Tag("partition"))
+	assert.Equal(t, true, consumeSpan.Tag("error"))
+}
+
+func TestTraceConsumer_root(t *testing.T) {
+	tracer := mocktracer.New()
+	consumer := TraceConsumer(tracer, HandleFunc(func(ctx context.Context, msg kafka.Message) error {
+		return nil
+	}))
+	assert.NoError(t, consumer.Handle(context.Background(), kafka.Message{Topic: "orders"}))
+	assert.Len(t, tracer.FinishedSpans(), 1)
+	assert.Equal(t, 0, tracer.FinishedSpans()[0].ParentID)
+}
+
+func TestContextToKafka(t *testing.T) {
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("parent")
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+
+	var msg kafka.Message
+	ContextToKafka(tracer, log.NewNopLogger())(ctx, &msg)
+	carrier := make(opentracing.TextMapCarrier)
+	assert.NoError(t, tracer.Inject(span.Context(), opentracing.TextMap, carrier))
+	assert.Len(t, msg.Headers, len(carrier))
+	assert.Equal(t, carrier, getCarrier(&msg))
+}
+
+func TestWriterFactory_tracer(t *testing.T) {
+	tracer := mocktracer.New()
+	factory, cleanup := ProvideWriterFactory(KafkaIn{
+		In:     di.In{},
+		Tracer: tracer,
+		Conf: config.MapAdapter{"kafka.writer": map[string]WriterConfig{
+			"default": {
+				Brokers: []string{"127.0.0.1:9092"},
+				Topic:   "Test",
+			},
+		}},
+	})
+	defer cleanup()
+	client, err := factory.MakeClient("default")
+	assert.NoError(t, err)
+	assert.Equal(t, tracer, client.tracer)
+}
@@ -2,11 +2,12 @@ package kitkafka
 
 import (
 	"context"
-	"math/rand"
 	"time"
 
+	"github.com/DoNewsCode/core/backoff"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/oklog/run"
+	"github.com/opentracing/opentracing-go"
 	"github.com/segmentio/kafka-go"
 )
 
@@ -115,11 +116,10 @@ func (s *SubscriberServer) serveSync(ctx context.Context) error {
 				}
 
 				// retry commit
-				var d time.Duration
-				for err != nil {
-					d = getRetryDuration(d)
-					<-time.After(d)
-					err = s.reader.CommitMessages(context.Background(), msg)
+				if err != nil {
+					_ = commitRetrier.Do(context.Background(), func(ctx context.Context) error {
+						return s.reader.CommitMessages(ctx, msg)
+					})
 				}
 			}
 		}, func(err error) {
@@ -181,21 +181,21 @@ func (p PublisherService) Publish(ctx context.Context, request interface{}) erro
 
 type writerHandle struct {
 	*kafka.Writer
+	tracer opentracing.Tracer
 }
 
 func (p *writerHandle) Handle(ctx context.Context, msg kafka.Message) error {
+	if p.tracer != nil {
+		return traceProduce(ctx, p.tracer, p.Writer.Topic, msg, HandleFunc(p.write))
+	}
+	return p.write(ctx, msg)
+}
+
+func (p *writerHandle) write(ctx context.Context, msg kafka.Message) error {
 	return p.Writer.WriteMessages(ctx, msg)
 }
 
-func getRetryDuration(d time.Duration) time.Duration {
-	d *= 2
-	jitter := rand.Float64() + 0.5
-	d = time.Duration(int64(float64(d.Nanoseconds()) * jitter))
-	if d > 10*time.Second {
-		d = 10 * time.Second
-	}
-	if d < time.Second {
-		d = time.Second
-	}
-	return d
+// commitRetrier retries failed commits until they succeed.
+var commitRetrier = backoff.Retrier{
+	Strategy: backoff.Exponential{Base: time.Second, Max: 10 * time.Second, Jitter: 0.5, Min: time.Second},
 }
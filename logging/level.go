@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+var levelRanks = map[string]int{
+	"debug": 0,
+	"info":  1,
+	"warn":  2,
+	"error": 3,
+	"none":  4,
+}
+
+// LevelControl adjusts the log level at runtime, either globally or for the
+// entries tagged by a module, for example log.With(logger, "tag", "database").
+// Changes take effect on the next log entry. It is safe for concurrent use.
+//
+// It is useful to temporarily enable verbose logging of a single module, such
+// as the SQL statements of gorm, while debugging in production:
+//
+//  control.SetTagLevel("database", "debug")
+//  defer control.SetTagLevel("database", "")
+type LevelControl struct {
+	mu     sync.RWMutex
+	global string
+	tags   map[string]string
+}
+
+// NewLevelControl creates a *LevelControl with the given global level.
+// Allowed levels are "debug", "info", "warn", "error", or "none". Invalid
+// levels allow all entries, same as LevelFilter.
+func NewLevelControl(lvl string) *LevelControl {
+	return &LevelControl{global: lvl, tags: make(map[string]string)}
+}
+
+// SetLevel sets the global level.
+func (c *LevelControl) SetLevel(lvl string) error {
+	if _, ok := levelRanks[lvl]; !ok {
+		return fmt.Errorf("unknown log level %s", lvl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.global = lvl
+	return nil
+}
+
+// SetTagLevel sets the level of the entries with the tag, overriding the
+// global level in both directions. An empty level removes the override.
+func (c *LevelControl) SetTagLevel(tag string, lvl string) error {
+	if _, ok := levelRanks[lvl]; !ok && lvl != "" {
+		return fmt.Errorf("unknown log level %s", lvl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if lvl == "" {
+		delete(c.tags, tag)
+		return nil
+	}
+	c.tags[tag] = lvl
+	return nil
+}
+
+// Levels returns the global level and the level overrides by tag.
+func (c *LevelControl) Levels() (string, map[string]string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	tags := make(map[string]string, len(c.tags))
+	for tag, lvl := range c.tags {
+		tags[tag] = lvl
+	}
+	return c.global, tags
+}
+
+// Filter decorates the logger so that entries are filtered by the current
+// levels. Entries without a level are never filtered.
+func (c *LevelControl) Filter(logger log.Logger) log.Logger {
+	return levelControlFilter{next: logger, control: c}
+}
+
+func (c *LevelControl) allow(tag interface{}, lvl string) bool {
+	c.mu.RLock()
+	threshold := c.global
+	if tag, ok := tag.(string); ok {
+		if override, ok := c.tags[tag]; ok {
+			threshold = override
+		}
+	}
+	c.mu.RUnlock()
+	thresholdRank, ok := levelRanks[threshold]
+	if !ok {
+		return true
+	}
+	return levelRanks[lvl] >= thresholdRank
+}
+
+type levelControlFilter struct {
+	next    log.Logger
+	control *LevelControl
+}
+
+// LevelControl returns the *LevelControl behind the logger.
+func (l levelControlFilter) LevelControl() *LevelControl {
+	return l.control
+}
+
+func (l levelControlFilter) Log(keyvals ...interface{}) error {
+	var (
+		tag interface{}
+		lvl level.Value
+	)
+	for i := 0; i < len(keyvals)-1; i += 2 {
+		if keyvals[i] == "tag" {
+			tag = keyvals[i+1]
+		}
+		if v, ok := keyvals[i+1].(level.Value); ok && keyvals[i] == level.Key() {
+			lvl = v
+		}
+	}
+	if lvl != nil && !l.control.allow(tag, lvl.String()) {
+		return nil
+	}
+	return l.next.Log(keyvals...)
+}
@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelControl(t *testing.T) {
+	var buf bytes.Buffer
+	control := NewLevelControl("info")
+	logger := control.Filter(log.NewLogfmtLogger(&buf))
+	database := log.With(logger, "tag", "database")
+
+	cases := []struct {
+		name   string
+		setup  func()
+		logger log.Logger
+		log    func(log.Logger) log.Logger
+		logged bool
+	}{
+		{"global info allows info", func() {}, logger, level.Info, true},
+		{"global info denies debug", func() {}, logger, level.Debug, false},
+		{"tagged entries follow global", func() {}, database, level.Debug, false},
+		{"tag override lowers", func() { control.SetTagLevel("database", "debug") }, database, level.Debug, true},
+		{"tag override doesn't leak", func() {}, logger, level.Debug, false},
+		{"global raised", func() { control.SetLevel("error") }, logger, level.Warn, false},
+		{"tag override is kept", func() {}, database, level.Warn, true},
+		{"tag override removed", func() { control.SetTagLevel("database", "") }, database, level.Warn, false},
+		{"unleveled entries pass", func() { control.SetLevel("none") }, logger, func(l log.Logger) log.Logger { return l }, true},
+	}
+	for _, c := range cases {
+		buf.Reset()
+		c.setup()
+		c.log(c.logger).Log("msg", "hello")
+		assert.Equal(t, c.logged, buf.Len() > 0, c.name)
+	}
+
+	assert.Error(t, control.SetLevel("verbose"))
+	assert.Error(t, control.SetTagLevel("database", "verbose"))
+	global, tags := control.Levels()
+	assert.Equal(t, "none", global)
+	assert.Empty(t, tags)
+}
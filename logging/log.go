@@ -13,6 +13,15 @@ Package logging is bundled in core. Enable logging as dependency by calling:
 	var c *core.C = core.New()
 	c.ProvideEssentials()
 
+The log level can be adjusted at runtime through *logging.LevelControl, which
+is also provided by the core. Module loggers are tagged, eg. "database" for
+gorm, "mongo", "kafka" and "queue", so that their levels can be tuned
+individually without a restart:
+
+	c.Invoke(func(control *logging.LevelControl) {
+		control.SetTagLevel("database", "debug")
+	})
+
 See example for usage.
 */
 package logging
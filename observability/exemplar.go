@@ -0,0 +1,62 @@
+package observability
+
+import (
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+// ExemplarCounter is a prometheus counter that also accepts OpenMetrics exemplars. Besides the metrics.Counter
+// interface, it implements AddWithExemplar, which packages such as queue look for to attach the trace ID of an
+// observation. Exemplars are only exposed when the metrics are scraped in the OpenMetrics format.
+type ExemplarCounter struct {
+	*prometheus.Counter
+	cv  *stdprometheus.CounterVec
+	lvs []string
+}
+
+// NewExemplarCounterFrom constructs and registers a prometheus CounterVec, and returns an ExemplarCounter wrapping it.
+func NewExemplarCounterFrom(opts stdprometheus.CounterOpts, labelNames []string) *ExemplarCounter {
+	cv := stdprometheus.NewCounterVec(opts, labelNames)
+	stdprometheus.MustRegister(cv)
+	return &ExemplarCounter{Counter: prometheus.NewCounter(cv), cv: cv}
+}
+
+// With implements metrics.Counter.
+func (c *ExemplarCounter) With(labelValues ...string) metrics.Counter {
+	return &ExemplarCounter{
+		Counter: c.Counter.With(labelValues...).(*prometheus.Counter),
+		cv:      c.cv,
+		lvs:     append(append([]string(nil), c.lvs...), labelValues...),
+	}
+}
+
+// AddWithExemplar adds delta to the counter, and attaches the exemplar to the observation. If the exemplar is
+// rejected by prometheus, for example because it is too long, the delta is added without it.
+func (c *ExemplarCounter) AddWithExemplar(delta float64, exemplar map[string]string) {
+	counter := c.cv.With(labels(c.lvs))
+	if adder, ok := counter.(stdprometheus.ExemplarAdder); ok {
+		if c.tryAddWithExemplar(adder, delta, exemplar) {
+			return
+		}
+	}
+	counter.Add(delta)
+}
+
+func (c *ExemplarCounter) tryAddWithExemplar(adder stdprometheus.ExemplarAdder, delta float64, exemplar map[string]string) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	adder.AddWithExemplar(delta, exemplar)
+	return true
+}
+
+func labels(lvs []string) stdprometheus.Labels {
+	labels := stdprometheus.Labels{}
+	for i := 0; i+1 < len(lvs); i += 2 {
+		labels[lvs[i]] = lvs[i+1]
+	}
+	return labels
+}
@@ -0,0 +1,27 @@
+package observability
+
+import (
+	"testing"
+
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExemplarCounter(t *testing.T) {
+	counter := NewExemplarCounterFrom(stdprometheus.CounterOpts{
+		Name: "exemplar_counter_test_total",
+		Help: "The counter of the exemplar test.",
+	}, []string{"queue"})
+	defer stdprometheus.Unregister(counter.cv)
+
+	counter.With("queue", "default").(*ExemplarCounter).AddWithExemplar(2, map[string]string{"trace_id": "abc"})
+	counter.With("queue", "default").Add(1)
+
+	var metric dto.Metric
+	assert.NoError(t, counter.cv.WithLabelValues("default").(stdprometheus.Metric).Write(&metric))
+	assert.Equal(t, float64(3), metric.Counter.GetValue())
+	assert.Equal(t, float64(2), metric.Counter.Exemplar.GetValue())
+	assert.Equal(t, "trace_id", metric.Counter.Exemplar.Label[0].GetName())
+	assert.Equal(t, "abc", metric.Counter.Exemplar.Label[0].GetValue())
+}
@@ -0,0 +1,252 @@
+package otgorm
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	gormcallbacks "gorm.io/gorm/callbacks"
+)
+
+// ErrCacheMiss is returned by CacheStore when the key is not found.
+var ErrCacheMiss = errors.New("cache miss")
+
+const cacheSettingKey = "otgorm:cache_ttl"
+
+// CacheStore is the storage backend of QueryCache.
+type CacheStore interface {
+	// Get returns the value under the key, or ErrCacheMiss if not found.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores the value under the key. A zero ttl means no expiration.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// QueryCache caches the results of read queries in a CacheStore, keyed by
+// the table, the SQL and its arguments. Caching is opt-in per query via the
+// Cached scope:
+//
+//  db.Scopes(otgorm.Cached(time.Minute)).Where("code = ?", "CN").Find(&countries)
+//
+// Invalidation is done per table. Every cache key embeds a version of the
+// table. Calling Invalidate bumps the version, so that all cached results of
+// the table become unreachable and expire by their TTL. Writes made by
+// Create, Update and Delete through the *gorm.DB on which QueryCache is
+// installed invalidate the written table automatically. Writes that gorm
+// cannot attribute to a table, including Exec, and writes from other
+// processes or other *gorm.DB are not detected: call Invalidate explicitly or
+// rely on a short TTL for those.
+//
+// Results are serialized as JSON. Only the fields that survive a JSON round
+// trip are cached. Associations loaded by Preload are not cached, they are
+// queried on every hit. Transactions are not isolated from the cache: a query
+// in a transaction may read a result cached outside of it.
+type QueryCache struct {
+	// Store is the storage backend.
+	Store CacheStore
+	// TTL is the default time to live, used when Cached is called with zero.
+	// By default it is one minute.
+	TTL time.Duration
+	// Prefix is prepended to all cache keys. By default it is "otgorm:cache".
+	Prefix string
+}
+
+// Cached is a scope that enables the QueryCache for the query. If ttl is zero,
+// the default TTL of QueryCache is used.
+func Cached(ttl time.Duration) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(cacheSettingKey, ttl)
+	}
+}
+
+// Install registers the gorm callbacks of the QueryCache on db.
+func (q *QueryCache) Install(db *gorm.DB) error {
+	if err := db.Callback().Query().Replace("gorm:query", q.query); err != nil {
+		return err
+	}
+	if err := db.Callback().Create().After("gorm:create").Register("otgorm:cache_invalidate", q.invalidate); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("otgorm:cache_invalidate", q.invalidate); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register("otgorm:cache_invalidate", q.invalidate)
+}
+
+// Invalidate discards all cached results of the table.
+func (q *QueryCache) Invalidate(ctx context.Context, table string) error {
+	version := strconv.FormatInt(time.Now().UnixNano(), 36)
+	return q.Store.Set(ctx, q.versionKey(table), []byte(version), 0)
+}
+
+func (q *QueryCache) query(db *gorm.DB) {
+	value, ok := db.Get(cacheSettingKey)
+	if !ok || db.Error != nil || db.DryRun || db.Statement.Dest == nil {
+		gormcallbacks.Query(db)
+		return
+	}
+	ttl, _ := value.(time.Duration)
+	if ttl == 0 {
+		ttl = q.ttl()
+	}
+	gormcallbacks.BuildQuerySQL(db)
+	if db.Error != nil {
+		return
+	}
+	ctx := db.Statement.Context
+	key, err := q.key(ctx, db)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	data, err := q.Store.Get(ctx, key)
+	if err == nil && json.Unmarshal(data, db.Statement.Dest) == nil {
+		db.RowsAffected = rowsOf(db.Statement.ReflectValue)
+		return
+	}
+	gormcallbacks.Query(db)
+	if db.Error != nil {
+		return
+	}
+	if data, err = json.Marshal(db.Statement.Dest); err == nil {
+		_ = q.Store.Set(ctx, key, data, ttl)
+	}
+}
+
+func (q *QueryCache) invalidate(db *gorm.DB) {
+	if db.Error != nil || db.DryRun || db.Statement.Table == "" {
+		return
+	}
+	if err := q.Invalidate(db.Statement.Context, db.Statement.Table); err != nil {
+		_ = db.AddError(fmt.Errorf("failed to invalidate query cache of %s: %w", db.Statement.Table, err))
+	}
+}
+
+func (q *QueryCache) key(ctx context.Context, db *gorm.DB) (string, error) {
+	version, err := q.Store.Get(ctx, q.versionKey(db.Statement.Table))
+	if err != nil && !errors.Is(err, ErrCacheMiss) {
+		return "", err
+	}
+	hash := sha1.Sum([]byte(fmt.Sprintf("%s%v", db.Statement.SQL.String(), db.Statement.Vars)))
+	return fmt.Sprintf("%s:%s:%s:%s", q.prefix(), db.Statement.Table, version, hex.EncodeToString(hash[:])), nil
+}
+
+func (q *QueryCache) versionKey(table string) string {
+	return fmt.Sprintf("%s:%s:version", q.prefix(), table)
+}
+
+func (q *QueryCache) prefix() string {
+	if q.Prefix == "" {
+		return "otgorm:cache"
+	}
+	return q.Prefix
+}
+
+func (q *QueryCache) ttl() time.Duration {
+	if q.TTL == 0 {
+		return time.Minute
+	}
+	return q.TTL
+}
+
+func rowsOf(value reflect.Value) int64 {
+	if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		return int64(value.Len())
+	}
+	return 1
+}
+
+// memoryCacheSweepInterval is how often MemoryCacheStore sweeps its expired entries.
+const memoryCacheSweepInterval = time.Minute
+
+// MemoryCacheStore is an in-memory CacheStore. It is only suitable for a single
+// process. Expired entries are evicted when they are read, and the entries that
+// are never read again are swept by Set, at most once a minute, so the memory
+// is bounded by the entries written within their TTL.
+type MemoryCacheStore struct {
+	mu        sync.Mutex
+	items     map[string]memoryCacheItem
+	lastSweep time.Time
+}
+
+type memoryCacheItem struct {
+	value    []byte
+	expireAt time.Time
+}
+
+func (i memoryCacheItem) expired(now time.Time) bool {
+	return !i.expireAt.IsZero() && now.After(i.expireAt)
+}
+
+// NewMemoryCacheStore creates a *MemoryCacheStore.
+func NewMemoryCacheStore() *MemoryCacheStore {
+	return &MemoryCacheStore{items: make(map[string]memoryCacheItem), lastSweep: time.Now()}
+}
+
+// Get implements CacheStore.
+func (m *MemoryCacheStore) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.items[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	if item.expired(time.Now()) {
+		delete(m.items, key)
+		return nil, ErrCacheMiss
+	}
+	return item.value, nil
+}
+
+// Set implements CacheStore.
+func (m *MemoryCacheStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if now.Sub(m.lastSweep) >= memoryCacheSweepInterval {
+		m.sweep(now)
+	}
+	item := memoryCacheItem{value: value}
+	if ttl > 0 {
+		item.expireAt = now.Add(ttl)
+	}
+	m.items[key] = item
+	return nil
+}
+
+// sweep deletes the expired entries. The caller must hold the lock.
+func (m *MemoryCacheStore) sweep(now time.Time) {
+	for key, item := range m.items {
+		if item.expired(now) {
+			delete(m.items, key)
+		}
+	}
+	m.lastSweep = now
+}
+
+// RedisCacheStore is a CacheStore backed by redis.
+type RedisCacheStore struct {
+	Client redis.UniversalClient
+}
+
+// Get implements CacheStore.
+func (r RedisCacheStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := r.Client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrCacheMiss
+	}
+	return data, err
+}
+
+// Set implements CacheStore.
+func (r RedisCacheStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.Client.Set(ctx, key, value, ttl).Err()
+}
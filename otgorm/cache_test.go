@@ -0,0 +1,93 @@
+package otgorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type cachedCountry struct {
+	ID   int
+	Code string
+}
+
+func TestQueryCache(t *testing.T) {
+	cache := &QueryCache{Store: NewMemoryCacheStore(), TTL: time.Minute}
+	factory, cleanup := provideDBFactory(DatabaseIn{
+		Conf: config.MapAdapter{"gorm": map[string]databaseConf{
+			"default": {
+				Database: "sqlite",
+				Dsn:      "file:query_cache?mode=memory&cache=shared",
+			},
+		}},
+		Logger:     log.NewNopLogger(),
+		QueryCache: cache,
+	})
+	defer cleanup()
+	db, err := factory.Make("default")
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&cachedCountry{}))
+	assert.NoError(t, db.Create(&cachedCountry{ID: 1, Code: "CN"}).Error)
+
+	var countries []cachedCountry
+	assert.NoError(t, db.Scopes(Cached(0)).Find(&countries).Error)
+	assert.Len(t, countries, 1)
+
+	// bypass the hooks, so that the cache is stale.
+	assert.NoError(t, db.Exec("INSERT INTO cached_countries (id, code) VALUES (2, 'US')").Error)
+
+	countries = nil
+	result := db.Scopes(Cached(0)).Find(&countries)
+	assert.NoError(t, result.Error)
+	assert.Equal(t, int64(1), result.RowsAffected)
+	assert.Len(t, countries, 1)
+
+	countries = nil
+	assert.NoError(t, db.Find(&countries).Error)
+	assert.Len(t, countries, 2)
+
+	assert.NoError(t, cache.Invalidate(context.Background(), "cached_countries"))
+	countries = nil
+	assert.NoError(t, db.Scopes(Cached(0)).Find(&countries).Error)
+	assert.Len(t, countries, 2)
+
+	// writes through gorm invalidate automatically.
+	assert.NoError(t, db.Create(&cachedCountry{ID: 3, Code: "JP"}).Error)
+	var country cachedCountry
+	assert.NoError(t, db.Scopes(Cached(0)).Where("code = ?", "JP").First(&country).Error)
+	assert.Equal(t, 3, country.ID)
+	countries = nil
+	assert.NoError(t, db.Scopes(Cached(0)).Find(&countries).Error)
+	assert.Len(t, countries, 3)
+}
+
+func TestMemoryCacheStore(t *testing.T) {
+	store := NewMemoryCacheStore()
+	ctx := context.Background()
+	_, err := store.Get(ctx, "foo")
+	assert.Equal(t, ErrCacheMiss, err)
+	assert.NoError(t, store.Set(ctx, "foo", []byte("bar"), time.Millisecond))
+	data, err := store.Get(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(data))
+	time.Sleep(2 * time.Millisecond)
+	_, err = store.Get(ctx, "foo")
+	assert.Equal(t, ErrCacheMiss, err)
+}
+
+func TestMemoryCacheStore_sweep(t *testing.T) {
+	store := NewMemoryCacheStore()
+	ctx := context.Background()
+	assert.NoError(t, store.Set(ctx, "foo", []byte("bar"), time.Millisecond))
+	assert.NoError(t, store.Set(ctx, "baz", []byte("bar"), 0))
+	time.Sleep(2 * time.Millisecond)
+
+	store.lastSweep = time.Now().Add(-memoryCacheSweepInterval)
+	assert.NoError(t, store.Set(ctx, "qux", []byte("bar"), time.Minute))
+	assert.Len(t, store.items, 2)
+	assert.NotContains(t, store.items, "foo")
+}
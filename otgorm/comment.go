@@ -0,0 +1,180 @@
+package otgorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"gorm.io/gorm"
+)
+
+// SQLCommenter appends a comment carrying tags to every statement executed by
+// gorm, in the format of sqlcommenter:
+//
+//  SELECT * FROM `users` WHERE `id` = ? /*application='orders',traceparent='00-...-01'*/
+//
+// The comment lets the database side attribute slow queries to the
+// application and to the request that issued them. The tags are made of the
+// static Tags and of the tags extracted from the context of the statement,
+// sorted by key. Tags with empty values are omitted. Statements that already contain a comment are left alone.
+//
+// To use it with Provide, add the *SQLCommenter to the core. It will be
+// installed on every *gorm.DB created by the Factory.
+//
+//  c.Provide(func() *otgorm.SQLCommenter {
+//    return &otgorm.SQLCommenter{Tags: map[string]string{"application": "orders"}}
+//  })
+//
+// Prepared statements are cached by their SQL. A comment that changes with
+// every request would defeat the cache, so the tags from the context are
+// dropped when gorm runs with PrepareStmt. Only the static Tags are appended
+// in that case.
+type SQLCommenter struct {
+	// Tags are added to every statement, eg. the application name.
+	Tags map[string]string
+	// Context extracts the tags of a statement from its context, eg. a request
+	// ID. By default it is TraceparentTag.
+	Context func(ctx context.Context) map[string]string
+}
+
+// TraceparentTag returns the W3C traceparent of the jaeger span in the context
+// as the "traceparent" tag. It returns nil if there is no such span.
+func TraceparentTag(ctx context.Context) map[string]string {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return nil
+	}
+	spanContext, ok := span.Context().(jaeger.SpanContext)
+	if !ok {
+		return nil
+	}
+	var flags byte
+	if spanContext.IsSampled() {
+		flags = 1
+	}
+	return map[string]string{
+		"traceparent": fmt.Sprintf("00-%016x%016x-%016x-%02x", spanContext.TraceID().High, spanContext.TraceID().Low, uint64(spanContext.SpanID()), flags),
+	}
+}
+
+// Install registers the gorm callbacks of the SQLCommenter on db.
+func (s *SQLCommenter) Install(db *gorm.DB) error {
+	const (
+		comment = "otgorm:sql_comment"
+		restore = "otgorm:sql_comment_restore"
+		commit  = "gorm:commit_or_rollback_transaction"
+	)
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("gorm:create").Register(comment, s.comment),
+		callbacks.Create().After("gorm:create").Before(commit).Register(restore, s.restore),
+		callbacks.Query().Before("gorm:query").Register(comment, s.comment),
+		callbacks.Query().After("gorm:query").Register(restore, s.restore),
+		callbacks.Update().Before("gorm:update").Register(comment, s.comment),
+		callbacks.Update().After("gorm:update").Before(commit).Register(restore, s.restore),
+		callbacks.Delete().Before("gorm:delete").Register(comment, s.comment),
+		callbacks.Delete().After("gorm:delete").Before(commit).Register(restore, s.restore),
+		callbacks.Row().Before("gorm:row").Register(comment, s.comment),
+		callbacks.Row().After("gorm:row").Register(restore, s.restore),
+		callbacks.Raw().Before("gorm:raw").Register(comment, s.comment),
+		callbacks.Raw().After("gorm:raw").Register(restore, s.restore),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// comment wraps the connection pool of the statement, so that the SQL sent to
+// the database carries the comment. The SQL of the statement itself is not
+// changed, as other callbacks, such as the QueryCache, depend on it.
+func (s *SQLCommenter) comment(db *gorm.DB) {
+	if db.Error != nil || db.DryRun {
+		return
+	}
+	if _, ok := db.Statement.ConnPool.(commentedConnPool); ok {
+		return
+	}
+	tags := make(map[string]string, len(s.Tags))
+	add := func(from map[string]string) {
+		for k, v := range from {
+			if v != "" {
+				tags[k] = v
+			}
+		}
+	}
+	add(s.Tags)
+	if !prepared(db.Statement.ConnPool) {
+		extract := s.Context
+		if extract == nil {
+			extract = TraceparentTag
+		}
+		add(extract(db.Statement.Context))
+	}
+	if len(tags) == 0 {
+		return
+	}
+	db.Statement.ConnPool = commentedConnPool{ConnPool: db.Statement.ConnPool, comment: formatComment(tags)}
+}
+
+// restore unwraps the connection pool before the transaction is committed, as
+// the commit needs the original one.
+func (s *SQLCommenter) restore(db *gorm.DB) {
+	if pool, ok := db.Statement.ConnPool.(commentedConnPool); ok {
+		db.Statement.ConnPool = pool.ConnPool
+	}
+}
+
+func prepared(pool gorm.ConnPool) bool {
+	switch pool.(type) {
+	case *gorm.PreparedStmtDB, *gorm.PreparedStmtTX:
+		return true
+	}
+	return false
+}
+
+// formatComment formats the tags as a sqlcommenter comment. Keys and values
+// are URL encoded, and values are quoted.
+func formatComment(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, fmt.Sprintf("%s='%s'", url.QueryEscape(k), url.QueryEscape(v)))
+	}
+	sort.Strings(pairs)
+	return "/*" + strings.Join(pairs, ",") + "*/"
+}
+
+// commentedConnPool appends the comment to the SQL it executes.
+type commentedConnPool struct {
+	gorm.ConnPool
+	comment string
+}
+
+func (c commentedConnPool) withComment(query string) string {
+	if strings.Contains(query, "/*") {
+		return query
+	}
+	return query + " " + c.comment
+}
+
+func (c commentedConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return c.ConnPool.PrepareContext(ctx, c.withComment(query))
+}
+
+func (c commentedConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.ConnPool.ExecContext(ctx, c.withComment(query), args...)
+}
+
+func (c commentedConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.ConnPool.QueryContext(ctx, c.withComment(query), args...)
+}
+
+func (c commentedConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return c.ConnPool.QueryRowContext(ctx, c.withComment(query), args...)
+}
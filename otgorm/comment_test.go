@@ -0,0 +1,109 @@
+package otgorm
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type recordingConnPool struct {
+	*sql.DB
+	mu      sync.Mutex
+	queries []string
+}
+
+func (r *recordingConnPool) record(query string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries = append(r.queries, query)
+}
+
+func (r *recordingConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	r.record(query)
+	return r.DB.ExecContext(ctx, query, args...)
+}
+
+func (r *recordingConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	r.record(query)
+	return r.DB.QueryContext(ctx, query, args...)
+}
+
+func (r *recordingConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	r.record(query)
+	return r.DB.QueryRowContext(ctx, query, args...)
+}
+
+func (r *recordingConnPool) last() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.queries[len(r.queries)-1]
+}
+
+type requestIDKey struct{}
+
+func TestSQLCommenter(t *testing.T) {
+	sqlDB, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "commenter.db"))
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+	pool := &recordingConnPool{DB: sqlDB}
+
+	db, err := gorm.Open(sqlite.Dialector{Conn: pool}, &gorm.Config{SkipDefaultTransaction: true})
+	assert.NoError(t, err)
+	commenter := &SQLCommenter{
+		Tags: map[string]string{"application": "orders"},
+		Context: func(ctx context.Context) map[string]string {
+			id, _ := ctx.Value(requestIDKey{}).(string)
+			return map[string]string{"request_id": id}
+		},
+	}
+	assert.NoError(t, commenter.Install(db))
+	assert.NoError(t, db.AutoMigrate(&scopedUser{}))
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "it's 1")
+	assert.NoError(t, db.WithContext(ctx).Create(&scopedUser{ID: 1, Name: "foo"}).Error)
+	assert.Equal(t, "INSERT INTO `scoped_users` (`tenant_id`,`name`,`id`) VALUES (?,?,?) /*application='orders',request_id='it%27s+1'*/", pool.last())
+
+	var user scopedUser
+	assert.NoError(t, db.WithContext(ctx).First(&user).Error)
+	assert.Equal(t, "foo", user.Name)
+	assert.Contains(t, pool.last(), "LIMIT 1 /*application='orders',request_id='it%27s+1'*/")
+
+	// statements with comments are not altered.
+	assert.NoError(t, db.Exec("/* manual */ DELETE FROM scoped_users").Error)
+	assert.Equal(t, "/* manual */ DELETE FROM scoped_users", pool.last())
+}
+
+func TestSQLCommenter_prepared(t *testing.T) {
+	sqlDB, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "prepared.db"))
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+
+	db, err := gorm.Open(sqlite.Dialector{Conn: sqlDB}, &gorm.Config{PrepareStmt: true})
+	assert.NoError(t, err)
+	commenter := &SQLCommenter{
+		Tags: map[string]string{"application": "orders"},
+		Context: func(ctx context.Context) map[string]string {
+			return map[string]string{"request_id": ctx.Value(requestIDKey{}).(string)}
+		},
+	}
+	assert.NoError(t, commenter.Install(db))
+	assert.NoError(t, db.AutoMigrate(&scopedUser{}))
+
+	for _, id := range []string{"1", "2"} {
+		ctx := context.WithValue(context.Background(), requestIDKey{}, id)
+		var users []scopedUser
+		assert.NoError(t, db.WithContext(ctx).Find(&users).Error)
+	}
+	// the request IDs are dropped, so that a single statement is prepared.
+	stmts := db.ConnPool.(*gorm.PreparedStmtDB).Stmts
+	assert.Contains(t, stmts, "SELECT * FROM `scoped_users` /*application='orders'*/")
+	for query := range stmts {
+		assert.NotContains(t, query, "request_id")
+	}
+}
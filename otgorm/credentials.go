@@ -0,0 +1,145 @@
+package otgorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// connectors maps the *sql.DB created by the factory to its dsnConnector.
+var connectors sync.Map
+
+// dsnConnector is a driver.Connector whose DSN can be replaced at runtime.
+// Each new connection is established with the current DSN.
+type dsnConnector struct {
+	driver driver.Driver
+	mu     sync.RWMutex
+	dsn    string
+}
+
+// Connect implements driver.Connector.
+func (c *dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.RLock()
+	dsn := c.dsn
+	c.mu.RUnlock()
+	if driverCtx, ok := c.driver.(driver.DriverContext); ok {
+		connector, err := driverCtx.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return connector.Connect(ctx)
+	}
+	return c.driver.Open(dsn)
+}
+
+// Driver implements driver.Connector.
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+func (c *dsnConnector) setDSN(dsn string) error {
+	if driverCtx, ok := c.driver.(driver.DriverContext); ok {
+		if _, err := driverCtx.OpenConnector(dsn); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	c.dsn = dsn
+	c.mu.Unlock()
+	return nil
+}
+
+// provideRefreshableDialector is like ProvideDialector, but the connection
+// pool is created upfront with a dsnConnector, so that its DSN can be
+// refreshed by RefreshCredentials. The returned function releases the pool.
+func provideRefreshableDialector(conf *databaseConf) (gorm.Dialector, func(), error) {
+	driverName, err := driverNameOf(conf.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+	// sql.Open doesn't connect. It only validates the DSN and looks up the driver.
+	probe, err := sql.Open(driverName, conf.Dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	connector := &dsnConnector{driver: probe.Driver(), dsn: conf.Dsn}
+	_ = probe.Close()
+
+	sqlDB := sql.OpenDB(connector)
+	connectors.Store(sqlDB, connector)
+	release := func() {
+		connectors.Delete(sqlDB)
+		_ = sqlDB.Close()
+	}
+	return dialectorWithConn(conf.Database, conf.Dsn, sqlDB), release, nil
+}
+
+// driverNameOf returns the name of the database/sql driver of the database
+// type.
+func driverNameOf(database string) (string, error) {
+	switch database {
+	case "mysql":
+		return "mysql", nil
+	case "sqlite":
+		return sqlite.DriverName, nil
+	case "postgres":
+		// Registered by the pgx stdlib package, which gorm's postgres driver imports.
+		return "pgx", nil
+	}
+	return "", unknownDatabaseErr(database)
+}
+
+// dialectorWithConn creates a gorm.Dialector of the database type on top of an
+// existing connection pool.
+func dialectorWithConn(database, dsn string, conn *sql.DB) gorm.Dialector {
+	switch database {
+	case "mysql":
+		return mysql.New(mysql.Config{DSN: dsn, Conn: conn})
+	case "postgres":
+		return postgres.New(postgres.Config{DSN: dsn, Conn: conn})
+	}
+	return &sqlite.Dialector{DSN: dsn, Conn: conn}
+}
+
+// RefreshCredentials replaces the DSN of a *gorm.DB created by the Factory,
+// typically to rotate short-lived credentials such as IAM authentication
+// tokens. The new DSN is obtained from fn, and is used by every connection
+// established afterwards. Call it periodically, before the credentials
+// expire.
+//
+// The connection pool is kept, so there is no downtime. Connections
+// established before the refresh are not interrupted: in-flight queries and
+// transactions complete normally, and the connections are reused until the
+// pool retires them. If the server rejects sessions authenticated with expired
+// credentials, set connMaxLifetimeSecond in the configuration shorter than the
+// lifetime of the credentials. As a consequence, for a while both the old and
+// the new credentials may be in use.
+//
+// If fn fails, or the new DSN is malformed, the current DSN is kept and an
+// error is returned.
+func RefreshCredentials(db *gorm.DB, fn func() (string, error)) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	value, ok := connectors.Load(sqlDB)
+	if !ok {
+		return errors.New("credentials of the database are not refreshable, only those created by the factory are")
+	}
+	dsn, err := fn()
+	if err != nil {
+		return fmt.Errorf("failed to refresh credentials: %w", err)
+	}
+	if err := value.(*dsnConnector).setDSN(dsn); err != nil {
+		return fmt.Errorf("failed to refresh credentials: %w", err)
+	}
+	return nil
+}
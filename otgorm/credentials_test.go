@@ -0,0 +1,55 @@
+package otgorm
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRefreshCredentials(t *testing.T) {
+	dir := t.TempDir()
+	oldDsn, newDsn := filepath.Join(dir, "old.db"), filepath.Join(dir, "new.db")
+	factory, cleanup := provideDBFactory(DatabaseIn{
+		Conf: config.MapAdapter{"gorm": map[string]databaseConf{
+			"default": {Database: "sqlite", Dsn: oldDsn},
+		}},
+		Logger: log.NewNopLogger(),
+	})
+	defer cleanup()
+	db, err := factory.Make("default")
+	assert.NoError(t, err)
+	sqlDB, _ := db.DB()
+	// Don't keep idle connections, so that every query dials with the current DSN.
+	sqlDB.SetMaxIdleConns(0)
+	assert.NoError(t, db.Exec("CREATE TABLE old_marker (id int)").Error)
+
+	assert.Error(t, RefreshCredentials(db, func() (string, error) {
+		return "", errors.New("token service unavailable")
+	}))
+	assert.NoError(t, RefreshCredentials(db, func() (string, error) {
+		return newDsn, nil
+	}))
+	assert.NoError(t, db.Exec("CREATE TABLE new_marker (id int)").Error)
+
+	newDB, err := gorm.Open(sqlite.Open(newDsn), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.True(t, newDB.Migrator().HasTable("new_marker"))
+	assert.False(t, newDB.Migrator().HasTable("old_marker"))
+	newSqlDB, _ := newDB.DB()
+	newSqlDB.Close()
+}
+
+func TestRefreshCredentials_notRefreshable(t *testing.T) {
+	db, cleanup, err := ProvideGormDB(sqlite.Open(""), &gorm.Config{}, nil)
+	assert.NoError(t, err)
+	defer cleanup()
+	assert.Error(t, RefreshCredentials(db, func() (string, error) {
+		return "", nil
+	}))
+}
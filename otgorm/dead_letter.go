@@ -0,0 +1,60 @@
+package otgorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/DoNewsCode/core/queue"
+	"gorm.io/gorm"
+)
+
+// DeadLetter is the model of a queued job that has finally failed. See DeadLetterStore.
+type DeadLetter struct {
+	ID         uint   `gorm:"primaryKey"`
+	Queue      string `gorm:"size:255;index"`
+	UniqueId   string `gorm:"size:255;index"`
+	Key        string `gorm:"size:255;index"`
+	Payload    []byte
+	Error      string
+	Attempts   int
+	EnqueuedAt time.Time
+	FailedAt   time.Time `gorm:"index"`
+}
+
+// DeadLetterStore is a queue.DeadLetterStore that archives the failed jobs in a
+// database, where they can be queried and audited long after the failure.
+// Create the table with a migration, for example:
+//
+//	&otgorm.Migration{ID: "202101010000", Migrate: func(db *gorm.DB) error {
+//		return db.AutoMigrate(&otgorm.DeadLetter{})
+//	}}
+//
+// Then provide the store to the core, so that the queues use it.
+//
+//	c.Provide(func(db *gorm.DB) queue.DeadLetterStore {
+//		return otgorm.DeadLetterStore{DB: db}
+//	})
+type DeadLetterStore struct {
+	// DB is the database of the archive.
+	DB *gorm.DB
+	// Table is the name of the table. By default it is "dead_letters".
+	Table string
+}
+
+// StoreDeadLetter implements queue.DeadLetterStore.
+func (s DeadLetterStore) StoreDeadLetter(ctx context.Context, letter queue.DeadLetter) error {
+	db := s.DB.WithContext(ctx)
+	if s.Table != "" {
+		db = db.Table(s.Table)
+	}
+	return db.Create(&DeadLetter{
+		Queue:      letter.Queue,
+		UniqueId:   letter.UniqueId,
+		Key:        letter.Key,
+		Payload:    letter.Payload,
+		Error:      letter.Error,
+		Attempts:   letter.Attempts,
+		EnqueuedAt: letter.EnqueuedAt,
+		FailedAt:   letter.FailedAt,
+	}).Error
+}
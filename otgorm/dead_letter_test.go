@@ -0,0 +1,61 @@
+package otgorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/DoNewsCode/core/queue"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingJob struct {
+	Order int
+}
+
+func TestDeadLetterStore(t *testing.T) {
+	factory, cleanup := provideDBFactory(DatabaseIn{
+		Conf: config.MapAdapter{"gorm": map[string]databaseConf{
+			"default": {
+				Database: "sqlite",
+				Dsn:      "file:dead_letters?mode=memory&cache=shared",
+			},
+		}},
+		Logger: log.NewNopLogger(),
+	})
+	defer cleanup()
+	db, err := factory.Make("default")
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&DeadLetter{}))
+
+	driver := queue.NewInProcessDriverWithPopInterval(time.Millisecond)
+	dispatcher := queue.WithQueue(&events.SyncDispatcher{}, driver, queue.UseDeadLetterStore("orders", DeadLetterStore{DB: db}))
+	dispatcher.Subscribe(events.Listen(events.From(failingJob{}), func(ctx context.Context, event contract.Event) error {
+		return errors.New("payment declined")
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Consume(ctx)
+	assert.NoError(t, dispatcher.Dispatch(ctx, queue.Persist(events.Of(failingJob{Order: 1}), queue.UniqueId("order-1"))))
+
+	var letters []DeadLetter
+	assert.Eventually(t, func() bool {
+		assert.NoError(t, db.Find(&letters).Error)
+		return len(letters) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "orders", letters[0].Queue)
+	assert.Equal(t, "order-1", letters[0].UniqueId)
+	assert.Equal(t, "payment declined", letters[0].Error)
+	assert.Equal(t, 1, letters[0].Attempts)
+	assert.NotEmpty(t, letters[0].Payload)
+
+	// Archived jobs are removed from the queue.
+	info, err := driver.Info(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, queue.QueueInfo{}, info)
+}
@@ -0,0 +1,57 @@
+package otgorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DoNewsCode/core/backoff"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/opentracing/opentracing-go"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// reconnectRetrier pings a degraded connection until the database is reachable.
+var reconnectRetrier = backoff.Retrier{
+	Strategy: backoff.Exponential{Base: time.Second, Max: time.Minute, Jitter: 0.2},
+}
+
+// provideDegradedGormDB opens a *gorm.DB without reaching the database, for
+// non-critical connections whose database is unavailable at startup. The
+// automatic ping is disabled, and so is the version detection of mysql, which
+// means version specific quirks are not applied until the application is
+// restarted. The *gorm.DB is never reopened: its pool dials the database on
+// each use, like any *sql.DB, so queries succeed again once it is reachable.
+// The background ping with backoff only reports the recovery in the log. It
+// stops at the first success, or when the returned cleanup is called.
+func provideDegradedGormDB(dialector gorm.Dialector, config *gorm.Config, tracer opentracing.Tracer, logger log.Logger, name string) (*gorm.DB, func(), error) {
+	if d, ok := dialector.(*mysql.Dialector); ok {
+		d.Config.SkipInitializeWithVersion = true
+	}
+	config.DisableAutomaticPing = true
+	db, cleanup, err := ProvideGormDB(dialector, config, tracer)
+	if err != nil {
+		return nil, nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := reconnectRetrier.Do(ctx, sqlDB.PingContext); err == nil {
+			level.Info(logger).Log("msg", fmt.Sprintf("database %s is reachable again", name))
+		}
+	}()
+	return db, func() {
+		cancel()
+		<-done
+		cleanup()
+	}, nil
+}
@@ -0,0 +1,29 @@
+package otgorm
+
+import (
+	"testing"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvideDBFactory_nonCritical(t *testing.T) {
+	const unreachable = "root@tcp(127.0.0.1:1)/app?timeout=100ms"
+	factory, cleanup := provideDBFactory(DatabaseIn{
+		Conf: config.MapAdapter{"gorm": map[string]databaseConf{
+			"critical":    {Database: "mysql", Dsn: unreachable},
+			"nonCritical": {Database: "mysql", Dsn: unreachable, NonCritical: true},
+		}},
+		Logger: log.NewNopLogger(),
+	})
+	defer cleanup()
+
+	_, err := factory.Make("critical")
+	assert.Error(t, err)
+
+	db, err := factory.Make("nonCritical")
+	assert.NoError(t, err)
+	// the connection is attempted again on use.
+	assert.Error(t, db.Exec("SELECT 1").Error)
+}
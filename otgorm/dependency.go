@@ -1,16 +1,20 @@
 package otgorm
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/DoNewsCode/core/config"
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/health"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/opentracing/opentracing-go"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/schema"
@@ -22,19 +26,33 @@ func (c confNotFoundErr) Error() string {
 	return string(c)
 }
 
+// unknownDatabaseErr reports a database type other than mysql, sqlite and
+// postgres.
+func unknownDatabaseErr(database string) error {
+	return fmt.Errorf("unknown database type %s, supported types are mysql, sqlite and postgres", database)
+}
+
 type databaseConf struct {
-	Database                                 string `json:"database" yaml:"database"`
-	Dsn                                      string `json:"dsn" yaml:"dsn"`
-	SkipDefaultTransaction                   bool   `json:"skipDefaultTransaction" yaml:"skipDefaultTransaction"`
-	FullSaveAssociations                     bool   `json:"fullSaveAssociations" yaml:"fullSaveAssociations"`
-	DryRun                                   bool   `json:"dryRun" yaml:"dryRun"`
-	PrepareStmt                              bool   `json:"prepareStmt" yaml:"prepareStmt"`
-	DisableAutomaticPing                     bool   `json:"disableAutomaticPing" yaml:"disableAutomaticPing"`
-	DisableForeignKeyConstraintWhenMigrating bool   `json:"disableForeignKeyConstraintWhenMigrating" yaml:"disableForeignKeyConstraintWhenMigrating"`
-	DisableNestedTransaction                 bool   `json:"disableNestedTransaction" yaml:"disableNestedTransaction"`
-	AllowGlobalUpdate                        bool   `json:"allowGlobalUpdate" yaml:"allowGlobalUpdate"`
-	QueryFields                              bool   `json:"queryFields" yaml:"queryFields"`
-	CreateBatchSize                          int    `json:"createBatchSize" yaml:"createBatchSize"`
+	Database                                 string            `json:"database" yaml:"database"`
+	Dsn                                      string            `json:"dsn" yaml:"dsn"`
+	SkipDefaultTransaction                   bool              `json:"skipDefaultTransaction" yaml:"skipDefaultTransaction"`
+	FullSaveAssociations                     bool              `json:"fullSaveAssociations" yaml:"fullSaveAssociations"`
+	DryRun                                   bool              `json:"dryRun" yaml:"dryRun"`
+	PrepareStmt                              bool              `json:"prepareStmt" yaml:"prepareStmt"`
+	DisableAutomaticPing                     bool              `json:"disableAutomaticPing" yaml:"disableAutomaticPing"`
+	DisableForeignKeyConstraintWhenMigrating bool              `json:"disableForeignKeyConstraintWhenMigrating" yaml:"disableForeignKeyConstraintWhenMigrating"`
+	DisableNestedTransaction                 bool              `json:"disableNestedTransaction" yaml:"disableNestedTransaction"`
+	AllowGlobalUpdate                        bool              `json:"allowGlobalUpdate" yaml:"allowGlobalUpdate"`
+	QueryFields                              bool              `json:"queryFields" yaml:"queryFields"`
+	CreateBatchSize                          int               `json:"createBatchSize" yaml:"createBatchSize"`
+	StatsIntervalSecond                      int               `json:"statsIntervalSecond" yaml:"statsIntervalSecond"`
+	MaxOpenConns                             int               `json:"maxOpenConns" yaml:"maxOpenConns"`
+	MaxIdleConns                             int               `json:"maxIdleConns" yaml:"maxIdleConns"`
+	ConnMaxLifetimeSecond                    int               `json:"connMaxLifetimeSecond" yaml:"connMaxLifetimeSecond"`
+	ConnMaxIdleTimeSecond                    int               `json:"connMaxIdleTimeSecond" yaml:"connMaxIdleTimeSecond"`
+	Replicas                                 []string          `json:"replicas" yaml:"replicas"`
+	NonCritical                              bool              `json:"nonCritical" yaml:"nonCritical"`
+	Labels                                   map[string]string `json:"labels" yaml:"labels"`
 	NamingStrategy                           struct {
 		TablePrefix   string `json:"tablePrefix" yaml:"tablePrefix"`
 		SingularTable bool   `json:"singularTable" yaml:"singularTable"`
@@ -58,6 +76,12 @@ type DatabaseIn struct {
 	Logger                log.Logger
 	GormConfigInterceptor GormConfigInterceptor `optional:"true"`
 	Tracer                opentracing.Tracer    `optional:"true"`
+	DefaultScopes         *DefaultScopes        `optional:"true"`
+	Warmup                *di.Warmup            `optional:"true"`
+	Validation            *di.Validation        `optional:"true"`
+	QueryCache            *QueryCache           `optional:"true"`
+	SQLCommenter          *SQLCommenter         `optional:"true"`
+	Gauge                 Gauge                 `optional:"true"`
 }
 
 // DatabaseOut is the result of Provide. *gorm.DB is not a interface
@@ -68,7 +92,8 @@ type DatabaseOut struct {
 	Database       *gorm.DB
 	Factory        Factory
 	Maker          Maker
-	ExportedConfig []config.ExportedConfig `group:"config,flatten"`
+	HealthCheckers []contract.HealthChecker `group:"health,flatten"`
+	ExportedConfig []config.ExportedConfig  `group:"config,flatten"`
 }
 
 // ProvideDialector provides a gorm.Dialector. Mean to be used as an intermediate
@@ -80,7 +105,10 @@ func ProvideDialector(conf *databaseConf) (gorm.Dialector, error) {
 	if conf.Database == "sqlite" {
 		return sqlite.Open(conf.Dsn), nil
 	}
-	return nil, fmt.Errorf("unknow database type %s", conf.Database)
+	if conf.Database == "postgres" {
+		return postgres.Open(conf.Dsn), nil
+	}
+	return nil, unknownDatabaseErr(conf.Database)
 }
 
 // ProvideGormConfig provides a *gorm.Config. Mean to be used as an intermediate
@@ -136,10 +164,21 @@ func Provide(p DatabaseIn) (DatabaseOut, func(), error) {
 			func() {},
 			fmt.Errorf("failed to construct default database: %w", err)
 	}
+	names := factory.Names()
+	if p.Warmup != nil {
+		if err := factory.Warm(names, p.Warmup.Parallelism); err != nil {
+			if p.Warmup.Fatal {
+				cleanup()
+				return DatabaseOut{}, func() {}, err
+			}
+			level.Warn(p.Logger).Log("err", err)
+		}
+	}
 	return DatabaseOut{
 		Database:       database,
 		Factory:        factory,
 		Maker:          factory,
+		HealthCheckers: provideHealthCheckers(factory, names),
 		ExportedConfig: provideConfig(),
 	}, cleanup, nil
 }
@@ -159,6 +198,34 @@ func (d Factory) Make(name string) (*gorm.DB, error) {
 	return db.(*gorm.DB), nil
 }
 
+// ping validates the *gorm.DB of the Factory.
+func ping(ctx context.Context, conn interface{}) error {
+	db, err := conn.(*gorm.DB).DB()
+	if err != nil {
+		return err
+	}
+	return db.PingContext(ctx)
+}
+
+func provideHealthCheckers(factory Factory, names []string) []contract.HealthChecker {
+	var checkers []contract.HealthChecker
+	for _, name := range names {
+		name := name
+		checkers = append(checkers, health.NewChecker("gorm."+name, func(ctx context.Context) error {
+			db, err := factory.Make(name)
+			if err != nil {
+				return err
+			}
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		}))
+	}
+	return checkers
+}
+
 // ProvideMemoryDatabase provides a sqlite database in memory mode. This is
 // useful for testing.
 func ProvideMemoryDatabase() *gorm.DB {
@@ -184,34 +251,94 @@ func provideDBFactory(p DatabaseIn) (Factory, func()) {
 	if err != nil {
 		level.Warn(logger).Log("err", err)
 	}
+	var names []string
+	for name := range dbConfs {
+		names = append(names, name)
+	}
+	gaugeLabelNames := labelNames(dbConfs)
 	factory := di.NewFactory(func(name string) (di.Pair, error) {
 		var (
-			dialector gorm.Dialector
-			conf      databaseConf
-			ok        bool
-			conn      *gorm.DB
-			cleanup   func()
+			conf    databaseConf
+			ok      bool
+			conn    *gorm.DB
+			cleanup func()
 		)
 		if conf, ok = dbConfs[name]; !ok {
 			return di.Pair{}, confNotFoundErr(fmt.Sprintf("database configuration %s not found", name))
 		}
-		dialector, err = ProvideDialector(&conf)
+		dialector, release, err := provideRefreshableDialector(&conf)
 		if err != nil {
 			return di.Pair{}, err
 		}
-		gormConfig := ProvideGormConfig(logger, &conf)
+		labels := labelValues(conf.Labels)
+		gormConfig := ProvideGormConfig(log.With(logger, logKeyvals(labels)...), &conf)
 		if p.GormConfigInterceptor != nil {
 			p.GormConfigInterceptor(name, gormConfig)
 		}
 		conn, cleanup, err = ProvideGormDB(dialector, gormConfig, p.Tracer)
+		if err != nil && conf.NonCritical {
+			level.Warn(logger).Log("msg", fmt.Sprintf("database %s is unavailable, starting degraded", name), "err", err)
+			conn, cleanup, err = provideDegradedGormDB(dialector, gormConfig, p.Tracer, logger, name)
+		}
+		if err != nil {
+			release()
+			return di.Pair{}, err
+		}
+		closeDB := cleanup
+		cleanup = func() {
+			closeDB()
+			release()
+		}
+		if err = configurePool(conn, &conf); err != nil {
+			cleanup()
+			return di.Pair{}, err
+		}
+		closeReplicas, err := useReplicas(conn, &conf)
 		if err != nil {
+			cleanup()
 			return di.Pair{}, err
 		}
+		closePrimary := cleanup
+		cleanup = func() {
+			closePrimary()
+			closeReplicas()
+		}
+		if p.DefaultScopes != nil {
+			if err = p.DefaultScopes.Install(conn); err != nil {
+				cleanup()
+				return di.Pair{}, err
+			}
+		}
+		if p.QueryCache != nil {
+			if err = p.QueryCache.Install(conn); err != nil {
+				cleanup()
+				return di.Pair{}, err
+			}
+		}
+		if p.SQLCommenter != nil {
+			if err = p.SQLCommenter.Install(conn); err != nil {
+				cleanup()
+				return di.Pair{}, err
+			}
+		}
+		if p.Gauge != nil {
+			interval := time.Duration(conf.StatsIntervalSecond) * time.Second
+			if interval <= 0 {
+				interval = 15 * time.Second
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			go CollectStats(ctx, conn, p.Gauge.With(append([]string{"dbname", name}, gaugeLabels(gaugeLabelNames, conf.Labels)...)...), interval)
+			closeConn := cleanup
+			cleanup = func() {
+				cancel()
+				closeConn()
+			}
+		}
 		return di.Pair{
 			Conn:   conn,
 			Closer: cleanup,
 		}, err
-	})
+	}, di.WithTracer(p.Tracer, "gorm"), di.WithNames(names...), p.Validation.Option(ping))
 	dbFactory := Factory{factory}
 	return dbFactory, dbFactory.Close
 }
@@ -222,7 +349,7 @@ func provideConfig() []config.ExportedConfig {
 		{
 			Owner: "otgorm",
 			Data: map[string]interface{}{
-				"database": map[string]databaseConf{
+				"gorm": map[string]databaseConf{
 					"default": {
 						Database:                                 "mysql",
 						Dsn:                                      "root@tcp(127.0.0.1:3306)/app?charset=utf8mb4&parseTime=True&loc=Local",
@@ -236,6 +363,14 @@ func provideConfig() []config.ExportedConfig {
 						AllowGlobalUpdate:                        false,
 						QueryFields:                              false,
 						CreateBatchSize:                          0,
+						StatsIntervalSecond:                      15,
+						MaxOpenConns:                             0,
+						MaxIdleConns:                             0,
+						ConnMaxLifetimeSecond:                    0,
+						ConnMaxIdleTimeSecond:                    0,
+						Replicas:                                 []string{},
+						NonCritical:                              false,
+						Labels:                                   map[string]string{},
 						NamingStrategy: struct {
 							TablePrefix   string `json:"tablePrefix" yaml:"tablePrefix"`
 							SingularTable bool   `json:"singularTable" yaml:"singularTable"`
@@ -1,10 +1,14 @@
 package otgorm
 
 import (
+	"context"
+	"os"
 	"testing"
 
 	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/di"
 	"github.com/go-kit/kit/log"
+	"github.com/knadh/koanf/providers/confmap"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,3 +35,115 @@ func TestProvideDBFactory(t *testing.T) {
 	assert.NotNil(t, def)
 	cleanup()
 }
+
+func TestProvideDBFactory_env(t *testing.T) {
+	os.Setenv("GORMTEST_GORM_DEFAULT_DATABASE", "sqlite")
+	os.Setenv("GORMTEST_GORM_DEFAULT_DSN", "file::memory:?cache=shared")
+	os.Setenv("GORMTEST_GORM_DEFAULT_SKIPDEFAULTTRANSACTION", "true")
+	defer os.Unsetenv("GORMTEST_GORM_DEFAULT_DATABASE")
+	defer os.Unsetenv("GORMTEST_GORM_DEFAULT_DSN")
+	defer os.Unsetenv("GORMTEST_GORM_DEFAULT_SKIPDEFAULTTRANSACTION")
+
+	conf, err := config.NewConfig(
+		config.WithProviderLayer(config.NewEnvProvider("GORMTEST_"), nil),
+		config.WithProviderLayer(confmap.Provider(map[string]interface{}{
+			"gorm.default.database":               "mysql",
+			"gorm.default.skipDefaultTransaction": false,
+		}, "."), nil),
+	)
+	assert.NoError(t, err)
+	factory, cleanup := provideDBFactory(DatabaseIn{
+		Conf:   conf,
+		Logger: log.NewNopLogger(),
+	})
+	defer cleanup()
+	db, err := factory.Make("default")
+	assert.NoError(t, err)
+	assert.Equal(t, "sqlite", db.Dialector.Name())
+	assert.True(t, db.Config.SkipDefaultTransaction)
+}
+
+func TestProvide_warmup(t *testing.T) {
+	conf := config.MapAdapter{"gorm": map[string]databaseConf{
+		"default":     {Database: "sqlite", Dsn: "file:warmup_default?mode=memory&cache=shared"},
+		"alternative": {Database: "sqlite", Dsn: "file:warmup_alternative?mode=memory&cache=shared"},
+	}}
+	out, cleanup, err := Provide(DatabaseIn{
+		Conf:   conf,
+		Logger: log.NewNopLogger(),
+		Warmup: &di.Warmup{Parallelism: 2, Fatal: true},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, out.Factory.List(), 2)
+	cleanup()
+
+	conf["gorm"].(map[string]databaseConf)["bad"] = databaseConf{Database: "oracle"}
+	_, _, err = Provide(DatabaseIn{
+		Conf:   conf,
+		Logger: log.NewNopLogger(),
+		Warmup: &di.Warmup{Fatal: true},
+	})
+	assert.Error(t, err)
+
+	out, cleanup, err = Provide(DatabaseIn{
+		Conf:   conf,
+		Logger: log.NewNopLogger(),
+		Warmup: &di.Warmup{},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, out.Factory.List(), 2)
+	cleanup()
+}
+
+func TestProvide_healthCheckers(t *testing.T) {
+	out, cleanup, err := Provide(DatabaseIn{
+		Conf: config.MapAdapter{"gorm": map[string]databaseConf{
+			"default": {Database: "sqlite", Dsn: "file:health_default?mode=memory&cache=shared"},
+		}},
+		Logger: log.NewNopLogger(),
+	})
+	assert.NoError(t, err)
+	defer cleanup()
+	assert.Len(t, out.HealthCheckers, 1)
+	assert.Equal(t, "gorm.default", out.HealthCheckers[0].Name())
+	assert.NoError(t, out.HealthCheckers[0].HealthCheck(context.Background()))
+}
+
+func TestProvideGormConfig_createBatchSize(t *testing.T) {
+	conf := ProvideGormConfig(log.NewNopLogger(), &databaseConf{CreateBatchSize: 100})
+	assert.Equal(t, 100, conf.CreateBatchSize)
+	// zero leaves gorm's default in place.
+	conf = ProvideGormConfig(log.NewNopLogger(), &databaseConf{})
+	assert.Equal(t, 0, conf.CreateBatchSize)
+}
+
+func TestProvideDialector(t *testing.T) {
+	cases := []struct {
+		database string
+		dsn      string
+		name     string
+	}{
+		{"mysql", "root@tcp(127.0.0.1:3306)/app", "mysql"},
+		{"sqlite", "file::memory:", "sqlite"},
+		{"postgres", "host=127.0.0.1 port=5432 user=postgres dbname=app", "postgres"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.database, func(t *testing.T) {
+			conf := &databaseConf{Database: c.database, Dsn: c.dsn}
+			dialector, err := ProvideDialector(conf)
+			assert.NoError(t, err)
+			assert.Equal(t, c.name, dialector.Name())
+
+			dialector, release, err := provideRefreshableDialector(conf)
+			assert.NoError(t, err)
+			defer release()
+			assert.Equal(t, c.name, dialector.Name())
+		})
+	}
+
+	_, err := ProvideDialector(&databaseConf{Database: "oracle"})
+	assert.Error(t, err)
+	_, _, err = provideRefreshableDialector(&databaseConf{Database: "oracle"})
+	assert.Error(t, err)
+}
@@ -11,6 +11,21 @@ package otgorm exports the configuration in the following format:
 		database: mysql
 		dsn: root@tcp(127.0.0.1:3306)/app?charset=utf8mb4&parseTime=True&loc=Local
 
+The database is one of mysql, sqlite and postgres. For postgres, the dsn is
+either a URL or a list of key=value pairs, as accepted by pgx.
+
+	gorm:
+	  default:
+	    database: postgres
+	    dsn: host=127.0.0.1 port=5432 user=postgres dbname=app sslmode=disable
+
+Other fields of gorm.Config can be set per entry as well, for example
+createBatchSize to split large batch inserts. Zero values leave the gorm
+defaults in place. See the output of the config init command for the full list.
+
+When config.EnvProvider is in the configuration stack, each entry can be
+overridden by environment variables, for example APP_GORM_DEFAULT_DSN.
+
 Add the gorm dependency to core:
 
 	var c *core.C = core.New()
@@ -30,6 +45,171 @@ Inject otgorm.Maker to factory a *gorm.DB with a specific configuration entry.
 		// do something with client
 	})
 
+Connections are created lazily on first use. To create every configured
+connection concurrently at boot, provide a *di.Warmup. The same dependency is
+also honored by otmongo and otredis, which only fail on a Fatal warm-up when
+provided with their ProvideStrict.
+
+	c.Provide(func() *di.Warmup { return &di.Warmup{Parallelism: 4, Fatal: true} })
+
+To create them on demand instead, for example in a health check, call MakeAll on
+the factory. It returns the connections keyed by name, and the errors of those
+that failed in a di.WarmupErrors.
+
+	conns, err := factory.MakeAll(4)
+
+If an opentracing.Tracer is provided, the creation of each connection is
+reported as a "di.Factory.Make" span, tagged with the component and the name.
+
+Non-critical Databases
+
+By default, a connection that can't be opened fails the factory, and with it
+the startup. A database the application can live without, such as a reporting
+replica, can be marked as nonCritical instead.
+
+	gorm:
+	  reporting:
+	    database: mysql
+	    dsn: root@tcp(127.0.0.1:3306)/reports
+	    nonCritical: true
+
+If it is unavailable, a warning is logged and the *gorm.DB is returned in a
+degraded state. Queries fail until the database is reachable again, and so does
+its health check. The pool dials the database on each query, so no reconnection
+is needed: the connection is only pinged in the background with exponential
+backoff, to log a message once it recovers. Version detection of mysql is
+skipped until the application is restarted.
+
+Pool Stats
+
+To monitor the utilization of the connection pools, inject a gauge into the
+core and alias it to otgorm.Gauge. The open, in use and idle connections, as
+well as the wait count and duration, of each connection are reported every
+statsIntervalSecond, 15 by default.
+
+	c.Provide(func() otgorm.Gauge {
+		return prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Name: "gorm_pool",
+			Help: "The connection pool stats of gorm",
+		}, []string{"dbname", "stat"})
+	})
+
+A *gorm.DB created outside of the factory can be monitored with CollectStats,
+which blocks until its context is canceled.
+
+	g.Add(func() error {
+		otgorm.CollectStats(ctx, db, gauge.With("dbname", "legacy"), 15*time.Second)
+		return nil
+	}, func(err error) {
+		cancel()
+	})
+
+The labels of a connection, such as its region, are added to its metrics and
+log lines. Declare them on the gauge too. The stats of every connection carry
+the labels of all connections, empty where a connection doesn't set them, so
+that the label names of the gauge are the same for all.
+
+	gorm:
+	  default:
+	    labels:
+	      region: eu
+
+Connection Pool
+
+The pool of the *sql.DB underlying each connection can be tuned with
+maxOpenConns, maxIdleConns, connMaxLifetimeSecond and connMaxIdleTimeSecond.
+Zero values keep the defaults of database/sql.
+
+	gorm:
+	  default:
+	    maxOpenConns: 50
+	    maxIdleConns: 10
+	    connMaxLifetimeSecond: 3600
+	    connMaxIdleTimeSecond: 300
+
+Read Replicas
+
+List the DSNs of read replicas under replicas to route reads to them with the
+dbresolver plugin of gorm. Writes, and reads inside transactions, go to the
+primary dsn. The replicas share the pool settings of the entry, and the spans of
+their queries are reported like those of the primary. RefreshCredentials only
+applies to the primary.
+
+	gorm:
+	  default:
+	    database: mysql
+	    dsn: root@tcp(primary:3306)/app
+	    replicas:
+	      - root@tcp(replica-1:3306)/app
+	      - root@tcp(replica-2:3306)/app
+
+To read your own writes, force a query to the primary with dbresolver.Write.
+
+	db.Clauses(dbresolver.Write).First(&user)
+
+Credentials Rotation
+
+Short-lived credentials, such as IAM authentication tokens, can be rotated
+without downtime. RefreshCredentials replaces the DSN used by new connections of
+a *gorm.DB created by the factory. Existing connections are not interrupted.
+
+	err := otgorm.RefreshCredentials(db, func() (string, error) {
+		return dsnWithFreshToken()
+	})
+
+Default Scopes
+
+Some filters, such as tenant isolation, should apply to every query of a model.
+Register them in an *otgorm.DefaultScopes and provide it to the core. The
+scopes are installed on each *gorm.DB created by the factory.
+
+	scopes := otgorm.NewDefaultScopes()
+	scopes.Add(&User{}, func(db *gorm.DB) *gorm.DB {
+		return db.Where("users.tenant_id = ?", tenantID)
+	})
+	c.Provide(func() *otgorm.DefaultScopes { return scopes })
+
+Default scopes apply to queries, updates and deletes of the primary model
+only. Joined tables, Raw and Exec are not scoped. Use Unscoped to bypass them.
+
+Query Cache
+
+Results of hot, rarely changing queries can be cached by providing an
+*otgorm.QueryCache. Caching is opt-in per query with the Cached scope.
+
+	c.Provide(func(client redis.UniversalClient) *otgorm.QueryCache {
+		return &otgorm.QueryCache{Store: otgorm.RedisCacheStore{Client: client}, TTL: time.Minute}
+	})
+	db.Scopes(otgorm.Cached(0)).Find(&countries)
+
+Cached results of a table are invalidated when the table is written by Create,
+Update or Delete on the same *gorm.DB. Writes by Exec, other *gorm.DB or other
+processes are invisible to the cache. Call QueryCache.Invalidate after them,
+or keep the TTL short.
+
+SQL Comments
+
+To attribute slow queries to requests on the database side, provide an
+*otgorm.SQLCommenter. Every statement then carries a sqlcommenter style comment
+with the static tags and the traceparent of the current span.
+
+	c.Provide(func() *otgorm.SQLCommenter {
+		return &otgorm.SQLCommenter{Tags: map[string]string{"application": "orders"}}
+	})
+
+With PrepareStmt, only the static tags are appended, so that prepared
+statements are still reused across requests.
+
+Dead Letters
+
+Queued jobs that finally fail can be archived in a database with an
+otgorm.DeadLetterStore, where they can be queried long after the failure.
+Migrate the otgorm.DeadLetter model, and provide the store to the core.
+
+	c.Provide(func(db *gorm.DB) queue.DeadLetterStore {
+		return otgorm.DeadLetterStore{DB: db}
+	})
+
 Migration and Seeding
 
 package otgorm comes with migration and seeding support. Other modules can
@@ -44,6 +224,35 @@ Then you can migrate the database by running:
 
 	go run main.go database migrate
 
+To check what a deployment will run, list the pending migrations without
+running them. The --to flag applies the migrations step by step, up to and
+including the given ID. Migrations.Status reports the same information
+programmatically.
+
+	go run main.go database migrate --dry-run
+	go run main.go database migrate --to 202101011000
+
+Roll back the last migration with --rollback, down to a given ID with
+--rollback=ID, or every migration with --rollback=0, for example to reset a
+test database.
+
+	go run main.go database migrate --rollback=0
+
+Modules owning their migrations as named sets implement MigrationSetProvider
+instead. The migrations of all sets are merged into a single run, sorted by ID.
+Conflicting IDs are reported with the names of the sets.
+
+	func (m billing) ProvideMigrationSets() []otgorm.MigrationSet {
+		return []otgorm.MigrationSet{{Name: "billing", Migrations: migrations}}
+	}
+
+Migrations that only make sense in some environments, such as dev-only
+indexes, carry an Env predicate. In other environments they are skipped, but
+still recorded in the migrations table, so that they never run against that
+database later. Delete the record to run such a migration after all.
+
+	&otgorm.Migration{ID: "202101010000", Migrate: addDebugIndex, Env: contract.Env.IsDevelopment}
+
 See examples to learn more.
 */
 package otgorm
@@ -0,0 +1,54 @@
+package otgorm
+
+import "sort"
+
+// labelValues flattens the labels of a connection into alternating keys and values, sorted by key, as expected by
+// metrics.Gauge.With.
+func labelValues(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]string, 0, 2*len(labels))
+	for _, k := range keys {
+		values = append(values, k, labels[k])
+	}
+	return values
+}
+
+// logKeyvals converts the label values to log.With arguments.
+func logKeyvals(values []string) []interface{} {
+	keyvals := make([]interface{}, len(values))
+	for i, v := range values {
+		keyvals[i] = v
+	}
+	return keyvals
+}
+
+// labelNames returns the sorted union of the label names of the connections. The stats of every connection are
+// reported with all of them, as Prometheus rejects a metric whose label names vary.
+func labelNames(confs map[string]databaseConf) []string {
+	seen := make(map[string]struct{})
+	for _, conf := range confs {
+		for k := range conf.Labels {
+			seen[k] = struct{}{}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for k := range seen {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// gaugeLabels flattens the labels of a connection like labelValues, but for the given names. Missing labels are
+// empty.
+func gaugeLabels(names []string, labels map[string]string) []string {
+	values := make([]string, 0, 2*len(names))
+	for _, k := range names {
+		values = append(values, k, labels[k])
+	}
+	return values
+}
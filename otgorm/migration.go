@@ -1,6 +1,11 @@
 package otgorm
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/DoNewsCode/core/contract"
 	"github.com/go-gormigrate/gormigrate/v2"
 
 	"gorm.io/gorm"
@@ -22,38 +27,237 @@ type Migration struct {
 	Migrate MigrateFunc
 	// Rollback will be executed on rollback. Can be nil.
 	Rollback RollbackFunc
+	// Env reports whether the migration applies to the environment, like
+	// contract.Env.IsDevelopment. Can be nil, in which case the migration
+	// applies to all environments.
+	Env func(env contract.Env) bool
+}
+
+// MigrationSet is a named group of migrations, typically owned by a feature
+// module. The name is reported in errors of its migrations.
+type MigrationSet struct {
+	Name       string
+	Migrations []*Migration
 }
 
 // Migrations is a collection of migrations in the application.
+//
+// Migrations in Collection run in the order of the slice. If Sets are present,
+// the migrations of Collection and of all Sets are merged into a single run,
+// sorted by ID. An ID must be unique across all of them.
+//
+// If Env is set, migrations that do not apply to it are skipped: they are
+// recorded in the migrations table as if they had run, without executing
+// anything. A skipped migration is therefore never executed later against the
+// same database, even by a run in an environment it applies to. Rolling back a
+// skipped migration only removes its record.
 type Migrations struct {
 	Db         *gorm.DB
 	Collection []*Migration
+	Sets       []MigrationSet
+	Env        contract.Env
 }
 
-func convert(old []*Migration) []*gormigrate.Migration {
+func (m Migrations) convert(old []*Migration) []*gormigrate.Migration {
 	var out []*gormigrate.Migration
-	for _, m := range old {
-		out = append(out, &gormigrate.Migration{
-			ID:       m.ID,
-			Migrate:  gormigrate.MigrateFunc(m.Migrate),
-			Rollback: gormigrate.RollbackFunc(m.Rollback),
-		})
+	for _, migration := range old {
+		out = append(out, m.skip(migration, &gormigrate.Migration{
+			ID:       migration.ID,
+			Migrate:  gormigrate.MigrateFunc(migration.Migrate),
+			Rollback: gormigrate.RollbackFunc(migration.Rollback),
+		}))
+	}
+	return out
+}
+
+// Skipped returns the IDs of the migrations that do not apply to Env.
+func (m Migrations) Skipped() []string {
+	var ids []string
+	for _, migration := range m.all() {
+		if !m.applies(migration) {
+			ids = append(ids, migration.ID)
+		}
+	}
+	return ids
+}
+
+func (m Migrations) all() []*Migration {
+	all := append([]*Migration(nil), m.Collection...)
+	for _, set := range m.Sets {
+		all = append(all, set.Migrations...)
+	}
+	return all
+}
+
+func (m Migrations) applies(migration *Migration) bool {
+	return m.Env == nil || migration.Env == nil || migration.Env(m.Env)
+}
+
+// skip replaces the functions of the converted migration with no-ops if the
+// migration does not apply to Env.
+func (m Migrations) skip(migration *Migration, out *gormigrate.Migration) *gormigrate.Migration {
+	if m.applies(migration) {
+		return out
+	}
+	out.Migrate = func(*gorm.DB) error { return nil }
+	out.Rollback = func(*gorm.DB) error { return nil }
+	return out
+}
+
+// merge merges the Collection and the Sets into a single run.
+func (m Migrations) merge() ([]*gormigrate.Migration, error) {
+	if len(m.Sets) == 0 {
+		return m.convert(m.Collection), nil
+	}
+	var (
+		out    []*gormigrate.Migration
+		owners = make(map[string]string)
+	)
+	add := func(set string, migrations []*Migration) error {
+		for _, migration := range migrations {
+			if owner, ok := owners[migration.ID]; ok {
+				return fmt.Errorf("migration %s is defined in both %s and %s", migration.ID, owner, set)
+			}
+			owners[migration.ID] = set
+			out = append(out, m.skip(migration, withProvenance(set, migration)))
+		}
+		return nil
+	}
+	if err := add("the collection", m.Collection); err != nil {
+		return nil, err
+	}
+	for _, set := range m.Sets {
+		if err := add(fmt.Sprintf("set %q", set.Name), set.Migrations); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+// withProvenance converts the migration, adding the owner to its errors.
+func withProvenance(owner string, m *Migration) *gormigrate.Migration {
+	out := &gormigrate.Migration{ID: m.ID}
+	if m.Migrate != nil {
+		out.Migrate = func(db *gorm.DB) error {
+			if err := m.Migrate(db); err != nil {
+				return fmt.Errorf("migration %s of %s: %w", m.ID, owner, err)
+			}
+			return nil
+		}
+	}
+	if m.Rollback != nil {
+		out.Rollback = func(db *gorm.DB) error {
+			if err := m.Rollback(db); err != nil {
+				return fmt.Errorf("rollback %s of %s: %w", m.ID, owner, err)
+			}
+			return nil
+		}
 	}
 	return out
 }
 
+// MigrationStatus reports whether a migration has been applied to the
+// database.
+type MigrationStatus struct {
+	ID      string
+	Applied bool
+}
+
+// Status returns the status of each migration, in the order they run, by
+// querying the migrations table. Skipped migrations count as applied once they
+// are recorded.
+func (m Migrations) Status() ([]MigrationStatus, error) {
+	migrations, err := m.merge()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[string]bool)
+	table := gormigrate.DefaultOptions.TableName
+	if m.Db.Migrator().HasTable(table) {
+		var ids []string
+		if err := m.Db.Table(table).Pluck(gormigrate.DefaultOptions.IDColumnName, &ids).Error; err != nil {
+			return nil, fmt.Errorf("unable to query the migrations table: %w", err)
+		}
+		for _, id := range ids {
+			applied[id] = true
+		}
+	}
+	status := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		status[i] = MigrationStatus{ID: migration.ID, Applied: applied[migration.ID]}
+	}
+	return status, nil
+}
+
 // Migrate migrates all migrations registered in the application
 func (m Migrations) Migrate() error {
-	migration := gormigrate.New(m.Db, &gormigrate.Options{}, convert(m.Collection))
+	migrations, err := m.merge()
+	if err != nil {
+		return err
+	}
+	migration := gormigrate.New(m.Db, &gormigrate.Options{}, migrations)
 	return migration.Migrate()
 }
 
-// Rollback rollbacks migrations to a specified ID. If that id is -1, the last migration
-// is rolled back.
+// MigrateTo migrates the migrations up to and including the one with the
+// specified ID.
+func (m Migrations) MigrateTo(id string) error {
+	migrations, err := m.merge()
+	if err != nil {
+		return err
+	}
+	migration := gormigrate.New(m.Db, &gormigrate.Options{}, migrations)
+	return migration.MigrateTo(id)
+}
+
+const (
+	// RollbackLast is the ID passed to Rollback to roll back the last
+	// migration.
+	RollbackLast = "-1"
+	// RollbackAll is the ID passed to Rollback to roll back every migration.
+	RollbackAll = "0"
+)
+
+// Rollback rollbacks migrations to a specified ID. If that id is RollbackLast,
+// the last migration is rolled back. If it is RollbackAll, every migration is
+// rolled back, see Migrations.RollbackAll.
 func (m Migrations) Rollback(id string) error {
-	migration := gormigrate.New(m.Db, &gormigrate.Options{}, convert(m.Collection))
-	if id == "-1" {
+	if id == RollbackAll {
+		return m.RollbackAll()
+	}
+	migrations, err := m.merge()
+	if err != nil {
+		return err
+	}
+	migration := gormigrate.New(m.Db, &gormigrate.Options{}, migrations)
+	if id == RollbackLast {
 		return migration.RollbackLast()
 	}
 	return migration.RollbackTo(id)
 }
+
+// RollbackAll rolls back the applied migrations one by one, last first, until
+// none is left in the migrations table, for example to reset a test database.
+// It stops at the first error, such as a migration without a Rollback.
+func (m Migrations) RollbackAll() error {
+	migrations, err := m.merge()
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 || !m.Db.Migrator().HasTable(gormigrate.DefaultOptions.TableName) {
+		return nil
+	}
+	migration := gormigrate.New(m.Db, &gormigrate.Options{}, migrations)
+	for {
+		err := migration.RollbackLast()
+		if errors.Is(err, gormigrate.ErrNoRunMigration) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package otgorm
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/contract"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMigrations_Sets(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sets.db")), &gorm.Config{})
+	assert.NoError(t, err)
+
+	var ran []string
+	migration := func(id string) *Migration {
+		return &Migration{ID: id, Migrate: func(db *gorm.DB) error {
+			ran = append(ran, id)
+			return nil
+		}}
+	}
+	migrations := Migrations{
+		Db:         db,
+		Collection: []*Migration{migration("3")},
+		Sets: []MigrationSet{
+			{Name: "users", Migrations: []*Migration{migration("1"), migration("4")}},
+			{Name: "billing", Migrations: []*Migration{migration("2")}},
+		},
+	}
+	assert.NoError(t, migrations.Migrate())
+	assert.Equal(t, []string{"1", "2", "3", "4"}, ran)
+}
+
+func TestMigrations_Sets_conflict(t *testing.T) {
+	migrations := Migrations{
+		Sets: []MigrationSet{
+			{Name: "users", Migrations: []*Migration{{ID: "1"}}},
+			{Name: "billing", Migrations: []*Migration{{ID: "1"}}},
+		},
+	}
+	err := migrations.Migrate()
+	assert.EqualError(t, err, `migration 1 is defined in both set "users" and set "billing"`)
+}
+
+func TestMigrations_Sets_provenance(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "provenance.db")), &gorm.Config{})
+	assert.NoError(t, err)
+	migrations := Migrations{
+		Db: db,
+		Sets: []MigrationSet{
+			{Name: "billing", Migrations: []*Migration{{ID: "1", Migrate: func(db *gorm.DB) error {
+				return errors.New("bad column")
+			}}}},
+		},
+	}
+	err = migrations.Migrate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `migration 1 of set "billing": bad column`)
+}
+
+func TestMigrations_Env(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "env.db")), &gorm.Config{})
+	assert.NoError(t, err)
+
+	var ran []string
+	migration := func(id string, env func(contract.Env) bool) *Migration {
+		return &Migration{ID: id, Env: env, Migrate: func(db *gorm.DB) error {
+			ran = append(ran, id)
+			return nil
+		}}
+	}
+	collection := []*Migration{
+		migration("1", nil),
+		migration("2", contract.Env.IsDevelopment),
+		migration("3", contract.Env.IsProduction),
+	}
+
+	production := Migrations{Db: db, Collection: collection, Env: config.NewEnv("production")}
+	assert.NoError(t, production.Migrate())
+	assert.Equal(t, []string{"1", "3"}, ran)
+	assert.Equal(t, []string{"2"}, production.Skipped())
+
+	// the skipped migration is recorded, so it does not run in another environment later.
+	development := Migrations{Db: db, Collection: collection, Env: config.NewEnv("development")}
+	assert.NoError(t, development.Migrate())
+	assert.Equal(t, []string{"1", "3"}, ran)
+}
+
+func TestMigrations_Status(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "status.db")), &gorm.Config{})
+	assert.NoError(t, err)
+
+	var ran []string
+	migration := func(id string) *Migration {
+		return &Migration{ID: id, Migrate: func(db *gorm.DB) error {
+			ran = append(ran, id)
+			return nil
+		}}
+	}
+	migrations := Migrations{
+		Db:         db,
+		Collection: []*Migration{migration("1"), migration("2"), migration("3")},
+	}
+
+	status, err := migrations.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, []MigrationStatus{{"1", false}, {"2", false}, {"3", false}}, status)
+
+	assert.NoError(t, migrations.MigrateTo("2"))
+	assert.Equal(t, []string{"1", "2"}, ran)
+
+	status, err = migrations.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, []MigrationStatus{{"1", true}, {"2", true}, {"3", false}}, status)
+
+	pending, err := pendingMigrations(migrations, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"3"}, pending)
+	_, err = pendingMigrations(migrations, "4")
+	assert.Error(t, err)
+}
+
+func TestMigrations_RollbackAll(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:rollback_all?mode=memory&cache=shared"), &gorm.Config{})
+	assert.NoError(t, err)
+
+	var rolledBack []string
+	migration := func(id string) *Migration {
+		return &Migration{
+			ID:      id,
+			Migrate: func(db *gorm.DB) error { return nil },
+			Rollback: func(db *gorm.DB) error {
+				rolledBack = append(rolledBack, id)
+				return nil
+			},
+		}
+	}
+	migrations := Migrations{
+		Db:         db,
+		Collection: []*Migration{migration("1"), migration("2"), migration("3")},
+	}
+
+	// Nothing to roll back before the migrations table exists.
+	assert.NoError(t, migrations.RollbackAll())
+
+	assert.NoError(t, migrations.Migrate())
+	assert.NoError(t, migrations.Rollback(RollbackAll))
+	assert.Equal(t, []string{"3", "2", "1"}, rolledBack)
+
+	var count int64
+	assert.NoError(t, db.Table("migrations").Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestMigrations_RollbackAll_error(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:rollback_all_error?mode=memory&cache=shared"), &gorm.Config{})
+	assert.NoError(t, err)
+
+	migrations := Migrations{
+		Db: db,
+		Collection: []*Migration{
+			{ID: "1", Migrate: func(db *gorm.DB) error { return nil }},
+			{ID: "2", Migrate: func(db *gorm.DB) error { return nil }, Rollback: func(db *gorm.DB) error { return nil }},
+		},
+	}
+	assert.NoError(t, migrations.Migrate())
+	assert.Error(t, migrations.RollbackAll())
+
+	status, err := migrations.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, []MigrationStatus{{"1", true}, {"2", false}}, status)
+}
@@ -16,6 +16,14 @@ type MigrationProvider interface {
 	ProvideMigration() []*Migration
 }
 
+// MigrationSetProvider is an interface for named sets of database
+// migrations. Modules implementing this interface own their migrations as
+// sets. The sets are merged with other migrations, sorted by ID, in migrate
+// command.
+type MigrationSetProvider interface {
+	ProvideMigrationSets() []MigrationSet
+}
+
 // SeedProvider is an interface for database seeding. modules
 // implementing this interface are seed providers. seeds will be
 // collected in seed command.
@@ -45,7 +53,9 @@ func New(make Maker, env contract.Env, logger log.Logger, container contract.Con
 func (m Module) ProvideCommand(command *cobra.Command) {
 	var (
 		force      bool
+		dryRun     bool
 		rollbackId string
+		targetId   string
 		logger     = logging.WithLevel(m.logger)
 	)
 	var migrateCmd = &cobra.Command{
@@ -58,13 +68,22 @@ func (m Module) ProvideCommand(command *cobra.Command) {
 				connection = args[0]
 			}
 
+			migrations := m.collectMigrations(connection)
+
+			if dryRun {
+				pending, err := pendingMigrations(migrations, targetId)
+				if err != nil {
+					return fmt.Errorf("unable to list pending migrations: %w", err)
+				}
+				logger.Infof("pending migrations: %v", pending)
+				return nil
+			}
+
 			if m.env.IsProduction() && !force {
 				e := fmt.Errorf("migrations and rollback in production requires force flag to be set")
 				return e
 			}
 
-			migrations := m.collectMigrations(connection)
-
 			if rollbackId != "" {
 				if err := migrations.Rollback(rollbackId); err != nil {
 					return fmt.Errorf("unable to rollback: %w", err)
@@ -74,17 +93,26 @@ func (m Module) ProvideCommand(command *cobra.Command) {
 				return nil
 			}
 
-			if err := migrations.Migrate(); err != nil {
+			if targetId != "" {
+				if err := migrations.MigrateTo(targetId); err != nil {
+					return fmt.Errorf("unable to migrate: %w", err)
+				}
+			} else if err := migrations.Migrate(); err != nil {
 				return fmt.Errorf("unable to migrate: %w", err)
 			}
+			if skipped := migrations.Skipped(); len(skipped) > 0 {
+				logger.Infof("migrations not applicable to %s are recorded without running: %v", m.env, skipped)
+			}
 
 			logger.Info("migration successfully completed")
 			return nil
 		},
 	}
 	migrateCmd.Flags().BoolVarP(&force, "force", "f", false, "migrations and rollback in production requires force flag to be set")
-	migrateCmd.Flags().StringVarP(&rollbackId, "rollback", "r", "", "rollback to the given migration id")
-	migrateCmd.Flag("rollback").NoOptDefVal = "-1"
+	migrateCmd.Flags().StringVarP(&rollbackId, "rollback", "r", "", "rollback to the given migration id, or all migrations if it is 0")
+	migrateCmd.Flag("rollback").NoOptDefVal = RollbackLast
+	migrateCmd.Flags().StringVarP(&targetId, "to", "t", "", "migrate up to and including the given migration id")
+	migrateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "list the pending migrations without running them")
 
 	var seedCmd = &cobra.Command{
 		Use:   "seed [database]",
@@ -122,11 +150,33 @@ func (m Module) ProvideCommand(command *cobra.Command) {
 	command.AddCommand(databaseCmd)
 }
 
+// pendingMigrations returns the IDs of the migrations not applied yet, up to
+// and including targetId if it is not empty.
+func pendingMigrations(migrations Migrations, targetId string) ([]string, error) {
+	status, err := migrations.Status()
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	for _, s := range status {
+		if !s.Applied {
+			pending = append(pending, s.ID)
+		}
+		if s.ID == targetId {
+			return pending, nil
+		}
+	}
+	if targetId != "" {
+		return nil, fmt.Errorf("migration %s not found", targetId)
+	}
+	return pending, nil
+}
+
 func (m Module) collectMigrations(connection string) Migrations {
 	if connection == "" {
 		connection = "default"
 	}
-	var migrations Migrations
+	var migrations = Migrations{Env: m.env}
 	m.container.Modules().Filter(func(p MigrationProvider) {
 		for _, migration := range p.ProvideMigration() {
 			if migration.Connection == "" {
@@ -137,6 +187,22 @@ func (m Module) collectMigrations(connection string) Migrations {
 			}
 		}
 	})
+	m.container.Modules().Filter(func(p MigrationSetProvider) {
+		for _, set := range p.ProvideMigrationSets() {
+			filtered := MigrationSet{Name: set.Name}
+			for _, migration := range set.Migrations {
+				if migration.Connection == "" {
+					migration.Connection = "default"
+				}
+				if migration.Connection == connection {
+					filtered.Migrations = append(filtered.Migrations, migration)
+				}
+			}
+			if len(filtered.Migrations) > 0 {
+				migrations.Sets = append(migrations.Sets, filtered)
+			}
+		}
+	})
 	migrations.Db, _ = m.maker.Make(connection)
 	return migrations
 }
@@ -0,0 +1,35 @@
+package otgorm
+
+import (
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// configurePool applies the pool settings of the configuration to the *sql.DB
+// underlying db. Zero values leave the defaults of database/sql in place.
+func configurePool(db *gorm.DB, conf *databaseConf) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	setPool(sqlDB, conf)
+	return nil
+}
+
+// setPool applies the pool settings of the configuration to sqlDB.
+func setPool(sqlDB *sql.DB, conf *databaseConf) {
+	if conf.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(conf.MaxOpenConns)
+	}
+	if conf.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(conf.MaxIdleConns)
+	}
+	if conf.ConnMaxLifetimeSecond > 0 {
+		sqlDB.SetConnMaxLifetime(time.Duration(conf.ConnMaxLifetimeSecond) * time.Second)
+	}
+	if conf.ConnMaxIdleTimeSecond > 0 {
+		sqlDB.SetConnMaxIdleTime(time.Duration(conf.ConnMaxIdleTimeSecond) * time.Second)
+	}
+}
@@ -0,0 +1,54 @@
+package otgorm
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvideDBFactory_pool(t *testing.T) {
+	factory, cleanup := provideDBFactory(DatabaseIn{
+		Conf: config.MapAdapter{"gorm": map[string]databaseConf{
+			"default": {
+				Database:              "sqlite",
+				Dsn:                   filepath.Join(t.TempDir(), "pool.db"),
+				MaxOpenConns:          3,
+				MaxIdleConns:          1,
+				ConnMaxLifetimeSecond: 60,
+				ConnMaxIdleTimeSecond: 60,
+			},
+			"unset": {Database: "sqlite", Dsn: filepath.Join(t.TempDir(), "unset.db")},
+		}},
+		Logger: log.NewNopLogger(),
+	})
+	defer cleanup()
+
+	db, err := factory.Make("default")
+	assert.NoError(t, err)
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, sqlDB.Stats().MaxOpenConnections)
+
+	// Hold three connections, then release them: only one is kept idle.
+	var conns []*sql.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := sqlDB.Conn(context.Background())
+		assert.NoError(t, err)
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	assert.Equal(t, 1, sqlDB.Stats().Idle)
+
+	db, err = factory.Make("unset")
+	assert.NoError(t, err)
+	sqlDB, err = db.DB()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sqlDB.Stats().MaxOpenConnections)
+}
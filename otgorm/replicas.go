@@ -0,0 +1,46 @@
+package otgorm
+
+import (
+	"database/sql"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// useReplicas routes the reads of db to the replicas of the configuration, if
+// any, with the dbresolver plugin of gorm. The primary keeps serving the
+// writes. The pools of the replicas share the settings of the primary. The
+// returned function closes them.
+func useReplicas(db *gorm.DB, conf *databaseConf) (func(), error) {
+	if len(conf.Replicas) == 0 {
+		return func() {}, nil
+	}
+	driverName, err := driverNameOf(conf.Database)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		pools     []*sql.DB
+		replicas  []gorm.Dialector
+		closePools = func() {
+			for _, pool := range pools {
+				_ = pool.Close()
+			}
+		}
+	)
+	for _, dsn := range conf.Replicas {
+		pool, err := sql.Open(driverName, dsn)
+		if err != nil {
+			closePools()
+			return nil, err
+		}
+		setPool(pool, conf)
+		pools = append(pools, pool)
+		replicas = append(replicas, dialectorWithConn(conf.Database, dsn, pool))
+	}
+	if err := db.Use(dbresolver.Register(dbresolver.Config{Replicas: replicas})); err != nil {
+		closePools()
+		return nil, err
+	}
+	return closePools, nil
+}
@@ -0,0 +1,55 @@
+package otgorm
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/go-kit/kit/log"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+func TestProvideDBFactory_replicas(t *testing.T) {
+	seed := func(dsn, name string) {
+		db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+		assert.NoError(t, err)
+		assert.NoError(t, db.Exec("CREATE TABLE users (name TEXT)").Error)
+		assert.NoError(t, db.Exec("INSERT INTO users (name) VALUES (?)", name).Error)
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}
+	primary := filepath.Join(t.TempDir(), "primary.db")
+	replica := filepath.Join(t.TempDir(), "replica.db")
+	seed(primary, "primary")
+	seed(replica, "replica")
+
+	tracer := mocktracer.New()
+	factory, cleanup := provideDBFactory(DatabaseIn{
+		Conf: config.MapAdapter{"gorm": map[string]databaseConf{
+			"default": {Database: "sqlite", Dsn: primary, Replicas: []string{replica}},
+		}},
+		Logger: log.NewNopLogger(),
+		Tracer: tracer,
+	})
+	defer cleanup()
+	db, err := factory.Make("default")
+	assert.NoError(t, err)
+	tracer.Reset()
+
+	var names []string
+	assert.NoError(t, db.Table("users").Pluck("name", &names).Error)
+	assert.Equal(t, []string{"replica"}, names)
+	assert.Len(t, tracer.FinishedSpans(), 1)
+
+	assert.NoError(t, db.Clauses(dbresolver.Write).Table("users").Pluck("name", &names).Error)
+	assert.Equal(t, []string{"primary"}, names)
+	assert.Len(t, tracer.FinishedSpans(), 2)
+
+	assert.NoError(t, db.Exec("INSERT INTO users (name) VALUES (?)", "written").Error)
+	assert.NoError(t, db.Clauses(dbresolver.Write).Table("users").Where("name = ?", "written").Pluck("name", &names).Error)
+	assert.Equal(t, []string{"written"}, names)
+}
@@ -0,0 +1,89 @@
+package otgorm
+
+import (
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Scope is a gorm scope, as accepted by (*gorm.DB).Scopes.
+type Scope func(db *gorm.DB) *gorm.DB
+
+// DefaultScopes is a registry of scopes that are always applied to queries,
+// updates and deletes of a given model. It is useful for filters that must
+// never be forgotten, such as tenant isolation.
+//
+//  scopes := otgorm.NewDefaultScopes()
+//  scopes.Add(&User{}, func(db *gorm.DB) *gorm.DB {
+//    return db.Where("users.tenant_id = ?", tenantFrom(db.Statement.Context))
+//  })
+//
+// To use it with Provide, add the *DefaultScopes to the core. It will be
+// installed on every *gorm.DB created by the Factory.
+//
+//  c.Provide(func() *otgorm.DefaultScopes { return scopes })
+//
+// The scopes are matched against the model of the statement, ie. the argument
+// of Model, or the destination if Model is not called. A few caveats:
+//
+// Joined tables are not scoped: only the scopes of the primary model are
+// applied. Qualify the columns with table names in scopes to avoid ambiguity
+// with joins.
+//
+// Raw and Exec are not scoped, as the SQL is written by hand.
+//
+// Unscoped skips the default scopes, together with the soft delete filter.
+type DefaultScopes struct {
+	mu     sync.RWMutex
+	scopes map[reflect.Type][]Scope
+}
+
+// NewDefaultScopes creates an empty *DefaultScopes.
+func NewDefaultScopes() *DefaultScopes {
+	return &DefaultScopes{scopes: make(map[reflect.Type][]Scope)}
+}
+
+// Add registers a scope for the model. Model can be a struct, a pointer to
+// struct or a slice of them. Multiple scopes can be registered for the same
+// model.
+func (d *DefaultScopes) Add(model interface{}, scope Scope) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t := modelType(reflect.TypeOf(model))
+	d.scopes[t] = append(d.scopes[t], scope)
+}
+
+// Install registers the gorm callbacks that apply the scopes on db.
+func (d *DefaultScopes) Install(db *gorm.DB) error {
+	const name = "otgorm:default_scopes"
+	if err := db.Callback().Query().Before("gorm:query").Register(name, d.apply); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register(name, d.apply); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register(name, d.apply); err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:delete").Register(name, d.apply)
+}
+
+func (d *DefaultScopes) apply(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Unscoped || db.Statement.Schema == nil || db.Statement.SQL.Len() > 0 {
+		return
+	}
+	d.mu.RLock()
+	scopes := d.scopes[db.Statement.Schema.ModelType]
+	d.mu.RUnlock()
+	for _, scope := range scopes {
+		scope(db)
+	}
+}
+
+func modelType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,68 @@
+package otgorm
+
+import (
+	"testing"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type scopedUser struct {
+	ID       int
+	TenantID int
+	Name     string
+}
+
+func TestDefaultScopes(t *testing.T) {
+	scopes := NewDefaultScopes()
+	scopes.Add(&scopedUser{}, func(db *gorm.DB) *gorm.DB {
+		return db.Where("scoped_users.tenant_id = ?", 1)
+	})
+	factory, cleanup := provideDBFactory(DatabaseIn{
+		Conf: config.MapAdapter{"gorm": map[string]databaseConf{
+			"default": {
+				Database: "sqlite",
+				Dsn:      "file:default_scopes?mode=memory&cache=shared",
+			},
+		}},
+		Logger:        log.NewNopLogger(),
+		DefaultScopes: scopes,
+	})
+	defer cleanup()
+	db, err := factory.Make("default")
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&scopedUser{}))
+	assert.NoError(t, db.Create(&[]scopedUser{
+		{ID: 1, TenantID: 1, Name: "foo"},
+		{ID: 2, TenantID: 2, Name: "bar"},
+	}).Error)
+
+	var users []scopedUser
+	assert.NoError(t, db.Find(&users).Error)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "foo", users[0].Name)
+
+	var count int64
+	assert.NoError(t, db.Model(&scopedUser{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	assert.NoError(t, db.Unscoped().Find(&users).Error)
+	assert.Len(t, users, 2)
+
+	assert.NoError(t, db.Raw("SELECT * FROM scoped_users").Scan(&users).Error)
+	assert.Len(t, users, 2)
+
+	result := db.Model(&scopedUser{}).Where("1 = 1").Update("name", "baz")
+	assert.NoError(t, result.Error)
+	assert.Equal(t, int64(1), result.RowsAffected)
+
+	result = db.Where("1 = 1").Delete(&scopedUser{})
+	assert.NoError(t, result.Error)
+	assert.Equal(t, int64(1), result.RowsAffected)
+
+	assert.NoError(t, db.Unscoped().Find(&users).Error)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "bar", users[0].Name)
+}
@@ -0,0 +1,43 @@
+package otgorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"gorm.io/gorm"
+)
+
+// Gauge is the metrics.Gauge type for the connection pool stats of *gorm.DB,
+// used for dependency injection. If provided, the pool stats of each
+// connection are reported periodically, labeled by "dbname" and "stat". The
+// stats are "open", "in_use", "idle", "wait_count" and
+// "wait_duration_seconds".
+type Gauge metrics.Gauge
+
+// CollectStats reports the pool stats of db to the gauge every interval, until
+// the context is canceled. The *gorm.DB created by the factory are collected
+// automatically when a Gauge is provided. Run it, for example in a run group,
+// to collect the stats of a *gorm.DB created otherwise. Label the gauge with
+// the name of the connection first.
+func CollectStats(ctx context.Context, db *gorm.DB, gauge metrics.Gauge, interval time.Duration) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		stats := sqlDB.Stats()
+		gauge.With("stat", "open").Set(float64(stats.OpenConnections))
+		gauge.With("stat", "in_use").Set(float64(stats.InUse))
+		gauge.With("stat", "idle").Set(float64(stats.Idle))
+		gauge.With("stat", "wait_count").Set(float64(stats.WaitCount))
+		gauge.With("stat", "wait_duration_seconds").Set(stats.WaitDuration.Seconds())
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
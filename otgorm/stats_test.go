@@ -0,0 +1,130 @@
+package otgorm
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingGauge struct {
+	mu     *sync.Mutex
+	labels []string
+	values map[string]float64
+}
+
+func newRecordingGauge() recordingGauge {
+	return recordingGauge{mu: &sync.Mutex{}, values: make(map[string]float64)}
+}
+
+func (r recordingGauge) With(labelValues ...string) metrics.Gauge {
+	return recordingGauge{mu: r.mu, labels: append(append([]string(nil), r.labels...), labelValues...), values: r.values}
+}
+
+func (r recordingGauge) Set(value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[filepath.Join(r.labels...)] = value
+}
+
+func (r recordingGauge) Add(delta float64) {}
+
+func (r recordingGauge) get(labels ...string) (float64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value, ok := r.values[filepath.Join(labels...)]
+	return value, ok
+}
+
+func TestProvideDBFactory_gauge(t *testing.T) {
+	gauge := newRecordingGauge()
+	factory, cleanup := provideDBFactory(DatabaseIn{
+		Conf: config.MapAdapter{"gorm": map[string]databaseConf{
+			"default": {Database: "sqlite", Dsn: filepath.Join(t.TempDir(), "stats.db"), StatsIntervalSecond: 1},
+		}},
+		Logger: log.NewNopLogger(),
+		Gauge:  gauge,
+	})
+	defer cleanup()
+	_, err := factory.Make("default")
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, ok := gauge.get("dbname", "default", "stat", "wait_duration_seconds")
+		return ok
+	}, time.Second, time.Millisecond)
+	open, _ := gauge.get("dbname", "default", "stat", "open")
+	assert.Equal(t, float64(1), open)
+	idle, _ := gauge.get("dbname", "default", "stat", "idle")
+	assert.Equal(t, float64(1), idle)
+}
+
+func TestProvideDBFactory_labels(t *testing.T) {
+	gauge := newRecordingGauge()
+	var buf bytes.Buffer
+	factory, cleanup := provideDBFactory(DatabaseIn{
+		Conf: config.MapAdapter{"gorm": map[string]databaseConf{
+			"default": {
+				Database:            "sqlite",
+				Dsn:                 filepath.Join(t.TempDir(), "labels.db"),
+				StatsIntervalSecond: 1,
+				Labels:              map[string]string{"region": "eu", "role": "primary"},
+			},
+			"archive": {
+				Database:            "sqlite",
+				Dsn:                 filepath.Join(t.TempDir(), "archive.db"),
+				StatsIntervalSecond: 1,
+				Labels:              map[string]string{"region": "us"},
+			},
+		}},
+		Logger: log.NewLogfmtLogger(&buf),
+		Gauge:  gauge,
+	})
+	defer cleanup()
+	db, err := factory.Make("default")
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, ok := gauge.get("dbname", "default", "region", "eu", "role", "primary", "stat", "open")
+		return ok
+	}, time.Second, time.Millisecond)
+
+	assert.Error(t, db.Exec("SELECT * FROM missing").Error)
+	assert.Contains(t, buf.String(), "region=eu role=primary")
+
+	_, err = factory.Make("archive")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, ok := gauge.get("dbname", "archive", "region", "us", "role", "", "stat", "open")
+		return ok
+	}, time.Second, time.Millisecond)
+}
+
+func TestCollectStats(t *testing.T) {
+	gauge := newRecordingGauge()
+	db := ProvideMemoryDatabase()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		CollectStats(ctx, db, gauge.With("dbname", "memory"), time.Millisecond)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		_, ok := gauge.get("dbname", "memory", "stat", "in_use")
+		return ok
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("CollectStats didn't stop on cancel")
+	}
+}
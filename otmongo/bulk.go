@@ -0,0 +1,100 @@
+package otmongo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BulkWriter splits a large number of write models, such as
+// mongo.InsertOneModel, mongo.UpdateOneModel and mongo.DeleteOneModel, into
+// batches and sends each batch as one bulk operation. It is considerably
+// faster than writing documents one by one.
+//
+// Wire compression is a property of the client rather than the bulk write. To
+// enable it, add the compressors option to the connection uri, eg.
+// mongodb://127.0.0.1:27017/?compressors=zstd,snappy.
+type BulkWriter struct {
+	// Collection is the target collection.
+	Collection *mongo.Collection
+	// BatchSize is the maximum number of models in each bulk operation. By default it is 1000.
+	BatchSize int
+	// Ordered instructs the server to execute the models in each batch in order, stopping at the first error.
+	// If false, the server executes the models in arbitrary order and continues after errors.
+	Ordered bool
+	// ContinueOnError decides if the remaining batches should still be written after a batch has failed.
+	ContinueOnError bool
+}
+
+// BulkWriteErrors collects the errors of failed batches.
+type BulkWriteErrors []error
+
+// Error implements error.
+func (b BulkWriteErrors) Error() string {
+	var msgs []string
+	for _, err := range b {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Write writes all models to the collection in batches. The returned
+// *mongo.BulkWriteResult aggregates the results of all batches. Keys of
+// UpsertedIDs are the indexes of models, not the indexes within batches. If
+// ContinueOnError is false, Write stops at the first failed batch and returns
+// its error. Otherwise a BulkWriteErrors is returned after all batches are
+// attempted.
+func (b BulkWriter) Write(ctx context.Context, models []mongo.WriteModel) (*mongo.BulkWriteResult, error) {
+	var (
+		errs   BulkWriteErrors
+		result = &mongo.BulkWriteResult{UpsertedIDs: make(map[int64]interface{})}
+		opts   = options.BulkWrite().SetOrdered(b.Ordered)
+	)
+	for _, batch := range chunk(models, b.BatchSize) {
+		res, err := b.Collection.BulkWrite(ctx, models[batch.start:batch.end], opts)
+		if res != nil {
+			result.InsertedCount += res.InsertedCount
+			result.MatchedCount += res.MatchedCount
+			result.ModifiedCount += res.ModifiedCount
+			result.DeletedCount += res.DeletedCount
+			result.UpsertedCount += res.UpsertedCount
+			for idx, id := range res.UpsertedIDs {
+				result.UpsertedIDs[idx+int64(batch.start)] = id
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("bulk write of models [%d, %d) failed: %w", batch.start, batch.end, err)
+			if !b.ContinueOnError {
+				return result, err
+			}
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return result, errs
+	}
+	return result, nil
+}
+
+type span struct {
+	start int
+	end   int
+}
+
+func chunk(models []mongo.WriteModel, size int) []span {
+	if size <= 0 {
+		size = 1000
+	}
+	var spans []span
+	for start := 0; start < len(models); start += size {
+		end := start + size
+		if end > len(models) {
+			end = len(models)
+		}
+		spans = append(spans, span{start: start, end: end})
+	}
+	return spans
+}
@@ -0,0 +1,40 @@
+package otmongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestChunk(t *testing.T) {
+	models := make([]mongo.WriteModel, 5)
+	assert.Equal(t, []span{{0, 2}, {2, 4}, {4, 5}}, chunk(models, 2))
+	assert.Equal(t, []span{{0, 5}}, chunk(models, 0))
+	assert.Empty(t, chunk(nil, 2))
+}
+
+func TestBulkWriter_Write(t *testing.T) {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:27017"))
+	assert.NoError(t, err)
+	collection := client.Database("test").Collection("bulk")
+	defer collection.Drop(context.Background())
+
+	var models []mongo.WriteModel
+	for i := 0; i < 5; i++ {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": i}).
+			SetUpdate(bson.M{"$set": bson.M{"value": i}}).
+			SetUpsert(true),
+		)
+	}
+	writer := BulkWriter{Collection: collection, BatchSize: 2}
+	result, err := writer.Write(context.Background(), models)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), result.UpsertedCount)
+	assert.Len(t, result.UpsertedIDs, 5)
+	assert.Contains(t, result.UpsertedIDs, int64(4))
+}
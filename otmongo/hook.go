@@ -3,6 +3,7 @@ package otmongo
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,6 +22,7 @@ type spanKey struct {
 type monitor struct {
 	sync.Mutex
 	tracer opentracing.Tracer
+	labels map[string]string
 	spans  map[spanKey]opentracing.Span
 }
 
@@ -35,6 +37,9 @@ func (m *monitor) Started(ctx context.Context, evt *event.CommandStartedEvent) {
 	ext.PeerPort.Set(span, port)
 	ext.DBStatement.Set(span, statement)
 	ext.SpanKind.Set(span, ext.SpanKindEnum("client"))
+	for k, v := range m.labels {
+		span.SetTag(k, v)
+	}
 	key := spanKey{
 		ConnectionID: evt.ConnectionID,
 		RequestID:    evt.RequestID,
@@ -75,9 +80,16 @@ func (m *monitor) Finished(evt *event.CommandFinishedEvent, err error) {
 
 // NewMonitor creates a new mongodb event CommandMonitor.
 func NewMonitor(tracer opentracing.Tracer) *event.CommandMonitor {
+	return newMonitor(tracer, nil)
+}
+
+// newMonitor creates a new mongodb event CommandMonitor that tags the spans
+// with the labels of the connection.
+func newMonitor(tracer opentracing.Tracer, labels map[string]string) *event.CommandMonitor {
 	m := &monitor{
 		spans:  make(map[spanKey]opentracing.Span),
 		tracer: tracer,
+		labels: labels,
 	}
 	return &event.CommandMonitor{
 		Started:   m.Started,
@@ -99,3 +111,18 @@ func peerInfo(evt *event.CommandStartedEvent) (hostname string, port uint16) {
 	uintPort, _ := strconv.ParseUint(strPort, 10, 32)
 	return hostname, uint16(uintPort)
 }
+
+// labelKeyvals flattens the labels of a connection into log.With arguments,
+// sorted by key.
+func labelKeyvals(labels map[string]string) []interface{} {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	keyvals := make([]interface{}, 0, 2*len(labels))
+	for _, k := range keys {
+		keyvals = append(keyvals, k, labels[k])
+	}
+	return keyvals
+}
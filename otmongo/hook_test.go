@@ -8,6 +8,7 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
@@ -26,3 +27,18 @@ func TestHook(t *testing.T) {
 		assert.NotEmpty(t, tracer.FinishedSpans())
 	})
 }
+
+func TestMonitor_labels(t *testing.T) {
+	tracer := mocktracer.New()
+	monitor := newMonitor(tracer, map[string]string{"region": "eu"})
+	monitor.Started(context.Background(), &event.CommandStartedEvent{ConnectionID: "localhost:27017[-1]", RequestID: 1})
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{ConnectionID: "localhost:27017[-1]", RequestID: 1},
+	})
+	assert.Len(t, tracer.FinishedSpans(), 1)
+	assert.Equal(t, "eu", tracer.FinishedSpans()[0].Tag("region"))
+}
+
+func TestLabelKeyvals(t *testing.T) {
+	assert.Equal(t, []interface{}{"region", "eu", "role", "primary"}, labelKeyvals(map[string]string{"role": "primary", "region": "eu"}))
+}
@@ -0,0 +1,97 @@
+package otmongo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// IndexManager ensures the indexes a collection requires exist. It is the
+// index counterpart of database migrations: declare the indexes next to the
+// code querying the collection, and ensure them at boot, so that environments
+// don't drift apart.
+type IndexManager struct {
+	// Collection is the indexed collection.
+	Collection *mongo.Collection
+	// Logger is an optional logger. By default a noop logger is used.
+	Logger log.Logger
+}
+
+// IndexErrors collects the errors of indexes that couldn't be ensured.
+type IndexErrors []error
+
+// Error implements error.
+func (i IndexErrors) Error() string {
+	var msgs []string
+	for _, err := range i {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Ensure creates the indexes that don't exist yet. It is idempotent: an index
+// with the same keys and options as an existing one is left alone. Each index
+// is logged as created or existing. The other indexes are still ensured after
+// one fails, for example because an index of the same name but different
+// options exists, and the failures are returned in an IndexErrors.
+func (m IndexManager) Ensure(ctx context.Context, indexes ...mongo.IndexModel) error {
+	logger := m.Logger
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	existing, err := m.names(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to list indexes of %s: %w", m.Collection.Name(), err)
+	}
+	var errs IndexErrors
+	for i, index := range indexes {
+		name, err := m.Collection.Indexes().CreateOne(ctx, index)
+		if err != nil {
+			err = fmt.Errorf("unable to create index %s of %s: %w", describeIndex(i, index), m.Collection.Name(), err)
+			level.Warn(logger).Log("err", err)
+			errs = append(errs, err)
+			continue
+		}
+		if existing[name] {
+			level.Debug(logger).Log("msg", fmt.Sprintf("index %s of %s exists", name, m.Collection.Name()))
+			continue
+		}
+		level.Info(logger).Log("msg", fmt.Sprintf("index %s of %s created", name, m.Collection.Name()))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// names returns the names of the existing indexes of the collection.
+func (m IndexManager) names(ctx context.Context) (map[string]bool, error) {
+	cursor, err := m.Collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var specs []struct {
+		Name string `bson:"name"`
+	}
+	if err := cursor.All(ctx, &specs); err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		names[spec.Name] = true
+	}
+	return names, nil
+}
+
+// describeIndex names the index in errors. Unnamed indexes are referred to by
+// their position.
+func describeIndex(i int, index mongo.IndexModel) string {
+	if index.Options != nil && index.Options.Name != nil {
+		return *index.Options.Name
+	}
+	return fmt.Sprintf("#%d", i)
+}
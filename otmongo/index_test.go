@@ -0,0 +1,42 @@
+package otmongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestIndexManager_Ensure(t *testing.T) {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:27017"))
+	assert.NoError(t, err)
+	collection := client.Database("test").Collection("index")
+	defer collection.Drop(context.Background())
+
+	manager := IndexManager{Collection: collection}
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "createdAt", Value: -1}}},
+	}
+	assert.NoError(t, manager.Ensure(context.Background(), indexes...))
+	assert.NoError(t, manager.Ensure(context.Background(), indexes...))
+
+	// The same name with different options conflicts, but doesn't stop the others.
+	err = manager.Ensure(context.Background(),
+		mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "name", Value: 1}}},
+	)
+	assert.IsType(t, IndexErrors{}, err)
+	assert.Len(t, err, 1)
+	names, err := manager.names(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"_id_": true, "email_1": true, "tenant_1_createdAt_-1": true, "name_1": true}, names)
+}
+
+func TestDescribeIndex(t *testing.T) {
+	assert.Equal(t, "#1", describeIndex(1, mongo.IndexModel{Keys: bson.D{{Key: "a", Value: 1}}}))
+	assert.Equal(t, "by_a", describeIndex(1, mongo.IndexModel{Options: options.Index().SetName("by_a")}))
+}
@@ -0,0 +1,111 @@
+package otmongo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// DurationHistogram is the metrics.Histogram type for the latency of mongo
+// commands, used for dependency injection. If provided, the duration of each
+// command is observed in seconds, labeled by "name" and "command", the names of
+// the connection and the command, and by the labels of the connections.
+type DurationHistogram metrics.Histogram
+
+// FailureCounter is the metrics.Counter type for the failed mongo commands,
+// used for dependency injection. If provided, it is incremented on each failed
+// command, labeled like DurationHistogram.
+type FailureCounter metrics.Counter
+
+// NewMetricsMonitor creates a new mongodb event CommandMonitor that observes
+// the duration of each command, and counts the failed ones. The metrics are
+// labeled by "command". Either of them may be nil.
+func NewMetricsMonitor(duration metrics.Histogram, failures metrics.Counter) *event.CommandMonitor {
+	observe := func(evt *event.CommandFinishedEvent) {
+		if duration != nil {
+			duration.With("command", evt.CommandName).Observe(time.Duration(evt.DurationNanos).Seconds())
+		}
+	}
+	return &event.CommandMonitor{
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			observe(&evt.CommandFinishedEvent)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			observe(&evt.CommandFinishedEvent)
+			if failures != nil {
+				failures.With("command", evt.CommandName).Add(1)
+			}
+		},
+	}
+}
+
+// labelNames returns the sorted union of the label names of the connections.
+// The metrics of every connection are labeled with all of them, as Prometheus
+// rejects a metric whose label names vary.
+func labelNames(confs map[string]mongoConf) []string {
+	seen := make(map[string]struct{})
+	for _, conf := range confs {
+		for k := range conf.Labels {
+			seen[k] = struct{}{}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for k := range seen {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// metricLabels flattens the labels of a connection into alternating names and
+// values for the given names, as expected by With. Missing labels are empty.
+func metricLabels(names []string, labels map[string]string) []string {
+	values := make([]string, 0, 2*len(names))
+	for _, k := range names {
+		values = append(values, k, labels[k])
+	}
+	return values
+}
+
+// composeMonitors creates a CommandMonitor that forwards the events to each of
+// the monitors in order. Nil monitors are skipped.
+func composeMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	var active []*event.CommandMonitor
+	for _, m := range monitors {
+		if m != nil {
+			active = append(active, m)
+		}
+	}
+	switch len(active) {
+	case 0:
+		return nil
+	case 1:
+		return active[0]
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			for _, m := range active {
+				if m.Started != nil {
+					m.Started(ctx, evt)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			for _, m := range active {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, evt)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			for _, m := range active {
+				if m.Failed != nil {
+					m.Failed(ctx, evt)
+				}
+			}
+		},
+	}
+}
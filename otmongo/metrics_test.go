@@ -0,0 +1,84 @@
+package otmongo
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+type recordingHistogram struct {
+	labels []string
+	values map[string][]float64
+}
+
+func (r *recordingHistogram) With(labelValues ...string) metrics.Histogram {
+	return &recordingHistogram{labels: append(append([]string{}, r.labels...), labelValues...), values: r.values}
+}
+
+func (r *recordingHistogram) Observe(value float64) {
+	key := strings.Join(r.labels, ",")
+	r.values[key] = append(r.values[key], value)
+}
+
+type recordingCounter struct {
+	labels []string
+	counts map[string]float64
+}
+
+func (r *recordingCounter) With(labelValues ...string) metrics.Counter {
+	return &recordingCounter{labels: append(append([]string{}, r.labels...), labelValues...), counts: r.counts}
+}
+
+func (r *recordingCounter) Add(delta float64) {
+	r.counts[strings.Join(r.labels, ",")] += delta
+}
+
+func TestNewMetricsMonitor(t *testing.T) {
+	histogram := &recordingHistogram{values: make(map[string][]float64)}
+	counter := &recordingCounter{counts: make(map[string]float64)}
+	monitor := NewMetricsMonitor(histogram.With("name", "default"), counter.With("name", "default"))
+
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", DurationNanos: int64(2 * time.Second)},
+	})
+	monitor.Failed(context.Background(), &event.CommandFailedEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "insert", DurationNanos: int64(time.Second)},
+	})
+
+	assert.Equal(t, map[string][]float64{
+		"name,default,command,find":   {2},
+		"name,default,command,insert": {1},
+	}, histogram.values)
+	assert.Equal(t, map[string]float64{"name,default,command,insert": 1}, counter.counts)
+}
+
+func TestComposeMonitors(t *testing.T) {
+	assert.Nil(t, composeMonitors(nil, nil))
+
+	tracer := mocktracer.New()
+	histogram := &recordingHistogram{values: make(map[string][]float64)}
+	monitor := composeMonitors(newMonitor(tracer, nil), NewMetricsMonitor(histogram, nil))
+	monitor.Started(context.Background(), &event.CommandStartedEvent{ConnectionID: "localhost:27017[-1]", RequestID: 1, CommandName: "ping"})
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{ConnectionID: "localhost:27017[-1]", RequestID: 1, CommandName: "ping"},
+	})
+	assert.Len(t, tracer.FinishedSpans(), 1)
+	assert.Len(t, histogram.values["command,ping"], 1)
+}
+
+func TestMetricLabels(t *testing.T) {
+	names := labelNames(map[string]mongoConf{
+		"default": {Labels: map[string]string{"region": "eu"}},
+		"archive": {Labels: map[string]string{"tier": "cold", "region": "us"}},
+		"bare":    {},
+	})
+	assert.Equal(t, []string{"region", "tier"}, names)
+	assert.Equal(t, []string{"region", "eu", "tier", ""}, metricLabels(names, map[string]string{"region": "eu"}))
+	assert.Empty(t, metricLabels(nil, map[string]string{"region": "eu"}))
+}
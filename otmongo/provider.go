@@ -3,25 +3,130 @@ package otmongo
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/DoNewsCode/core/config"
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/health"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
 	"github.com/opentracing/opentracing-go"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
 	"go.uber.org/dig"
 )
 
+const (
+	// defaultUri is used when the default configuration is missing.
+	defaultUri = "mongodb://127.0.0.1:27017"
+	// defaultTimeout is the connect and server selection timeout of the fabricated default.
+	defaultTimeout = time.Second
+	// connectTimeout bounds mongo.Connect.
+	connectTimeout = 10 * time.Second
+	// defaultPingTimeout bounds Factory.Ping when pingTimeoutSecond is not set.
+	defaultPingTimeout = 5 * time.Second
+)
+
+// mongoConf is the configuration entry of a mongo client.
+type mongoConf struct {
+	Uri string `json:"uri" yaml:"uri"`
+	// Database is the default database of the connection, returned by
+	// Factory.Database. Defaults to the database in the uri, if any.
+	Database string `json:"database" yaml:"database"`
+	// Labels annotate the connection, eg. with its region. They are added to
+	// the log lines, the spans and the metrics of the connection.
+	Labels map[string]string `json:"labels" yaml:"labels"`
+	// The fields below override the options of the same name in the uri.
+	// Zero values leave them in place.
+	MaxPoolSize                  uint64 `json:"maxPoolSize" yaml:"maxPoolSize"`
+	MinPoolSize                  uint64 `json:"minPoolSize" yaml:"minPoolSize"`
+	ConnectTimeoutSecond         int    `json:"connectTimeoutSecond" yaml:"connectTimeoutSecond"`
+	ServerSelectionTimeoutSecond int    `json:"serverSelectionTimeoutSecond" yaml:"serverSelectionTimeoutSecond"`
+	// ReadPreference is one of primary, primaryPreferred, secondary,
+	// secondaryPreferred and nearest.
+	ReadPreference string `json:"readPreference" yaml:"readPreference"`
+	// AuthSource overrides the database the credentials in the uri are
+	// authenticated against.
+	AuthSource string `json:"authSource" yaml:"authSource"`
+	// PingOnBoot pings the server at boot. If it can't be pinged,
+	// ProvideStrict fails, while Provide only logs the error.
+	PingOnBoot bool `json:"pingOnBoot" yaml:"pingOnBoot"`
+	// PingTimeoutSecond bounds Factory.Ping. Defaults to 5 seconds.
+	PingTimeoutSecond int `json:"pingTimeoutSecond" yaml:"pingTimeoutSecond"`
+}
+
+// database returns the default database of the connection.
+func (c mongoConf) database() (string, error) {
+	if c.Database != "" {
+		return c.Database, nil
+	}
+	cs, err := connstring.Parse(c.Uri)
+	if err != nil {
+		return "", err
+	}
+	return cs.Database, nil
+}
+
+// pingTimeout returns the timeout of Factory.Ping.
+func (c mongoConf) pingTimeout() time.Duration {
+	if c.PingTimeoutSecond > 0 {
+		return time.Duration(c.PingTimeoutSecond) * time.Second
+	}
+	return defaultPingTimeout
+}
+
+// apply layers the options of the configuration on top of those parsed
+// from the uri.
+func (c mongoConf) apply(opts *options.ClientOptions) error {
+	if c.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(c.MaxPoolSize)
+	}
+	if c.MinPoolSize > 0 {
+		opts.SetMinPoolSize(c.MinPoolSize)
+	}
+	if c.ConnectTimeoutSecond > 0 {
+		opts.SetConnectTimeout(time.Duration(c.ConnectTimeoutSecond) * time.Second)
+	}
+	if c.ServerSelectionTimeoutSecond > 0 {
+		opts.SetServerSelectionTimeout(time.Duration(c.ServerSelectionTimeoutSecond) * time.Second)
+	}
+	if c.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(c.ReadPreference)
+		if err != nil {
+			return err
+		}
+		pref, err := readpref.New(mode)
+		if err != nil {
+			return err
+		}
+		opts.SetReadPreference(pref)
+	}
+	if c.AuthSource != "" {
+		if opts.Auth == nil {
+			return fmt.Errorf("authSource %s requires credentials in the uri", c.AuthSource)
+		}
+		opts.Auth.AuthSource = c.AuthSource
+	}
+	return nil
+}
+
 // MongoIn is the injection parameter for Provide.
 type MongoIn struct {
 	dig.In
 
-	Logger log.Logger
-	Conf   contract.ConfigAccessor
-	Tracer opentracing.Tracer `optional:"true"`
+	Logger     log.Logger
+	Conf       contract.ConfigAccessor
+	Tracer     opentracing.Tracer `optional:"true"`
+	Warmup     *di.Warmup         `optional:"true"`
+	Validation *di.Validation     `optional:"true"`
+	Duration   DurationHistogram  `optional:"true"`
+	Failures   FailureCounter     `optional:"true"`
 }
 
 // Maker models Factory
@@ -29,6 +134,11 @@ type Maker interface {
 	Make(name string) (*mongo.Client, error)
 }
 
+// DatabaseMaker models Factory.Database
+type DatabaseMaker interface {
+	Database(name string) (*mongo.Database, error)
+}
+
 // MongoOut is the result of Provide. The official mongo package doesn't
 // provide a proper interface type. It is up to the users to define their own
 // mongodb repository interface.
@@ -37,54 +147,138 @@ type MongoOut struct {
 
 	Factory        Factory
 	Maker          Maker
+	DatabaseMaker  DatabaseMaker
 	Client         *mongo.Client
-	ExportedConfig []config.ExportedConfig `group:"config,flatten"`
+	HealthCheckers []contract.HealthChecker `group:"health,flatten"`
+	ExportedConfig []config.ExportedConfig  `group:"config,flatten"`
 }
 
 // Provide creates Factory and *mongo.Client. It is a valid dependency for
-// package core.
+// package core. The entries that fail pingOnBoot, or the warm-up, are logged.
+// Use ProvideStrict to fail the boot instead.
 func Provide(p MongoIn) (MongoOut, func()) {
+	out, cleanup, err := provide(p)
+	if err != nil {
+		level.Error(p.Logger).Log("tag", "mongo", "err", err)
+	}
+	return out, cleanup
+}
+
+// ProvideStrict is like Provide, but fails if an entry with pingOnBoot is
+// unreachable, or if the warm-up fails while the *di.Warmup is Fatal. It is a
+// valid dependency for package core, in place of Provide.
+func ProvideStrict(p MongoIn) (MongoOut, func(), error) {
+	out, cleanup, err := provide(p)
+	if err != nil {
+		cleanup()
+		return MongoOut{}, func() {}, err
+	}
+	return out, cleanup, nil
+}
+
+// provide creates the MongoOut. The error reports the failed boot checks, in
+// which case the MongoOut is usable nonetheless.
+func provide(p MongoIn) (MongoOut, func(), error) {
 	var err error
-	var dbConfs map[string]struct{ Uri string }
+	var dbConfs map[string]mongoConf
+	logger := log.With(p.Logger, "tag", "mongo")
 	err = p.Conf.Unmarshal("mongo", &dbConfs)
 	if err != nil {
-		level.Warn(p.Logger).Log("err", err)
+		level.Warn(logger).Log("err", err)
 	}
+	var names []string
+	for name := range dbConfs {
+		names = append(names, name)
+	}
+	metricLabelNames := labelNames(dbConfs)
 	factory := di.NewFactory(func(name string) (di.Pair, error) {
 		var (
 			ok   bool
-			conf struct{ Uri string }
+			conf mongoConf
+			opts = options.Client()
 		)
 		if conf, ok = dbConfs[name]; !ok {
 			if name != "default" {
 				return di.Pair{}, fmt.Errorf("mongo configuration %s not valid", name)
 			}
-			conf.Uri = "mongodb://127.0.0.1:27017"
+			// The fabricated default is likely unreachable, eg. in CI. Fail
+			// fast instead of blocking for the driver's default 30 seconds.
+			level.Info(logger).Log("msg", fmt.Sprintf("mongo configuration default not found, falling back to %s", defaultUri))
+			conf.Uri = defaultUri
+			opts.SetConnectTimeout(defaultTimeout)
+			opts.SetServerSelectionTimeout(defaultTimeout)
 		}
-		opts := options.Client()
 		opts.ApplyURI(conf.Uri)
+		if err := conf.apply(opts); err != nil {
+			return di.Pair{}, fmt.Errorf("mongo configuration %s not valid: %w", name, err)
+		}
+		var tracing, metering *event.CommandMonitor
 		if p.Tracer != nil {
-			opts.Monitor = NewMonitor(p.Tracer)
+			tracing = newMonitor(p.Tracer, conf.Labels)
+		}
+		if p.Duration != nil || p.Failures != nil {
+			var (
+				duration metrics.Histogram
+				failures metrics.Counter
+			)
+			labels := append([]string{"name", name}, metricLabels(metricLabelNames, conf.Labels)...)
+			if p.Duration != nil {
+				duration = p.Duration.With(labels...)
+			}
+			if p.Failures != nil {
+				failures = p.Failures.With(labels...)
+			}
+			metering = NewMetricsMonitor(duration, failures)
 		}
-		client, err := mongo.Connect(context.Background(), opts)
+		opts.Monitor = composeMonitors(tracing, metering)
+		ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+		defer cancel()
+		client, err := mongo.Connect(ctx, opts)
 		if err != nil {
+			level.Warn(log.With(logger, labelKeyvals(conf.Labels)...)).Log("msg", fmt.Sprintf("unable to connect to mongo %s", name), "err", err)
 			return di.Pair{}, err
 		}
+		clientConfs.Store(client, conf)
 		return di.Pair{
 			Conn: client,
 			Closer: func() {
+				clientConfs.Delete(client)
 				_ = client.Disconnect(context.Background())
 			},
 		}, nil
-	})
+	}, di.WithTracer(p.Tracer, "mongo"), di.WithNames(names...), p.Validation.Option(ping))
 	f := Factory{factory}
+	var bootErr error
+	pingErrs := make(di.WarmupErrors)
+	for _, name := range names {
+		if !dbConfs[name].PingOnBoot {
+			continue
+		}
+		if err := f.Ping(context.Background(), name); err != nil {
+			pingErrs[name] = err
+		}
+	}
+	if len(pingErrs) > 0 {
+		bootErr = pingErrs
+	}
 	client, _ := f.Make("default")
+	if p.Warmup != nil && bootErr == nil {
+		if err := factory.Warm(names, p.Warmup.Parallelism); err != nil {
+			if p.Warmup.Fatal {
+				bootErr = err
+			} else {
+				level.Warn(logger).Log("err", err)
+			}
+		}
+	}
 	return MongoOut{
 		Factory:        f,
 		Maker:          f,
+		DatabaseMaker:  f,
 		Client:         client,
+		HealthCheckers: provideHealthCheckers(f, names),
 		ExportedConfig: provideConfig(),
-	}, factory.Close
+	}, factory.Close, bootErr
 }
 
 // Factory is a *di.Factory that creates *mongo.Client using a specific
@@ -93,6 +287,19 @@ type Factory struct {
 	*di.Factory
 }
 
+// clientConfs maps the clients created by the Factory to their
+// configuration entry, so that the entry can be looked up from the client.
+var clientConfs sync.Map
+
+// confOf returns the configuration entry of a client created by the Factory.
+// Clients created otherwise, eg. by di.NewStaticFactory, have an empty entry.
+func confOf(client *mongo.Client) mongoConf {
+	if conf, ok := clientConfs.Load(client); ok {
+		return conf.(mongoConf)
+	}
+	return mongoConf{}
+}
+
 // Make creates *mongo.Client using a specific configuration entry.
 func (r Factory) Make(name string) (*mongo.Client, error) {
 	client, err := r.Factory.Make(name)
@@ -102,17 +309,72 @@ func (r Factory) Make(name string) (*mongo.Client, error) {
 	return client.(*mongo.Client), nil
 }
 
+// Database returns the default database of a specific configuration entry.
+// It returns an error if the entry configures no database, either in the
+// database field or in the uri.
+func (r Factory) Database(name string) (*mongo.Database, error) {
+	client, err := r.Make(name)
+	if err != nil {
+		return nil, err
+	}
+	database, err := confOf(client).database()
+	if err != nil {
+		return nil, fmt.Errorf("mongo configuration %s not valid: %w", name, err)
+	}
+	if database == "" {
+		return nil, fmt.Errorf("mongo configuration %s has no database", name)
+	}
+	return client.Database(database), nil
+}
+
+// Ping verifies that the server of a specific configuration entry is
+// reachable, connecting to it if needed. It gives up after the
+// pingTimeoutSecond of the entry, or the deadline of ctx if earlier.
+func (r Factory) Ping(ctx context.Context, name string) error {
+	client, err := r.Make(name)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, confOf(client).pingTimeout())
+	defer cancel()
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		return fmt.Errorf("unable to ping mongo %s: %w", name, err)
+	}
+	return nil
+}
+
+// ping validates the *mongo.Client of the Factory.
+func ping(ctx context.Context, conn interface{}) error {
+	return conn.(*mongo.Client).Ping(ctx, readpref.Primary())
+}
+
+func provideHealthCheckers(factory Factory, names []string) []contract.HealthChecker {
+	var checkers []contract.HealthChecker
+	for _, name := range names {
+		name := name
+		checkers = append(checkers, health.NewChecker("mongo."+name, func(ctx context.Context) error {
+			return factory.Ping(ctx, name)
+		}))
+	}
+	return checkers
+}
+
 // provideConfig exports the default mongo configuration.
 func provideConfig() []config.ExportedConfig {
 	return []config.ExportedConfig{
 		{
 			Owner: "otmongo",
 			Data: map[string]interface{}{
-				"mongo": map[string]struct {
-					Uri string `json:"uri" yaml:"uri"`
-				}{
+				"mongo": map[string]mongoConf{
 					"default": {
-						Uri: "",
+						Uri:                          "",
+						Database:                     "",
+						Labels:                       map[string]string{},
+						MaxPoolSize:                  100,
+						ConnectTimeoutSecond:         30,
+						ServerSelectionTimeoutSecond: 30,
+						PingOnBoot:                   false,
+						PingTimeoutSecond:            5,
 					},
 				},
 			},
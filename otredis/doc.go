@@ -14,7 +14,23 @@ package otredis exports the configuration in the following format:
 	      - 127.0.0.1:6379
 	    DB: 0
 
-To see all available configurations, use the exportConfig command.
+To see all available configurations, use the exportConfig command. Besides the
+options of redis.UniversalOptions, such as the pool size and the timeouts, a
+tls block enables TLS connections:
+
+	redis:
+	  default:
+	    addrs:
+	      - redis.example.com:6380
+	    poolSize: 20
+	    tls:
+	      enabled: true
+	      caFile: /etc/redis/ca.pem
+
+With a masterName, a sentinel client is created. With two or more addrs, a
+cluster client is created. Otherwise a single node client is created. Other
+packages that need redis, such as package queue, depend on the
+redis.UniversalClient provided here.
 
 Add the redis dependency to core:
 
@@ -0,0 +1,76 @@
+package otredis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisUniversalOptions is the configuration entry of a redis client. It
+// extends redis.UniversalOptions with the TLS options, which cannot be
+// expressed in a configuration file otherwise. Whether a single node, a
+// sentinel or a cluster client is created follows the rules of
+// redis.NewUniversalClient:
+//
+//  1. If the MasterName option is specified, a sentinel-backed FailoverClient is returned.
+//  2. if the number of Addrs is two or more, a ClusterClient is returned.
+//  3. Otherwise, a single-node Client is returned.
+type RedisUniversalOptions struct {
+	redis.UniversalOptions `json:",squash"`
+
+	TLS TLSOptions `json:"tls"`
+}
+
+// TLSOptions configures the TLS connection to redis. The certificate and key
+// files are only needed for client authentication. Without a CA file, the
+// system roots are used.
+type TLSOptions struct {
+	Enabled            bool   `json:"enabled"`
+	ServerName         string `json:"serverName"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	CAFile             string `json:"caFile"`
+	CertFile           string `json:"certFile"`
+	KeyFile            string `json:"keyFile"`
+}
+
+// options returns the redis.UniversalOptions with the TLS config filled.
+func (o RedisUniversalOptions) options() (redis.UniversalOptions, error) {
+	opts := o.UniversalOptions
+	if !o.TLS.Enabled {
+		return opts, nil
+	}
+	tlsConfig, err := o.TLS.config()
+	if err != nil {
+		return redis.UniversalOptions{}, err
+	}
+	opts.TLSConfig = tlsConfig
+	return opts, nil
+}
+
+func (o TLSOptions) config() (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+	if o.CAFile != "" {
+		ca, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read redis CA file: %w", err)
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in redis CA file %s", o.CAFile)
+		}
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load redis client certificate: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
@@ -1,6 +1,7 @@
 package otredis
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/DoNewsCode/core/config"
@@ -27,6 +28,8 @@ type RedisIn struct {
 	Conf        contract.ConfigAccessor
 	Interceptor RedisConfigurationInterceptor `optional:"true"`
 	Tracer      opentracing.Tracer            `optional:"true"`
+	Warmup      *di.Warmup                    `optional:"true"`
+	Validation  *di.Validation                `optional:"true"`
 }
 
 // RedisOut is the result of Provide.
@@ -40,22 +43,50 @@ type RedisOut struct {
 }
 
 // Provide creates Factory and redis.UniversalClient. It is a valid
-// dependency for package core.
+// dependency for package core. If the warm-up fails, the error is logged. Use
+// ProvideStrict to fail the boot instead.
 func Provide(p RedisIn) (RedisOut, func()) {
+	out, cleanup, err := provide(p)
+	if err != nil {
+		level.Error(p.Logger).Log("err", err)
+	}
+	return out, cleanup
+}
+
+// ProvideStrict is like Provide, but fails if the warm-up fails while the
+// *di.Warmup is Fatal. It is a valid dependency for package core, in place of
+// Provide.
+func ProvideStrict(p RedisIn) (RedisOut, func(), error) {
+	out, cleanup, err := provide(p)
+	if err != nil {
+		cleanup()
+		return RedisOut{}, func() {}, err
+	}
+	return out, cleanup, nil
+}
+
+// provide creates the RedisOut. The error reports a fatal warm-up failure, in
+// which case the RedisOut is usable nonetheless.
+func provide(p RedisIn) (RedisOut, func(), error) {
 	var err error
-	var dbConfs map[string]redis.UniversalOptions
+	var dbConfs map[string]RedisUniversalOptions
 	err = p.Conf.Unmarshal("redis", &dbConfs)
 	if err != nil {
 		level.Warn(p.Logger).Log("err", err)
 	}
+	var names []string
+	for name := range dbConfs {
+		names = append(names, name)
+	}
 	factory := di.NewFactory(func(name string) (di.Pair, error) {
-		var (
-			ok   bool
-			conf redis.UniversalOptions
-		)
-		if conf, ok = dbConfs[name]; !ok {
+		options, ok := dbConfs[name]
+		if !ok {
 			return di.Pair{}, fmt.Errorf("redis configuration %s not valid", name)
 		}
+		conf, err := options.options()
+		if err != nil {
+			return di.Pair{}, fmt.Errorf("redis configuration %s not valid: %w", name, err)
+		}
 		if p.Interceptor != nil {
 			p.Interceptor(name, &conf)
 		}
@@ -75,7 +106,7 @@ func Provide(p RedisIn) (RedisOut, func()) {
 				_ = client.Close()
 			},
 		}, nil
-	})
+	}, di.WithTracer(p.Tracer, "redis"), di.WithNames(names...), p.Validation.Option(ping))
 	redisFactory := Factory{factory}
 	redisOut := RedisOut{
 		Maker:          redisFactory,
@@ -85,7 +116,20 @@ func Provide(p RedisIn) (RedisOut, func()) {
 	}
 	defaultRedisClient, _ := redisFactory.Make("default")
 	redisOut.Client = defaultRedisClient
-	return redisOut, redisFactory.Close
+	if p.Warmup != nil {
+		if err := factory.Warm(factory.Names(), p.Warmup.Parallelism); err != nil {
+			if p.Warmup.Fatal {
+				return redisOut, redisFactory.Close, err
+			}
+			level.Warn(p.Logger).Log("err", err)
+		}
+	}
+	return redisOut, redisFactory.Close, nil
+}
+
+// ping validates the redis.UniversalClient of the Factory.
+func ping(ctx context.Context, conn interface{}) error {
+	return conn.(redis.UniversalClient).Ping(ctx).Err()
 }
 
 // Maker is models Factory
@@ -138,6 +182,14 @@ func provideConfig() []config.ExportedConfig {
 						"routeByLatency":     false,
 						"routeRandomly":      false,
 						"masterName":         "",
+						"tls": map[string]interface{}{
+							"enabled":            false,
+							"serverName":         "",
+							"insecureSkipVerify": false,
+							"caFile":             "",
+							"certFile":           "",
+							"keyFile":            "",
+						},
 					},
 				},
 			},
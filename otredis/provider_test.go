@@ -1,17 +1,22 @@
 package otredis
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/di"
 	"github.com/go-kit/kit/log"
 	"github.com/go-redis/redis/v8"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/rawbytes"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewRedisFactory(t *testing.T) {
 	redisOut, cleanup := Provide(RedisIn{
-		Conf: config.MapAdapter{"redis": map[string]redis.UniversalOptions{
+		Conf: config.MapAdapter{"redis": map[string]RedisUniversalOptions{
 			"default":     {},
 			"alternative": {},
 		}},
@@ -27,3 +32,78 @@ func TestNewRedisFactory(t *testing.T) {
 	assert.NotNil(t, cleanup)
 	cleanup()
 }
+
+func TestProvide_options(t *testing.T) {
+	conf, err := config.NewConfig(config.WithProviderLayer(rawbytes.Provider([]byte(`
+redis:
+  default:
+    addrs:
+      - 127.0.0.1:6379
+    poolSize: 20
+    dialTimeout: 1000000000
+    tls:
+      enabled: true
+      serverName: redis.example.com
+  broken:
+    tls:
+      enabled: true
+      caFile: /not/exist
+`)), yaml.Parser()))
+	assert.NoError(t, err)
+	redisOut, cleanup := Provide(RedisIn{
+		Conf:   conf,
+		Logger: log.NewNopLogger(),
+	})
+	defer cleanup()
+
+	opts := redisOut.Client.(*redis.Client).Options()
+	assert.Equal(t, 20, opts.PoolSize)
+	assert.Equal(t, time.Second, opts.DialTimeout)
+	assert.Equal(t, "redis.example.com", opts.TLSConfig.ServerName)
+
+	_, err = redisOut.Maker.Make("broken")
+	assert.Error(t, err)
+}
+
+func TestProvideStrict(t *testing.T) {
+	conf := config.MapAdapter{"redis": map[string]RedisUniversalOptions{
+		"default": {},
+		"broken":  {TLS: TLSOptions{Enabled: true, CAFile: "/not/exist"}},
+	}}
+	_, _, err := ProvideStrict(RedisIn{
+		Conf:   conf,
+		Logger: log.NewNopLogger(),
+		Warmup: &di.Warmup{Fatal: true},
+	})
+	assert.Error(t, err)
+
+	redisOut, cleanup := Provide(RedisIn{
+		Conf:   conf,
+		Logger: log.NewNopLogger(),
+		Warmup: &di.Warmup{Fatal: true},
+	})
+	defer cleanup()
+	assert.NotNil(t, redisOut.Client)
+}
+
+func TestProvide_validation(t *testing.T) {
+	redisOut, cleanup := Provide(RedisIn{
+		Conf:       config.MapAdapter{"redis": map[string]RedisUniversalOptions{"default": {}}},
+		Logger:     log.NewNopLogger(),
+		Validation: &di.Validation{},
+	})
+	defer cleanup()
+
+	client, err := redisOut.Maker.Make("default")
+	assert.NoError(t, err)
+	same, err := redisOut.Maker.Make("default")
+	assert.NoError(t, err)
+	assert.Same(t, client, same)
+
+	// The closed client fails the ping, and is replaced.
+	assert.NoError(t, client.Close())
+	healed, err := redisOut.Maker.Make("default")
+	assert.NoError(t, err)
+	assert.NotSame(t, client, healed)
+	assert.NoError(t, healed.Ping(context.Background()).Err())
+}
@@ -72,6 +72,10 @@ func Provide(p S3In) S3Out {
 	if err != nil {
 		level.Warn(p.Logger).Log("err", err)
 	}
+	var names []string
+	for name := range s3configs {
+		names = append(names, name)
+	}
 	factory := di.NewFactory(func(name string) (di.Pair, error) {
 		var (
 			ok   bool
@@ -99,7 +103,7 @@ func Provide(p S3In) S3Out {
 			Closer: nil,
 			Conn:   manager,
 		}, nil
-	})
+	}, di.WithNames(names...))
 	s3Factory := S3Factory{factory}
 	manager, err := factory.Make("default")
 	if err != nil {
@@ -0,0 +1,46 @@
+package queue
+
+import (
+	"context"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/pkg/errors"
+)
+
+// ErrNotCancelable means the job can't be cancelled, because it is not delayed anymore. It may be waiting, being
+// handled, or already handled.
+var ErrNotCancelable = errors.New("job not cancelable")
+
+// DispatchJob dispatches the event like Dispatch, and returns the unique ID of the job, so that it can be cancelled
+// later with Cancel. The ID is empty if the event is not persisted.
+func (d *QueueableDispatcher) DispatchJob(ctx context.Context, e contract.Event) (string, error) {
+	if _, ok := e.(*PersistedEvent); ok {
+		return "", d.Dispatch(ctx, e)
+	}
+	p, ok := e.(persistent)
+	if !ok {
+		return "", d.Dispatch(ctx, e)
+	}
+	msg, err := d.push(ctx, e, p)
+	if err != nil {
+		return "", err
+	}
+	return msg.UniqueId, nil
+}
+
+// Cancel removes the delayed job with the unique ID before it runs, for example a reminder of an entity that has
+// been deleted. The unique ID is returned by DispatchJob, or set by the UniqueId option. Once the job has become due,
+// it can't be cancelled anymore, and ErrNotCancelable is returned. The delayed jobs are scanned like CancelDelayed
+// does, so the cost is linear in their number.
+func (d *QueueableDispatcher) Cancel(ctx context.Context, uniqueId string) error {
+	cancelled, err := d.CancelDelayed(ctx, func(msg *PersistedEvent) bool {
+		return msg.UniqueId == uniqueId
+	})
+	if err != nil {
+		return err
+	}
+	if cancelled == 0 {
+		return errors.Wrapf(ErrNotCancelable, "job %s is not delayed", uniqueId)
+	}
+	return nil
+}
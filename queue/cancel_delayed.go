@@ -0,0 +1,102 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// DelayedCanceller is implemented by drivers that can cancel delayed jobs.
+type DelayedCanceller interface {
+	// CancelDelayed removes all delayed jobs for which match returns true, and returns the number of jobs removed.
+	// Each job is removed atomically: a job that has become due and been moved to the waiting queue in the meantime
+	// is neither removed nor counted.
+	CancelDelayed(ctx context.Context, match func(*PersistedEvent) bool) (int64, error)
+}
+
+// CancelDelayed cancels all delayed jobs of the queue for which match returns true, for example all the reminders
+// of a deactivated campaign, and returns the number of jobs cancelled. The match function is called on every delayed
+// job. Jobs that are already waiting or being handled are not affected. An error is returned if the driver doesn't
+// implement DelayedCanceller.
+func (d *QueueableDispatcher) CancelDelayed(ctx context.Context, match func(*PersistedEvent) bool) (int64, error) {
+	canceller, ok := d.driver.(DelayedCanceller)
+	if !ok {
+		return 0, fmt.Errorf("driver %T doesn't support cancelling delayed jobs", d.driver)
+	}
+	return canceller.CancelDelayed(ctx, match)
+}
+
+// CancelDelayed implements DelayedCanceller. It scans the delayed sorted set with ZSCAN and removes the matches with
+// ZREM. The unique guards of the cancelled jobs are released.
+func (r *RedisDriver) CancelDelayed(ctx context.Context, match func(*PersistedEvent) bool) (int64, error) {
+	r.populateDefaults()
+	var (
+		cursor    uint64
+		cancelled int64
+	)
+	for {
+		// ZSCAN returns members and scores interleaved.
+		keys, next, err := r.RedisClient.ZScan(ctx, r.ChannelConfig.Delayed, cursor, "", 100).Result()
+		if err != nil {
+			return cancelled, errors.Wrap(err, "failed to zscan while cancelling delayed jobs")
+		}
+		var (
+			matches  []*PersistedEvent
+			removals []*redis.IntCmd
+			p        = r.RedisClient.Pipeline()
+		)
+		for i := 0; i < len(keys); i += 2 {
+			message := &PersistedEvent{}
+			if err := r.Packer.Decompress([]byte(keys[i]), message); err != nil {
+				return cancelled, errors.Wrap(err, "failed to decompress message")
+			}
+			if match(message) {
+				matches = append(matches, message)
+				removals = append(removals, p.ZRem(ctx, r.ChannelConfig.Delayed, keys[i]))
+			}
+		}
+		if len(matches) > 0 {
+			if _, err := p.Exec(ctx); err != nil {
+				return cancelled, errors.Wrap(err, "failed to zrem while cancelling delayed jobs")
+			}
+			// The jobs promoted in the meantime are not cancelled, and keep their unique guards.
+			for i, removal := range removals {
+				if removal.Val() == 0 {
+					continue
+				}
+				cancelled++
+				if matches[i].UniqueKey != "" {
+					r.unguard(ctx, matches[i])
+				}
+			}
+		}
+		if next == 0 {
+			return cancelled, nil
+		}
+		cursor = next
+	}
+}
+
+// CancelDelayed implements DelayedCanceller.
+func (i *InProcessDriver) CancelDelayed(ctx context.Context, match func(*PersistedEvent) bool) (int64, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	var (
+		kept      = make(priorityQueue, 0, len(*i.delayed))
+		cancelled int64
+	)
+	for _, delayed := range *i.delayed {
+		if match(delayed.event) {
+			cancelled++
+			i.unguard(delayed.event)
+			continue
+		}
+		kept = append(kept, delayed)
+	}
+	heap.Init(&kept)
+	i.delayed = &kept
+	return cancelled, nil
+}
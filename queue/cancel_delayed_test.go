@@ -0,0 +1,73 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_CancelDelayed(t *testing.T) {
+	cases := []struct {
+		name       string
+		dispatcher *QueueableDispatcher
+	}{
+		{"redis", setUp()},
+		{"in process", WithQueue(&events.SyncDispatcher{}, NewInProcessDriver())},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, key := range []string{"campaign.1", "campaign.2", "campaign.1", ""} {
+				err := c.dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Defer(time.Hour), RoutingKey(key)))
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, c.dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), RoutingKey("campaign.1"))))
+
+			cancelled, err := c.dispatcher.CancelDelayed(ctx, func(event *PersistedEvent) bool {
+				return event.RoutingKey == "campaign.1"
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, int64(2), cancelled)
+			info, _ := c.dispatcher.driver.Info(ctx)
+			assert.Equal(t, int64(2), info.Delayed)
+			assert.Equal(t, int64(1), info.Waiting)
+		})
+	}
+}
+
+func TestDispatcher_CancelDelayed_unsupported(t *testing.T) {
+	dispatcher := WithQueue(&events.SyncDispatcher{}, plainDriver{NewInProcessDriver()})
+	_, err := dispatcher.CancelDelayed(context.Background(), func(event *PersistedEvent) bool { return true })
+	assert.Error(t, err)
+}
+
+func TestDispatcher_CancelDelayed_unique(t *testing.T) {
+	cases := []struct {
+		name       string
+		dispatcher *QueueableDispatcher
+	}{
+		{"redis", setUp()},
+		{"in process", WithQueue(&events.SyncDispatcher{}, NewInProcessDriver())},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			dispatch := func() error {
+				return c.dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Defer(time.Hour), Unique("reminder", 0)))
+			}
+			assert.NoError(t, dispatch())
+			assert.True(t, errors.Is(dispatch(), ErrDuplicate))
+
+			cancelled, err := c.dispatcher.CancelDelayed(ctx, func(event *PersistedEvent) bool { return true })
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), cancelled)
+			assert.NoError(t, dispatch())
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_Cancel(t *testing.T) {
+	cases := []struct {
+		name       string
+		dispatcher *QueueableDispatcher
+	}{
+		{"redis", setUp()},
+		{"in process", WithQueue(&events.SyncDispatcher{}, NewInProcessDriver())},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			delayed, err := c.dispatcher.DispatchJob(ctx, Persist(events.Of(MockEvent{}), Defer(time.Hour)))
+			assert.NoError(t, err)
+			assert.NotEmpty(t, delayed)
+			due, err := c.dispatcher.DispatchJob(ctx, Persist(events.Of(MockEvent{})))
+			assert.NoError(t, err)
+
+			assert.NoError(t, c.dispatcher.Cancel(ctx, delayed))
+			info, _ := c.dispatcher.driver.Info(ctx)
+			assert.Equal(t, int64(0), info.Delayed)
+			assert.True(t, errors.Is(c.dispatcher.Cancel(ctx, delayed), ErrNotCancelable))
+
+			msg, err := c.dispatcher.driver.Pop(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, due, msg.UniqueId)
+			assert.True(t, errors.Is(c.dispatcher.Cancel(ctx, due), ErrNotCancelable))
+		})
+	}
+}
+
+func TestDispatcher_DispatchJob_notPersisted(t *testing.T) {
+	dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriver())
+	id, err := dispatcher.DispatchJob(context.Background(), events.Of(MockEvent{}))
+	assert.NoError(t, err)
+	assert.Empty(t, id)
+}
@@ -0,0 +1,39 @@
+package queue
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// CanceledJobPolicy decides what happens to a job whose listeners return nil after the consumer was shut down
+// mid-job. Such a run is ambiguous: a listener that ignores its context may have completed the work, or may have been
+// cut short by a canceled downstream call without reporting it.
+type CanceledJobPolicy int
+
+const (
+	// AckCanceledJob treats nil as success, and acks the job. It is the default.
+	AckCanceledJob CanceledJobPolicy = iota
+	// ReleaseCanceledJob treats the cancellation as an incomplete run, and puts the job back to the waiting queue
+	// without counting an attempt, nor its reservation if the driver implements Releaser. The job may then be handled
+	// twice, so the listeners must be idempotent.
+	ReleaseCanceledJob
+)
+
+// UseCanceledJobPolicy is an option for WithQueue that sets the CanceledJobPolicy. By default, the job is acked.
+func UseCanceledJobPolicy(policy CanceledJobPolicy) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.canceledJobPolicy = policy
+	}
+}
+
+// releaseCanceled puts the job back to the waiting queue if the consumer context is canceled and the policy says so.
+// It reports whether the job was released.
+func (d *QueueableDispatcher) releaseCanceled(ctx context.Context, msg *PersistedEvent) bool {
+	if d.canceledJobPolicy != ReleaseCanceledJob || ctx.Err() == nil {
+		return false
+	}
+	_ = level.Info(d.logger).Log("msg", "job completed after the consumer was canceled, released", "event", msg.Key)
+	d.deferJob(msg, 0)
+	return true
+}
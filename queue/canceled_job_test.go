@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_UseCanceledJobPolicy(t *testing.T) {
+	cases := []struct {
+		name    string
+		policy  CanceledJobPolicy
+		waiting int64
+	}{
+		{"ack", AckCanceledJob, 0},
+		{"release", ReleaseCanceledJob, 1},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			driver := NewInProcessDriverWithPopInterval(time.Millisecond)
+			dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseCanceledJobPolicy(c.policy))
+			started := make(chan struct{})
+			dispatcher.Subscribe(events.Listen(events.From(MockEvent{}), func(ctx context.Context, event contract.Event) error {
+				close(started)
+				<-ctx.Done()
+				return nil
+			}))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			consumed := make(chan struct{})
+			go func() {
+				dispatcher.Consume(ctx)
+				close(consumed)
+			}()
+			assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), MaxAttempts(2))))
+			<-started
+			cancel()
+			<-consumed
+
+			info, _ := driver.Info(context.Background())
+			assert.Equal(t, QueueInfo{Waiting: c.waiting}, info)
+			if c.waiting > 0 {
+				msg, err := driver.Pop(context.Background())
+				assert.NoError(t, err)
+				assert.Equal(t, 1, msg.Attempts)
+			}
+		})
+	}
+}
+
+func TestDispatcher_UseCanceledJobPolicy_redis(t *testing.T) {
+	driver := setUpReleaseDriver(t)
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseCanceledJobPolicy(ReleaseCanceledJob))
+	dispatcher.Subscribe(events.Listen(events.From(MockEvent{}), func(ctx context.Context, event contract.Event) error {
+		return nil
+	}))
+
+	ctx := context.Background()
+	unique := []PersistOption{Unique("key", time.Minute), UniqueUntil(ReleaseOnCompletion)}
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), unique...)))
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	for i := 0; i < DefaultMaxAttempts+1; i++ {
+		msg, err := driver.Pop(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, msg.Reservations)
+		dispatcher.work(canceled, msg)
+	}
+
+	info, _ := driver.Info(ctx)
+	assert.Equal(t, QueueInfo{Waiting: 1}, info)
+	err := dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), unique...))
+	assert.True(t, errors.Is(err, ErrDuplicate), err)
+}
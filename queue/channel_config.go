@@ -1,5 +1,7 @@
 package queue
 
+import "fmt"
+
 // ChannelConfig describes the key name of each queue, also known as channel.
 type ChannelConfig struct {
 	Delayed  string
@@ -7,4 +9,19 @@ type ChannelConfig struct {
 	Reserved string
 	Waiting  string
 	Timeout  string
+	// Dead holds the jobs that have been reserved more than RedisDriver.MaxAttempts times. If it is empty, the
+	// Failed key with the ":dead" suffix is used.
+	Dead string
+}
+
+// NewChannelConfig returns the ChannelConfig used by Provide for the queue of the given name.
+func NewChannelConfig(appName, env, name string) ChannelConfig {
+	return ChannelConfig{
+		Delayed:  fmt.Sprintf("{%s:%s:%s}:delayed", appName, env, name),
+		Failed:   fmt.Sprintf("{%s:%s:%s}:failed", appName, env, name),
+		Reserved: fmt.Sprintf("{%s:%s:%s}:reserved", appName, env, name),
+		Waiting:  fmt.Sprintf("{%s:%s:%s}:waiting", appName, env, name),
+		Timeout:  fmt.Sprintf("{%s:%s:%s}:timeout", appName, env, name),
+		Dead:     fmt.Sprintf("{%s:%s:%s}:dead", appName, env, name),
+	}
 }
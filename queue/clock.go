@@ -0,0 +1,89 @@
+package queue
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time to the Scheduler. It can be replaced by a ManualClock in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock of the time package.
+type SystemClock struct{}
+
+// Now implements Clock.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// After implements Clock.
+func (SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// ManualClock is a Clock whose time only moves when Advance is called. It makes the tests of scheduled jobs
+// deterministic, without real waits. ManualClock is safe for concurrent use.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []manualWaiter
+}
+
+type manualWaiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewManualClock creates a *ManualClock set to now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now implements Clock.
+func (m *ManualClock) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// After implements Clock. The channel receives when the clock is advanced past the duration.
+func (m *ManualClock) After(d time.Duration) <-chan time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := make(chan time.Time, 1)
+	if d <= 0 {
+		c <- m.now
+		return c
+	}
+	m.waiters = append(m.waiters, manualWaiter{at: m.now.Add(d), c: c})
+	return c
+}
+
+// Advance moves the clock forward, and fires the channels returned by After that are due.
+func (m *ManualClock) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+	waiters := m.waiters[:0]
+	for _, w := range m.waiters {
+		if w.at.After(m.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.c <- m.now
+	}
+	m.waiters = waiters
+}
+
+// Waiters returns the number of channels returned by After that haven't fired yet. Tests use it to wait for the
+// code under test to block on the clock before advancing it.
+func (m *ManualClock) Waiters() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.waiters)
+}
@@ -0,0 +1,61 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// DeadLetter is the record of a job that has finally failed.
+type DeadLetter struct {
+	// Queue is the name of the queue of the job.
+	Queue string
+	// UniqueId identifies the job.
+	UniqueId string
+	// Key is the type of the event.
+	Key string
+	// Payload is the serialized event.
+	Payload []byte
+	// Error is the message of the last error of the job.
+	Error string
+	// Attempts is the number of times the job has been attempted.
+	Attempts int
+	// EnqueuedAt is the time the job was dispatched.
+	EnqueuedAt time.Time
+	// FailedAt is the time the job finally failed.
+	FailedAt time.Time
+}
+
+// DeadLetterStore archives the jobs that have finally failed, for example in a database for long-term querying and
+// auditing. See otgorm.DeadLetterStore for a gorm implementation.
+type DeadLetterStore interface {
+	// StoreDeadLetter archives the failed job.
+	StoreDeadLetter(ctx context.Context, letter DeadLetter) error
+}
+
+// StoreDeadLetters returns a DeadLetterHandler that archives the failed jobs of the named queue in the store. Jobs
+// archived successfully are removed from the queue. If the store fails, they are moved to the failed channel as usual.
+func StoreDeadLetters(name string, store DeadLetterStore) DeadLetterHandler {
+	return func(ctx context.Context, failed AbortedEvent) error {
+		letter := DeadLetter{
+			Queue:      name,
+			UniqueId:   failed.Msg.UniqueId,
+			Key:        failed.Msg.Key,
+			Payload:    failed.Msg.Value,
+			Attempts:   failed.Msg.Attempts,
+			EnqueuedAt: failed.Msg.EnqueuedAt,
+			FailedAt:   time.Now(),
+		}
+		if failed.Err != nil {
+			letter.Error = failed.Err.Error()
+		}
+		return store.StoreDeadLetter(ctx, letter)
+	}
+}
+
+// UseDeadLetterStore is an option for WithQueue that archives the failed jobs of the named queue in the store,
+// instead of the failed channel. See StoreDeadLetters.
+func UseDeadLetterStore(name string, store DeadLetterStore) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.onDeadLetter = StoreDeadLetters(name, store)
+	}
+}
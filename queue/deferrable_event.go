@@ -4,7 +4,9 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/DoNewsCode/core/backoff"
 	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
 )
 
 // DeferrablePersistentEvent is a persisted event.
@@ -14,6 +16,15 @@ type DeferrablePersistentEvent struct {
 	handleTimeout time.Duration
 	maxAttempts   int
 	uniqueId      string
+	deadline      time.Time
+	routingKey    string
+	tenant        string
+	priority      int
+	retryBackoff  *backoff.Exponential
+	backoffJitter float64
+	uniqueKey     string
+	uniqueTTL     time.Duration
+	uniqueRelease UniqueRelease
 }
 
 // Defer defers the execution of the job for the period of time returned.
@@ -28,6 +39,21 @@ func (d DeferrablePersistentEvent) Decorate(s *PersistedEvent) {
 	s.HandleTimeout = d.handleTimeout
 	s.MaxAttempts = d.maxAttempts
 	s.Key = d.Type()
+	s.Deadline = d.deadline
+	s.RoutingKey = d.routingKey
+	s.Tenant = d.tenant
+	s.Priority = d.priority
+	s.UniqueKey = d.uniqueKey
+	s.UniqueTTL = d.uniqueTTL
+	s.UniqueRelease = d.uniqueRelease
+	if d.retryBackoff != nil {
+		retryBackoff := *d.retryBackoff
+		retryBackoff.Jitter = d.backoffJitter
+		s.RetryBackoff = &retryBackoff
+	}
+	if metadata, ok := events.MetadataOf(d.Event); ok {
+		s.Metadata = &metadata
+	}
 }
 
 // PersistOption defines some options for Persist
@@ -35,7 +61,7 @@ type PersistOption func(event *DeferrablePersistentEvent)
 
 // Persist converts any contract.Event to DeferrablePersistentEvent. Namely, store them in external storage.
 func Persist(event contract.Event, opts ...PersistOption) DeferrablePersistentEvent {
-	e := DeferrablePersistentEvent{Event: event, maxAttempts: 1, handleTimeout: time.Hour, uniqueId: randomId()}
+	e := DeferrablePersistentEvent{Event: event, maxAttempts: 1, uniqueId: randomId()}
 	for _, f := range opts {
 		f(&e)
 	}
@@ -56,8 +82,9 @@ func ScheduleAt(t time.Time) PersistOption {
 	}
 }
 
-// Timeout is a PersistOption that defines the maximum time the event can be processed until timeout. Note: this timeout
-// is shared among all listeners.
+// Timeout is a PersistOption that defines the maximum time the event can be processed until timeout. The job stays
+// reserved for as long, so slow jobs are not reclaimed and handled twice while still running. By default, the handle
+// timeout of the queue is used. See UseHandleTimeout. Note: this timeout is shared among all listeners.
 func Timeout(timeout time.Duration) PersistOption {
 	return func(event *DeferrablePersistentEvent) {
 		event.handleTimeout = timeout
@@ -71,6 +98,50 @@ func MaxAttempts(attempts int) PersistOption {
 	}
 }
 
+// Deadline is a PersistOption that sets an absolute deadline for the event. If the deadline has passed, or would
+// pass before the deferred execution, the dispatch is rejected with ErrDeadlineExceeded. If the deadline passes while
+// the event waits in the queue, the event is skipped and moved to the failed queue when reserved.
+func Deadline(t time.Time) PersistOption {
+	return func(event *DeferrablePersistentEvent) {
+		event.deadline = t
+	}
+}
+
+// RoutingKey is a PersistOption that sets the routing key of the event. On consumption, the event is handled by the
+// RoutedHandler registered under the same key, if any. See QueueableDispatcher.Route.
+func RoutingKey(key string) PersistOption {
+	return func(event *DeferrablePersistentEvent) {
+		event.routingKey = key
+	}
+}
+
+// Priority is a PersistOption that sets the priority of the event. With the RedisDriver, it orders the waiting events
+// if PriorityOrder is enabled, and selects the list of the event among the PriorityLevels. With UseLoadShedding, the
+// events below the CriticalPriority are rejected when the queue is over capacity. Other drivers ignore it.
+func Priority(priority int) PersistOption {
+	return func(event *DeferrablePersistentEvent) {
+		event.priority = priority
+	}
+}
+
+// Backoff is a PersistOption that sets the backoff between the attempts of the event. The n-th retry is deferred by
+// base * factor^(n-1), capped at max. A zero factor means 2. The backoff is saved along with the event, so that it is
+// honored by any consumer. By default, the retries are deferred by 1s * 2^(n-1), capped at 10 minutes, with a jitter
+// of 50%.
+func Backoff(base, max time.Duration, factor float64) PersistOption {
+	return func(event *DeferrablePersistentEvent) {
+		event.retryBackoff = &backoff.Exponential{Base: base, Max: max, Factor: factor}
+	}
+}
+
+// BackoffJitter is a PersistOption that randomizes the backoff set by Backoff by up to ±jitter of its value, so that
+// the retries of events failed at once spread out. It has no effect without Backoff.
+func BackoffJitter(jitter float64) PersistOption {
+	return func(event *DeferrablePersistentEvent) {
+		event.backoffJitter = jitter
+	}
+}
+
 // UniqueId is a PersistOption that outsources the generation of uniqueId to the caller.
 func UniqueId(id string) PersistOption {
 	return func(event *DeferrablePersistentEvent) {
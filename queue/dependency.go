@@ -9,16 +9,52 @@ import (
 	"github.com/DoNewsCode/core/config"
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/health"
+	"github.com/DoNewsCode/core/otredis"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/go-kit/kit/metrics"
 	"github.com/go-redis/redis/v8"
 	"github.com/oklog/run"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
 )
 
 // Gauge is an alias used for dependency injection
 type Gauge metrics.Gauge
 
+// ExpiredCounter is an alias used for dependency injection. It counts the events rejected at dispatch because of
+// passed deadlines.
+type ExpiredCounter metrics.Counter
+
+// OversizedCounter is an alias used for dependency injection. It counts the events rejected at dispatch because their
+// payloads are too large.
+type OversizedCounter metrics.Counter
+
+// ShedCounter is an alias used for dependency injection. It counts the events rejected at dispatch by load shedding.
+type ShedCounter metrics.Counter
+
+// SkippedCounter is an alias used for dependency injection. It counts the events skipped by DispatchMany because they
+// can't be serialized.
+type SkippedCounter metrics.Counter
+
+// ProcessedCounter is an alias used for dependency injection. It counts the jobs handled successfully.
+type ProcessedCounter metrics.Counter
+
+// FailedCounter is an alias used for dependency injection. It counts the jobs aborted after their final attempt.
+type FailedCounter metrics.Counter
+
+// RetriedCounter is an alias used for dependency injection. It counts the failed attempts that are retried.
+type RetriedCounter metrics.Counter
+
+// DurationHistogram is an alias used for dependency injection. It observes the seconds spent handling each attempt
+// of a job.
+type DurationHistogram metrics.Histogram
+
+// LatencyHistogram is an alias used for dependency injection. It observes the seconds from the dispatch of each job to
+// its completion.
+type LatencyHistogram metrics.Histogram
+
 // Dispatcher is the key of *QueueableDispatcher in the dependencies graph. Used as a type hint for injection.
 type Dispatcher interface {
 	contract.Dispatcher
@@ -30,22 +66,71 @@ type DispatcherMaker interface {
 	Make(string) (*QueueableDispatcher, error)
 }
 
+// DefaultMaxAttempts is the default ceiling of reservations of a job in the queues created by Provide. See
+// RedisDriver.MaxAttempts.
+const DefaultMaxAttempts = 5
+
 type configuration struct {
-	Parallelism                    int `yaml:"parallelism" json:"parallelism"`
-	CheckQueueLengthIntervalSecond int `yaml:"checkQueueLengthIntervalSecond" json:"checkQueueLengthIntervalSecond"`
+	Parallelism                    int            `yaml:"parallelism" json:"parallelism"`
+	CheckQueueLengthIntervalSecond int            `yaml:"checkQueueLengthIntervalSecond" json:"checkQueueLengthIntervalSecond"`
+	FailureWebhook                 string         `yaml:"failureWebhook" json:"failureWebhook"`
+	FIFO                           bool           `yaml:"fifo" json:"fifo"`
+	LivenessWindowSecond           int            `yaml:"livenessWindowSecond" json:"livenessWindowSecond"`
+	MaxPayloadBytes                int            `yaml:"maxPayloadBytes" json:"maxPayloadBytes"`
+	TenantLimits                   *TenantLimits  `yaml:"tenantLimits" json:"tenantLimits"`
+	PromotionBatchSize             int            `yaml:"promotionBatchSize" json:"promotionBatchSize"`
+	RampUpSecond                   int            `yaml:"rampUpSecond" json:"rampUpSecond"`
+	PriorityOrder                  bool           `yaml:"priorityOrder" json:"priorityOrder"`
+	PriorityLevels                 map[string]int `yaml:"priorityLevels" json:"priorityLevels"`
+	LoadShedding                   *LoadShedding  `yaml:"loadShedding" json:"loadShedding"`
+	MaxAttempts                    int            `yaml:"maxAttempts" json:"maxAttempts"`
+	Role                           Role           `yaml:"role" json:"role"`
+	SkipUnserializable             bool           `yaml:"skipUnserializable" json:"skipUnserializable"`
+	ShutdownTimeoutSecond          int            `yaml:"shutdownTimeoutSecond" json:"shutdownTimeoutSecond"`
+	Serializer                     string         `yaml:"serializer" json:"serializer"`
+	ReleaseCanceledJobs            bool           `yaml:"releaseCanceledJobs" json:"releaseCanceledJobs"`
+	HandleTimeoutSecond            int            `yaml:"handleTimeoutSecond" json:"handleTimeoutSecond"`
+	RateLimit                      *RateLimit     `yaml:"rateLimit" json:"rateLimit"`
+	MaxInFlightBytes               int64          `yaml:"maxInFlightBytes" json:"maxInFlightBytes"`
+	RedisConnection                string         `yaml:"redisConnection" json:"redisConnection"`
 }
 
 // DispatcherIn is the injection parameters for Provide
 type DispatcherIn struct {
 	di.In
 
-	Conf        contract.ConfigAccessor
-	Dispatcher  contract.Dispatcher
-	RedisClient redis.UniversalClient
-	Logger      log.Logger
-	AppName     contract.AppName
-	Env         contract.Env
-	Gauge       Gauge `optional:"true"`
+	Conf              contract.ConfigAccessor
+	Dispatcher        contract.Dispatcher
+	RedisClient       redis.UniversalClient
+	Logger            log.Logger
+	AppName           contract.AppName
+	Env               contract.Env
+	Gauge             Gauge              `optional:"true"`
+	ExpiredCounter    ExpiredCounter     `optional:"true"`
+	OversizedCounter  OversizedCounter   `optional:"true"`
+	ShedCounter       ShedCounter        `optional:"true"`
+	SkippedCounter    SkippedCounter     `optional:"true"`
+	LatencyHistogram  LatencyHistogram   `optional:"true"`
+	ProcessedCounter  ProcessedCounter   `optional:"true"`
+	FailedCounter     FailedCounter      `optional:"true"`
+	RetriedCounter    RetriedCounter     `optional:"true"`
+	DurationHistogram DurationHistogram  `optional:"true"`
+	Tracer            opentracing.Tracer `optional:"true"`
+	WorkerBudget      *WorkerBudget      `optional:"true"`
+	DeadLetterStore   DeadLetterStore    `optional:"true"`
+	Middlewares       *Middlewares       `optional:"true"`
+	RedisMaker        otredis.Maker      `optional:"true"`
+}
+
+// redisClient returns the client of the named redis connection, or the shared client if the name is empty.
+func (p DispatcherIn) redisClient(connection string) (redis.UniversalClient, error) {
+	if connection == "" {
+		return p.RedisClient, nil
+	}
+	if p.RedisMaker == nil {
+		return nil, fmt.Errorf("redis connection %s requires an otredis.Maker", connection)
+	}
+	return p.RedisMaker.Make(connection)
 }
 
 // DispatcherOut is the di output of Provide
@@ -57,7 +142,8 @@ type DispatcherOut struct {
 	DispatcherMaker     DispatcherMaker
 	QueueableDispatcher *QueueableDispatcher
 	DispatcherFactory   *DispatcherFactory
-	ExportedConfig      []config.ExportedConfig `group:"config,flatten"`
+	HealthCheckers      []contract.HealthChecker `group:"health,flatten"`
+	ExportedConfig      []config.ExportedConfig  `group:"config,flatten"`
 }
 
 // Provide is a provider for *DispatcherFactory and *QueueableDispatcher.
@@ -71,6 +157,11 @@ func Provide(p DispatcherIn) (DispatcherOut, error) {
 	if err != nil {
 		level.Warn(p.Logger).Log("err", err)
 	}
+	logger := log.With(p.Logger, "tag", "queue")
+	var names []string
+	for name := range queueConfs {
+		names = append(names, name)
+	}
 	factory := di.NewFactory(func(name string) (di.Pair, error) {
 		var (
 			ok   bool
@@ -79,52 +170,163 @@ func Provide(p DispatcherIn) (DispatcherOut, error) {
 		if conf, ok = queueConfs[name]; !ok {
 			return di.Pair{}, fmt.Errorf("queue configuration %s not found", name)
 		}
+		if err := conf.Role.validate(); err != nil {
+			return di.Pair{}, errors.Wrapf(err, "queue configuration %s not valid", name)
+		}
+		if conf.PriorityOrder && len(conf.PriorityLevels) > 0 {
+			return di.Pair{}, fmt.Errorf("queue configuration %s not valid: priorityOrder and priorityLevels are exclusive", name)
+		}
+		serializer, err := serializerOf(conf.Serializer)
+		if err != nil {
+			return di.Pair{}, errors.Wrapf(err, "queue configuration %s not valid", name)
+		}
+		redisClient, err := p.redisClient(conf.RedisConnection)
+		if err != nil {
+			return di.Pair{}, errors.Wrapf(err, "queue configuration %s not valid", name)
+		}
+		var gauge metrics.Gauge
 		if p.Gauge != nil {
-			p.Gauge = p.Gauge.With("queue", name)
+			gauge = p.Gauge.With("queue", name)
 		}
 		redisDriver := &RedisDriver{
-			Logger:      p.Logger,
-			RedisClient: p.RedisClient,
-			ChannelConfig: ChannelConfig{
-				Delayed:  fmt.Sprintf("{%s:%s:%s}:delayed", p.AppName.String(), p.Env.String(), name),
-				Failed:   fmt.Sprintf("{%s:%s:%s}:failed", p.AppName.String(), p.Env.String(), name),
-				Reserved: fmt.Sprintf("{%s:%s:%s}:reserved", p.AppName.String(), p.Env.String(), name),
-				Waiting:  fmt.Sprintf("{%s:%s:%s}:waiting", p.AppName.String(), p.Env.String(), name),
-				Timeout:  fmt.Sprintf("{%s:%s:%s}:timeout", p.AppName.String(), p.Env.String(), name),
-			},
+			Logger:             logger,
+			RedisClient:        redisClient,
+			ChannelConfig:      NewChannelConfig(p.AppName.String(), p.Env.String(), name),
+			PromotionBatchSize: conf.PromotionBatchSize,
+			PriorityOrder:      conf.PriorityOrder,
+			PriorityLevels:     conf.PriorityLevels,
+			MaxAttempts:        conf.MaxAttempts,
 		}
-		queuedDispatcher := WithQueue(
-			p.Dispatcher,
-			redisDriver,
-			UseLogger(p.Logger),
+		if conf.MaxAttempts == 0 {
+			redisDriver.MaxAttempts = DefaultMaxAttempts
+		}
+		opts := []func(*QueueableDispatcher){
+			UseLogger(logger),
 			UseParallelism(conf.Parallelism),
-			UseGauge(p.Gauge, time.Duration(conf.CheckQueueLengthIntervalSecond)*time.Second),
-		)
+			UseGauge(gauge, time.Duration(conf.CheckQueueLengthIntervalSecond)*time.Second),
+			UseFIFO(conf.FIFO),
+			UseLivenessWindow(time.Duration(conf.LivenessWindowSecond) * time.Second),
+			UseRampUp(time.Duration(conf.RampUpSecond) * time.Second),
+			UseRole(conf.Role),
+			UseWorkerBudget(p.WorkerBudget),
+			UseShutdownTimeout(time.Duration(conf.ShutdownTimeoutSecond) * time.Second),
+			UseHandleTimeout(time.Duration(conf.HandleTimeoutSecond) * time.Second),
+			UseMaxInFlightBytes(conf.MaxInFlightBytes),
+		}
+		if p.ExpiredCounter != nil {
+			opts = append(opts, UseExpiredCounter(p.ExpiredCounter.With("queue", name)))
+		}
+		if p.OversizedCounter != nil {
+			opts = append(opts, UseOversizedCounter(p.OversizedCounter.With("queue", name)))
+		}
+		if conf.RateLimit != nil {
+			opts = append(opts, UseRateLimit(conf.RateLimit.Rate, conf.RateLimit.Burst))
+		}
+		if conf.LoadShedding != nil {
+			opts = append(opts, UseLoadShedding(*conf.LoadShedding))
+		}
+		if p.ShedCounter != nil {
+			opts = append(opts, UseShedCounter(p.ShedCounter.With("queue", name)))
+		}
+		if conf.ReleaseCanceledJobs {
+			opts = append(opts, UseCanceledJobPolicy(ReleaseCanceledJob))
+		}
+		if serializer != nil {
+			opt, err := UseSerializer(redisDriver, serializer)
+			if err != nil {
+				return di.Pair{}, errors.Wrapf(err, "queue configuration %s not valid", name)
+			}
+			opts = append(opts, opt)
+		}
+		if conf.SkipUnserializable {
+			opts = append(opts, UseSerializationPolicy(SkipOnSerializationError))
+		}
+		if p.SkippedCounter != nil {
+			opts = append(opts, UseSkippedCounter(p.SkippedCounter.With("queue", name)))
+		}
+		if p.LatencyHistogram != nil {
+			opts = append(opts, UseLatencyHistogram(p.LatencyHistogram.With("queue", name)))
+		}
+		if p.ProcessedCounter != nil || p.FailedCounter != nil || p.RetriedCounter != nil {
+			opts = append(opts, UseCounters(
+				withQueueLabel(p.ProcessedCounter, name),
+				withQueueLabel(p.FailedCounter, name),
+				withQueueLabel(p.RetriedCounter, name),
+			))
+		}
+		if p.DurationHistogram != nil {
+			opts = append(opts, UseDurationHistogram(p.DurationHistogram.With("queue", name)))
+		}
+		if p.Tracer != nil {
+			opts = append(opts, UseTracer(p.Tracer))
+		}
+		if p.DeadLetterStore != nil {
+			opts = append(opts, UseDeadLetterStore(name, p.DeadLetterStore))
+		}
+		if p.Middlewares != nil {
+			opts = append(opts, UseMiddleware(p.Middlewares.For(name)...))
+		}
+		if conf.TenantLimits != nil {
+			opts = append(opts, UseTenantLimiter(&RedisTenantLimiter{
+				Client: redisClient,
+				Limits: conf.TenantLimits.Of,
+				Prefix: fmt.Sprintf("{%s:%s:%s}:tenant", p.AppName.String(), p.Env.String(), name),
+			}))
+		}
+		if conf.MaxPayloadBytes != 0 {
+			opts = append(opts, UseMaxPayloadSize(conf.MaxPayloadBytes))
+		}
+		if conf.FailureWebhook != "" {
+			opts = append(opts, UseWebhookNotifier(&WebhookNotifier{
+				Queue:  name,
+				URL:    conf.FailureWebhook,
+				Logger: logger,
+			}))
+		}
+		queuedDispatcher := WithQueue(p.Dispatcher, redisDriver, opts...)
 		return di.Pair{
 			Closer: nil,
 			Conn:   queuedDispatcher,
 		}, nil
-	})
+	}, di.WithNames(names...))
 
-	// QueueableDispatcher must be created eagerly, so that the consumer goroutines can start on boot up.
+	// QueueableDispatcher must be created eagerly, so that the consumer goroutines can start on boot up. A queue that
+	// can't be made would never be consumed, so it fails the provider.
+	var healthCheckers []contract.HealthChecker
 	for name := range queueConfs {
-		factory.Make(name)
+		if _, err := factory.Make(name); err != nil {
+			return DispatcherOut{}, errors.Wrapf(err, "unable to make the queue %s", name)
+		}
+		healthCheckers = append(healthCheckers, provideHealthChecker(factory, name))
 	}
 
 	dispatcherFactory := &DispatcherFactory{Factory: factory}
-	defaultQueueableDispatcher, _ := dispatcherFactory.Make("default")
+	var defaultQueueableDispatcher *QueueableDispatcher
+	if _, ok := queueConfs["default"]; ok {
+		if defaultQueueableDispatcher, err = dispatcherFactory.Make("default"); err != nil {
+			return DispatcherOut{}, errors.Wrap(err, "unable to make the default queue")
+		}
+	} else {
+		// Persisted events sent to the default dispatcher fail with ErrQueueNotConfigured, rather than a nil pointer.
+		level.Warn(logger).Log("msg", "default queue is not configured, dispatching persisted events to it will fail")
+		defaultQueueableDispatcher = WithQueue(p.Dispatcher, unconfiguredDriver{name: "default"}, UseLogger(logger))
+	}
 	return DispatcherOut{
 		QueueableDispatcher: defaultQueueableDispatcher,
 		Dispatcher:          defaultQueueableDispatcher,
 		DispatcherFactory:   dispatcherFactory,
 		DispatcherMaker:     dispatcherFactory,
+		HealthCheckers:      healthCheckers,
 		ExportedConfig:      provideConfig(),
 	}, nil
 }
 
-// ProvideRunGroup implements RunProvider.
+// ProvideRunGroup implements RunProvider. Queues of the ProducerOnly role are not consumed.
 func (d DispatcherOut) ProvideRunGroup(group *run.Group) {
-	for name := range d.DispatcherFactory.List() {
+	for name, pair := range d.DispatcherFactory.List() {
+		if dispatcher, ok := pair.Conn.(*QueueableDispatcher); ok && dispatcher.Role() == ProducerOnly {
+			continue
+		}
 		queueName := name
 		ctx, cancel := context.WithCancel(context.Background())
 		group.Add(func() error {
@@ -145,15 +347,14 @@ func (d DispatcherOut) ProvideRunGroup(group *run.Group) {
 //
 // Here is an example on how to create a custom DispatcherFactory with an InProcessDriver.
 //
-//		factory := di.NewFactory(func(name string) (di.Pair, error) {
-//			queuedDispatcher := queue.WithQueue(
-//				&events.SyncDispatcher{},
-//				queue.NewInProcessDriver(),
-//			)
-//			return di.Pair{Conn: queuedDispatcher}, nil
-//		})
-//		dispatcherFactory := DispatcherFactory{Factory: factory}
-//
+//	factory := di.NewFactory(func(name string) (di.Pair, error) {
+//		queuedDispatcher := queue.WithQueue(
+//			&events.SyncDispatcher{},
+//			queue.NewInProcessDriver(),
+//		)
+//		return di.Pair{Conn: queuedDispatcher}, nil
+//	})
+//	dispatcherFactory := DispatcherFactory{Factory: factory}
 type DispatcherFactory struct {
 	*di.Factory
 }
@@ -168,6 +369,20 @@ func (s *DispatcherFactory) Make(name string) (*QueueableDispatcher, error) {
 	return client.(*QueueableDispatcher), nil
 }
 
+func provideHealthChecker(factory *di.Factory, name string) contract.HealthChecker {
+	return health.NewChecker("queue."+name, func(ctx context.Context) error {
+		dispatcher, err := factory.Make(name)
+		if err != nil {
+			return err
+		}
+		if err := dispatcher.(*QueueableDispatcher).checkLiveness(); err != nil {
+			return err
+		}
+		_, err = dispatcher.(*QueueableDispatcher).Driver().Info(ctx)
+		return err
+	})
+}
+
 func provideConfig() []config.ExportedConfig {
 	return []config.ExportedConfig{{
 		Owner: "queue",
@@ -176,6 +391,9 @@ func provideConfig() []config.ExportedConfig {
 				"default": {
 					Parallelism:                    runtime.NumCPU(),
 					CheckQueueLengthIntervalSecond: 15,
+					MaxPayloadBytes:                DefaultMaxPayloadSize,
+					PromotionBatchSize:             100,
+					MaxAttempts:                    DefaultMaxAttempts,
 				},
 			},
 		},
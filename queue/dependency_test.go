@@ -1,25 +1,35 @@
 package queue
 
 import (
+	"context"
+	"errors"
+
 	"github.com/DoNewsCode/core/config"
 	"github.com/DoNewsCode/core/di"
 	"github.com/DoNewsCode/core/events"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/generic"
 	"github.com/go-redis/redis/v8"
+	"github.com/knadh/koanf/parsers/json"
+	"github.com/knadh/koanf/providers/rawbytes"
+	"github.com/oklog/run"
 	"github.com/stretchr/testify/assert"
+	"os"
+	"runtime"
 	"testing"
+	"time"
 )
 
 func TestProvideDispatcher(t *testing.T) {
 	out, err := Provide(DispatcherIn{
 		Conf: config.MapAdapter{"queue": map[string]configuration{
 			"default": {
-				1,
-				5,
+				Parallelism:                    1,
+				CheckQueueLengthIntervalSecond: 5,
 			},
 			"alternative": {
-				3,
-				5,
+				Parallelism:                    3,
+				CheckQueueLengthIntervalSecond: 5,
 			},
 		}},
 		Dispatcher:  &events.SyncDispatcher{},
@@ -37,4 +47,180 @@ func TestProvideDispatcher(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, def)
 	assert.Implements(t, (*di.Module)(nil), out)
+	assert.Len(t, out.HealthCheckers, 2)
+	for _, checker := range out.HealthCheckers {
+		assert.NoError(t, checker.HealthCheck(context.Background()), checker.Name())
+	}
+}
+
+func TestProvideDispatcher_missingDefault(t *testing.T) {
+	out, err := Provide(DispatcherIn{
+		Conf: config.MapAdapter{"queue": map[string]configuration{
+			"alternative": {Parallelism: 1},
+		}},
+		Dispatcher:  &events.SyncDispatcher{},
+		RedisClient: redis.NewUniversalClient(&redis.UniversalOptions{}),
+		Logger:      log.NewNopLogger(),
+		AppName:     config.AppName("test"),
+		Env:         config.NewEnv("testing"),
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, out.QueueableDispatcher)
+	assert.NotNil(t, out.Dispatcher)
+	assert.Len(t, out.DispatcherFactory.List(), 1)
+
+	err = out.Dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{})))
+	assert.True(t, errors.Is(err, ErrQueueNotConfigured), err)
+}
+
+func TestProvideDispatcher_independentParallelism(t *testing.T) {
+	gauge := generic.NewGauge("queue_length")
+	out, err := Provide(DispatcherIn{
+		Conf: config.MapAdapter{"queue": map[string]configuration{
+			"default": {
+				Parallelism:                    1,
+				CheckQueueLengthIntervalSecond: 5,
+			},
+			"busy": {
+				Parallelism:                    100,
+				CheckQueueLengthIntervalSecond: 5,
+			},
+			"unset": {
+				CheckQueueLengthIntervalSecond: 5,
+			},
+		}},
+		Dispatcher:  &events.SyncDispatcher{},
+		RedisClient: redis.NewUniversalClient(&redis.UniversalOptions{}),
+		Logger:      log.NewNopLogger(),
+		AppName:     config.AppName("test"),
+		Env:         config.NewEnv("testing"),
+		Gauge:       gauge,
+	})
+	assert.NoError(t, err)
+
+	def, _ := out.DispatcherMaker.Make("default")
+	busy, _ := out.DispatcherMaker.Make("busy")
+	unset, _ := out.DispatcherMaker.Make("unset")
+	assert.Equal(t, 1, def.parallelism)
+	assert.Equal(t, 100, busy.parallelism)
+	assert.Equal(t, runtime.NumCPU(), unset.parallelism)
+
+	assert.Equal(t, []string{"queue", "default"}, def.queueLengthGauge.(*generic.Gauge).LabelValues())
+	assert.Equal(t, []string{"queue", "busy"}, busy.queueLengthGauge.(*generic.Gauge).LabelValues())
+	assert.Empty(t, gauge.LabelValues())
+}
+
+func TestProvideDispatcher_env(t *testing.T) {
+	os.Setenv("QUEUETEST_QUEUE_DEFAULT_PARALLELISM", "7")
+	os.Setenv("QUEUETEST_QUEUE_DEFAULT_CHECKQUEUELENGTHINTERVALSECOND", "3")
+	defer os.Unsetenv("QUEUETEST_QUEUE_DEFAULT_PARALLELISM")
+	defer os.Unsetenv("QUEUETEST_QUEUE_DEFAULT_CHECKQUEUELENGTHINTERVALSECOND")
+
+	conf, err := config.NewConfig(
+		config.WithProviderLayer(config.NewEnvProvider("QUEUETEST_"), nil),
+		config.WithProviderLayer(rawbytes.Provider([]byte(`{"queue":{"default":{"parallelism":1,"checkQueueLengthIntervalSecond":15}}}`)), json.Parser()),
+	)
+	assert.NoError(t, err)
+	out, err := Provide(DispatcherIn{
+		Conf:        conf,
+		Dispatcher:  &events.SyncDispatcher{},
+		RedisClient: redis.NewUniversalClient(&redis.UniversalOptions{}),
+		Logger:      log.NewNopLogger(),
+		AppName:     config.AppName("test"),
+		Env:         config.NewEnv("testing"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 7, out.QueueableDispatcher.parallelism)
+	assert.Equal(t, 3*time.Second, out.QueueableDispatcher.checkQueueLengthInterval)
+}
+
+func TestProvideDispatcher_role(t *testing.T) {
+	out, err := Provide(DispatcherIn{
+		Conf: config.MapAdapter{"queue": map[string]configuration{
+			"default":  {Parallelism: 1, Role: ProducerOnly},
+			"consumer": {Parallelism: 1, Role: ConsumerOnly},
+		}},
+		Dispatcher:  &events.SyncDispatcher{},
+		RedisClient: redis.NewUniversalClient(&redis.UniversalOptions{}),
+		Logger:      log.NewNopLogger(),
+		AppName:     config.AppName("test"),
+		Env:         config.NewEnv("testing"),
+	})
+	assert.NoError(t, err)
+
+	consumer, err := out.DispatcherFactory.Make("consumer")
+	assert.NoError(t, err)
+	err = consumer.Dispatch(context.Background(), Persist(events.Of(MockEvent{})))
+	assert.True(t, errors.Is(err, ErrConsumerOnly), err)
+
+	var group run.Group
+	out.ProvideRunGroup(&group)
+	group.Add(func() error {
+		for consumer.LastActivity().IsZero() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return nil
+	}, func(err error) {})
+	assert.NoError(t, group.Run())
+	assert.True(t, out.QueueableDispatcher.LastActivity().IsZero())
+
+	_, err = Provide(DispatcherIn{
+		Conf:        config.MapAdapter{"queue": map[string]configuration{"default": {Role: "both"}}},
+		Dispatcher:  &events.SyncDispatcher{},
+		RedisClient: redis.NewUniversalClient(&redis.UniversalOptions{}),
+		Logger:      log.NewNopLogger(),
+		AppName:     config.AppName("test"),
+		Env:         config.NewEnv("testing"),
+	})
+	assert.Error(t, err)
+}
+
+type mockRedisMaker map[string]redis.UniversalClient
+
+func (m mockRedisMaker) Make(name string) (redis.UniversalClient, error) {
+	client, ok := m[name]
+	if !ok {
+		return nil, errors.New("redis connection not found")
+	}
+	return client, nil
+}
+
+func TestProvideDispatcher_redisConnection(t *testing.T) {
+	shared := redis.NewUniversalClient(&redis.UniversalOptions{})
+	isolated := redis.NewUniversalClient(&redis.UniversalOptions{DB: 1})
+	out, err := Provide(DispatcherIn{
+		Conf: config.MapAdapter{"queue": map[string]configuration{
+			"default":  {Parallelism: 1},
+			"isolated": {Parallelism: 1, RedisConnection: "isolated"},
+		}},
+		Dispatcher:  &events.SyncDispatcher{},
+		RedisClient: shared,
+		RedisMaker:  mockRedisMaker{"isolated": isolated},
+		Logger:      log.NewNopLogger(),
+		AppName:     config.AppName("test"),
+		Env:         config.NewEnv("testing"),
+	})
+	assert.NoError(t, err)
+
+	def, err := out.DispatcherFactory.Make("default")
+	assert.NoError(t, err)
+	assert.Equal(t, shared, def.Driver().(*RedisDriver).RedisClient)
+	dispatcher, err := out.DispatcherFactory.Make("isolated")
+	assert.NoError(t, err)
+	assert.Equal(t, isolated, dispatcher.Driver().(*RedisDriver).RedisClient)
+
+	_, err = Provide(DispatcherIn{
+		Conf: config.MapAdapter{"queue": map[string]configuration{
+			"default": {Parallelism: 1},
+			"missing": {Parallelism: 1, RedisConnection: "missing"},
+		}},
+		Dispatcher:  &events.SyncDispatcher{},
+		RedisClient: shared,
+		RedisMaker:  mockRedisMaker{"isolated": isolated},
+		Logger:      log.NewNopLogger(),
+		AppName:     config.AppName("test"),
+		Env:         config.NewEnv("testing"),
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "missing")
 }
@@ -0,0 +1,175 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// BatchPusher is implemented by drivers that can push many messages in a single round trip. See DispatchBatch.
+type BatchPusher interface {
+	// PushBatch pushes each message after its delay. If some messages can't be pushed, a *BatchError indexed by the
+	// position of the messages is returned.
+	PushBatch(ctx context.Context, messages []*PersistedEvent, delays []time.Duration) error
+}
+
+// BatchError reports the events of a batch that couldn't be enqueued. The others have been enqueued.
+type BatchError struct {
+	// Errors maps the index of each failed event to its error.
+	Errors map[int]error
+}
+
+// Error implements error.
+func (b *BatchError) Error() string {
+	var indexes []int
+	for i := range b.Errors {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	var msgs []string
+	for _, i := range indexes {
+		msgs = append(msgs, fmt.Sprintf("event %d: %s", i, b.Errors[i]))
+	}
+	return fmt.Sprintf("%d events not dispatched: %s", len(b.Errors), strings.Join(msgs, "; "))
+}
+
+func (b *BatchError) add(i int, err error) {
+	if b.Errors == nil {
+		b.Errors = make(map[int]error)
+	}
+	b.Errors[i] = err
+}
+
+// DispatchBatch dispatches the events to the queue. If the driver implements BatchPusher, as RedisDriver does, they
+// are enqueued in a single round trip. Events that aren't persistent are persisted with the options, as if by
+// Persist; persistent events keep their own options. Otherwise, each event is treated as if dispatched individually:
+// delays, deadlines, payload limits and load shedding all apply. If some events are not enqueued, a *BatchError is
+// returned, and the rest are enqueued nonetheless.
+func (d *QueueableDispatcher) DispatchBatch(ctx context.Context, events []contract.Event, opts ...PersistOption) error {
+	var (
+		batchErr BatchError
+		indexes  []int
+		messages []*PersistedEvent
+		delays   []time.Duration
+	)
+	for i, e := range events {
+		p, ok := e.(persistent)
+		if !ok {
+			persisted := Persist(e, opts...)
+			e, p = persisted, persisted
+		}
+		msg, err := d.persist(ctx, e, p)
+		if err != nil {
+			batchErr.add(i, err)
+			continue
+		}
+		indexes = append(indexes, i)
+		messages = append(messages, msg)
+		delays = append(delays, p.Defer())
+	}
+	if pusher, ok := d.driver.(BatchPusher); ok && len(messages) > 0 {
+		err := pusher.PushBatch(ctx, messages, delays)
+		var pushErr *BatchError
+		switch {
+		case errors.As(err, &pushErr):
+			for j, err := range pushErr.Errors {
+				batchErr.add(indexes[j], err)
+			}
+		case err != nil:
+			for _, i := range indexes {
+				batchErr.add(i, err)
+			}
+		}
+	} else {
+		for j, msg := range messages {
+			if err := d.driver.Push(ctx, msg, delays[j]); err != nil {
+				batchErr.add(indexes[j], err)
+			}
+		}
+	}
+	if len(batchErr.Errors) > 0 {
+		return &batchErr
+	}
+	return nil
+}
+
+// PushBatch implements BatchPusher. The messages are pushed in a single pipeline. Unlike Push, the batch is not
+// atomic: the failure of one message doesn't prevent the others.
+func (r *RedisDriver) PushBatch(ctx context.Context, messages []*PersistedEvent, delays []time.Duration) error {
+	r.populateDefaults()
+	var (
+		batchErr BatchError
+		indexes  []int
+		now      = time.Now()
+		p        = r.RedisClient.Pipeline()
+	)
+	for i, message := range messages {
+		data, err := r.Packer.Compress(message)
+		if err != nil {
+			batchErr.add(i, errors.Wrap(err, "failed to compress message"))
+			continue
+		}
+		// The unique guards take a round trip each, so that duplicates are dropped before the pipeline.
+		if err := r.guard(ctx, message); err != nil {
+			batchErr.add(i, err)
+			continue
+		}
+		if delays[i] <= 0 {
+			if err := r.pushWaiting(ctx, p, string(data), now); err != nil {
+				batchErr.add(i, err)
+				if message.guarded() {
+					r.unguard(ctx, message)
+				}
+				continue
+			}
+		} else {
+			p.ZAdd(ctx, r.ChannelConfig.Delayed, &redis.Z{
+				Score:  float64(now.Add(delays[i]).Unix()),
+				Member: data,
+			})
+		}
+		indexes = append(indexes, i)
+	}
+	if len(indexes) > 0 {
+		cmds, err := p.Exec(ctx)
+		if len(cmds) != len(indexes) {
+			for _, i := range indexes {
+				batchErr.add(i, errors.Wrap(err, "failed to push batch"))
+			}
+		}
+		for j, cmd := range cmds {
+			if cmd.Err() != nil {
+				batchErr.add(indexes[j], errors.Wrap(cmd.Err(), "failed to push message"))
+			}
+		}
+		for _, i := range indexes {
+			if _, failed := batchErr.Errors[i]; failed && messages[i].guarded() {
+				r.unguard(ctx, messages[i])
+			}
+		}
+	}
+	if len(batchErr.Errors) > 0 {
+		return &batchErr
+	}
+	return nil
+}
+
+// PushBatch implements BatchPusher by pushing the messages one by one.
+func (i *InProcessDriver) PushBatch(ctx context.Context, messages []*PersistedEvent, delays []time.Duration) error {
+	var batchErr BatchError
+	for j, message := range messages {
+		if err := i.Push(ctx, message, delays[j]); err != nil {
+			batchErr.add(j, err)
+		}
+	}
+	if len(batchErr.Errors) > 0 {
+		return &batchErr
+	}
+	return nil
+}
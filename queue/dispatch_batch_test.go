@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_DispatchBatch(t *testing.T) {
+	ctx := context.Background()
+	redisDriver := &RedisDriver{
+		RedisClient:   redis.NewUniversalClient(&redis.UniversalOptions{}),
+		ChannelConfig: NewChannelConfig("app", "testing", "batch"),
+	}
+	cases := []struct {
+		name   string
+		driver Driver
+	}{
+		{"in process", NewInProcessDriver()},
+		{"redis", redisDriver},
+		{"mirror", &MirrorDriver{Primary: redisDriver, Mirrors: []Mirror{{Driver: NewInProcessDriver()}}}},
+		{"mirror in process", &MirrorDriver{Primary: plainDriver{NewInProcessDriver()}, Mirrors: []Mirror{{Driver: NewInProcessDriver()}}}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			dispatcher := WithQueue(&events.SyncDispatcher{}, c.driver, UseMaxPayloadSize(1000))
+			primary := c.driver
+			if mirror, ok := c.driver.(*MirrorDriver); ok {
+				primary = mirror.Primary
+			}
+			if primary == redisDriver {
+				for _, channel := range []string{redisDriver.ChannelConfig.Waiting, redisDriver.ChannelConfig.Delayed, redisDriver.ChannelConfig.Reserved} {
+					redisDriver.Flush(ctx, channel)
+					defer redisDriver.Flush(ctx, channel)
+				}
+			}
+
+			err := dispatcher.DispatchBatch(ctx, []contract.Event{
+				events.Of(MockEvent{Value: "delayed by the options"}),
+				Persist(events.Of(MockEvent{Value: "due"})),
+				events.Of(MockEvent{Value: strings.Repeat("x", 1000)}),
+			}, Defer(time.Hour))
+
+			var batchErr *BatchError
+			assert.True(t, errors.As(err, &batchErr))
+			assert.Len(t, batchErr.Errors, 1)
+			assert.True(t, errors.Is(batchErr.Errors[2], ErrPayloadTooLarge))
+			info, err := c.driver.Info(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, QueueInfo{Waiting: 1, Delayed: 1}, info)
+			if mirror, ok := c.driver.(*MirrorDriver); ok {
+				info, err := mirror.Mirrors[0].Driver.Info(ctx)
+				assert.NoError(t, err)
+				assert.Equal(t, QueueInfo{Waiting: 1, Delayed: 1}, info)
+			}
+
+			msg, err := c.driver.Pop(ctx)
+			assert.NoError(t, err)
+			var payload MockEvent
+			assert.NoError(t, dispatcher.packer.Decompress(msg.Value, &payload))
+			assert.Equal(t, "due", payload.Value)
+		})
+	}
+}
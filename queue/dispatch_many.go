@@ -0,0 +1,91 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+	"github.com/pkg/errors"
+)
+
+// SerializationError means the payload of a persisted event can't be serialized by the Packer, for example because
+// its type is not registered with encoding/gob.
+type SerializationError struct {
+	// Type is the type of the event.
+	Type string
+	// Err is the error of the Packer.
+	Err error
+}
+
+// Error implements error.
+func (s *SerializationError) Error() string {
+	return fmt.Sprintf("dispatch deferrable %s failed: %s", s.Type, s.Err)
+}
+
+// Unwrap returns the error of the Packer.
+func (s *SerializationError) Unwrap() error {
+	return s.Err
+}
+
+// SerializationPolicy decides what DispatchMany does with an event that can't be serialized.
+type SerializationPolicy int
+
+const (
+	// HaltOnSerializationError stops DispatchMany at the event, and returns the *SerializationError. It is the
+	// default.
+	HaltOnSerializationError SerializationPolicy = iota
+	// SkipOnSerializationError logs and counts the event, and carries on with the rest. The skipped events are
+	// returned to the caller.
+	SkipOnSerializationError
+)
+
+// SkippedEvent is an event skipped by DispatchMany.
+type SkippedEvent struct {
+	// Index is the position of the event in the arguments of DispatchMany.
+	Index int
+	// Event is the skipped event.
+	Event contract.Event
+	// Err is the *SerializationError of the event.
+	Err error
+}
+
+// UseSerializationPolicy is an option for WithQueue that sets the SerializationPolicy of DispatchMany. By default,
+// it halts at the first event that can't be serialized.
+func UseSerializationPolicy(policy SerializationPolicy) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.serializationPolicy = policy
+	}
+}
+
+// UseSkippedCounter is an option for WithQueue that counts the events skipped by DispatchMany. See
+// UseSerializationPolicy.
+func UseSkippedCounter(counter metrics.Counter) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.skippedCounter = counter
+	}
+}
+
+// DispatchMany dispatches the events in order. Other errors stop it at the failed event, and so do serialization
+// errors, unless the SkipOnSerializationError policy is in effect. The events before the failed one have been
+// dispatched, and those after it have not. The events skipped under the SkipOnSerializationError policy are returned,
+// so that the caller can report or repair them.
+func (d *QueueableDispatcher) DispatchMany(ctx context.Context, events ...contract.Event) ([]SkippedEvent, error) {
+	var skipped []SkippedEvent
+	for i, e := range events {
+		err := d.Dispatch(ctx, e)
+		if err == nil {
+			continue
+		}
+		var serializationErr *SerializationError
+		if d.serializationPolicy == SkipOnSerializationError && errors.As(err, &serializationErr) {
+			_ = level.Warn(d.logger).Log("err", errors.Wrapf(err, "event %d skipped", i))
+			d.count(ctx, d.skippedCounter)
+			skipped = append(skipped, SkippedEvent{Index: i, Event: e, Err: err})
+			continue
+		}
+		return skipped, errors.Wrapf(err, "failed to dispatch event %d of %d", i, len(events))
+	}
+	return skipped, nil
+}
@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+)
+
+type unserializable struct {
+	C chan int
+}
+
+func TestQueueableDispatcher_DispatchMany(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("halt", func(t *testing.T) {
+		driver := NewInProcessDriver()
+		dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseLogger(log.NewNopLogger()))
+		skipped, err := dispatcher.DispatchMany(ctx,
+			Persist(events.Of(MockEvent{})),
+			Persist(events.Of(unserializable{})),
+			Persist(events.Of(MockEvent{})),
+		)
+		var serializationErr *SerializationError
+		assert.True(t, errors.As(err, &serializationErr), err)
+		assert.Empty(t, skipped)
+		assert.Len(t, driver.waiting, 1)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		driver := NewInProcessDriver()
+		counter := generic.NewCounter("skipped")
+		dispatcher := WithQueue(
+			&events.SyncDispatcher{},
+			driver,
+			UseSerializationPolicy(SkipOnSerializationError),
+			UseSkippedCounter(counter),
+		)
+		skipped, err := dispatcher.DispatchMany(ctx,
+			Persist(events.Of(MockEvent{})),
+			Persist(events.Of(unserializable{})),
+			Persist(events.Of(MockEvent{})),
+		)
+		assert.NoError(t, err)
+		assert.Len(t, skipped, 1)
+		assert.Equal(t, 1, skipped[0].Index)
+		assert.IsType(t, &SerializationError{}, skipped[0].Err)
+		assert.Len(t, driver.waiting, 2)
+		assert.Equal(t, 1.0, counter.Value())
+	})
+
+	t.Run("other errors halt", func(t *testing.T) {
+		dispatcher := WithQueue(
+			&events.SyncDispatcher{},
+			NewInProcessDriver(),
+			UseLogger(log.NewNopLogger()),
+			UseSerializationPolicy(SkipOnSerializationError),
+			UseRole(ConsumerOnly),
+		)
+		_, err := dispatcher.DispatchMany(ctx, Persist(events.Of(MockEvent{})))
+		assert.True(t, errors.Is(err, ErrConsumerOnly), err)
+	})
+}
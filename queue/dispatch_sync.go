@@ -0,0 +1,197 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// completionTTL is how long the outcome of an awaited job is kept in redis for the waiting producer.
+const completionTTL = 10 * time.Minute
+
+// CompletionNotifier is implemented by drivers that can relay the outcome of awaited jobs from the consumer to the
+// producer, which may run in different processes. See DispatchSync.
+type CompletionNotifier interface {
+	// NotifyCompletion reports the outcome of the job with the unique ID. A nil jobErr means success.
+	NotifyCompletion(ctx context.Context, uniqueId string, jobErr error) error
+	// AwaitCompletion blocks until the outcome of the job with the unique ID is reported, or the context is done. It
+	// returns the error of the job, and an error if the outcome couldn't be awaited.
+	AwaitCompletion(ctx context.Context, uniqueId string) (jobErr error, err error)
+}
+
+// completionNotifier returns the CompletionNotifier of the driver. A MirrorDriver is one only if its primary is, as
+// the outcomes are reported where the jobs are consumed.
+func completionNotifier(driver Driver) (CompletionNotifier, bool) {
+	if mirror, ok := driver.(*MirrorDriver); ok {
+		if _, ok := completionNotifier(mirror.Primary); !ok {
+			return nil, false
+		}
+	}
+	notifier, ok := driver.(CompletionNotifier)
+	return notifier, ok
+}
+
+// awaitedEvent marks a persisted event as awaited by its producer.
+type awaitedEvent struct {
+	contract.Event
+	persistent persistent
+	decorated  func(s *PersistedEvent)
+}
+
+func (a awaitedEvent) Defer() time.Duration {
+	return a.persistent.Defer()
+}
+
+func (a awaitedEvent) Decorate(s *PersistedEvent) {
+	a.persistent.Decorate(s)
+	if s.UniqueId == "" {
+		s.UniqueId = randomId()
+	}
+	s.Awaited = true
+	a.decorated(s)
+}
+
+// DispatchSync dispatches the persisted event, and blocks until it is handled. It returns the error of the handler,
+// or the error of the context if it is done first. Retries are waited for, so the error is that of the final attempt.
+// Events that aren't persisted are dispatched as usual.
+//
+// DispatchSync couples the caller to the availability of a consumer: if none is running, it blocks until the context
+// is done. Always pass a context with a deadline. If the driver implements CompletionNotifier, as RedisDriver does,
+// or is a MirrorDriver whose primary does, the job may be handled by any process, and only the message of its error
+// is preserved. Otherwise, it must be handled by the consumer of this dispatcher.
+func (d *QueueableDispatcher) DispatchSync(ctx context.Context, event contract.Event) error {
+	p, ok := event.(persistent)
+	if !ok {
+		return d.Dispatch(ctx, event)
+	}
+	notifier, remote := completionNotifier(d.driver)
+	var (
+		uniqueId string
+		done     chan error
+	)
+	err := d.Dispatch(ctx, awaitedEvent{Event: event, persistent: p, decorated: func(s *PersistedEvent) {
+		uniqueId = s.UniqueId
+		if !remote {
+			done = d.await(uniqueId)
+		}
+	}})
+	if err != nil {
+		d.forget(uniqueId)
+		return err
+	}
+	if remote {
+		jobErr, err := notifier.AwaitCompletion(ctx, uniqueId)
+		if err != nil {
+			return err
+		}
+		return jobErr
+	}
+	select {
+	case jobErr := <-done:
+		return jobErr
+	case <-ctx.Done():
+		d.forget(uniqueId)
+		return ctx.Err()
+	}
+}
+
+// await registers a waiter for the job handled by this dispatcher.
+func (d *QueueableDispatcher) await(uniqueId string) chan error {
+	done := make(chan error, 1)
+	d.waitersLock.Lock()
+	defer d.waitersLock.Unlock()
+	if d.waiters == nil {
+		d.waiters = make(map[string]chan error)
+	}
+	d.waiters[uniqueId] = done
+	return done
+}
+
+func (d *QueueableDispatcher) forget(uniqueId string) {
+	d.waitersLock.Lock()
+	defer d.waitersLock.Unlock()
+	delete(d.waiters, uniqueId)
+}
+
+// complete reports the outcome of an awaited job to its producer.
+func (d *QueueableDispatcher) complete(msg *PersistedEvent, jobErr error) {
+	if !msg.Awaited {
+		return
+	}
+	if notifier, ok := completionNotifier(d.driver); ok {
+		if err := notifier.NotifyCompletion(context.Background(), msg.UniqueId, jobErr); err != nil {
+			_ = level.Warn(d.logger).Log("err", errors.Wrapf(err, "failed to notify the completion of job %s", msg.UniqueId))
+		}
+		return
+	}
+	d.waitersLock.Lock()
+	done, ok := d.waiters[msg.UniqueId]
+	delete(d.waiters, msg.UniqueId)
+	d.waitersLock.Unlock()
+	if ok {
+		done <- jobErr
+	}
+}
+
+// completion is the outcome of an awaited job in redis.
+type completion struct {
+	Err string `json:"err,omitempty"`
+}
+
+// completionKey returns the list holding the outcome of the job. It shares the hash tag of the waiting queue.
+func (r *RedisDriver) completionKey(uniqueId string) string {
+	return fmt.Sprintf("%s:completion:%s", r.ChannelConfig.Waiting, uniqueId)
+}
+
+// NotifyCompletion implements CompletionNotifier. The outcome is kept for a few minutes.
+func (r *RedisDriver) NotifyCompletion(ctx context.Context, uniqueId string, jobErr error) error {
+	r.populateDefaults()
+	var c completion
+	if jobErr != nil {
+		c.Err = jobErr.Error()
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal completion")
+	}
+	key := r.completionKey(uniqueId)
+	pipe := r.RedisClient.TxPipeline()
+	pipe.LPush(ctx, key, data)
+	pipe.Expire(ctx, key, completionTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errors.Wrap(err, "failed to push completion")
+	}
+	return nil
+}
+
+// AwaitCompletion implements CompletionNotifier. The context is checked at least once per PopTimeout.
+func (r *RedisDriver) AwaitCompletion(ctx context.Context, uniqueId string) (error, error) {
+	r.populateDefaults()
+	key := r.completionKey(uniqueId)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		res, err := r.RedisClient.BRPop(ctx, r.PopTimeout, key).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to await completion")
+		}
+		var c completion
+		if err := json.Unmarshal([]byte(res[1]), &c); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal completion")
+		}
+		if c.Err != "" {
+			return errors.New(c.Err), nil
+		}
+		return nil, nil
+	}
+}
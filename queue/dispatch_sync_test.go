@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_DispatchSync(t *testing.T) {
+	redisDriver := &RedisDriver{
+		RedisClient:   redis.NewUniversalClient(&redis.UniversalOptions{}),
+		ChannelConfig: NewChannelConfig("app", "testing", "sync"),
+		PopTimeout:    time.Second,
+	}
+	cases := []struct {
+		name   string
+		driver Driver
+		remote bool
+	}{
+		{"in process", NewInProcessDriverWithPopInterval(time.Millisecond), false},
+		{"redis", redisDriver, true},
+		{"mirror in process", &MirrorDriver{Primary: NewInProcessDriverWithPopInterval(time.Millisecond)}, false},
+		{"mirror redis", &MirrorDriver{Primary: redisDriver, Mirrors: []Mirror{{Driver: NewInProcessDriver()}}}, true},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			producer := WithQueue(&events.SyncDispatcher{}, c.driver)
+			consumer := producer
+			if c.remote {
+				// The job may be handled by another process.
+				consumer = WithQueue(&events.SyncDispatcher{}, c.driver)
+				defer redisDriver.Flush(context.Background(), redisDriver.ChannelConfig.Waiting)
+				defer redisDriver.Flush(context.Background(), redisDriver.ChannelConfig.Failed)
+			}
+			consumer.Subscribe(events.Listen(events.From(MockEvent{}), func(ctx context.Context, event contract.Event) error {
+				if event.Data().(MockEvent).Value == "fail" {
+					return errors.New("failed")
+				}
+				return nil
+			}))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go consumer.Consume(ctx)
+
+			timeout, cancelTimeout := context.WithTimeout(ctx, 5*time.Second)
+			defer cancelTimeout()
+			assert.NoError(t, producer.DispatchSync(timeout, Persist(events.Of(MockEvent{Value: "ok"}))))
+			err := producer.DispatchSync(timeout, Persist(events.Of(MockEvent{Value: "fail"}), MaxAttempts(2), Backoff(time.Millisecond, time.Millisecond, 1)))
+			assert.EqualError(t, err, "failed")
+		})
+	}
+}
+
+func TestDispatcher_DispatchSync_timeout(t *testing.T) {
+	dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriver())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := dispatcher.DispatchSync(ctx, Persist(events.Of(MockEvent{})))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Empty(t, dispatcher.waiters)
+}
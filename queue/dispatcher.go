@@ -2,7 +2,6 @@ package queue
 
 import (
 	"context"
-	"fmt"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/go-kit/kit/metrics"
@@ -14,7 +13,9 @@ import (
 
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/events"
+	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
 )
 
 // persistent is an interface that describes a persisted event.
@@ -23,8 +24,23 @@ type persistent interface {
 	Decorate(s *PersistedEvent)
 }
 
+// ErrDeadlineExceeded means the deadline of the event has passed before it is handled.
+var ErrDeadlineExceeded = errors.New("event deadline exceeded")
+
+// ErrUnknownType means the type of a persisted event is neither subscribed nor registered, so it cannot be
+// deserialized. Events of unknown types are moved to the failed channel without retrying.
+var ErrUnknownType = errors.New("unknown event type")
+
+// ErrPayloadTooLarge means the serialized event exceeds the maximum payload size of the queue.
+var ErrPayloadTooLarge = errors.New("event payload too large")
+
+// DefaultMaxPayloadSize is the default maximum size of a serialized event, in bytes.
+const DefaultMaxPayloadSize = 1 << 20
+
 // QueueableDispatcher is an extension of the embed dispatcher. It adds the persistent event feature.
 type QueueableDispatcher struct {
+	// lastActivity is accessed atomically. It is the first field to be 64-bit aligned on 32-bit platforms.
+	lastActivity             int64
 	logger                   log.Logger
 	driver                   Driver
 	packer                   Packer
@@ -34,37 +50,130 @@ type QueueableDispatcher struct {
 	parallelism              int
 	queueLengthGauge         metrics.Gauge
 	checkQueueLengthInterval time.Duration
+	webhook                  *WebhookNotifier
+	errorHistory             *errorHistory
+	expiredCounter           metrics.Counter
+	fifo                     bool
+	routes                   map[string]RoutedHandler
+	onDeadLetter             DeadLetterHandler
+	drainFile                string
+	livenessWindow           time.Duration
+	maxPayloadSize           int
+	oversizedCounter         metrics.Counter
+	tenantLimiter            TenantLimiter
+	rampUp                   time.Duration
+	tracer                   opentracing.Tracer
+	exemplar                 ExemplarFunc
+	latencyHistogram         metrics.Histogram
+	loadShedder              *loadShedder
+	shedCounter              metrics.Counter
+	progressTTL              time.Duration
+	role                     Role
+	serializationPolicy      SerializationPolicy
+	skippedCounter           metrics.Counter
+	workerBudget             *WorkerBudget
+	activeWorkers            int32
+	shutdownTimeout          time.Duration
+	canceledJobPolicy        CanceledJobPolicy
+	handleTimeout            time.Duration
+	waitersLock              sync.Mutex
+	waiters                  map[string]chan error
+	rateLimiter              *rate.Limiter
+	inFlightBytes            *inFlightBytes
+	middlewares              []Middleware
+	processedCounter         metrics.Counter
+	failedCounter            metrics.Counter
+	retriedCounter           metrics.Counter
+	durationHistogram        metrics.Histogram
 }
 
 // Dispatch dispatches an event. See contract.Dispatcher.
 func (d *QueueableDispatcher) Dispatch(ctx context.Context, e contract.Event) error {
-	if _, ok := e.(*PersistedEvent); ok {
+	if msg, ok := e.(*PersistedEvent); ok {
+		if handler := d.route(msg.RoutingKey); handler != nil {
+			return handler(ctx, msg)
+		}
 		rType := d.reflectType(e.Type())
 		if rType == nil {
-			return fmt.Errorf("unable to reverse engineer the event %s", e.Type())
+			return errors.Wrapf(ErrUnknownType, "unable to reverse engineer the event %s", e.Type())
 		}
 		ptr := reflect.New(rType)
 		err := d.packer.Decompress(e.Data().([]byte), ptr)
 		if err != nil {
 			return errors.Wrapf(err, "dispatch serialized %s failed", e.Type())
 		}
+		if msg.Metadata != nil {
+			return d.base.Dispatch(ctx, events.New(ptr.Elem().Interface(), events.WithMetadata(*msg.Metadata)))
+		}
 		return d.base.Dispatch(ctx, events.Of(ptr.Elem().Interface()))
 	}
-	if _, ok := e.(persistent); ok {
-		data, err := d.packer.Compress(e.Data())
-		if err != nil {
-			return errors.Wrapf(err, "dispatch deferrable %s failed", e.Type())
-		}
-		msg := &PersistedEvent{
-			Attempts: 1,
-			Value:    data,
-		}
-		e.(persistent).Decorate(msg)
-		return d.driver.Push(ctx, msg, e.(persistent).Defer())
+	if p, ok := e.(persistent); ok {
+		_, err := d.push(ctx, e, p)
+		return err
 	}
 	return d.base.Dispatch(ctx, e)
 }
 
+// push persists the persistent event, and pushes it to the driver.
+func (d *QueueableDispatcher) push(ctx context.Context, e contract.Event, p persistent) (*PersistedEvent, error) {
+	msg, err := d.persist(ctx, e, p)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.driver.Push(ctx, msg, p.Defer()); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// persist serializes and decorates the persistent event, and checks that it can be pushed to the driver.
+func (d *QueueableDispatcher) persist(ctx context.Context, e contract.Event, p persistent) (*PersistedEvent, error) {
+	if d.role == ConsumerOnly {
+		return nil, errors.Wrapf(ErrConsumerOnly, "dispatch deferrable %s rejected", e.Type())
+	}
+	data, err := d.packer.Compress(e.Data())
+	if err != nil {
+		return nil, &SerializationError{Type: e.Type(), Err: err}
+	}
+	if d.maxPayloadSize > 0 && len(data) > d.maxPayloadSize {
+		d.count(ctx, d.oversizedCounter)
+		return nil, errors.Wrapf(ErrPayloadTooLarge, "dispatch deferrable %s rejected: %d bytes exceeds the limit of %d bytes", e.Type(), len(data), d.maxPayloadSize)
+	}
+	msg := &PersistedEvent{
+		Attempts:   1,
+		Value:      data,
+		EnqueuedAt: time.Now(),
+	}
+	p.Decorate(msg)
+	if msg.HandleTimeout <= 0 {
+		msg.HandleTimeout = d.handleTimeout
+	}
+	if msg.expired(time.Now().Add(p.Defer())) {
+		d.count(ctx, d.expiredCounter)
+		return nil, errors.Wrapf(ErrDeadlineExceeded, "dispatch deferrable %s rejected", e.Type())
+	}
+	if d.shed(ctx, msg) {
+		d.count(ctx, d.shedCounter)
+		return nil, errors.Wrapf(ErrOverCapacity, "dispatch deferrable %s of priority %d shed", e.Type(), msg.Priority)
+	}
+	return msg, nil
+}
+
+// RegisterType registers the type of payload under the name, so that persisted events whose Key is the name are
+// deserialized into that type. Subscribe registers the types of the subscribed events under their default names
+// automatically. RegisterType is needed for names other than the default, such as those set by producers in other
+// languages, eg:
+//
+//  dispatcher.RegisterType("user.created", UserCreated{})
+//
+// The registry belongs to the dispatcher, unlike the global registry of encoding/gob. The deserialized event is
+// delivered to the listeners of the payload type.
+func (d *QueueableDispatcher) RegisterType(name string, payload interface{}) {
+	d.rwLock.Lock()
+	defer d.rwLock.Unlock()
+	d.reflectTypes[name] = reflect.TypeOf(payload)
+}
+
 // Subscribe subscribes an event. See contract.Dispatcher.
 func (d *QueueableDispatcher) Subscribe(listener contract.Listener) {
 	d.rwLock.Lock()
@@ -75,17 +184,40 @@ func (d *QueueableDispatcher) Subscribe(listener contract.Listener) {
 	d.base.Subscribe(listener)
 }
 
+// SubscribedTypes returns the event types subscribed on the underlying dispatcher, if it reports them. It allows
+// events.Registry to validate the queue.
+func (d *QueueableDispatcher) SubscribedTypes() []string {
+	if subscriber, ok := d.base.(interface{ SubscribedTypes() []string }); ok {
+		return subscriber.SubscribedTypes()
+	}
+	return nil
+}
+
 // Consume starts the runner and blocks until context canceled or error occurred.
 func (d *QueueableDispatcher) Consume(ctx context.Context) error {
 	if d.logger == nil {
 		d.logger = log.NewNopLogger()
 	}
+	if err := d.restore(ctx); err != nil {
+		return err
+	}
+	defer d.drain()
+
 	var jobChan = make(chan *PersistedEvent)
 	g, ctx := errgroup.WithContext(ctx)
+	workCtx, stop := d.gracefulContext(ctx)
+	defer stop()
 
 	g.Go(func() error {
 		defer close(jobChan)
 		for {
+			d.heartbeat()
+			if err := d.inFlightBytes.wait(ctx); err != nil {
+				return err
+			}
+			if err := d.waitRate(ctx); err != nil {
+				return err
+			}
 			msg, err := d.driver.Pop(ctx)
 			if errors.Is(err, ErrEmpty) {
 				continue
@@ -93,6 +225,12 @@ func (d *QueueableDispatcher) Consume(ctx context.Context) error {
 			if err != nil {
 				return err
 			}
+			d.inFlightBytes.add(msg)
+			if d.fifo {
+				d.withinBudget(workCtx, func() { d.workInOrder(workCtx, msg) })
+				d.inFlightBytes.release(msg)
+				continue
+			}
 			jobChan <- msg
 		}
 	})
@@ -115,10 +253,27 @@ func (d *QueueableDispatcher) Consume(ctx context.Context) error {
 		})
 	}
 
-	for i := 0; i < d.parallelism; i++ {
+	workers := d.parallelism
+	if d.fifo {
+		workers = 0
+	}
+	for i := 0; i < workers; i++ {
+		delay := d.rampUpDelay(i)
 		g.Go(func() error {
+			if delay > 0 {
+				timer := time.NewTimer(delay)
+				defer timer.Stop()
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					return nil
+				}
+			}
 			for msg := range jobChan {
-				d.work(ctx, msg)
+				msg := msg
+				d.withinBudget(workCtx, func() { d.work(workCtx, msg) })
+				d.inFlightBytes.release(msg)
+				d.heartbeat()
 			}
 			return nil
 		})
@@ -130,23 +285,116 @@ func (d *QueueableDispatcher) Driver() Driver {
 	return d.driver
 }
 
+// Info returns the number of jobs in each channel of the queue, for example to build a status page or a dashboard.
+// See Driver.Info.
+func (d *QueueableDispatcher) Info(ctx context.Context) (QueueInfo, error) {
+	return d.driver.Info(ctx)
+}
+
 func (d *QueueableDispatcher) work(ctx context.Context, msg *PersistedEvent) {
-	ctx, cancel := context.WithTimeout(ctx, msg.HandleTimeout)
+	if msg.expired(time.Now()) {
+		_ = level.Warn(d.logger).Log("err", errors.Wrapf(ErrDeadlineExceeded, "event %s skipped", msg.Key))
+		d.abort(msg, ErrDeadlineExceeded)
+		return
+	}
+	if wait := d.throttle(ctx, msg); wait > 0 {
+		d.deferJob(msg, jitter(wait))
+		return
+	}
+	handleCtx, cancel := context.WithTimeout(ctx, msg.HandleTimeout)
 	defer cancel()
-	err := d.Dispatch(ctx, msg)
+	next, err := d.handle(handleCtx, msg)
 	if err != nil {
-		if msg.Attempts < msg.MaxAttempts {
+		if msg.Attempts < msg.MaxAttempts && !errors.Is(err, ErrUnknownType) {
+			d.recordError(msg, err)
 			_ = level.Info(d.logger).Log("err", errors.Wrapf(err, "event %s failed %d times, retrying", msg.Key, msg.Attempts))
 			_ = d.Dispatch(context.Background(), events.Of(RetryingEvent{Err: err, Msg: msg}))
-			_ = d.driver.Retry(context.Background(), msg)
+			d.released(msg, d.driver.Retry(context.Background(), msg))
+			d.count(ctx, d.retriedCounter)
 			return
 		}
 		_ = level.Warn(d.logger).Log("err", errors.Wrapf(err, "event %s failed after %d Attempts, aborted", msg.Key, msg.MaxAttempts))
-		_ = d.Dispatch(context.Background(), events.Of(AbortedEvent{Err: err, Msg: msg}))
-		_ = d.driver.Fail(context.Background(), msg)
+		d.abort(msg, err)
 		return
 	}
-	_ = d.driver.Ack(context.Background(), msg)
+	if d.releaseCanceled(ctx, msg) {
+		return
+	}
+	d.released(msg, d.ack(msg, next))
+	d.observeLatency(msg)
+	d.count(ctx, d.processedCounter)
+	d.complete(msg, nil)
+}
+
+func (d *QueueableDispatcher) abort(msg *PersistedEvent, err error) {
+	d.abortReserved(msg, msg, err)
+}
+
+// abortReserved aborts the msg. The reserved job is the one known to the driver, which may differ from msg in FIFO
+// mode.
+func (d *QueueableDispatcher) abortReserved(reserved, msg *PersistedEvent, err error) {
+	d.recordError(msg, err)
+	d.observeLatency(msg)
+	d.count(context.Background(), d.failedCounter)
+	_ = d.Dispatch(context.Background(), events.Of(AbortedEvent{Err: err, Msg: msg}))
+	if d.webhook != nil {
+		d.webhook.Notify(AbortedEvent{Err: err, Msg: msg})
+	}
+	if handler := d.deadLetterHandler(); handler != nil {
+		handlerErr := handler(context.Background(), AbortedEvent{Err: err, Msg: msg})
+		if handlerErr == nil {
+			d.released(msg, d.driver.Ack(context.Background(), reserved))
+			d.complete(msg, err)
+			return
+		}
+		_ = level.Warn(d.logger).Log("err", errors.Wrapf(handlerErr, "dead letter handler of event %s failed", msg.Key))
+	}
+	d.released(msg, d.driver.Fail(context.Background(), reserved))
+	d.complete(msg, err)
+}
+
+// released logs the error of releasing the reserved msg, if its lease has been lost. The job is then owned by another
+// reservation, and the outcome of this one is discarded.
+func (d *QueueableDispatcher) released(msg *PersistedEvent, err error) {
+	if errors.Is(err, ErrLeaseLost) {
+		_ = level.Warn(d.logger).Log("err", errors.Wrapf(err, "outcome of event %s discarded", msg.Key))
+	}
+}
+
+func (d *QueueableDispatcher) recordError(msg *PersistedEvent, err error) {
+	if d.errorHistory != nil {
+		d.errorHistory.add(ErrorRecord{Time: time.Now(), Event: msg.Key, Attempts: msg.Attempts, Err: err})
+	}
+}
+
+// DeadLetterHandler handles a job that has finally failed, either because it has exhausted its attempts or because
+// its deadline has passed.
+type DeadLetterHandler func(ctx context.Context, failed AbortedEvent) error
+
+// OnDeadLetter registers a handler that is called when a job of this queue finally fails, after the
+// "queue.AbortedEvent" is fired. If the handler returns nil, the job is considered handled and is removed from the
+// queue instead of being moved to the failed channel. Otherwise, the error is logged and the job is moved to the
+// failed channel as usual. Registering a new handler replaces the previous one.
+func (d *QueueableDispatcher) OnDeadLetter(handler DeadLetterHandler) {
+	d.rwLock.Lock()
+	defer d.rwLock.Unlock()
+	d.onDeadLetter = handler
+}
+
+func (d *QueueableDispatcher) deadLetterHandler() DeadLetterHandler {
+	d.rwLock.RLock()
+	defer d.rwLock.RUnlock()
+	return d.onDeadLetter
+}
+
+// RecentErrors returns the most recent errors occurred while handling jobs in
+// this queue, ordered from the newest to the oldest. The number of errors kept
+// is bounded, see UseErrorHistory.
+func (d *QueueableDispatcher) RecentErrors() []ErrorRecord {
+	if d.errorHistory == nil {
+		return nil
+	}
+	return d.errorHistory.list()
 }
 
 func (d *QueueableDispatcher) reflectType(typeName string) reflect.Type {
@@ -162,8 +410,10 @@ func (d *QueueableDispatcher) gauge(ctx context.Context) {
 	}
 	d.queueLengthGauge.With("channel", "failed").Set(float64(queueInfo.Failed))
 	d.queueLengthGauge.With("channel", "delayed").Set(float64(queueInfo.Delayed))
+	d.queueLengthGauge.With("channel", "reserved").Set(float64(queueInfo.Reserved))
 	d.queueLengthGauge.With("channel", "timeout").Set(float64(queueInfo.Timeout))
 	d.queueLengthGauge.With("channel", "waiting").Set(float64(queueInfo.Waiting))
+	d.queueLengthGauge.With("channel", "dead").Set(float64(queueInfo.Dead))
 }
 
 // UsePacker allows consumer to replace the default Packer with a custom one. UsePacker is an option for WithQueue.
@@ -173,6 +423,14 @@ func UsePacker(packer Packer) func(*QueueableDispatcher) {
 	}
 }
 
+// UseHandleTimeout is an option for WithQueue that sets the handle timeout of the jobs dispatched without the Timeout
+// option. By default, it is DefaultHandleTimeout.
+func UseHandleTimeout(timeout time.Duration) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.handleTimeout = timeout
+	}
+}
+
 // UseLogger is an option for WithQueue that feeds the queue with a Logger of choice.
 func UseLogger(logger log.Logger) func(*QueueableDispatcher) {
 	return func(dispatcher *QueueableDispatcher) {
@@ -180,9 +438,13 @@ func UseLogger(logger log.Logger) func(*QueueableDispatcher) {
 	}
 }
 
-// UseParallelism is an option for WithQueue that sets the parallelism for queue consumption
+// UseParallelism is an option for WithQueue that sets the parallelism for queue consumption.
+// Non-positive values are ignored, leaving the default parallelism (runtime.NumCPU) in place.
 func UseParallelism(parallelism int) func(*QueueableDispatcher) {
 	return func(dispatcher *QueueableDispatcher) {
+		if parallelism <= 0 {
+			return
+		}
 		dispatcher.parallelism = parallelism
 	}
 }
@@ -195,17 +457,79 @@ func UseGauge(gauge metrics.Gauge, interval time.Duration) func(*QueueableDispat
 	}
 }
 
+// UseWebhookNotifier is an option for WithQueue that notifies an external webhook when a job is aborted, or moved to
+// the dead channel by the driver.
+func UseWebhookNotifier(notifier *WebhookNotifier) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.webhook = notifier
+		if driver, ok := dispatcher.driver.(buryNotifier); ok {
+			driver.onBury(notifier.notifyBuried)
+		}
+	}
+}
+
+// UseExpiredCounter is an option for WithQueue that counts the events rejected at dispatch because their
+// deadlines would have passed before they could be handled. See Deadline.
+func UseExpiredCounter(counter metrics.Counter) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.expiredCounter = counter
+	}
+}
+
+// UseMaxPayloadSize is an option for WithQueue that sets the maximum size of a serialized event, in bytes. Larger
+// events are rejected at dispatch with ErrPayloadTooLarge, rather than being pushed to the driver. By default, the
+// limit is DefaultMaxPayloadSize. Non-positive values remove the limit.
+func UseMaxPayloadSize(size int) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.maxPayloadSize = size
+	}
+}
+
+// UseOversizedCounter is an option for WithQueue that counts the events rejected at dispatch because their payloads
+// exceed the maximum size. See UseMaxPayloadSize.
+func UseOversizedCounter(counter metrics.Counter) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.oversizedCounter = counter
+	}
+}
+
+// UseFIFO is an option for WithQueue that enables the FIFO mode, in which jobs are handled and completed strictly
+// in the order they are popped. In FIFO mode, the parallelism is ignored and there is no prefetching. A failed job is
+// retried in place, blocking the jobs behind it, until it succeeds or runs out of attempts. All attempts must complete
+// within the HandleTimeout of the job. This greatly limits the throughput, so only use it when ordering matters, eg.
+// for event-sourced aggregates.
+func UseFIFO(fifo bool) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.fifo = fifo
+	}
+}
+
+// UseErrorHistory is an option for WithQueue that sets how many recent errors are kept in memory for
+// RecentErrors. By default, the last 10 errors are kept. Non-positive values disable the history.
+func UseErrorHistory(size int) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		if size <= 0 {
+			dispatcher.errorHistory = nil
+			return
+		}
+		dispatcher.errorHistory = newErrorHistory(size)
+	}
+}
+
 // WithQueue wraps a QueueableDispatcher and returns a decorated QueueableDispatcher. The latter QueueableDispatcher now can send and
 // listen to "persisted" events. Those persisted events will guarantee at least one execution, as they are stored in an
 // external storage and won't be released until the QueueableDispatcher acknowledges the end of execution.
 func WithQueue(baseDispatcher contract.Dispatcher, driver Driver, opts ...func(*QueueableDispatcher)) *QueueableDispatcher {
 	qd := QueueableDispatcher{
-		driver:       driver,
-		packer:       packer{},
-		rwLock:       sync.RWMutex{},
-		reflectTypes: make(map[string]reflect.Type),
-		base:         baseDispatcher,
-		parallelism:  runtime.NumCPU(),
+		driver:         driver,
+		packer:         packer{},
+		rwLock:         sync.RWMutex{},
+		reflectTypes:   make(map[string]reflect.Type),
+		base:           baseDispatcher,
+		parallelism:    runtime.NumCPU(),
+		errorHistory:   newErrorHistory(10),
+		maxPayloadSize: DefaultMaxPayloadSize,
+		logger:         log.NewNopLogger(),
 	}
 	for _, f := range opts {
 		f(&qd)
@@ -5,11 +5,14 @@ import (
 	"errors"
 	"go.uber.org/atomic"
 	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/events"
 	"github.com/DoNewsCode/core/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/generic"
 	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 
@@ -281,3 +284,213 @@ func TestDispatcher_Consume(t *testing.T) {
 		})
 	}
 }
+
+func TestDispatcher_Consume_parallelism(t *testing.T) {
+	for _, parallelism := range []int{1, 4} {
+		var (
+			running atomic.Int32
+			peak    atomic.Int32
+			done    = make(chan struct{}, 8)
+		)
+		dispatcher := WithQueue(
+			&events.SyncDispatcher{},
+			NewInProcessDriverWithPopInterval(time.Millisecond),
+			UseParallelism(parallelism),
+		)
+		dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+			n := running.Inc()
+			for {
+				p := peak.Load()
+				if n <= p || peak.CAS(p, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			running.Dec()
+			done <- struct{}{}
+			return nil
+		}))
+		ctx, cancel := context.WithCancel(context.Background())
+		go dispatcher.Consume(ctx)
+		for i := 0; i < 8; i++ {
+			assert.NoError(t, dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{}))))
+		}
+		for i := 0; i < 8; i++ {
+			<-done
+		}
+		cancel()
+		assert.Equal(t, int32(parallelism), peak.Load())
+	}
+}
+
+func TestDispatcher_Deadline(t *testing.T) {
+	counter := generic.NewCounter("expired")
+	driver := NewInProcessDriver()
+	dispatcher := WithQueue(
+		&events.SyncDispatcher{},
+		driver,
+		UseLogger(log.NewNopLogger()),
+		UseExpiredCounter(counter),
+	)
+	var (
+		handled int
+		aborted []error
+	)
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		handled++
+		return nil
+	}))
+	dispatcher.Subscribe(AbortedListener(func(ctx context.Context, event contract.Event) error {
+		aborted = append(aborted, event.Data().(AbortedEvent).Err)
+		return nil
+	}))
+
+	// rejected at dispatch
+	err := dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{}), Deadline(time.Now().Add(-time.Second))))
+	assert.True(t, errors.Is(err, ErrDeadlineExceeded))
+	err = dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{}), Defer(time.Hour), Deadline(time.Now().Add(time.Minute))))
+	assert.True(t, errors.Is(err, ErrDeadlineExceeded))
+	assert.Equal(t, 2.0, counter.Value())
+
+	// skipped at reservation
+	err = dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{}), Deadline(time.Now().Add(10*time.Millisecond))))
+	assert.NoError(t, err)
+	msg, err := driver.Pop(context.Background())
+	assert.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	dispatcher.work(context.Background(), msg)
+	assert.Equal(t, 0, handled)
+	assert.Equal(t, []error{ErrDeadlineExceeded}, aborted)
+	info, _ := driver.Info(context.Background())
+	assert.Equal(t, int64(1), info.Failed)
+
+	// handled before deadline
+	err = dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{}), Deadline(time.Now().Add(time.Minute))))
+	assert.NoError(t, err)
+	msg, err = driver.Pop(context.Background())
+	assert.NoError(t, err)
+	dispatcher.work(context.Background(), msg)
+	assert.Equal(t, 1, handled)
+}
+
+func TestDispatcher_OnDeadLetter(t *testing.T) {
+	driver := NewInProcessDriver()
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseLogger(log.NewNopLogger()))
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		return errors.New("always fails")
+	}))
+	var (
+		deadLetters []AbortedEvent
+		handlerErr  error
+	)
+	dispatcher.OnDeadLetter(func(ctx context.Context, failed AbortedEvent) error {
+		deadLetters = append(deadLetters, failed)
+		return handlerErr
+	})
+
+	cases := []struct {
+		name       string
+		handlerErr error
+		failed     int64
+	}{
+		{"handled", nil, 0},
+		{"not handled", errors.New("cannot compensate"), 1},
+	}
+	for i, c := range cases {
+		handlerErr = c.handlerErr
+		err := dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{}), MaxAttempts(1)))
+		assert.NoError(t, err, c.name)
+		msg, err := driver.Pop(context.Background())
+		assert.NoError(t, err, c.name)
+		dispatcher.work(context.Background(), msg)
+		assert.Len(t, deadLetters, i+1, c.name)
+		assert.EqualError(t, deadLetters[i].Err, "always fails", c.name)
+		info, _ := driver.Info(context.Background())
+		assert.Equal(t, c.failed, info.Failed, c.name)
+	}
+}
+
+func TestDispatcher_Metadata(t *testing.T) {
+	driver := NewInProcessDriver()
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver)
+	var received []events.Metadata
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		if metadata, ok := events.MetadataOf(event); ok {
+			received = append(received, metadata)
+		}
+		return nil
+	}))
+
+	for _, event := range []contract.Event{
+		events.Of(MockEvent{}),
+		events.New(MockEvent{}, events.WithID("1"), events.WithCorrelationID("2")),
+	} {
+		assert.NoError(t, dispatcher.Dispatch(context.Background(), Persist(event)))
+		msg, err := driver.Pop(context.Background())
+		assert.NoError(t, err)
+		dispatcher.work(context.Background(), msg)
+	}
+	assert.Len(t, received, 1)
+	assert.Equal(t, "1", received[0].ID)
+	assert.Equal(t, "2", received[0].CorrelationID)
+}
+
+func TestDispatcher_MaxPayloadSize(t *testing.T) {
+	counter := generic.NewCounter("oversized")
+	driver := NewInProcessDriver()
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseMaxPayloadSize(100), UseOversizedCounter(counter))
+
+	err := dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{Value: strings.Repeat("a", 100)})))
+	assert.True(t, errors.Is(err, ErrPayloadTooLarge))
+	assert.Equal(t, 1.0, counter.Value())
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{Value: "a"}))))
+	info, _ := driver.Info(context.Background())
+	assert.Equal(t, int64(1), info.Waiting)
+
+	// the default limit is generous but finite.
+	dispatcher = WithQueue(&events.SyncDispatcher{}, driver)
+	err = dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{Value: strings.Repeat("a", DefaultMaxPayloadSize)})))
+	assert.True(t, errors.Is(err, ErrPayloadTooLarge))
+	dispatcher = WithQueue(&events.SyncDispatcher{}, driver, UseMaxPayloadSize(0))
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{Value: strings.Repeat("a", DefaultMaxPayloadSize)}))))
+}
+
+func TestDispatcher_RegisterType(t *testing.T) {
+	driver := NewInProcessDriver()
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseLogger(log.NewNopLogger()))
+	var received []string
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		received = append(received, event.Data().(MockEvent).Value)
+		return nil
+	}))
+	dispatcher.RegisterType("mock.event", MockEvent{})
+
+	data, err := dispatcher.packer.Compress(MockEvent{Value: "foreign"})
+	assert.NoError(t, err)
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), &PersistedEvent{Key: "mock.event", Value: data}))
+	assert.Equal(t, []string{"foreign"}, received)
+
+	// unknown types are failed without retrying.
+	assert.NoError(t, driver.Push(context.Background(), &PersistedEvent{Key: "unknown", Value: data, Attempts: 1, MaxAttempts: 3}, 0))
+	msg, err := driver.Pop(context.Background())
+	assert.NoError(t, err)
+	dispatcher.work(context.Background(), msg)
+	info, _ := driver.Info(context.Background())
+	assert.Equal(t, QueueInfo{Failed: 1}, info)
+	assert.True(t, errors.Is(dispatcher.RecentErrors()[0].Err, ErrUnknownType))
+}
+
+func TestDispatcher_Info(t *testing.T) {
+	ctx := context.Background()
+	driver := NewInProcessDriverWithPopInterval(time.Millisecond)
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseLogger(log.NewNopLogger()))
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}))))
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}))))
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Defer(time.Hour))))
+	_, err := driver.Pop(ctx)
+	assert.NoError(t, err)
+
+	info, err := dispatcher.Info(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, QueueInfo{Waiting: 1, Delayed: 1, Reserved: 1}, info)
+}
@@ -44,6 +44,13 @@
 //
 //  go dispatcher.Consume(context.Background())
 //
+// To skip the type assertion in listeners, subscribe a typed handler with queue.Handle. The payload type is
+// inferred from the handler signature:
+//
+//  queue.Handle(dispatcher, func(ctx context.Context, e UserCreated) error {
+//    // use e directly
+//  })
+//
 // There is no difference between listeners for normal event and listeners for persisted event. They can be
 // used interchangeably. But note if a event is retryable, it is your responsibility to ensure the idempotency.
 // Also, be aware if a persisted event have many listeners, the event is up to retry when any of the listeners fail.
@@ -56,6 +63,16 @@
 //    default:
 //      parallelism: 3
 //      checkQueueLengthIntervalSecond: 15
+//      failureWebhook: ""
+//      fifo: false
+//      livenessWindowSecond: 0
+//      maxPayloadBytes: 1048576
+//      promotionBatchSize: 100
+//      rampUpSecond: 0
+//      priorityOrder: false
+//      maxAttempts: 5
+//      role: ""
+//      skipUnserializable: false
 //
 // While manually constructing the queue.Dispatcher is absolutely feasible, users can use the bundled dependency provider
 // without breaking a sweat. Using this approach, the life cycle of consumer goroutine will be managed
@@ -64,6 +81,9 @@
 //  var c *core.C
 //  c.Provide(queue.Provide)
 //
+// The default queue backs the injected queue.Dispatcher and *queue.QueueableDispatcher. If it is not configured,
+// a warning is logged, and persisted events dispatched to them fail with queue.ErrQueueNotConfigured.
+//
 // A module is also bundled, providing the queue command.
 //
 //  c.AddModuleFunc(queue.New)
@@ -77,16 +97,424 @@
 //    // see examples for details
 //  })
 //
+// All the queues share the injected redis.UniversalClient by default. To isolate a high-volume queue on its own redis
+// instance, set redisConnection to the name of a connection of the otredis package. The client is then made by the
+// injected otredis.Maker, which otredis.Provide provides.
+//
+//  queue:
+//    events:
+//      redisConnection: events
+//
+// In split deployments, a service may only produce or only consume a queue. Set role to "producer" to skip starting
+// its consumer, or to "consumer" to reject persisted events dispatched to it with queue.ErrConsumerOnly. By default, a
+// service does both.
+//
+// By default, all the workers start at once. Against a cold downstream, set rampUpSecond to start them gradually, from
+// one to the parallelism, over the window.
+//
+// The parallelism caps the workers of each queue. To also cap the jobs handled at once across all the queues of a
+// service, provide a *queue.WorkerBudget. It is shared by every dispatcher of the factory, and reports the number of
+// active workers, as does ActiveWorkers on each dispatcher.
+//
+//  c.Provide(func() *queue.WorkerBudget { return queue.NewWorkerBudget(64) })
+//
+// The parallelism bounds the concurrency, not the rate. To keep the jobs calling a rate-limited API under its quota,
+// set rateLimit. Jobs are then reserved at most rate times per second, with bursts of up to burst jobs. Throttled jobs
+// stay in the waiting queue. The limit is local to each consumer, so divide it among the replicas.
+//
+//  queue:
+//    default:
+//      rateLimit:
+//        rate: 10
+//        burst: 1
+//
+// Neither the parallelism nor the rate limit accounts for the size of jobs. For memory-heavy workloads, set
+// maxInFlightBytes to cap the total payload bytes of the jobs being handled. Reservations pause at the cap, and resume
+// as jobs complete. The current total is reported by InFlightBytes.
+//
+// When the run group stops, the contexts of the jobs in flight are canceled at once, and the jobs are retried later.
+// To let them finish instead, set shutdownTimeoutSecond. The consumer then stops reserving jobs, and waits up to the
+// timeout for those in flight. The number of jobs abandoned after the timeout is logged.
+//
+// A listener that returns nil after the consumer was canceled may or may not have completed the work. By default, such
+// a job is acked. Set releaseCanceledJobs, or use queue.UseCanceledJobPolicy, to put it back to the waiting queue
+// instead, without counting an attempt. The job may then be handled twice, so the listeners must be idempotent.
+//
+// Due delayed jobs are moved to the waiting queue in chunks of at most promotionBatchSize on each pop, so that a
+// burst of scheduled jobs doesn't block redis with a long running command. Raise it if the jobs due at once are
+// promoted too slowly.
+//
+// FIFO
+//
+// By default jobs are handled concurrently, and a failed job is retried later while the jobs behind it proceed. For
+// strictly ordered queues, set fifo to true. Jobs are then handled one at a time, without prefetching, and failed jobs
+// are retried in place within their HandleTimeout. The throughput is bounded by the latency of a single job, so only
+// enable it where ordering matters.
+//
+// Priority Order
+//
+// By default the waiting jobs are reserved roughly in the order they were pushed. For SLA-sensitive jobs, set
+// priorityOrder to true. The waiting queue then becomes a sorted set, and jobs are reserved strictly by their priority,
+// highest first, and within equal priority by the time they became waiting, oldest first, at millisecond resolution.
+// Set the priority with the queue.Priority option. Drain the waiting queue before switching the mode.
+//
+//  dispatcher.Dispatch(ctx, queue.Persist(event, queue.Priority(10)))
+//
+// Alternatively, declare a few priority levels. Each level has its own waiting list, and the lists are polled from the
+// highest level to the lowest. A job goes to the highest level whose minimum priority it reaches, and jobs below every
+// level stay on the plain waiting list, which is polled last. Within a level, jobs are reserved in the order they
+// were pushed. Retries, reloads and reclaimed timeouts return to the list of their level. The levels and
+// priorityOrder are exclusive.
+//
+//  queue:
+//    default:
+//      priorityLevels:
+//        urgent: 10
+//        normal: 0
+//
+// Cancelling Delayed Jobs
+//
+// To cancel a class of scheduled jobs, for example all the reminders of a deactivated campaign, call CancelDelayed
+// with a predicate. It returns the number of jobs cancelled. The redis driver and the in-process driver support it.
+//
+//  n, err := dispatcher.CancelDelayed(ctx, func(e *queue.PersistedEvent) bool {
+//    return e.RoutingKey == "reminder.campaign-42"
+//  })
+//
+// To cancel a single job, dispatch it with DispatchJob, which returns its unique ID, and pass the ID to Cancel. Once
+// the job has become due, Cancel returns queue.ErrNotCancelable.
+//
+//  id, err := dispatcher.DispatchJob(ctx, queue.Persist(events.Of(reminder), queue.Defer(time.Hour)))
+//  // later, when the entity is deleted
+//  err = dispatcher.Cancel(ctx, id)
+//
+// Recurring Jobs
+//
+// queue.Scheduler dispatches jobs on cron schedules, so that each firing is handled by the queue. It tells the time
+// with a queue.Clock. In tests, a queue.ManualClock fires the jobs deterministically when advanced, without real
+// waits.
+//
+// Job Position
+//
+// To show users where their job stands, call Position with the unique ID of the job. It returns the position in the
+// waiting queue, starting from 1, or -1 if the job is not waiting. The waiting queue is scanned, up to
+// queue.DefaultPositionScanLimit jobs, so the cost grows with the position. Only the redis driver supports it.
+//
+//  dispatcher.Dispatch(ctx, queue.Persist(event, queue.UniqueId(orderID)))
+//  position, err := dispatcher.Position(ctx, orderID)
+//
+// Progress
+//
+// Long-running jobs can report their progress from the listeners with queue.ReportProgress, so that a UI can poll it
+// with Progress by the unique ID of the job. The progress is kept for 24 hours after it is last reported, see
+// queue.UseProgressTTL, and is cleared once the job has completed. Reporting is a no-op for drivers without progress
+// support. Only the redis driver supports it.
+//
+//  queue.ReportProgress(ctx, queue.Progress{Percent: 40, Status: "transcoding"})
+//  progress, err := dispatcher.Progress(ctx, videoID)
+//
+// Retries
+//
+// A job is attempted up to the number of times set by the queue.MaxAttempts option, once by default. Each retry is
+// deferred on the delayed queue with an exponential backoff, 1s * 2^(n-1) for the n-th retry, capped at 10 minutes and
+// jittered by 50%. To spare a flaky downstream, set the backoff per job. It is stored with the job, so that it survives
+// restarts and is honored by any consumer.
+//
+//  queue.Persist(event, queue.MaxAttempts(5), queue.Backoff(time.Second, time.Minute, 3), queue.BackoffJitter(0.2))
+//
+// Deadlines
+//
+// Some jobs are worthless past a certain point in time. Use the queue.Deadline option to attach an absolute deadline:
+//
+//  dispatcher.Dispatch(ctx, queue.Persist(event, queue.Deadline(time.Now().Add(time.Minute))))
+//
+// If the deadline has already passed at dispatch, or would pass before a deferred job becomes available, the
+// dispatch fails with queue.ErrDeadlineExceeded and the queue.ExpiredCounter, if provided, is incremented. If the
+// deadline passes while the job is waiting, the job is skipped on reservation, moved to the failed queue and a
+// "queue.AbortedEvent" is fired.
+//
+// Leases
+//
+// A reserved job is leased to its worker for its HandleTimeout. Once the lease expires, the job is moved to the timeout
+// queue, and may be reloaded and reserved by another worker. The redis driver stamps each reservation with a new
+// LeaseToken, and rejects the ack, failure or retry of a stale reservation with queue.ErrLeaseLost. The outcome of the
+// slow worker is then discarded with a warning, and the job is left to its current holder. The in-process driver
+// doesn't fence reservations.
+//
+// The HandleTimeout of a job is set with the queue.Timeout option. Jobs dispatched without it get the
+// handleTimeoutSecond of the queue, or queue.DefaultHandleTimeout, one hour. Give slow jobs a longer timeout, so that
+// they aren't reclaimed and handled twice while still running, without holding up the reclaim of fast ones.
+//
+//  dispatcher.Dispatch(ctx, queue.Persist(events.Of(Transcode{}), queue.Timeout(6*time.Hour)))
+//
+// Serialization
+//
+// Payloads are serialized with encoding/gob by default. Times are decoded to the same instant and zone offset they
+// were encoded with, independent of the local zone of the consumer. To share payloads with other languages, use
+// queue.JSONPacker, which encodes times in RFC3339Nano. In both cases, decimal types keep their precision if they
+// implement the respective marshaler interfaces. See queue.UsePacker.
+//
+// Alternatively, set serializer to "json" in the configuration, or pass the option returned by queue.UseSerializer
+// to WithQueue. The packer then encodes both the payloads and the jobs stored by the driver, so that they can be
+// inspected with other tooling, and payloads of interface types no longer need to be registered with encoding/gob.
+// Drain the queue before switching the serializer, as jobs already enqueued can't be decoded by the new one.
+//
+// To derive deduplication keys, queue.ContentHash computes a canonical hash of an event, which is the same for
+// semantically equal payloads regardless of the iteration order of maps.
+//
+// Type Registry
+//
+// A persisted event is deserialized into the Go type registered under its Key. Subscribing a listener registers the
+// types it listens to under their default names. Producers in other languages may use other names, which can be
+// mapped with RegisterType:
+//
+//  dispatcher.RegisterType("user.created", UserCreated{})
+//
+// Events of types that are not registered cannot be handled. They are moved to the failed channel without retrying,
+// where they can be inspected, and reloaded once a consumer that knows the type is deployed.
+//
+// Routing
+//
+// By default, a persisted event is decoded and dispatched to the listeners by its payload type. Alternatively, attach
+// a routing key with the queue.RoutingKey option, and register a handler for it on the consuming dispatcher:
+//
+//  dispatcher.Dispatch(ctx, queue.Persist(event, queue.RoutingKey("email.send")))
+//  dispatcher.Route("email.send", func(ctx context.Context, e *queue.PersistedEvent) error {
+//    // decode e.Value and handle it
+//  })
+//
+// The routing key takes precedence over the type. If a handler is registered for the routing key, it receives the
+// raw event and the type based listeners are not called. Otherwise the event falls back to the type based matching.
+// Routing is useful when a queue carries polymorphic payloads, or when producers in other languages can only name
+// the job by a string.
+//
+// Middleware
+//
+// Cross-cutting concerns of the consumer, such as idempotency or distributed locks, are implemented as a
+// queue.Middleware, which wraps the handling of each reserved job. To install middlewares on the queues created by
+// Provide, register them in a *queue.Middlewares and provide it to the core. Heavy middlewares can be limited to the
+// queues that need them with UseFor:
+//
+//  middlewares := queue.NewMiddlewares()
+//  middlewares.Use(logJobs)
+//  middlewares.UseFor("payments", idempotency, lock)
+//  c.Provide(func() *queue.Middlewares { return middlewares })
+//
+// The middlewares registered with Use wrap those of the named queue, and each group runs in the order of
+// registration. In the example above, a job of the payments queue passes logJobs, idempotency and lock, in that
+// order, before it reaches the listeners. Jobs of other queues only pass logJobs. With WithQueue, pass the
+// middlewares with queue.UseMiddleware instead.
+//
+// Draining
+//
+// Small single-node apps may use the InProcessDriver instead of redis. To keep the jobs across restarts, use the
+// queue.UseDrainFile option. When the consumer stops, for example because the run group is interrupted by SIGTERM,
+// the jobs are saved to the file, and they are loaded back when the consumer starts again.
+//
+//  dispatcher := queue.WithQueue(base, queue.NewInProcessDriver(), queue.UseDrainFile("/var/lib/app/queue.gob"))
+//
+// The InProcessDriver holds at most queue.DefaultBufferSize waiting jobs. Tune it with queue.WithBufferSize. When the
+// buffer is full, Dispatch blocks until there is room or the context is done. With queue.WithBlocking(false), it
+// fails at once with queue.ErrQueueFull instead, which applies backpressure to the producer much like a busy redis
+// would.
+//
+//  driver := queue.NewInProcessDriver(queue.WithBufferSize(100), queue.WithBlocking(false))
+//
+// Kafka
+//
+// The KafkaDriver uses kafka topics as the transport. Listeners are unchanged: only the driver passed to WithQueue
+// differs. Jobs are produced to a topic, and consumed by a consumer group. Delayed jobs and retries go to a delay
+// topic, consumed by another group, and the Promote method moves them to the main topic once they are due. Run it
+// next to the consumers. Failed jobs go to an optional failed topic.
+//
+//  driver := &queue.KafkaDriver{
+//    Writer:      &kafka.Writer{Addr: kafka.TCP("127.0.0.1:9092")},
+//    Reader:      kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, GroupID: "app", Topic: "jobs"}),
+//    DelayReader: kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, GroupID: "app-delay", Topic: "jobs.delayed"}),
+//    Topic:       "jobs",
+//    DelayTopic:  "jobs.delayed",
+//    FailedTopic: "jobs.failed",
+//  }
+//  dispatcher := queue.WithQueue(base, driver)
+//  g.Add(func() error { return driver.Promote(ctx) }, func(err error) { cancel() })
+//
+// Kafka is a log, not a queue. The delay topic is consumed in order, so a delayed job waits for those produced before
+// it in the same partition. Reload, Flush and Unique jobs fail with queue.ErrNotSupportedByKafka, and Info only counts
+// the reserved jobs. An acked job commits its offset, which commits the jobs before it in the partition, so keep the
+// parallelism at one if a crash must not lose jobs popped but not acked yet.
+//
+// Export and Import
+//
+// To back up a redis queue, or to move it to another cluster, export all its channels to a file with the Export
+// method of the RedisDriver, and load the file with Import on the other side. Delayed and reserved jobs keep their
+// scores, so that they fire at the same time as before. Stop the consumers and producers of the queue first.
+//
+//  err := driver.Export(ctx, file)
+//  // on the new cluster
+//  err = driver.Import(ctx, file)
+//
+// Payload Size
+//
+// To protect the shared storage from a misbehaving producer, events whose serialized payloads exceed maxPayloadBytes
+// are rejected at dispatch with queue.ErrPayloadTooLarge. The limit defaults to 1 MiB. Rejections are counted by
+// queue.OversizedCounter, if provided.
+//
+// Unique Jobs
+//
+// Some jobs only need to run once however often they are dispatched, for example rebuilding the search index of a
+// product after each edit. Dispatch them with the queue.Unique option. While a job with the same key is guarded,
+// dispatching fails with queue.ErrDuplicate, which the caller can safely ignore.
+//
+//  err := dispatcher.Dispatch(ctx, queue.Persist(event, queue.Unique("reindex:"+id, time.Hour)))
+//  if errors.Is(err, queue.ErrDuplicate) {
+//    err = nil
+//  }
+//
+// By default, the guard is released when the job is reserved. An edit made while the job is running then enqueues
+// another job, so it is never missed, at the cost of an occasional redundant run. With
+// queue.UniqueUntil(queue.ReleaseOnCompletion), the guard is kept until the job succeeds or finally fails, including
+// its retries. Duplicates are suppressed strictly, but an edit made while the job is running may be missed. In both
+// modes, the guard expires after the ttl, so that a job lost by a crashed worker doesn't block the key forever.
+//
+// Batch Dispatch
+//
+// DispatchMany dispatches several events in order, and stops at the first failure. A payload that can't be
+// serialized, such as one of a type unknown to encoding/gob, fails with a *queue.SerializationError. To keep one bad
+// event from blocking the rest, set skipUnserializable to true. Such events are then logged, counted by
+// queue.SkippedCounter, if provided, and returned to the caller.
+//
+//  skipped, err := dispatcher.DispatchMany(ctx, events...)
+//
+// For bulk ingestion, DispatchBatch enqueues many events in a single round trip to redis. Each event is otherwise
+// treated as if dispatched individually. Events that can't be enqueued are reported in a *queue.BatchError, indexed by
+// their position, while the rest are enqueued.
+//
+//  err := dispatcher.DispatchBatch(ctx, []contract.Event{events.Of(a), events.Of(b)}, queue.MaxAttempts(3))
+//
+// Follow-up Jobs
+//
+// To chain jobs into a workflow, a listener registers the jobs to run next with queue.FollowUp. They are dispatched
+// when the job completes successfully, and discarded if it fails.
+//
+//  queue.Handle(dispatcher, func(ctx context.Context, e OrderPaid) error {
+//    // handle the payment
+//    return queue.FollowUp(ctx, queue.Persist(events.Of(ShipOrder{ID: e.ID})))
+//  })
+//
+// The redis driver pushes the follow-ups in the same script as the ack of the job, so that either both happen or
+// neither does. If the follow-ups can't be persisted, for example because a payload is too large, the job fails and
+// is retried like any other failure. If the ack fails, for example because the lease of the job has expired, the
+// follow-ups are not pushed, and the job is handled again once it times out. Either way the chain doesn't break, but
+// the job may run more than once. Drivers without atomic support, such as the InProcessDriver, push the follow-ups
+// after the ack. A follow-up that fails to be pushed is then logged and lost.
+//
+// Waiting for Completion
+//
+// In tests and a few critical flows, the caller needs the outcome of a job. DispatchSync dispatches the event, and
+// blocks until it is handled, returning the error of the final attempt.
+//
+//  ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+//  defer cancel()
+//  err := dispatcher.DispatchSync(ctx, queue.Persist(events.Of(ChargeCard{})))
+//
+// This couples the caller to the availability of a consumer. If none is running, DispatchSync blocks until the context
+// is done, so always set a deadline. With the redis driver, the outcome is relayed through redis, and the job may be
+// handled by any replica. Other drivers require the job to be handled by the consumer of the same dispatcher.
+//
+// Load Shedding
+//
+// Under extreme backlog, a queue can shed the less important events instead of growing unbounded. When the number of
+// waiting jobs exceeds the high-water mark, events whose priority is below the critical priority are rejected at
+// dispatch with queue.ErrOverCapacity, and counted by queue.ShedCounter, if provided. Critical events always pass.
+// The critical priority defaults to 1, so that the events without a priority are shed.
+//
+//  queue:
+//    default:
+//      loadShedding:
+//        highWaterMark: 100000
+//        criticalPriority: 100
+//
+// Tenant Limits
+//
+// When a queue is shared by many tenants, a burst of one tenant may take all the workers. Tag the jobs with
+// queue.Tenant, and configure the limits of the tenants under tenantLimits:
+//
+//  queue:
+//    default:
+//      tenantLimits:
+//        default: {rate: 10, burst: 20}
+//        tenants:
+//          big-customer: {rate: 100, burst: 100}
+//
+// The limits are token buckets stored in redis, shared by all replicas. Jobs over the limit are deferred until the
+// tenant has capacity again, rather than dropped, and the deferral doesn't count as an attempt. To look up the limits
+// elsewhere, for example in a database, pass a queue.RedisTenantLimiter with a custom Limits function to
+// queue.UseTenantLimiter.
+//
+// Mirroring
+//
+// During a migration between backends, queue.MirrorDriver writes every pushed event to a primary driver and a list of
+// mirrors. Each mirror is either queue.BestEffort, whose failures are logged, or queue.MustSucceed, whose failures
+// are returned to the caller. Only the primary is consumed. See queue.MirrorDriver for ordering and failure semantics.
+//
+// Testing
+//
+// Listeners can read the UniqueId of the job they handle with queue.JobID. To assert that a reliability critical
+// listener processes every job exactly once under retries and redeliveries, wrap it with a queue.ProcessedSet in
+// tests and check its Duplicates.
+//
+// Migrating Keys
+//
+// The redis keys of a queue embed the AppName and the Env. When either changes, queue.MigrateKeys moves the jobs
+// from the old keys to the new ones, one channel at a time, and reports the number of jobs moved per channel. Run it
+// with dryRun first to see what would be moved. The lists of the PriorityLevels are moved with
+// queue.MigratePriorityLevels.
+//
 // Events
 //
 // When an attempt to execute the event handler failed, two kinds of event will be fired. If the failed event can be
 // retried, "queue.RetryingEvent" will be fired. If not, "queue.AbortedEvent" will be fired.
 //
+// When config.EnvProvider is in the configuration stack, these entries can be overridden by environment variables,
+// for example APP_QUEUE_DEFAULT_PARALLELISM=3 or APP_QUEUE_DEFAULT_CHECKQUEUELENGTHINTERVALSECOND=5.
+//
+// If failureWebhook is set, a JSON payload containing the queue name, event type, error and number of attempts is
+// posted to the URL whenever an event is aborted, or moved to the dead channel after maxAttempts reservations. The
+// notification is best-effort and never blocks the consumer.
+//
+// To run custom code when a job finally fails, for example to write a compensating record, register a dead letter
+// handler on the dispatcher of the queue. If the handler succeeds, the job is removed instead of being moved to the
+// failed channel. Its errors are logged.
+//
+//  dispatcher.OnDeadLetter(func(ctx context.Context, failed queue.AbortedEvent) error {
+//    return compensate(ctx, failed.Msg)
+//  })
+//
+// To archive the failed jobs for long-term querying and auditing, provide a queue.DeadLetterStore to the core. The
+// queues created by Provide then store their failed jobs in it, along with the error, attempts and timestamps, instead
+// of the failed channel. The failed channel remains the fallback if the store fails. See otgorm.DeadLetterStore for a
+// database implementation.
+//
+// Jobs reloaded from the failed or timeout channel over and over never finish. Each job is therefore reserved at most
+// maxAttempts times, 5 by default, counting retries, timeouts and reloads. Further reservations move it to the dead
+// channel instead, unless its own MaxAttempts is higher. Reload the dead channel to give the jobs another maxAttempts
+// reservations. Set maxAttempts to -1 to lift the ceiling.
+//
+// To detect a stalled consumer, set livenessWindowSecond. The health check of the queue then fails if the consumer
+// has made no progress within the window. The time of the last progress is also available from LastActivity.
+//
+// The last few errors of each queue are kept in memory for quick diagnosis, for example on a status page. Call
+// RecentErrors on the dispatcher to retrieve them. The size of the history is tunable via queue.UseErrorHistory.
+//
 // Metrics
 //
 // To gain visibility on how the length of the queue, inject a gauge into the core and alias it to queue.Gauge. The
 // queue length of the all internal queues will be periodically reported to metrics collector (Presumably Prometheus).
 //
+// The same numbers are available on demand from Info, for example to serve a status page.
+//
+//  info, err := dispatcher.Info(ctx)
+//
 //  c.Provide(func(appName contract.AppName, env contract.Env) queue.Gauge {
 //    return prometheus.NewGaugeFrom(
 //      stdprometheus.GaugeOpts{
@@ -97,4 +525,23 @@
 //      }, []string{"name", "channel"},
 //    )
 //  })
+//
+// To measure the end-to-end latency of jobs, from dispatch to completion, inject a histogram and alias it to
+// queue.LatencyHistogram. It is labelled by "queue", and observes seconds, including the time spent waiting in the
+// queue and retrying. Percentiles of this histogram expose backlogs that the queue length hides.
+//
+// To alert on the failure rate, inject counters aliased to queue.ProcessedCounter, queue.FailedCounter and
+// queue.RetriedCounter. They count the jobs handled successfully, the jobs aborted after their final attempt, and the
+// failed attempts that are retried. The time spent handling each attempt is observed by queue.DurationHistogram. All
+// of them are labelled by "queue". With WithQueue, use queue.UseCounters and queue.UseDurationHistogram instead.
+//
+// Tracing and Exemplars
+//
+// If an opentracing.Tracer is available in the container, or set via queue.UseTracer, each job is handled in a
+// "queue.Handle" span, so that the spans started by the listeners share a trace per job.
+//
+// Counters whose backend supports exemplars, such as observability.ExemplarCounter, receive the ID of the current
+// trace as the "trace_id" exemplar, so that a spike in the metrics links to an example trace. Jaeger spans are
+// supported by default. For other backends, provide the exemplar via queue.UseExemplars. Counters without exemplar
+// support are incremented as usual.
 package queue
@@ -9,6 +9,10 @@ import (
 // ErrEmpty means the queue is empty.
 var ErrEmpty = errors.New("no message available")
 
+// ErrLeaseLost means the message is no longer reserved by the caller, usually because its HandleTimeout has exceeded
+// and it has been reserved again. The outcome of the caller is discarded.
+var ErrLeaseLost = errors.New("lease of the message has been lost")
+
 // Driver is the interface for queue engines. See RedisDriver for usage.
 type Driver interface {
 	// Push pushes the message onto the queue. It is possible to specify a time delay. If so the message
@@ -16,7 +20,8 @@ type Driver interface {
 	Push(ctx context.Context, message *PersistedEvent, delay time.Duration) error
 	// Pop pops the message out of the queue. It blocks until a message is available or a timeout is reached.
 	Pop(ctx context.Context) (*PersistedEvent, error)
-	// Ack acknowledges a message has been processed.
+	// Ack acknowledges a message has been processed. Drivers fencing reservations, such as the RedisDriver, reject
+	// Ack, Fail and Retry with ErrLeaseLost if the message has been reserved again since it was popped.
 	Ack(ctx context.Context, message *PersistedEvent) error
 	// \Fail marks a message has failed.
 	Fail(ctx context.Context, message *PersistedEvent) error
@@ -0,0 +1,55 @@
+package queue
+
+import (
+	"sync"
+	"time"
+)
+
+// ErrorRecord is an error that occurred while handling a job in the queue.
+type ErrorRecord struct {
+	// Time is when the error occurred.
+	Time time.Time
+	// Event is the type of the failed event.
+	Event string
+	// Attempts is the number of attempts made so far, including the failed one.
+	Attempts int
+	// Err is the error returned by the listeners.
+	Err error
+}
+
+// errorHistory is a bounded ring buffer of ErrorRecord.
+type errorHistory struct {
+	mu      sync.Mutex
+	records []ErrorRecord
+	next    int
+	full    bool
+}
+
+func newErrorHistory(size int) *errorHistory {
+	return &errorHistory{records: make([]ErrorRecord, size)}
+}
+
+func (h *errorHistory) add(record ErrorRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records[h.next] = record
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the records from the most recent to the oldest.
+func (h *errorHistory) list() []ErrorRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := h.next
+	if h.full {
+		n = len(h.records)
+	}
+	out := make([]ErrorRecord, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, h.records[(h.next-i+len(h.records))%len(h.records)])
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorHistory(t *testing.T) {
+	h := newErrorHistory(3)
+	assert.Empty(t, h.list())
+	for i := 0; i < 5; i++ {
+		h.add(ErrorRecord{Attempts: i})
+	}
+	records := h.list()
+	assert.Len(t, records, 3)
+	assert.Equal(t, 4, records[0].Attempts)
+	assert.Equal(t, 3, records[1].Attempts)
+	assert.Equal(t, 2, records[2].Attempts)
+}
+
+func TestDispatcher_RecentErrors(t *testing.T) {
+	dispatcher := WithQueue(
+		&events.SyncDispatcher{},
+		NewInProcessDriver(),
+		UseLogger(log.NewNopLogger()),
+		UseErrorHistory(2),
+	)
+	var count int
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		count++
+		return fmt.Errorf("error %d", count)
+	}))
+	msg, _ := dispatcher.packer.Compress(MockEvent{Value: "hello"})
+	for i := 0; i < 3; i++ {
+		dispatcher.work(context.Background(), &PersistedEvent{
+			Key:         events.Of(MockEvent{}).Type(),
+			Value:       msg,
+			Attempts:    1,
+			MaxAttempts: 1,
+		})
+	}
+	records := dispatcher.RecentErrors()
+	assert.Len(t, records, 2)
+	assert.Equal(t, "error 3", records[0].Err.Error())
+	assert.Equal(t, "error 2", records[1].Err.Error())
+	assert.Equal(t, events.Of(MockEvent{}).Type(), records[0].Event)
+	assert.False(t, records[0].Time.IsZero())
+
+	disabled := WithQueue(&events.SyncDispatcher{}, NewInProcessDriver(), UseErrorHistory(0))
+	assert.Nil(t, disabled.RecentErrors())
+}
@@ -0,0 +1,59 @@
+package queue
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+// ExemplarCounter is implemented by counters whose backend supports exemplars, such as OpenMetrics. If a counter
+// injected into the queue implements it, the observations made within a trace carry the exemplar returned by the
+// ExemplarFunc, so that a spike in the metrics can be correlated with an example trace.
+type ExemplarCounter interface {
+	metrics.Counter
+	AddWithExemplar(delta float64, exemplar map[string]string)
+}
+
+// ExemplarFunc returns the exemplar of the observation made within the context, or nil if there is none.
+type ExemplarFunc func(ctx context.Context) map[string]string
+
+// TraceExemplar is the default ExemplarFunc. It returns the ID of the trace of the span in the context as "trace_id".
+// Only jaeger spans are supported.
+func TraceExemplar(ctx context.Context) map[string]string {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return nil
+	}
+	if spanContext, ok := span.Context().(jaeger.SpanContext); ok {
+		return map[string]string{"trace_id": spanContext.TraceID().String()}
+	}
+	return nil
+}
+
+// UseExemplars is an option for WithQueue that replaces the TraceExemplar with a custom ExemplarFunc, for example to
+// support other tracing backends.
+func UseExemplars(exemplar ExemplarFunc) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.exemplar = exemplar
+	}
+}
+
+// count adds one to the counter, with an exemplar if the counter supports it and the context is traced.
+func (d *QueueableDispatcher) count(ctx context.Context, counter metrics.Counter) {
+	if counter == nil {
+		return
+	}
+	if exemplarCounter, ok := counter.(ExemplarCounter); ok {
+		exemplar := d.exemplar
+		if exemplar == nil {
+			exemplar = TraceExemplar
+		}
+		if labels := exemplar(ctx); labels != nil {
+			exemplarCounter.AddWithExemplar(1, labels)
+			return
+		}
+	}
+	counter.Add(1)
+}
@@ -0,0 +1,102 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-kit/kit/metrics"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/jaeger-client-go"
+)
+
+type exemplarCounter struct {
+	mu        sync.Mutex
+	value     float64
+	exemplars []map[string]string
+}
+
+func (c *exemplarCounter) With(labelValues ...string) metrics.Counter { return c }
+
+func (c *exemplarCounter) Add(delta float64) {
+	c.AddWithExemplar(delta, nil)
+}
+
+func (c *exemplarCounter) AddWithExemplar(delta float64, exemplar map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.value += delta
+	c.exemplars = append(c.exemplars, exemplar)
+}
+
+// mockExemplar returns the trace ID of the spans of the mocktracer.
+func mockExemplar(ctx context.Context) map[string]string {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return nil
+	}
+	return map[string]string{"trace_id": fmt.Sprint(span.Context().(mocktracer.MockSpanContext).TraceID)}
+}
+
+func TestDispatcher_exemplars(t *testing.T) {
+	tracer := mocktracer.New()
+	counter := &exemplarCounter{}
+	dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriver(), UseExpiredCounter(counter), UseExemplars(mockExemplar))
+
+	expired := Persist(events.Of(MockEvent{}), Deadline(time.Now().Add(-time.Second)))
+	assert.Error(t, dispatcher.Dispatch(context.Background(), expired))
+
+	span := tracer.StartSpan("test")
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	assert.Error(t, dispatcher.Dispatch(ctx, expired))
+	span.Finish()
+
+	traceID := span.Context().(mocktracer.MockSpanContext).TraceID
+	assert.Equal(t, float64(2), counter.value)
+	assert.Equal(t, []map[string]string{nil, {"trace_id": fmt.Sprint(traceID)}}, counter.exemplars)
+}
+
+func TestTraceExemplar(t *testing.T) {
+	assert.Nil(t, TraceExemplar(context.Background()))
+
+	span := mocktracer.New().StartSpan("test")
+	defer span.Finish()
+	assert.Nil(t, TraceExemplar(opentracing.ContextWithSpan(context.Background(), span)))
+
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+	span = tracer.StartSpan("test")
+	defer span.Finish()
+	traceID := span.Context().(jaeger.SpanContext).TraceID().String()
+	assert.Equal(t, map[string]string{"trace_id": traceID}, TraceExemplar(opentracing.ContextWithSpan(context.Background(), span)))
+}
+
+func TestDispatcher_UseTracer(t *testing.T) {
+	tracer := mocktracer.New()
+	dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriverWithPopInterval(time.Millisecond), UseTracer(tracer))
+
+	traced := make(chan bool, 1)
+	dispatcher.Subscribe(events.Listen(events.From(MockEvent{}), func(ctx context.Context, event contract.Event) error {
+		traced <- opentracing.SpanFromContext(ctx) != nil
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Consume(ctx)
+
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "foo"}))))
+	assert.True(t, <-traced)
+	assert.Eventually(t, func() bool {
+		return len(tracer.FinishedSpans()) == 1
+	}, time.Second, time.Millisecond)
+	span := tracer.FinishedSpans()[0]
+	assert.Equal(t, "queue.Handle", span.OperationName)
+	assert.Equal(t, "queue", span.Tag("component"))
+}
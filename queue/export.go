@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// exportPageSize is the number of jobs read or written per round trip by Export and Import.
+const exportPageSize = 100
+
+// exportedJob is a line of the file written by Export.
+type exportedJob struct {
+	// Channel is the name of the channel, such as "delayed". See MigrateKeys.
+	Channel string `json:"channel"`
+	// Score is the score of jobs in sorted sets. For delayed and reserved jobs, it is the unix time they become due
+	// or time out.
+	Score *float64 `json:"score,omitempty"`
+	// Job is the job as serialized by the Packer.
+	Job []byte `json:"job"`
+}
+
+// exportedChannel is a channel of the RedisDriver, and whether it is a sorted set.
+type exportedChannel struct {
+	name   string
+	key    string
+	sorted bool
+}
+
+// exportedChannels returns the channels of the driver. The waiting channel spans the lists of all the PriorityLevels.
+func (r *RedisDriver) exportedChannels() []exportedChannel {
+	var channels []exportedChannel
+	for _, key := range r.waitingKeys() {
+		channels = append(channels, exportedChannel{"waiting", key, r.PriorityOrder})
+	}
+	return append(channels, []exportedChannel{
+		{"delayed", r.ChannelConfig.Delayed, true},
+		{"reserved", r.ChannelConfig.Reserved, true},
+		{"timeout", r.ChannelConfig.Timeout, false},
+		{"failed", r.ChannelConfig.Failed, false},
+		{"dead", r.ChannelConfig.Dead, false},
+	}...)
+}
+
+// Export writes the jobs of all the channels to w, one JSON object per line, for example to back up the queue or to
+// move it to another redis cluster with Import. The jobs are written as serialized by the Packer, along with the
+// scores of sorted sets, so that delayed jobs keep their firing time. Lists are written in the order they are popped.
+//
+// The channels are read page by page, so stop the consumers and producers first to get a consistent snapshot.
+func (r *RedisDriver) Export(ctx context.Context, w io.Writer) error {
+	r.populateDefaults()
+	encoder := json.NewEncoder(w)
+	for _, channel := range r.exportedChannels() {
+		for start := int64(0); ; start += exportPageSize {
+			jobs, err := r.exportPage(ctx, channel, start, start+exportPageSize-1)
+			if err != nil {
+				return errors.Wrapf(err, "failed to export the %s channel", channel.name)
+			}
+			for _, job := range jobs {
+				if err := encoder.Encode(job); err != nil {
+					return errors.Wrap(err, "failed to write exported job")
+				}
+			}
+			if len(jobs) < exportPageSize {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// exportPage returns the jobs of the channel from the start-th to the stop-th to be popped, both inclusive and
+// starting from 0.
+func (r *RedisDriver) exportPage(ctx context.Context, channel exportedChannel, start, stop int64) ([]exportedJob, error) {
+	if channel.sorted {
+		members, err := r.RedisClient.ZRangeWithScores(ctx, channel.key, start, stop).Result()
+		if err != nil {
+			return nil, err
+		}
+		jobs := make([]exportedJob, len(members))
+		for i, member := range members {
+			score := member.Score
+			jobs[i] = exportedJob{Channel: channel.name, Score: &score, Job: []byte(member.Member.(string))}
+		}
+		return jobs, nil
+	}
+	// Jobs are pushed to the left of the lists and popped from the right.
+	members, err := r.RedisClient.LRange(ctx, channel.key, -stop-1, -start-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]exportedJob, len(members))
+	for i, member := range members {
+		jobs[len(members)-1-i] = exportedJob{Channel: channel.name, Job: []byte(member)}
+	}
+	return jobs, nil
+}
+
+// Import reads the jobs written by Export from r, and adds them to the channels of the driver, behind the jobs already
+// there. Delayed and reserved jobs keep their scores, so that they become due or time out at the same time as before
+// the export. If the waiting queue is a sorted set on both sides, the waiting jobs keep their scores too. Otherwise,
+// they are pushed in the order they were exported, onto the lists of their PriorityLevels.
+//
+// Import the file before starting the consumers. Importing the same file twice duplicates the jobs in lists.
+func (r *RedisDriver) Import(ctx context.Context, reader io.Reader) error {
+	r.populateDefaults()
+	channels := make(map[string]exportedChannel)
+	for _, channel := range r.exportedChannels() {
+		if _, ok := channels[channel.name]; !ok {
+			channels[channel.name] = channel
+		}
+	}
+	var (
+		decoder = json.NewDecoder(reader)
+		now     = time.Now()
+		p       = r.RedisClient.Pipeline()
+		queued  int
+	)
+	for {
+		var job exportedJob
+		err := decoder.Decode(&job)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read exported job")
+		}
+		channel, ok := channels[job.Channel]
+		if !ok {
+			return errors.Errorf("unknown channel %q of exported job", job.Channel)
+		}
+		if err := r.importJob(ctx, p, channel, job, now); err != nil {
+			return err
+		}
+		if queued++; queued == exportPageSize {
+			if _, err := p.Exec(ctx); err != nil {
+				return errors.Wrap(err, "failed to import jobs")
+			}
+			queued = 0
+		}
+	}
+	if queued == 0 {
+		return nil
+	}
+	if _, err := p.Exec(ctx); err != nil {
+		return errors.Wrap(err, "failed to import jobs")
+	}
+	return nil
+}
+
+// importJob queues the commands adding the exported job to the channel.
+func (r *RedisDriver) importJob(ctx context.Context, p redis.Pipeliner, channel exportedChannel, job exportedJob, now time.Time) error {
+	switch {
+	case channel.name == "waiting" && (!channel.sorted || job.Score == nil):
+		return r.pushWaiting(ctx, p, string(job.Job), now)
+	case channel.sorted && job.Score == nil:
+		return errors.Errorf("exported job of the %s channel has no score", channel.name)
+	case channel.sorted:
+		p.ZAdd(ctx, channel.key, &redis.Z{Score: *job.Score, Member: job.Job})
+	default:
+		p.LPush(ctx, channel.key, job.Job)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisDriver_ExportImport(t *testing.T) {
+	client := redis.NewUniversalClient(&redis.UniversalOptions{})
+	ctx := context.Background()
+	from := NewChannelConfig("export", "testing", "default")
+	to := NewChannelConfig("import", "testing", "default")
+	keys := []string{
+		from.Waiting, from.Delayed, from.Reserved, from.Timeout, from.Failed, from.Dead,
+		to.Waiting, to.Delayed, to.Reserved, to.Timeout, to.Failed, to.Dead,
+	}
+	client.Del(ctx, keys...)
+	defer client.Del(ctx, keys...)
+
+	source := &RedisDriver{RedisClient: client, ChannelConfig: from, PopTimeout: time.Second}
+	dispatcher := WithQueue(&events.SyncDispatcher{}, source)
+	for _, value := range []string{"1", "2", "3"} {
+		assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: value}))))
+	}
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "delayed"}), Defer(time.Hour))))
+	failed, err := source.Pop(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, source.Fail(ctx, failed))
+
+	var buf bytes.Buffer
+	assert.NoError(t, source.Export(ctx, &buf))
+
+	target := &RedisDriver{RedisClient: client, ChannelConfig: to, PopTimeout: time.Second}
+	assert.NoError(t, target.Import(ctx, &buf))
+	sourceInfo, err := source.Info(ctx)
+	assert.NoError(t, err)
+	targetInfo, err := target.Info(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, QueueInfo{Waiting: 2, Delayed: 1, Failed: 1}, targetInfo)
+	assert.Equal(t, sourceInfo, targetInfo)
+
+	// delayed jobs keep their firing time.
+	assert.Equal(t, client.ZRangeWithScores(ctx, from.Delayed, 0, -1).Val(), client.ZRangeWithScores(ctx, to.Delayed, 0, -1).Val())
+
+	// waiting jobs keep their order.
+	for _, value := range []string{"2", "3"} {
+		msg, err := target.Pop(ctx)
+		assert.NoError(t, err)
+		assert.Contains(t, string(msg.Value), value)
+	}
+}
+
+func TestRedisDriver_Import_unknownChannel(t *testing.T) {
+	driver := &RedisDriver{ChannelConfig: NewChannelConfig("import", "testing", "unknown")}
+	err := driver.Import(context.Background(), bytes.NewBufferString(`{"channel":"archived","job":"Zm9v"}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// workInOrder is the FIFO counterpart of work. Instead of putting a failed job
+// onto the delayed queue, which would let the jobs behind it overtake, it
+// retries the job in place until success, or the attempts or the handle
+// timeout are exhausted.
+func (d *QueueableDispatcher) workInOrder(ctx context.Context, msg *PersistedEvent) {
+	if msg.expired(time.Now()) {
+		_ = level.Warn(d.logger).Log("err", errors.Wrapf(ErrDeadlineExceeded, "event %s skipped", msg.Key))
+		d.abort(msg, ErrDeadlineExceeded)
+		return
+	}
+
+	for wait := d.throttle(ctx, msg); wait > 0; wait = d.throttle(ctx, msg) {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	// The driver identifies the reserved job by its content or its address.
+	// Attempts are counted on a copy, so that the original can still be acked
+	// or failed.
+	current := *msg
+	deadline := time.Now().Add(msg.HandleTimeout)
+	for {
+		attemptCtx, cancel := context.WithDeadline(ctx, deadline)
+		next, err := d.handle(attemptCtx, &current)
+		cancel()
+		if err == nil {
+			if d.releaseCanceled(ctx, msg) {
+				return
+			}
+			d.released(msg, d.ack(msg, next))
+			d.observeLatency(msg)
+			d.count(ctx, d.processedCounter)
+			d.complete(msg, nil)
+			return
+		}
+		if ctx.Err() != nil {
+			// Shutting down. The job stays reserved and will be moved to the timeout queue.
+			return
+		}
+		wait := current.retryDelay()
+		if current.Attempts >= current.MaxAttempts || time.Now().Add(wait).After(deadline) || errors.Is(err, ErrUnknownType) {
+			_ = level.Warn(d.logger).Log("err", errors.Wrapf(err, "event %s failed after %d Attempts, aborted", current.Key, current.Attempts))
+			d.abortReserved(msg, &current, err)
+			return
+		}
+		d.recordError(&current, err)
+		_ = level.Info(d.logger).Log("err", errors.Wrapf(err, "event %s failed %d times, retrying in place", current.Key, current.Attempts))
+		_ = d.Dispatch(context.Background(), events.Of(RetryingEvent{Err: err, Msg: &current}))
+		d.count(ctx, d.retriedCounter)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+		current.Attempts++
+	}
+}
@@ -0,0 +1,83 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_Consume_fifo(t *testing.T) {
+	driver := NewInProcessDriverWithPopInterval(time.Millisecond)
+	dispatcher := WithQueue(
+		&events.SyncDispatcher{},
+		driver,
+		UseLogger(log.NewNopLogger()),
+		UseParallelism(4),
+		UseFIFO(true),
+	)
+	var (
+		mu        sync.Mutex
+		completed []string
+		failed    bool
+		done      = make(chan struct{}, 4)
+	)
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		value := event.Data().(MockEvent).Value
+		mu.Lock()
+		defer mu.Unlock()
+		if value == "b" && !failed {
+			failed = true
+			return errors.New("try again")
+		}
+		completed = append(completed, value)
+		done <- struct{}{}
+		return nil
+	}))
+	for _, value := range []string{"a", "b", "c", "d"} {
+		assert.NoError(t, dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{Value: value}), MaxAttempts(2))))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Consume(ctx)
+	for i := 0; i < 4; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, completed)
+	assert.Len(t, dispatcher.RecentErrors(), 1)
+	info, _ := driver.Info(context.Background())
+	assert.Equal(t, int64(0), info.Delayed)
+}
+
+func TestDispatcher_workInOrder_abort(t *testing.T) {
+	driver := NewInProcessDriver()
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseLogger(log.NewNopLogger()), UseFIFO(true))
+	var aborted int
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		return errors.New("foo")
+	}))
+	dispatcher.Subscribe(AbortedListener(func(ctx context.Context, event contract.Event) error {
+		aborted++
+		return nil
+	}))
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{}), Timeout(100*time.Millisecond), MaxAttempts(3))))
+	msg, err := driver.Pop(context.Background())
+	assert.NoError(t, err)
+
+	// the backoff would exceed the handle timeout, so the job is aborted after the first attempt.
+	dispatcher.workInOrder(context.Background(), msg)
+	assert.Equal(t, 1, aborted)
+	info, _ := driver.Info(context.Background())
+	assert.Equal(t, int64(1), info.Failed)
+}
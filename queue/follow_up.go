@@ -0,0 +1,186 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// FollowUpJob is a job dispatched on the completion of another job. See FollowUp.
+type FollowUpJob struct {
+	// Msg is the persisted follow-up.
+	Msg *PersistedEvent
+	// Delay is the time to wait before the follow-up becomes available, as set by the Defer option.
+	Delay time.Duration
+}
+
+// FollowUpPusher is implemented by drivers that can push follow-up jobs atomically with the ack of a job.
+type FollowUpPusher interface {
+	// AckWithFollowUps acks the message, and pushes the follow-ups atomically with the ack. If the ack fails, for
+	// example with ErrLeaseLost, none of the follow-ups is pushed.
+	AckWithFollowUps(ctx context.Context, message *PersistedEvent, followUps []FollowUpJob) error
+}
+
+type followUpsKey struct{}
+
+// followUps collects the follow-ups of the job being handled.
+type followUps struct {
+	events []contract.Event
+}
+
+// FollowUp registers persisted events to be dispatched once the job being handled in the context completes
+// successfully, for example to chain the steps of a workflow. If the driver implements FollowUpPusher, as the
+// RedisDriver does, they are pushed atomically with the ack of the job, so that the chain can't break between the two.
+// If the job fails, the follow-ups are discarded, and registered again by the retry.
+//
+// It returns an error if it is called outside of the listeners of a persisted event, or if an event is not
+// persisted.
+func FollowUp(ctx context.Context, events ...contract.Event) error {
+	collector, ok := ctx.Value(followUpsKey{}).(*followUps)
+	if !ok {
+		return errors.New("follow-ups can only be registered while handling a persisted event")
+	}
+	for _, e := range events {
+		if _, ok := e.(persistent); !ok {
+			return fmt.Errorf("follow-up %s is not a persisted event", e.Type())
+		}
+	}
+	collector.events = append(collector.events, events...)
+	return nil
+}
+
+// collectFollowUps makes follow-ups registrable through the context.
+func collectFollowUps(ctx context.Context) (context.Context, *followUps) {
+	collector := &followUps{}
+	return context.WithValue(ctx, followUpsKey{}, collector), collector
+}
+
+// persistFollowUps persists the registered follow-ups. An error fails the job, which is then retried as usual.
+func (d *QueueableDispatcher) persistFollowUps(ctx context.Context, collector *followUps) ([]FollowUpJob, error) {
+	if len(collector.events) == 0 {
+		return nil, nil
+	}
+	jobs := make([]FollowUpJob, 0, len(collector.events))
+	for _, e := range collector.events {
+		p := e.(persistent)
+		msg, err := d.persist(ctx, e, p)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to persist follow-up")
+		}
+		jobs = append(jobs, FollowUpJob{Msg: msg, Delay: p.Defer()})
+	}
+	return jobs, nil
+}
+
+// ack acks the message along with its follow-ups. Drivers that don't implement FollowUpPusher push the follow-ups
+// after the ack, and those that fail to be pushed are lost.
+func (d *QueueableDispatcher) ack(msg *PersistedEvent, next []FollowUpJob) error {
+	ctx := context.Background()
+	if len(next) == 0 {
+		return d.driver.Ack(ctx, msg)
+	}
+	if pusher, ok := d.driver.(FollowUpPusher); ok {
+		return pusher.AckWithFollowUps(ctx, msg, next)
+	}
+	if err := d.driver.Ack(ctx, msg); err != nil {
+		return err
+	}
+	for _, job := range next {
+		if err := d.driver.Push(ctx, job.Msg, job.Delay); err != nil {
+			_ = level.Warn(d.logger).Log("err", errors.Wrapf(err, "follow-up %s of event %s lost", job.Msg.Key, msg.Key))
+		}
+	}
+	return nil
+}
+
+// ackWithFollowUps removes the reserved job ARGV[1] from KEYS[1], and then adds the follow-ups in the triples of
+// ARGV[3:], made of the job, the index of its key in KEYS and its score. Delayed follow-ups are added to the sorted
+// set KEYS[2]. The others are pushed onto their waiting queue in KEYS[3:], which is a sorted set if ARGV[2] is "1". If
+// the job is not reserved, nothing is changed and 0 is returned.
+var ackWithFollowUps = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+for i = 3, #ARGV, 3 do
+	local key = KEYS[tonumber(ARGV[i + 1])]
+	if ARGV[i + 1] == '2' or ARGV[2] == '1' then
+		redis.call('ZADD', key, ARGV[i + 2], ARGV[i])
+	else
+		redis.call('LPUSH', key, ARGV[i])
+	end
+end
+return 1
+`)
+
+// AckWithFollowUps implements FollowUpPusher. The ack and the follow-ups are applied in a single script. Follow-ups
+// guarded by Unique are dropped if they are duplicates.
+func (r *RedisDriver) AckWithFollowUps(ctx context.Context, message *PersistedEvent, followUps []FollowUpJob) error {
+	r.populateDefaults()
+	data, err := r.Packer.Compress(message)
+	if err != nil {
+		return errors.Wrap(err, "failed to compress message")
+	}
+	priorityOrder := "0"
+	if r.PriorityOrder {
+		priorityOrder = "1"
+	}
+	var (
+		args    = []interface{}{data, priorityOrder}
+		keys    = append([]string{r.ChannelConfig.Reserved, r.ChannelConfig.Delayed}, r.waitingKeys()...)
+		guarded []*PersistedEvent
+		now     = time.Now()
+	)
+	keyIndex := func(key string) int {
+		for i, k := range keys {
+			if k == key {
+				return i + 1
+			}
+		}
+		return 0
+	}
+	unguard := func() {
+		for _, msg := range guarded {
+			r.unguard(ctx, msg)
+		}
+	}
+	for _, job := range followUps {
+		followUp, err := r.Packer.Compress(job.Msg)
+		if err != nil {
+			unguard()
+			return errors.Wrap(err, "failed to compress follow-up")
+		}
+		if err := r.guard(ctx, job.Msg); err != nil {
+			if errors.Is(err, ErrDuplicate) {
+				_ = level.Info(r.Logger).Log("msg", fmt.Sprintf("duplicate follow-up %s of event %s dropped", job.Msg.Key, message.Key))
+				continue
+			}
+			unguard()
+			return err
+		}
+		if job.Msg.guarded() {
+			guarded = append(guarded, job.Msg)
+		}
+		if job.Delay > 0 {
+			args = append(args, followUp, 2, now.Add(job.Delay).Unix())
+			continue
+		}
+		args = append(args, followUp, keyIndex(r.waitingKeyOf(job.Msg)), waitingScore(job.Msg, now))
+	}
+	acked, err := ackWithFollowUps.Run(ctx, r.RedisClient, keys, args...).Int()
+	if err == nil && acked == 0 {
+		err = ErrLeaseLost
+	}
+	if err != nil {
+		unguard()
+		return errors.Wrap(err, "failed to ack message with follow-ups")
+	}
+	if message.releasedAt(ReleaseOnCompletion) {
+		r.unguard(ctx, message)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_FollowUp(t *testing.T) {
+	cases := []struct {
+		name       string
+		dispatcher *QueueableDispatcher
+	}{
+		{"redis", setUp()},
+		{"in process", WithQueue(&events.SyncDispatcher{}, NewInProcessDriver())},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			c.dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+				if event.Data().(MockEvent).Value != "A" {
+					return nil
+				}
+				return FollowUp(ctx,
+					Persist(events.Of(MockEvent{Value: "B"})),
+					Persist(events.Of(MockEvent{Value: "C"}), Defer(time.Hour)),
+				)
+			}))
+			assert.NoError(t, c.dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "A"}))))
+			msg, err := c.dispatcher.driver.Pop(ctx)
+			assert.NoError(t, err)
+			c.dispatcher.work(ctx, msg)
+
+			info, err := c.dispatcher.driver.Info(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, QueueInfo{Waiting: 1, Delayed: 1}, info)
+			msg, err = c.dispatcher.driver.Pop(ctx)
+			assert.NoError(t, err)
+			assert.Contains(t, string(msg.Value), "B")
+		})
+	}
+}
+
+func TestDispatcher_FollowUp_leaseLost(t *testing.T) {
+	ctx := context.Background()
+	dispatcher := setUp()
+	driver := dispatcher.driver.(*RedisDriver)
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		if event.Data().(MockEvent).Value != "A" {
+			return nil
+		}
+		// the reservation expires while the job is being handled.
+		driver.Flush(ctx, driver.ChannelConfig.Reserved)
+		return FollowUp(ctx, Persist(events.Of(MockEvent{Value: "B"})))
+	}))
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "A"}))))
+	msg, err := driver.Pop(ctx)
+	assert.NoError(t, err)
+	dispatcher.work(ctx, msg)
+
+	info, err := driver.Info(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, QueueInfo{}, info)
+}
+
+func TestFollowUp_outsideOfJob(t *testing.T) {
+	assert.Error(t, FollowUp(context.Background(), Persist(events.Of(MockEvent{}))))
+}
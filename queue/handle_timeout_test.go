@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_UseHandleTimeout(t *testing.T) {
+	ctx := context.Background()
+	driver := &RedisDriver{
+		RedisClient:   redis.NewUniversalClient(&redis.UniversalOptions{}),
+		ChannelConfig: NewChannelConfig("app", "testing", "handle_timeout"),
+		PopTimeout:    time.Second,
+	}
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseHandleTimeout(time.Minute))
+	for _, channel := range []string{driver.ChannelConfig.Waiting, driver.ChannelConfig.Reserved} {
+		driver.Flush(ctx, channel)
+		defer driver.Flush(ctx, channel)
+	}
+
+	cases := []struct {
+		name  string
+		push  func() error
+		lease time.Duration
+	}{
+		{"queue default", func() error {
+			return dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{})))
+		}, time.Minute},
+		{"per message", func() error {
+			return dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Timeout(2*time.Hour)))
+		}, 2 * time.Hour},
+		{"unset", func() error {
+			return driver.Push(ctx, &PersistedEvent{Key: "foreign"}, 0)
+		}, DefaultHandleTimeout},
+	}
+	for _, c := range cases {
+		assert.NoError(t, c.push(), c.name)
+		msg, err := driver.Pop(ctx)
+		assert.NoError(t, err, c.name)
+		assert.Equal(t, c.lease, msg.HandleTimeout, c.name)
+
+		reserved, err := driver.RedisClient.ZRangeWithScores(ctx, driver.ChannelConfig.Reserved, 0, -1).Result()
+		assert.NoError(t, err, c.name)
+		assert.Len(t, reserved, 1, c.name)
+		assert.InDelta(t, time.Now().Add(c.lease).Unix(), int64(reserved[0].Score), 2, c.name)
+		assert.NoError(t, driver.Ack(ctx, msg), c.name)
+	}
+}
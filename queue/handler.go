@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Handle subscribes a typed handler to the dispatcher. The handler must be a
+// function in the form of func(context.Context, T) error, where T is the event
+// payload type, for example:
+//
+//  err := queue.Handle(dispatcher, func(ctx context.Context, e UserCreated) error {
+//    return sendWelcomeEmail(ctx, e.Email)
+//  })
+//
+// Handle listens to T, and performs the type assertion before calling the
+// handler. An error is returned on mismatch, rather than a panic. It works
+// with any contract.Dispatcher, including the QueueableDispatcher, through the
+// standard Subscribe machinery.
+//
+// As the module targets go versions without type parameters, the signature of
+// handler is validated by reflection when Handle is called, and an error is
+// returned if it is invalid.
+func Handle(dispatcher contract.Dispatcher, handler interface{}) error {
+	listener, err := newTypedListener(handler)
+	if err != nil {
+		return err
+	}
+	dispatcher.Subscribe(listener)
+	return nil
+}
+
+type typedListener struct {
+	payloadType reflect.Type
+	handler     reflect.Value
+}
+
+func newTypedListener(handler interface{}) (typedListener, error) {
+	fn := reflect.ValueOf(handler)
+	ft := fn.Type()
+	if ft.Kind() != reflect.Func ||
+		ft.NumIn() != 2 ||
+		ft.NumOut() != 1 ||
+		ft.In(0) != contextType ||
+		ft.Out(0) != errorType {
+		return typedListener{}, fmt.Errorf("handler must be func(context.Context, T) error, got %s", ft)
+	}
+	if ft.In(1).Name() == "" {
+		return typedListener{}, fmt.Errorf("payload type of handler must be a named type, got %s", ft.In(1))
+	}
+	return typedListener{payloadType: ft.In(1), handler: fn}, nil
+}
+
+// Listen implements contract.Listener.
+func (t typedListener) Listen() []contract.Event {
+	return events.From(reflect.Zero(t.payloadType).Interface())
+}
+
+// Process implements contract.Listener.
+func (t typedListener) Process(ctx context.Context, event contract.Event) error {
+	data := event.Data()
+	if reflect.TypeOf(data) != t.payloadType {
+		return fmt.Errorf("event %s carries %T, handler expects %s", event.Type(), data, t.payloadType)
+	}
+	out := t.handler.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(data)})
+	if err, _ := out[0].Interface().(error); err != nil {
+		return err
+	}
+	return nil
+}
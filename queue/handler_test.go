@@ -0,0 +1,61 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandle(t *testing.T) {
+	dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriver())
+	var received MockEvent
+	err := Handle(dispatcher, func(ctx context.Context, e MockEvent) error {
+		received = e
+		if e.Value == "bad" {
+			return errors.New("bad value")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// the payload type is registered for deserialization.
+	assert.NotNil(t, dispatcher.reflectType(events.Of(MockEvent{}).Type()))
+
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), events.Of(MockEvent{Value: "hello"})))
+	assert.Equal(t, "hello", received.Value)
+	assert.EqualError(t, dispatcher.Dispatch(context.Background(), events.Of(MockEvent{Value: "bad"})), "bad value")
+
+	// persisted events are deserialized before reaching the handler.
+	msg, err := dispatcher.packer.Compress(MockEvent{Value: "persisted"})
+	assert.NoError(t, err)
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), &PersistedEvent{Key: events.Of(MockEvent{}).Type(), Value: msg}))
+	assert.Equal(t, "persisted", received.Value)
+}
+
+func TestHandle_invalid(t *testing.T) {
+	dispatcher := &events.SyncDispatcher{}
+	cases := []struct {
+		name    string
+		handler interface{}
+	}{
+		{"not a func", "foo"},
+		{"no context", func(e MockEvent) error { return nil }},
+		{"no error", func(ctx context.Context, e MockEvent) {}},
+		{"unnamed payload", func(ctx context.Context, e *MockEvent) error { return nil }},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			assert.Error(t, Handle(dispatcher, c.handler))
+		})
+	}
+}
+
+func TestTypedListener_mismatch(t *testing.T) {
+	listener, err := newTypedListener(func(ctx context.Context, e MockEvent) error { return nil })
+	assert.NoError(t, err)
+	assert.Error(t, listener.Process(context.Background(), events.Of(RetryingEvent{})))
+}
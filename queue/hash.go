@@ -0,0 +1,61 @@
+package queue
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/pkg/errors"
+)
+
+// ContentHasher computes a canonical hash of the content of events, suitable
+// as a deduplication key. Semantically equal events have the same hash,
+// regardless of the iteration order of maps in the payload, or whether the
+// payload is a struct or a map with the same fields.
+//
+// The payload is converted to JSON, and the JSON is normalized with map keys
+// sorted and numbers kept verbatim, before it is hashed along with the type of
+// the event. Therefore, only the fields that survive a JSON round trip
+// contribute to the hash. Hash the event before it is dispatched: the payload
+// of a PersistedEvent is already serialized.
+type ContentHasher struct {
+	// Hash creates the hash function. By default it is sha256.New.
+	Hash func() hash.Hash
+}
+
+// Sum returns the hex encoded hash of the event.
+func (c ContentHasher) Sum(event contract.Event) (string, error) {
+	data, err := json.Marshal(event.Data())
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to hash the payload of %s", event.Type())
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var normalized interface{}
+	if err := decoder.Decode(&normalized); err != nil {
+		return "", errors.Wrapf(err, "failed to hash the payload of %s", event.Type())
+	}
+	// encoding/json sorts the keys of maps.
+	if data, err = json.Marshal(normalized); err != nil {
+		return "", errors.Wrapf(err, "failed to hash the payload of %s", event.Type())
+	}
+
+	newHash := c.Hash
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	h := newHash()
+	h.Write([]byte(event.Type()))
+	h.Write([]byte{0})
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ContentHash returns the canonical hash of the event with the default
+// ContentHasher.
+func ContentHash(event contract.Event) (string, error) {
+	return ContentHasher{}.Sum(event)
+}
@@ -0,0 +1,49 @@
+package queue
+
+import (
+	"crypto/md5"
+	"testing"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+type hashPayload struct {
+	Name  string                 `json:"name"`
+	Attrs map[string]interface{} `json:"attrs"`
+}
+
+func TestContentHash(t *testing.T) {
+	a := events.Of(hashPayload{Name: "foo", Attrs: map[string]interface{}{"a": 1, "b": 2.5, "c": "x"}})
+	b := events.Of(hashPayload{Name: "foo", Attrs: map[string]interface{}{"c": "x", "b": 2.5, "a": 1}})
+	c := events.Of(hashPayload{Name: "bar", Attrs: map[string]interface{}{"a": 1, "b": 2.5, "c": "x"}})
+
+	hashA, err := ContentHash(a)
+	assert.NoError(t, err)
+	assert.Len(t, hashA, 64)
+	for i := 0; i < 10; i++ {
+		hashB, err := ContentHash(b)
+		assert.NoError(t, err)
+		assert.Equal(t, hashA, hashB)
+	}
+	hashC, _ := ContentHash(c)
+	assert.NotEqual(t, hashA, hashC)
+
+	// persisting doesn't change the content.
+	hashP, _ := ContentHash(Persist(a, MaxAttempts(3)))
+	assert.Equal(t, hashA, hashP)
+
+	// the type is part of the hash.
+	hashM, _ := ContentHash(events.Of(MockEvent{}))
+	hashE, _ := ContentHash(events.Of(RetryingEvent{}))
+	assert.NotEqual(t, hashM, hashE)
+
+	_, err = ContentHash(events.Of(hashPayload{Attrs: map[string]interface{}{"f": func() {}}}))
+	assert.Error(t, err)
+}
+
+func TestContentHasher_Hash(t *testing.T) {
+	sum, err := ContentHasher{Hash: md5.New}.Sum(events.Of(MockEvent{Value: "foo"}))
+	assert.NoError(t, err)
+	assert.Len(t, sum, 32)
+}
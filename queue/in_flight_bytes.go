@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+// inFlightBytes tracks the payload bytes of the jobs being handled, and pauses reservations above the limit.
+type inFlightBytes struct {
+	limit int64
+	mu    sync.Mutex
+	used  int64
+	freed chan struct{}
+}
+
+// UseMaxInFlightBytes is an option for WithQueue that caps the total payload bytes of the jobs being handled by the
+// consumer, as a memory-aware backpressure independent of the parallelism. Once the cap is reached, no more jobs are
+// reserved until enough of them complete. The size of a job is only known once it is reserved, so the cap may be
+// exceeded by one job, and a job larger than the cap is handled alone. Non-positive values disable the cap.
+func UseMaxInFlightBytes(bytes int64) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		if bytes <= 0 {
+			dispatcher.inFlightBytes = nil
+			return
+		}
+		dispatcher.inFlightBytes = &inFlightBytes{limit: bytes, freed: make(chan struct{})}
+	}
+}
+
+// InFlightBytes returns the total payload bytes of the jobs being handled. It is only tracked if UseMaxInFlightBytes
+// is in effect.
+func (d *QueueableDispatcher) InFlightBytes() int64 {
+	if d.inFlightBytes == nil {
+		return 0
+	}
+	d.inFlightBytes.mu.Lock()
+	defer d.inFlightBytes.mu.Unlock()
+	return d.inFlightBytes.used
+}
+
+// wait blocks until the bytes in flight are below the limit, or the context is canceled.
+func (b *inFlightBytes) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		if b.used < b.limit {
+			b.mu.Unlock()
+			return nil
+		}
+		freed := b.freed
+		b.mu.Unlock()
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (b *inFlightBytes) add(msg *PersistedEvent) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used += int64(len(msg.Value))
+}
+
+func (b *inFlightBytes) release(msg *PersistedEvent) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= int64(len(msg.Value))
+	close(b.freed)
+	b.freed = make(chan struct{})
+}
@@ -0,0 +1,47 @@
+package queue
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_UseMaxInFlightBytes(t *testing.T) {
+	driver := NewInProcessDriverWithPopInterval(time.Millisecond)
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseParallelism(5), UseMaxInFlightBytes(1500))
+	var running int32
+	release := make(chan struct{})
+	dispatcher.Subscribe(events.Listen(events.From(MockEvent{}), func(ctx context.Context, event contract.Event) error {
+		atomic.AddInt32(&running, 1)
+		<-release
+		atomic.AddInt32(&running, -1)
+		return nil
+	}))
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{Value: strings.Repeat("x", 1000)}))))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Consume(ctx)
+
+	// The first job is below the cap, so a second one is reserved. Then the cap is exceeded.
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&running) == 2
+	}, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&running))
+	assert.Greater(t, dispatcher.InFlightBytes(), int64(2000))
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		info, _ := driver.Info(ctx)
+		return info == QueueInfo{} && dispatcher.InFlightBytes() == 0
+	}, time.Second, time.Millisecond)
+}
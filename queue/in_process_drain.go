@@ -0,0 +1,148 @@
+package queue
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// drainer is implemented by drivers that keep jobs in memory, and can save them to a file on shutdown.
+type drainer interface {
+	Drain(ctx context.Context, path string) error
+	Restore(ctx context.Context, path string) error
+}
+
+type inProcessSnapshot struct {
+	Waiting []*PersistedEvent
+	Delayed []delayedSnapshot
+	Failed  []*PersistedEvent
+	Timeout []*PersistedEvent
+}
+
+type delayedSnapshot struct {
+	Event *PersistedEvent
+	At    time.Time
+}
+
+// Drain moves all jobs out of the driver and saves them to the file at path. Reserved jobs, which have not been
+// acknowledged yet, are saved as waiting, so that they are handled again after Restore. The file is written
+// atomically. The driver is empty afterwards.
+func (i *InProcessDriver) Drain(ctx context.Context, path string) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	var snapshot inProcessSnapshot
+	for message := range i.reserved {
+		snapshot.Waiting = append(snapshot.Waiting, message)
+	}
+	for len(i.waiting) > 0 {
+		snapshot.Waiting = append(snapshot.Waiting, <-i.waiting)
+	}
+	for _, delayed := range *i.delayed {
+		snapshot.Delayed = append(snapshot.Delayed, delayedSnapshot{Event: delayed.event, At: delayed.priority})
+	}
+	for message := range i.failed {
+		snapshot.Failed = append(snapshot.Failed, message)
+	}
+	for message := range i.timeout {
+		snapshot.Timeout = append(snapshot.Timeout, message)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snapshot); err != nil {
+		return errors.Wrap(err, "failed to encode in-process queue")
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "failed to drain in-process queue")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to drain in-process queue")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to drain in-process queue")
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrap(err, "failed to drain in-process queue")
+	}
+
+	delayed := make(priorityQueue, 0, 10)
+	i.delayed = &delayed
+	i.reserved = make(map[*PersistedEvent]time.Time)
+	i.failed = make(map[*PersistedEvent]struct{})
+	i.timeout = make(map[*PersistedEvent]struct{})
+	return nil
+}
+
+// Restore loads the jobs saved by Drain from the file at path into the driver, and removes the file. It is a no-op
+// if the file doesn't exist.
+func (i *InProcessDriver) Restore(ctx context.Context, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to restore in-process queue")
+	}
+	var snapshot inProcessSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snapshot); err != nil {
+		return errors.Wrap(err, "failed to decode in-process queue")
+	}
+
+	i.mutex.Lock()
+	// Waiting jobs are pushed to the front of the delayed queue, as the waiting channel is bounded.
+	for _, message := range snapshot.Waiting {
+		heap.Push(i.delayed, &item{event: message})
+	}
+	for _, delayed := range snapshot.Delayed {
+		heap.Push(i.delayed, &item{event: delayed.Event, priority: delayed.At})
+	}
+	for _, message := range snapshot.Failed {
+		i.failed[message] = struct{}{}
+	}
+	for _, message := range snapshot.Timeout {
+		i.timeout[message] = struct{}{}
+	}
+	i.mutex.Unlock()
+
+	return os.Remove(path)
+}
+
+// UseDrainFile is an option for WithQueue that saves the jobs to the file at path when Consume returns, for example
+// when the run group is interrupted by SIGTERM, and loads them back when Consume starts. It gives basic durability
+// to drivers that keep jobs in memory, such as the InProcessDriver. It is a no-op for other drivers.
+//
+// Jobs dispatched after the consumer has stopped are not saved.
+func UseDrainFile(path string) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.drainFile = path
+	}
+}
+
+func (d *QueueableDispatcher) restore(ctx context.Context) error {
+	driver, ok := d.driver.(drainer)
+	if !ok || d.drainFile == "" {
+		return nil
+	}
+	return driver.Restore(ctx, d.drainFile)
+}
+
+func (d *QueueableDispatcher) drain() {
+	driver, ok := d.driver.(drainer)
+	if !ok || d.drainFile == "" {
+		return
+	}
+	if err := driver.Drain(context.Background(), d.drainFile); err != nil {
+		_ = level.Error(d.logger).Log("err", err)
+	}
+}
@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInProcessDriver_Drain(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "queue.gob")
+
+	driver := NewInProcessDriverWithPopInterval(time.Millisecond)
+	assert.NoError(t, driver.Push(ctx, &PersistedEvent{Key: "waiting"}, 0))
+	assert.NoError(t, driver.Push(ctx, &PersistedEvent{Key: "reserved", HandleTimeout: time.Hour}, 0))
+	assert.NoError(t, driver.Push(ctx, &PersistedEvent{Key: "delayed"}, time.Hour))
+	assert.NoError(t, driver.Fail(ctx, &PersistedEvent{Key: "failed"}))
+	msg, err := driver.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "waiting", msg.Key)
+	assert.NoError(t, driver.Ack(ctx, msg))
+	_, err = driver.Pop(ctx)
+	assert.NoError(t, err)
+
+	assert.NoError(t, driver.Drain(ctx, path))
+	info, _ := driver.Info(ctx)
+	assert.Equal(t, QueueInfo{}, info)
+
+	restored := NewInProcessDriverWithPopInterval(time.Millisecond)
+	assert.NoError(t, restored.Restore(ctx, path))
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+	info, _ = restored.Info(ctx)
+	assert.Equal(t, QueueInfo{Delayed: 2, Failed: 1}, info)
+
+	// the reserved job is handed out again, the delayed one keeps its schedule.
+	msg, err = restored.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "reserved", msg.Key)
+	_, err = restored.Pop(ctx)
+	assert.Equal(t, ErrEmpty, err)
+
+	// restoring from a missing file is a no-op.
+	assert.NoError(t, restored.Restore(ctx, path))
+}
+
+func TestDispatcher_UseDrainFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.gob")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// the job is either left waiting, or interrupted and retried. Either way it is drained.
+	dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriverWithPopInterval(time.Millisecond), UseLogger(log.NewNopLogger()), UseDrainFile(path))
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		return ctx.Err()
+	}))
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{Value: "hello"}), MaxAttempts(2))))
+	assert.Error(t, dispatcher.Consume(ctx))
+	_, err := os.Stat(path)
+	assert.NoError(t, err)
+
+	handled := make(chan string, 1)
+	dispatcher = WithQueue(&events.SyncDispatcher{}, NewInProcessDriverWithPopInterval(time.Millisecond), UseLogger(log.NewNopLogger()), UseDrainFile(path))
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		handled <- event.Data().(MockEvent).Value
+		return nil
+	}))
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Consume(ctx)
+	select {
+	case value := <-handled:
+		assert.Equal(t, "hello", value)
+	case <-time.After(5 * time.Second):
+		t.Fatal("drained job is not restored")
+	}
+}
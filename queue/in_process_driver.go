@@ -6,8 +6,16 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
+// DefaultBufferSize is the default number of waiting jobs held by the InProcessDriver.
+const DefaultBufferSize = 1000
+
+// ErrQueueFull is returned by the non-blocking InProcessDriver when its buffer is full. See WithBlocking.
+var ErrQueueFull = errors.New("queue full")
+
 // An item is something we manage in a priority queue.
 type item struct {
 	event    *PersistedEvent // The value of the item; arbitrary.
@@ -50,44 +58,82 @@ func (pq *priorityQueue) Pop() interface{} {
 }
 
 // InProcessDriver is a test replacement for redis driver. It doesn't persist your event in any way,
-// so not suitable for production use.
+// so not suitable for production use. For small single-node apps, UseDrainFile offers basic durability by saving the
+// jobs to a file on shutdown.
 type InProcessDriver struct {
 	popInterval time.Duration
+	bufferSize  int
+	nonBlocking bool
 	mutex       sync.Mutex
 	delayed     *priorityQueue
 	waiting     chan *PersistedEvent
 	reserved    map[*PersistedEvent]time.Time
 	failed      map[*PersistedEvent]struct{}
 	timeout     map[*PersistedEvent]struct{}
+	unique      map[string]uniqueGuard
+}
+
+// InProcessOption is an option for NewInProcessDriver.
+type InProcessOption func(*InProcessDriver)
+
+// WithBufferSize is an InProcessOption that bounds the number of waiting jobs, DefaultBufferSize by default. When
+// the buffer is full, pushing a job blocks, or fails, see WithBlocking. Delayed jobs become waiting as room is made.
+// Sizes below 1 are raised to 1, as the driver needs room for at least one waiting job.
+func WithBufferSize(size int) InProcessOption {
+	return func(driver *InProcessDriver) {
+		if size < 1 {
+			size = 1
+		}
+		driver.bufferSize = size
+	}
+}
+
+// WithBlocking is an InProcessOption that decides what pushing a job does when the buffer is full. If blocking, the
+// default, it waits for room until the context is done. Otherwise, it fails at once with ErrQueueFull.
+func WithBlocking(blocking bool) InProcessOption {
+	return func(driver *InProcessDriver) {
+		driver.nonBlocking = !blocking
+	}
 }
 
-// NewInProcessDriverWithPopInterval creates an *InProcessDriver for testing
-func NewInProcessDriver() *InProcessDriver {
+// WithPopInterval is an InProcessOption that sets how long Pop waits for a job before it returns ErrEmpty, one second
+// by default.
+func WithPopInterval(duration time.Duration) InProcessOption {
+	return func(driver *InProcessDriver) {
+		driver.popInterval = duration
+	}
+}
+
+// NewInProcessDriver creates an *InProcessDriver for testing
+func NewInProcessDriver(opts ...InProcessOption) *InProcessDriver {
 	delayed := make(priorityQueue, 0, 10)
-	return &InProcessDriver{
+	driver := &InProcessDriver{
 		popInterval: time.Second,
+		bufferSize:  DefaultBufferSize,
 		delayed:     &delayed,
 		reserved:    make(map[*PersistedEvent]time.Time),
-		waiting:     make(chan *PersistedEvent, 1000),
 		failed:      make(map[*PersistedEvent]struct{}),
 		timeout:     make(map[*PersistedEvent]struct{}),
 	}
+	for _, f := range opts {
+		f(driver)
+	}
+	driver.waiting = make(chan *PersistedEvent, driver.bufferSize)
+	return driver
 }
 
 // NewInProcessDriverWithPopInterval creates an *InProcessDriver with an pop interval.
 func NewInProcessDriverWithPopInterval(duration time.Duration) *InProcessDriver {
-	delayed := make(priorityQueue, 0, 10)
-	return &InProcessDriver{
-		popInterval: duration,
-		delayed:     &delayed,
-		reserved:    make(map[*PersistedEvent]time.Time),
-		waiting:     make(chan *PersistedEvent, 1000),
-		failed:      make(map[*PersistedEvent]struct{}),
-		timeout:     make(map[*PersistedEvent]struct{}),
-	}
+	return NewInProcessDriver(WithPopInterval(duration))
 }
 
 func (i *InProcessDriver) Push(ctx context.Context, message *PersistedEvent, delay time.Duration) error {
+	i.mutex.Lock()
+	if err := i.guard(message); err != nil {
+		i.mutex.Unlock()
+		return err
+	}
+	i.mutex.Unlock()
 	if delay > 0 {
 		i.mutex.Lock()
 		heap.Push(i.delayed, &item{
@@ -97,10 +143,24 @@ func (i *InProcessDriver) Push(ctx context.Context, message *PersistedEvent, del
 		i.mutex.Unlock()
 		return nil
 	}
+	if i.nonBlocking {
+		select {
+		case i.waiting <- message:
+			return nil
+		default:
+			i.mutex.Lock()
+			i.unguard(message)
+			i.mutex.Unlock()
+			return errors.Wrapf(ErrQueueFull, "%d jobs waiting", cap(i.waiting))
+		}
+	}
 	select {
 	case i.waiting <- message:
 		return nil
 	case <-ctx.Done():
+		i.mutex.Lock()
+		i.unguard(message)
+		i.mutex.Unlock()
 		return ctx.Err()
 	}
 }
@@ -108,7 +168,8 @@ func (i *InProcessDriver) Push(ctx context.Context, message *PersistedEvent, del
 func (i *InProcessDriver) Pop(ctx context.Context) (*PersistedEvent, error) {
 	i.mutex.Lock()
 	for {
-		if len(*i.delayed) == 0 {
+		// Due jobs stay delayed until there is room, as Pop must not block while holding the mutex.
+		if len(*i.delayed) == 0 || len(i.waiting) == cap(i.waiting) {
 			break
 		}
 		top := heap.Pop(i.delayed).(*item)
@@ -127,7 +188,13 @@ func (i *InProcessDriver) Pop(ctx context.Context) (*PersistedEvent, error) {
 	i.mutex.Unlock()
 	select {
 	case message := <-i.waiting:
+		message.reserve()
+		i.mutex.Lock()
 		i.reserved[message] = time.Now().Add(message.HandleTimeout)
+		if message.releasedAt(ReleaseOnReserve) {
+			i.unguard(message)
+		}
+		i.mutex.Unlock()
 		return message, nil
 	case <-time.After(i.popInterval):
 		return nil, ErrEmpty
@@ -140,6 +207,9 @@ func (i *InProcessDriver) Ack(ctx context.Context, message *PersistedEvent) erro
 	i.mutex.Lock()
 	defer i.mutex.Unlock()
 	delete(i.reserved, message)
+	if message.releasedAt(ReleaseOnCompletion) {
+		i.unguard(message)
+	}
 	return nil
 }
 
@@ -148,6 +218,9 @@ func (i *InProcessDriver) Fail(ctx context.Context, message *PersistedEvent) err
 	defer i.mutex.Unlock()
 	delete(i.reserved, message)
 	i.failed[message] = struct{}{}
+	if message.releasedAt(ReleaseOnCompletion) {
+		i.unguard(message)
+	}
 	return nil
 }
 
@@ -158,7 +231,7 @@ func (i *InProcessDriver) Reload(ctx context.Context, channel string) (int64, er
 	if channel == "failed" {
 		for k := range i.failed {
 			delete(i.failed, k)
-			i.waiting <- k
+			i.reload(k)
 			j++
 		}
 		return j, nil
@@ -166,7 +239,7 @@ func (i *InProcessDriver) Reload(ctx context.Context, channel string) (int64, er
 	if channel == "timeout" {
 		for k := range i.timeout {
 			delete(i.timeout, k)
-			i.waiting <- k
+			i.reload(k)
 			j++
 		}
 		return j, nil
@@ -174,13 +247,29 @@ func (i *InProcessDriver) Reload(ctx context.Context, channel string) (int64, er
 	return 0, fmt.Errorf("unsupported channel %s", channel)
 }
 
+// reload puts the message onto the waiting channel. If it is full, the message is pushed to the front of the delayed
+// queue instead. The mutex must be held.
+func (i *InProcessDriver) reload(message *PersistedEvent) {
+	select {
+	case i.waiting <- message:
+	default:
+		heap.Push(i.delayed, &item{event: message})
+	}
+}
+
 func (i *InProcessDriver) Flush(ctx context.Context, channel string) error {
 	i.mutex.Lock()
 	defer i.mutex.Unlock()
 	if channel == "failed" {
+		for message := range i.failed {
+			i.unguard(message)
+		}
 		i.failed = make(map[*PersistedEvent]struct{})
 	}
 	if channel == "timeout" {
+		for message := range i.timeout {
+			i.unguard(message)
+		}
 		i.timeout = make(map[*PersistedEvent]struct{})
 	}
 	return nil
@@ -190,10 +279,11 @@ func (i *InProcessDriver) Info(ctx context.Context) (QueueInfo, error) {
 	i.mutex.Lock()
 	defer i.mutex.Unlock()
 	return QueueInfo{
-		Waiting: int64(len(i.waiting)),
-		Delayed: int64(len(*i.delayed)),
-		Timeout: int64(len(i.timeout)),
-		Failed:  int64(len(i.failed)),
+		Waiting:  int64(len(i.waiting)),
+		Delayed:  int64(len(*i.delayed)),
+		Reserved: int64(len(i.reserved)),
+		Timeout:  int64(len(i.timeout)),
+		Failed:   int64(len(i.failed)),
 	}, nil
 }
 
@@ -201,7 +291,8 @@ func (i *InProcessDriver) Retry(ctx context.Context, message *PersistedEvent) er
 	i.mutex.Lock()
 	defer i.mutex.Unlock()
 	delete(i.reserved, message)
-	newBackOff := getRetryDuration(message.Backoff)
+	newBackOff := message.retryDelay()
+	message.Attempts++
 	heap.Push(i.delayed, &item{
 		event:    message,
 		priority: time.Now().Add(newBackOff),
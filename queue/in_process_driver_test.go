@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInProcessDriver_bufferSize(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("non-blocking", func(t *testing.T) {
+		dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriver(WithBufferSize(2), WithBlocking(false)))
+		for i := 0; i < 2; i++ {
+			assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}))))
+		}
+		err := dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{})))
+		assert.True(t, errors.Is(err, ErrQueueFull), err)
+	})
+
+	t.Run("blocking", func(t *testing.T) {
+		driver := NewInProcessDriver(WithBufferSize(1))
+		dispatcher := WithQueue(&events.SyncDispatcher{}, driver)
+		assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}))))
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		err := dispatcher.Dispatch(timeoutCtx, Persist(events.Of(MockEvent{})))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+
+		done := make(chan error)
+		go func() {
+			done <- dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{})))
+		}()
+		_, err = driver.Pop(ctx)
+		assert.NoError(t, err)
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("sizes below 1", func(t *testing.T) {
+		for _, size := range []int{0, -1} {
+			driver := NewInProcessDriver(WithBufferSize(size), WithPopInterval(time.Millisecond))
+			dispatcher := WithQueue(&events.SyncDispatcher{}, driver)
+			assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Defer(time.Millisecond))))
+			time.Sleep(2 * time.Millisecond)
+			_, err := driver.Pop(ctx)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("delayed jobs wait for room", func(t *testing.T) {
+		driver := NewInProcessDriver(WithBufferSize(1), WithPopInterval(time.Millisecond))
+		dispatcher := WithQueue(&events.SyncDispatcher{}, driver)
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Defer(time.Millisecond))))
+		}
+		time.Sleep(2 * time.Millisecond)
+		for i := 0; i < 3; i++ {
+			_, err := driver.Pop(ctx)
+			assert.NoError(t, err)
+		}
+	})
+}
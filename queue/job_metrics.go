@@ -0,0 +1,42 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// UseCounters is an option for WithQueue that counts the jobs processed successfully, the jobs failed after their
+// final attempt, and the attempts retried. Any of the counters can be nil. Together they allow alerting on the failure
+// rate, rather than on the queue length alone.
+func UseCounters(processed, failed, retried metrics.Counter) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.processedCounter = processed
+		dispatcher.failedCounter = failed
+		dispatcher.retriedCounter = retried
+	}
+}
+
+// UseDurationHistogram is an option for WithQueue that observes the duration of each attempt to handle a job, in
+// seconds. Unlike the latency, it excludes the time spent waiting in the queue. See UseLatencyHistogram.
+func UseDurationHistogram(histogram metrics.Histogram) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.durationHistogram = histogram
+	}
+}
+
+// observeDuration records the duration of an attempt that started at start.
+func (d *QueueableDispatcher) observeDuration(start time.Time) {
+	if d.durationHistogram == nil {
+		return
+	}
+	d.durationHistogram.Observe(time.Since(start).Seconds())
+}
+
+// withQueueLabel labels the counter with the queue name. A nil counter stays nil.
+func withQueueLabel(counter metrics.Counter, name string) metrics.Counter {
+	if counter == nil {
+		return nil
+	}
+	return counter.With("queue", name)
+}
@@ -0,0 +1,51 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_UseCounters(t *testing.T) {
+	processed := generic.NewCounter("processed")
+	failed := generic.NewCounter("failed")
+	retried := generic.NewCounter("retried")
+	histogram := &recordingHistogram{}
+	driver := NewInProcessDriver()
+	dispatcher := WithQueue(
+		&events.SyncDispatcher{},
+		driver,
+		UseLogger(log.NewNopLogger()),
+		UseCounters(processed, failed, retried),
+		UseDurationHistogram(histogram),
+	)
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		if event.Data().(MockEvent).Value == "fail" {
+			return errors.New("failed")
+		}
+		return nil
+	}))
+
+	ctx := context.Background()
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "ok"}))))
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "fail"}), MaxAttempts(2), Backoff(time.Millisecond, time.Millisecond, 1))))
+	for i := 0; i < 3; i++ {
+		// lets the retry become due
+		time.Sleep(10 * time.Millisecond)
+		msg, err := driver.Pop(ctx)
+		assert.NoError(t, err)
+		dispatcher.work(ctx, msg)
+	}
+
+	assert.Equal(t, 1.0, processed.Value())
+	assert.Equal(t, 1.0, failed.Value())
+	assert.Equal(t, 1.0, retried.Value())
+	assert.Len(t, histogram.values, 3)
+}
@@ -0,0 +1,292 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaDueHeader is the header of delayed jobs holding the unix time in milliseconds at which they become due.
+const kafkaDueHeader = "queue-due"
+
+// ErrNotSupportedByKafka is returned by the KafkaDriver for the operations that a log can't support, such as
+// flushing a channel.
+var ErrNotSupportedByKafka = errors.New("not supported by the kafka driver")
+
+// KafkaReader consumes a topic for the KafkaDriver. It is implemented by *kafka.Reader, which must belong to a
+// consumer group.
+type KafkaReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// KafkaWriter produces messages for the KafkaDriver. It is implemented by *kafka.Writer, which must not set a Topic,
+// as the driver names the topic of each message.
+type KafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// KafkaDriver is a Driver using kafka as the transport. Pushing a job produces it to Topic, and Pop consumes Topic
+// with the consumer group of Reader. The offset of a job is committed once it is acked, failed or retried, so a job
+// being handled when the consumer crashes is consumed again.
+//
+// Kafka has no delayed delivery. Delayed jobs and retries are produced to DelayTopic instead, and Promote moves them
+// to Topic once they are due. As DelayTopic is consumed in order, a job waits for the jobs produced before it in the
+// same partition, even if it is due earlier. Failed jobs are produced to FailedTopic, if set, and dropped otherwise.
+// So are the messages that Pop can't decompress, which are logged and skipped.
+//
+// The driver has no view of the whole queue. Reload, Flush and Unique jobs are not supported, and Info only reports
+// the reserved jobs. Committing an offset commits the jobs before it in the partition, so with a parallelism greater
+// than one, a crash may lose jobs that were popped earlier but not acked yet.
+type KafkaDriver struct {
+	// Writer produces the jobs to the topics.
+	Writer KafkaWriter
+	// Reader consumes Topic.
+	Reader KafkaReader
+	// DelayReader consumes DelayTopic. It is only used by Promote, and must be in a different consumer group than
+	// Reader. Without it, delayed jobs can't be pushed, and retried jobs are failed.
+	DelayReader KafkaReader
+	// Topic is the topic of the waiting jobs.
+	Topic string
+	// DelayTopic is the topic of the delayed jobs.
+	DelayTopic string
+	// FailedTopic is the topic of the failed jobs. Optional.
+	FailedTopic string
+	// Packer serializes the jobs. Defaults to gob.
+	Packer Packer
+	// Logger logs the jobs dropped by Fail or Pop, and those failed by Retry. Defaults to a nop logger.
+	Logger log.Logger
+	// PopTimeout is how long Pop waits for a job before it returns ErrEmpty, one second by default.
+	PopTimeout time.Duration
+
+	fetchMu  sync.Mutex
+	mu       sync.Mutex
+	reserved map[*PersistedEvent]kafka.Message
+}
+
+// Push implements Driver. Jobs with a delay are produced to DelayTopic.
+func (k *KafkaDriver) Push(ctx context.Context, message *PersistedEvent, delay time.Duration) error {
+	if message.guarded() {
+		return errors.Wrap(ErrNotSupportedByKafka, "unique jobs")
+	}
+	data, err := k.packer().Compress(message)
+	if err != nil {
+		return errors.Wrap(err, "failed to compress message")
+	}
+	if delay > 0 {
+		return k.delay(ctx, message.Key, data, time.Now().Add(delay))
+	}
+	if err := k.Writer.WriteMessages(ctx, kafka.Message{Topic: k.Topic, Key: []byte(message.Key), Value: data}); err != nil {
+		return errors.Wrap(err, "failed to produce message")
+	}
+	return nil
+}
+
+// delay produces the job to DelayTopic, to be promoted at due.
+func (k *KafkaDriver) delay(ctx context.Context, key string, data []byte, due time.Time) error {
+	if k.DelayReader == nil {
+		return errors.Wrap(ErrNotSupportedByKafka, "delayed jobs without a DelayReader")
+	}
+	err := k.Writer.WriteMessages(ctx, kafka.Message{
+		Topic:   k.DelayTopic,
+		Key:     []byte(key),
+		Value:   data,
+		Headers: []kafka.Header{{Key: kafkaDueHeader, Value: []byte(strconv.FormatInt(due.UnixNano()/int64(time.Millisecond), 10))}},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to produce delayed message")
+	}
+	return nil
+}
+
+// Pop implements Driver.
+func (k *KafkaDriver) Pop(ctx context.Context) (*PersistedEvent, error) {
+	timeout := k.PopTimeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	fetchCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	k.fetchMu.Lock()
+	msg, err := k.Reader.FetchMessage(fetchCtx)
+	k.fetchMu.Unlock()
+	if err != nil {
+		if ctx.Err() == nil && fetchCtx.Err() != nil {
+			return nil, ErrEmpty
+		}
+		return nil, errors.Wrap(err, "failed to fetch message")
+	}
+	var message PersistedEvent
+	if err := k.packer().Decompress(msg.Value, &message); err != nil {
+		_ = level.Warn(k.logger()).Log("msg", "failed to decompress message", "offset", msg.Offset, "err", err)
+		if err := k.discard(ctx, msg); err != nil {
+			return nil, err
+		}
+		return nil, ErrEmpty
+	}
+	message.Reservations++
+	message.reserve()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.reserved == nil {
+		k.reserved = make(map[*PersistedEvent]kafka.Message)
+	}
+	k.reserved[&message] = msg
+	return &message, nil
+}
+
+// discard produces the message that can't be decompressed to FailedTopic as is, and commits its offset, so that it
+// neither blocks the partition nor stops the consumer.
+func (k *KafkaDriver) discard(ctx context.Context, msg kafka.Message) error {
+	if k.FailedTopic == "" {
+		_ = level.Warn(k.logger()).Log("msg", "undecodable job dropped, as no failed topic is configured", "offset", msg.Offset)
+	} else {
+		err := k.Writer.WriteMessages(ctx, kafka.Message{Topic: k.FailedTopic, Key: msg.Key, Value: msg.Value, Headers: msg.Headers})
+		if err != nil {
+			return errors.Wrap(err, "failed to produce undecodable message")
+		}
+	}
+	if err := k.Reader.CommitMessages(ctx, msg); err != nil {
+		return errors.Wrap(err, "failed to commit undecodable message")
+	}
+	return nil
+}
+
+// Ack implements Driver. It commits the offset of the job.
+func (k *KafkaDriver) Ack(ctx context.Context, message *PersistedEvent) error {
+	return k.commit(ctx, message)
+}
+
+// Fail implements Driver. The job is produced to FailedTopic before its offset is committed.
+func (k *KafkaDriver) Fail(ctx context.Context, message *PersistedEvent) error {
+	if k.FailedTopic == "" {
+		_ = level.Warn(k.logger()).Log("msg", "failed job dropped, as no failed topic is configured", "key", message.Key)
+		return k.commit(ctx, message)
+	}
+	data, err := k.packer().Compress(message)
+	if err != nil {
+		return errors.Wrap(err, "failed to compress message")
+	}
+	if err := k.Writer.WriteMessages(ctx, kafka.Message{Topic: k.FailedTopic, Key: []byte(message.Key), Value: data}); err != nil {
+		return errors.Wrap(err, "failed to produce failed message")
+	}
+	return k.commit(ctx, message)
+}
+
+// Retry implements Driver. The job is produced to DelayTopic, due after its backoff, before its offset is committed.
+// Without a DelayReader, the job can't be retried and is failed instead.
+func (k *KafkaDriver) Retry(ctx context.Context, message *PersistedEvent) error {
+	if k.DelayReader == nil {
+		_ = level.Warn(k.logger()).Log("msg", "job failed instead of retried, as no delay reader is configured", "key", message.Key)
+		return k.Fail(ctx, message)
+	}
+	message.Backoff = message.retryDelay()
+	message.Attempts++
+	data, err := k.packer().Compress(message)
+	if err != nil {
+		return errors.Wrap(err, "failed to compress message")
+	}
+	if err := k.delay(ctx, message.Key, data, time.Now().Add(message.Backoff)); err != nil {
+		return err
+	}
+	return k.commit(ctx, message)
+}
+
+// commit commits the offset of the reserved job.
+func (k *KafkaDriver) commit(ctx context.Context, message *PersistedEvent) error {
+	k.mu.Lock()
+	msg, ok := k.reserved[message]
+	delete(k.reserved, message)
+	k.mu.Unlock()
+	if !ok {
+		return ErrLeaseLost
+	}
+	if err := k.Reader.CommitMessages(ctx, msg); err != nil {
+		return errors.Wrap(err, "failed to commit message")
+	}
+	return nil
+}
+
+// Reload implements Driver. It is not supported: replay FailedTopic instead.
+func (k *KafkaDriver) Reload(ctx context.Context, channel string) (int64, error) {
+	return 0, errors.Wrap(ErrNotSupportedByKafka, "reload")
+}
+
+// Flush implements Driver. It is not supported: kafka topics are emptied by retention.
+func (k *KafkaDriver) Flush(ctx context.Context, channel string) error {
+	return errors.Wrap(ErrNotSupportedByKafka, "flush")
+}
+
+// Info implements Driver. Only the number of reserved jobs is known.
+func (k *KafkaDriver) Info(ctx context.Context) (QueueInfo, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return QueueInfo{Reserved: int64(len(k.reserved))}, nil
+}
+
+// Promote consumes DelayTopic, and produces each delayed job to Topic once it is due. It blocks until the context is
+// canceled, so run it next to the consumers, for example in the run group. Running it in several instances of the
+// application is safe, as the instances share the partitions of DelayTopic.
+func (k *KafkaDriver) Promote(ctx context.Context) error {
+	if k.DelayReader == nil {
+		return errors.Wrap(ErrNotSupportedByKafka, "promoting without a DelayReader")
+	}
+	for {
+		msg, err := k.DelayReader.FetchMessage(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to fetch delayed message")
+		}
+		select {
+		case <-time.After(time.Until(kafkaDue(msg))):
+		case <-ctx.Done():
+			return nil
+		}
+		err = k.Writer.WriteMessages(ctx, kafka.Message{Topic: k.Topic, Key: msg.Key, Value: msg.Value})
+		if err != nil {
+			return errors.Wrap(err, "failed to promote delayed message")
+		}
+		if err := k.DelayReader.CommitMessages(ctx, msg); err != nil {
+			return errors.Wrap(err, "failed to commit delayed message")
+		}
+	}
+}
+
+// kafkaDue returns the time at which the delayed message becomes due. Messages without a valid header are due at once.
+func kafkaDue(msg kafka.Message) time.Time {
+	for _, header := range msg.Headers {
+		if header.Key != kafkaDueHeader {
+			continue
+		}
+		millis, err := strconv.ParseInt(string(header.Value), 10, 64)
+		if err != nil {
+			break
+		}
+		return time.Unix(0, millis*int64(time.Millisecond))
+	}
+	return time.Time{}
+}
+
+func (k *KafkaDriver) packer() Packer {
+	if k.Packer == nil {
+		return packer{}
+	}
+	return k.Packer
+}
+
+func (k *KafkaDriver) logger() log.Logger {
+	if k.Logger == nil {
+		return log.NewNopLogger()
+	}
+	return k.Logger
+}
@@ -0,0 +1,216 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKafka is an in-memory broker of single partition topics.
+type fakeKafka struct {
+	mu        sync.Mutex
+	topics    map[string][]kafka.Message
+	committed map[string]int64
+}
+
+func newFakeKafka() *fakeKafka {
+	return &fakeKafka{topics: make(map[string][]kafka.Message), committed: make(map[string]int64)}
+}
+
+func (f *fakeKafka) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, msg := range msgs {
+		msg.Offset = int64(len(f.topics[msg.Topic]))
+		f.topics[msg.Topic] = append(f.topics[msg.Topic], msg)
+	}
+	return nil
+}
+
+func (f *fakeKafka) messages(topic string) []kafka.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]kafka.Message(nil), f.topics[topic]...)
+}
+
+func (f *fakeKafka) committedOf(topic string) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.committed[topic]
+}
+
+func (f *fakeKafka) reader(topic string) *fakeReader {
+	return &fakeReader{broker: f, topic: topic}
+}
+
+type fakeReader struct {
+	broker *fakeKafka
+	topic  string
+	offset int
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		r.broker.mu.Lock()
+		if r.offset < len(r.broker.topics[r.topic]) {
+			msg := r.broker.topics[r.topic][r.offset]
+			r.offset++
+			r.broker.mu.Unlock()
+			return msg, nil
+		}
+		r.broker.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.broker.mu.Lock()
+	defer r.broker.mu.Unlock()
+	for _, msg := range msgs {
+		if msg.Offset+1 > r.broker.committed[msg.Topic] {
+			r.broker.committed[msg.Topic] = msg.Offset + 1
+		}
+	}
+	return nil
+}
+
+func newTestKafkaDriver(broker *fakeKafka) *KafkaDriver {
+	return &KafkaDriver{
+		Writer:      broker,
+		Reader:      broker.reader("jobs"),
+		DelayReader: broker.reader("jobs.delayed"),
+		Topic:       "jobs",
+		DelayTopic:  "jobs.delayed",
+		FailedTopic: "jobs.failed",
+		PopTimeout:  10 * time.Millisecond,
+	}
+}
+
+func TestKafkaDriver(t *testing.T) {
+	ctx := context.Background()
+	broker := newFakeKafka()
+	driver := newTestKafkaDriver(broker)
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver)
+
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "now"}))))
+	dispatched := time.Now()
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "later"}), Defer(20*time.Millisecond), Backoff(time.Millisecond, time.Millisecond, 1))))
+	assert.Len(t, broker.messages("jobs"), 1)
+	assert.Len(t, broker.messages("jobs.delayed"), 1)
+
+	msg, err := driver.Pop(ctx)
+	assert.NoError(t, err)
+	info, _ := driver.Info(ctx)
+	assert.Equal(t, int64(1), info.Reserved)
+	assert.NoError(t, driver.Ack(ctx, msg))
+	assert.Equal(t, int64(1), broker.committedOf("jobs"))
+	assert.True(t, errors.Is(driver.Ack(ctx, msg), ErrLeaseLost))
+
+	_, err = driver.Pop(ctx)
+	assert.True(t, errors.Is(err, ErrEmpty), err)
+
+	promoteCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() {
+		done <- driver.Promote(promoteCtx)
+	}()
+	var delayed *PersistedEvent
+	assert.Eventually(t, func() bool {
+		delayed, err = driver.Pop(ctx)
+		return err == nil
+	}, time.Second, time.Millisecond)
+	assert.GreaterOrEqual(t, int64(time.Since(dispatched)), int64(20*time.Millisecond))
+	assert.Eventually(t, func() bool {
+		return broker.committedOf("jobs.delayed") == 1
+	}, time.Second, time.Millisecond)
+
+	attempts := delayed.Attempts
+	assert.NoError(t, driver.Retry(ctx, delayed))
+	assert.Equal(t, attempts+1, delayed.Attempts)
+	retried := broker.messages("jobs.delayed")
+	assert.Len(t, retried, 2)
+	assert.False(t, kafkaDue(retried[1]).IsZero())
+
+	retry, err := func() (*PersistedEvent, error) {
+		for {
+			msg, err := driver.Pop(ctx)
+			if !errors.Is(err, ErrEmpty) {
+				return msg, err
+			}
+		}
+	}()
+	assert.NoError(t, err)
+	assert.NoError(t, driver.Fail(ctx, retry))
+	assert.Len(t, broker.messages("jobs.failed"), 1)
+	assert.Equal(t, int64(3), broker.committedOf("jobs"))
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	_, err = driver.Reload(ctx, "failed")
+	assert.True(t, errors.Is(err, ErrNotSupportedByKafka))
+	err = dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Unique("key", time.Minute)))
+	assert.True(t, errors.Is(err, ErrNotSupportedByKafka))
+}
+
+func TestKafkaDriver_consume(t *testing.T) {
+	broker := newFakeKafka()
+	dispatcher := WithQueue(&events.SyncDispatcher{}, newTestKafkaDriver(broker))
+	handled := make(chan string, 1)
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		handled <- event.Data().(MockEvent).Value
+		return nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Consume(ctx)
+
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "hello"}))))
+	select {
+	case value := <-handled:
+		assert.Equal(t, "hello", value)
+	case <-time.After(time.Second):
+		t.Fatal("job not handled")
+	}
+}
+
+func TestKafkaDriver_undecodable(t *testing.T) {
+	ctx := context.Background()
+	broker := newFakeKafka()
+	driver := newTestKafkaDriver(broker)
+
+	assert.NoError(t, broker.WriteMessages(ctx, kafka.Message{Topic: "jobs", Value: []byte("garbage")}))
+	_, err := driver.Pop(ctx)
+	assert.True(t, errors.Is(err, ErrEmpty), err)
+	failed := broker.messages("jobs.failed")
+	assert.Len(t, failed, 1)
+	assert.Equal(t, []byte("garbage"), failed[0].Value)
+	assert.Equal(t, int64(1), broker.committedOf("jobs"))
+}
+
+func TestKafkaDriver_Retry_withoutDelayReader(t *testing.T) {
+	ctx := context.Background()
+	broker := newFakeKafka()
+	driver := newTestKafkaDriver(broker)
+	driver.DelayReader = nil
+
+	assert.NoError(t, driver.Push(ctx, &PersistedEvent{Key: "foo"}, 0))
+	msg, err := driver.Pop(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, driver.Retry(ctx, msg))
+	assert.Len(t, broker.messages("jobs.failed"), 1)
+	assert.Equal(t, int64(1), broker.committedOf("jobs"))
+	info, _ := driver.Info(ctx)
+	assert.Zero(t, info.Reserved)
+}
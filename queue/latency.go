@@ -0,0 +1,26 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// UseLatencyHistogram is an option for WithQueue that observes the end-to-end latency of each job, in seconds, from
+// its dispatch to its completion. A job is complete when it is handled successfully or aborted. The latency includes
+// the time spent waiting in the queue, delays and retries, so the percentiles reveal backlogs that the queue length
+// alone does not.
+func UseLatencyHistogram(histogram metrics.Histogram) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.latencyHistogram = histogram
+	}
+}
+
+// observeLatency records the latency of the completed job. Jobs without enqueue time, such as those persisted by
+// older versions, are ignored.
+func (d *QueueableDispatcher) observeLatency(msg *PersistedEvent) {
+	if d.latencyHistogram == nil || msg.EnqueuedAt.IsZero() {
+		return
+	}
+	d.latencyHistogram.Observe(time.Since(msg.EnqueuedAt).Seconds())
+}
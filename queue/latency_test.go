@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingHistogram struct {
+	values []float64
+}
+
+func (r *recordingHistogram) With(labelValues ...string) metrics.Histogram { return r }
+
+func (r *recordingHistogram) Observe(value float64) {
+	r.values = append(r.values, value)
+}
+
+func TestDispatcher_UseLatencyHistogram(t *testing.T) {
+	histogram := &recordingHistogram{}
+	driver := NewInProcessDriver()
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseLogger(log.NewNopLogger()), UseLatencyHistogram(histogram))
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		if event.Data().(MockEvent).Value == "fail" {
+			return errors.New("failed")
+		}
+		return nil
+	}))
+
+	ctx := context.Background()
+	for _, value := range []string{"ok", "fail"} {
+		assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: value}))))
+	}
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		msg, err := driver.Pop(ctx)
+		assert.NoError(t, err)
+		dispatcher.work(ctx, msg)
+	}
+
+	// both the handled and the aborted jobs are complete.
+	assert.Len(t, histogram.values, 2)
+	for _, value := range histogram.values {
+		assert.True(t, value >= 0.01, "latency: %f", value)
+	}
+}
@@ -0,0 +1,48 @@
+package queue
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// LastActivity returns the last time the consumer made progress, that is, the last time it polled the driver or
+// finished a job. It returns the zero time if Consume has not been called. A consumer that is idle still polls the
+// driver regularly, so an old LastActivity means the consumer is stalled.
+func (d *QueueableDispatcher) LastActivity() time.Time {
+	nano := atomic.LoadInt64(&d.lastActivity)
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
+}
+
+func (d *QueueableDispatcher) heartbeat() {
+	atomic.StoreInt64(&d.lastActivity, time.Now().UnixNano())
+}
+
+// checkLiveness returns an error if the consumer has made no progress within the liveness window. It never fails if
+// the window is not set or the consumer is not running.
+func (d *QueueableDispatcher) checkLiveness() error {
+	if d.livenessWindow <= 0 {
+		return nil
+	}
+	last := d.LastActivity()
+	if last.IsZero() {
+		return nil
+	}
+	if idle := time.Since(last); idle > d.livenessWindow {
+		return fmt.Errorf("consumer stalled: no activity for %s", idle.Round(time.Second))
+	}
+	return nil
+}
+
+// UseLivenessWindow is an option for WithQueue that makes the health check of the queue fail if the consumer has
+// made no progress within the window, so that orchestrators can restart a stalled instance. The window must be longer
+// than the HandleTimeout of the slowest job, as a busy consumer doesn't poll the driver until a worker is free. By
+// default, the liveness is not checked. See LastActivity.
+func UseLivenessWindow(window time.Duration) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.livenessWindow = window
+	}
+}
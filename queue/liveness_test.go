@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_LastActivity(t *testing.T) {
+	dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriverWithPopInterval(time.Millisecond), UseLivenessWindow(time.Minute))
+	assert.True(t, dispatcher.LastActivity().IsZero())
+	assert.NoError(t, dispatcher.checkLiveness())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Consume(ctx)
+	assert.Eventually(t, func() bool {
+		return time.Since(dispatcher.LastActivity()) < 10*time.Millisecond
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, dispatcher.checkLiveness())
+}
+
+func TestDispatcher_checkLiveness(t *testing.T) {
+	cases := []struct {
+		name   string
+		window time.Duration
+		last   time.Time
+		stall  bool
+	}{
+		{"not consuming", time.Minute, time.Time{}, false},
+		{"active", time.Minute, time.Now(), false},
+		{"stalled", time.Minute, time.Now().Add(-time.Hour), true},
+		{"disabled", 0, time.Now().Add(-time.Hour), false},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriver(), UseLivenessWindow(c.window))
+			if !c.last.IsZero() {
+				dispatcher.lastActivity = c.last.UnixNano()
+			}
+			if c.stall {
+				assert.Error(t, dispatcher.checkLiveness())
+				return
+			}
+			assert.NoError(t, dispatcher.checkLiveness())
+		})
+	}
+}
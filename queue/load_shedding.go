@@ -0,0 +1,75 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+	"github.com/pkg/errors"
+)
+
+// ErrOverCapacity means the event is rejected at dispatch because the queue is over its high-water mark. See
+// UseLoadShedding.
+var ErrOverCapacity = errors.New("queue over capacity")
+
+// loadSheddingCheckInterval is how long the depth of the queue is cached between checks, so that load shedding
+// doesn't add a round trip to every dispatch.
+const loadSheddingCheckInterval = time.Second
+
+// LoadShedding configures the load shedding of a queue.
+type LoadShedding struct {
+	// HighWaterMark is the number of waiting jobs above which non-critical events are rejected.
+	HighWaterMark int64 `yaml:"highWaterMark" json:"highWaterMark"`
+	// CriticalPriority is the lowest Priority of the events that are never rejected. Zero, the default, is taken as
+	// 1, so that the events of the default Priority 0 are rejected. Set it explicitly to choose which events are
+	// critical.
+	CriticalPriority int `yaml:"criticalPriority" json:"criticalPriority"`
+}
+
+type loadShedder struct {
+	LoadShedding
+	mu        sync.Mutex
+	depth     int64
+	checkedAt time.Time
+}
+
+// UseLoadShedding is an option for WithQueue that rejects the events whose Priority is below the CriticalPriority
+// with ErrOverCapacity, while the number of waiting jobs exceeds the HighWaterMark. It protects the critical jobs
+// from the delays of an unbounded backlog. The number of waiting jobs is checked at most once a second, so the queue
+// may briefly overshoot the mark. If it cannot be checked, events are accepted.
+func UseLoadShedding(shedding LoadShedding) func(*QueueableDispatcher) {
+	if shedding.CriticalPriority == 0 {
+		shedding.CriticalPriority = 1
+	}
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.loadShedder = &loadShedder{LoadShedding: shedding}
+	}
+}
+
+// UseShedCounter is an option for WithQueue that counts the events rejected by load shedding. See UseLoadShedding.
+func UseShedCounter(counter metrics.Counter) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.shedCounter = counter
+	}
+}
+
+// shed reports whether the event should be rejected because the queue is over capacity.
+func (d *QueueableDispatcher) shed(ctx context.Context, msg *PersistedEvent) bool {
+	s := d.loadShedder
+	if s == nil || msg.Priority >= s.CriticalPriority {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.checkedAt) >= loadSheddingCheckInterval {
+		info, err := d.driver.Info(ctx)
+		if err != nil {
+			_ = level.Warn(d.logger).Log("err", errors.Wrap(err, "unable to check the queue depth for load shedding"))
+			return false
+		}
+		s.depth, s.checkedAt = info.Waiting, time.Now()
+	}
+	return s.depth > s.HighWaterMark
+}
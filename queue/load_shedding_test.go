@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_UseLoadShedding(t *testing.T) {
+	counter := generic.NewCounter("shed")
+	driver := NewInProcessDriver()
+	dispatcher := WithQueue(
+		&events.SyncDispatcher{},
+		driver,
+		UseLoadShedding(LoadShedding{HighWaterMark: 1, CriticalPriority: 10}),
+		UseShedCounter(counter),
+	)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}))))
+		dispatcher.loadShedder.checkedAt = time.Time{}
+	}
+	err := dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Priority(9)))
+	assert.True(t, errors.Is(err, ErrOverCapacity))
+	assert.Equal(t, 1.0, counter.Value())
+
+	// critical events always pass.
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Priority(10))))
+	info, _ := driver.Info(ctx)
+	assert.Equal(t, int64(3), info.Waiting)
+}
+
+func TestDispatcher_UseLoadShedding_defaultCriticalPriority(t *testing.T) {
+	dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriver(), UseLoadShedding(LoadShedding{HighWaterMark: 1}))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}))))
+		dispatcher.loadShedder.checkedAt = time.Time{}
+	}
+	err := dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{})))
+	assert.True(t, errors.Is(err, ErrOverCapacity))
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Priority(1))))
+}
@@ -0,0 +1,66 @@
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+// JobHandler handles a reserved job.
+type JobHandler func(ctx context.Context, msg *PersistedEvent) error
+
+// Middleware wraps the handling of the reserved jobs of a queue, for example to take a distributed lock or to enforce
+// idempotency. It is called with the context of the job, before the job is dispatched to the listeners.
+type Middleware func(next JobHandler) JobHandler
+
+// UseMiddleware is an option for WithQueue that wraps the handling of each reserved job with the middlewares. The
+// first middleware is the outermost one. Calling it again appends to the existing middlewares.
+func UseMiddleware(middlewares ...Middleware) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.middlewares = append(dispatcher.middlewares, middlewares...)
+	}
+}
+
+// Middlewares is a registry of middlewares for the queues created by Provide. Middlewares registered with Use apply
+// to every queue, and those registered with UseFor to the named queue only. Provide it to the core to install them.
+type Middlewares struct {
+	mu     sync.Mutex
+	global []Middleware
+	queues map[string][]Middleware
+}
+
+// NewMiddlewares creates an empty *Middlewares.
+func NewMiddlewares() *Middlewares {
+	return &Middlewares{queues: make(map[string][]Middleware)}
+}
+
+// Use registers middlewares for every queue.
+func (m *Middlewares) Use(middlewares ...Middleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.global = append(m.global, middlewares...)
+}
+
+// UseFor registers middlewares for the named queue only.
+func (m *Middlewares) UseFor(name string, middlewares ...Middleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queues[name] = append(m.queues[name], middlewares...)
+}
+
+// For returns the middlewares of the named queue, outermost first. The middlewares registered for every queue wrap
+// those of the named queue, and each group is in the order of registration.
+func (m *Middlewares) For(name string) []Middleware {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	middlewares := make([]Middleware, 0, len(m.global)+len(m.queues[name]))
+	middlewares = append(middlewares, m.global...)
+	return append(middlewares, m.queues[name]...)
+}
+
+// chain wraps the handler with the middlewares of the dispatcher.
+func (d *QueueableDispatcher) chain(handler JobHandler) JobHandler {
+	for i := len(d.middlewares) - 1; i >= 0; i-- {
+		handler = d.middlewares[i](handler)
+	}
+	return handler
+}
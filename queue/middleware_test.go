@@ -0,0 +1,63 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-kit/kit/log"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvideDispatcher_middlewares(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next JobHandler) JobHandler {
+			return func(ctx context.Context, msg *PersistedEvent) error {
+				calls = append(calls, name)
+				return next(ctx, msg)
+			}
+		}
+	}
+	middlewares := NewMiddlewares()
+	middlewares.UseFor("payments", record("idempotency"), record("lock"))
+	middlewares.Use(record("logging"))
+
+	out, err := Provide(DispatcherIn{
+		Conf: config.MapAdapter{"queue": map[string]configuration{
+			"default":  {Parallelism: 1},
+			"payments": {Parallelism: 1},
+		}},
+		Dispatcher:  &events.SyncDispatcher{},
+		RedisClient: redis.NewUniversalClient(&redis.UniversalOptions{}),
+		Logger:      log.NewNopLogger(),
+		AppName:     config.AppName("test"),
+		Env:         config.NewEnv("testing"),
+		Middlewares: middlewares,
+	})
+	assert.NoError(t, err)
+
+	cases := []struct {
+		queue    string
+		expected []string
+	}{
+		{"default", []string{"logging", "handler"}},
+		{"payments", []string{"logging", "idempotency", "lock", "handler"}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.queue, func(t *testing.T) {
+			calls = nil
+			dispatcher, err := out.DispatcherFactory.Make(c.queue)
+			assert.NoError(t, err)
+			err = dispatcher.chain(func(ctx context.Context, msg *PersistedEvent) error {
+				calls = append(calls, "handler")
+				return nil
+			})(context.Background(), &PersistedEvent{})
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, calls)
+		})
+	}
+}
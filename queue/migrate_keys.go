@@ -0,0 +1,109 @@
+package queue
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// migrateKey moves all jobs from KEYS[1] to KEYS[2] and returns the number of jobs moved. Lists keep their order,
+// behind the jobs already in the target. Sorted sets keep their scores. If ARGV[1] is "1", nothing is moved, and the
+// number of jobs that would be moved is returned.
+var migrateKey = redis.NewScript(`
+local kind = redis.call('TYPE', KEYS[1])['ok']
+if kind == 'none' then
+	return 0
+end
+local n
+if kind == 'list' then
+	n = redis.call('LLEN', KEYS[1])
+elseif kind == 'zset' then
+	n = redis.call('ZCARD', KEYS[1])
+else
+	return redis.error_reply('unexpected type ' .. kind .. ' of ' .. KEYS[1])
+end
+if ARGV[1] == '1' then
+	return n
+end
+if kind == 'list' then
+	for i = 1, n do
+		redis.call('RPOPLPUSH', KEYS[1], KEYS[2])
+	end
+else
+	redis.call('ZUNIONSTORE', KEYS[2], 2, KEYS[2], KEYS[1], 'AGGREGATE', 'MIN')
+	redis.call('DEL', KEYS[1])
+end
+return n
+`)
+
+// MigrateOption is an option of MigrateKeys.
+type MigrateOption func(*migration)
+
+type migration struct {
+	priorityLevels map[string]int
+}
+
+// MigratePriorityLevels moves the waiting lists of the PriorityLevels of the RedisDriver along with the waiting
+// channel. Pass the PriorityLevels of the driver that uses the keys, as the lists can't be found otherwise.
+func MigratePriorityLevels(levels map[string]int) MigrateOption {
+	return func(m *migration) {
+		m.priorityLevels = levels
+	}
+}
+
+// MigrateKeys moves the jobs of a redis queue from the keys of one ChannelConfig to those of another, for example
+// after the AppName or the Env is changed. NewChannelConfig returns the keys used by Provide:
+//
+//  moved, err := queue.MigrateKeys(ctx, client, queue.NewChannelConfig("old", "prod", "default"), queue.NewChannelConfig("new", "prod", "default"), false)
+//
+// Each channel is moved atomically with a script, and the jobs are appended to those already in the target. The
+// returned map holds the number of jobs moved per channel, keyed by "waiting", "delayed", "reserved", "timeout",
+// "failed" and "dead". With dryRun, nothing is moved and the map holds the number of jobs that would be moved. If the
+// queue uses PriorityLevels, pass them with MigratePriorityLevels. The jobs of their lists are counted as "waiting".
+//
+// Stop the consumers of both key sets before migrating. Reserved jobs are moved with their deadlines, and are
+// reloaded by the new consumers as usual once they time out. In redis cluster, the source and target keys of each
+// channel must hash to the same slot, which is not the case for the keys returned by NewChannelConfig.
+func MigrateKeys(ctx context.Context, client redis.UniversalClient, from, to ChannelConfig, dryRun bool, opts ...MigrateOption) (map[string]int64, error) {
+	var m migration
+	for _, f := range opts {
+		f(&m)
+	}
+	arg := "0"
+	if dryRun {
+		arg = "1"
+	}
+	type channel struct {
+		name     string
+		from, to string
+	}
+	var (
+		channels   []channel
+		targetKeys = (&RedisDriver{ChannelConfig: to, PriorityLevels: m.priorityLevels}).waitingKeys()
+	)
+	for i, key := range (&RedisDriver{ChannelConfig: from, PriorityLevels: m.priorityLevels}).waitingKeys() {
+		channels = append(channels, channel{"waiting", key, targetKeys[i]})
+	}
+	channels = append(channels, []channel{
+		{"delayed", from.Delayed, to.Delayed},
+		{"reserved", from.Reserved, to.Reserved},
+		{"timeout", from.Timeout, to.Timeout},
+		{"failed", from.Failed, to.Failed},
+		{"dead", from.Dead, to.Dead},
+	}...)
+
+	moved := make(map[string]int64)
+	for _, channel := range channels {
+		if channel.from == channel.to {
+			moved[channel.name] += 0
+			continue
+		}
+		n, err := migrateKey.Run(ctx, client, []string{channel.from, channel.to}, arg).Int64()
+		if err != nil {
+			return moved, errors.Wrapf(err, "failed to migrate the %s channel from %s to %s", channel.name, channel.from, channel.to)
+		}
+		moved[channel.name] += n
+	}
+	return moved, nil
+}
@@ -0,0 +1,73 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateKeys(t *testing.T) {
+	client := redis.NewUniversalClient(&redis.UniversalOptions{})
+	ctx := context.Background()
+	from := NewChannelConfig("old", "testing", "default")
+	to := NewChannelConfig("new", "testing", "default")
+	keys := []string{from.Waiting, from.Delayed, from.Reserved, from.Timeout, from.Failed, to.Waiting, to.Delayed, to.Reserved, to.Timeout, to.Failed}
+	client.Del(ctx, keys...)
+	defer client.Del(ctx, keys...)
+
+	old := WithQueue(&events.SyncDispatcher{}, &RedisDriver{RedisClient: client, ChannelConfig: from})
+	for _, value := range []string{"1", "2"} {
+		assert.NoError(t, old.Dispatch(ctx, Persist(events.Of(MockEvent{Value: value}))))
+	}
+	assert.NoError(t, old.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "3"}), Defer(time.Hour))))
+
+	moved, err := MigrateKeys(ctx, client, from, to, true)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"waiting": 2, "delayed": 1, "reserved": 0, "timeout": 0, "failed": 0, "dead": 0}, moved)
+	assert.Equal(t, int64(2), client.LLen(ctx, from.Waiting).Val())
+
+	moved, err = MigrateKeys(ctx, client, from, to, false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"waiting": 2, "delayed": 1, "reserved": 0, "timeout": 0, "failed": 0, "dead": 0}, moved)
+	assert.Zero(t, client.Exists(ctx, from.Waiting, from.Delayed).Val())
+	assert.Equal(t, int64(1), client.ZCard(ctx, to.Delayed).Val())
+
+	// the jobs are reserved from the new keys in their original order.
+	driver := &RedisDriver{RedisClient: client, ChannelConfig: to}
+	for _, value := range []string{"1", "2"} {
+		msg, err := driver.Pop(ctx)
+		assert.NoError(t, err)
+		assert.Contains(t, string(msg.Value), value)
+	}
+}
+
+func TestMigrateKeys_priorityLevels(t *testing.T) {
+	client := redis.NewUniversalClient(&redis.UniversalOptions{})
+	ctx := context.Background()
+	levels := map[string]int{"high": 10}
+	from := NewChannelConfig("old", "testing", "levels")
+	to := NewChannelConfig("new", "testing", "levels")
+	keys := []string{from.Waiting, from.Waiting + ":high", to.Waiting, to.Waiting + ":high", to.Reserved}
+	client.Del(ctx, keys...)
+	defer client.Del(ctx, keys...)
+
+	old := &RedisDriver{RedisClient: client, ChannelConfig: from, PriorityLevels: levels}
+	assert.NoError(t, old.Push(ctx, &PersistedEvent{UniqueId: "normal"}, 0))
+	assert.NoError(t, old.Push(ctx, &PersistedEvent{UniqueId: "urgent", Priority: 20}, 0))
+
+	moved, err := MigrateKeys(ctx, client, from, to, false, MigratePriorityLevels(levels))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), moved["waiting"])
+	assert.Zero(t, client.Exists(ctx, from.Waiting, from.Waiting+":high").Val())
+
+	driver := &RedisDriver{RedisClient: client, ChannelConfig: to, PriorityLevels: levels}
+	for _, id := range []string{"urgent", "normal"} {
+		msg, err := driver.Pop(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, id, msg.UniqueId)
+	}
+}
@@ -0,0 +1,316 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// MirrorPolicy decides how a failed push to a mirror affects the overall push.
+type MirrorPolicy int
+
+const (
+	// BestEffort mirrors only log their failures. The push is still considered successful.
+	BestEffort MirrorPolicy = iota
+	// MustSucceed mirrors fail the push if the message cannot be written to them.
+	MustSucceed
+)
+
+// Mirror is a secondary Driver that receives a copy of every pushed message.
+type Mirror struct {
+	Driver Driver
+	Policy MirrorPolicy
+}
+
+// MirrorDriver is a Driver that fans out pushes to a primary driver and
+// several mirrors. It is designed for dual-writes during a migration from one
+// backend to another. It composes with WithQueue like any other driver:
+//
+//  driver := &queue.MirrorDriver{
+//    Primary: redisDriver,
+//    Mirrors: []queue.Mirror{{Driver: newDriver, Policy: queue.BestEffort}},
+//  }
+//  dispatcher := queue.WithQueue(&events.SyncDispatcher{}, driver)
+//
+// Messages are pushed to the primary first, and then to the mirrors one by
+// one in the order they are listed. If the primary fails, the mirrors are not
+// written. If a MustSucceed mirror fails, the error is returned, but the
+// message already written to the primary and the preceding mirrors is not
+// rolled back. Dual-writes are therefore not atomic: the caller may retry the
+// push and produce duplicates, which is acceptable under the at-least-once
+// guarantee of the queue.
+//
+// All other operations, including Pop, Ack and Retry, are served by the
+// primary alone. The mirrors are write-only from the perspective of this
+// driver. They should be consumed, if desired, by a separate dispatcher.
+//
+// The optional capabilities of the primary, such as DelayedCanceller,
+// PositionFinder, ProgressStore, FollowUpPusher, Releaser and
+// CompletionNotifier, are forwarded to it, as well as Export, Import and the
+// drain file of UseDrainFile. The capabilities the primary lacks return an
+// error, or fall back to the base Driver methods like the dispatcher does.
+// Follow-up jobs and batches are mirrored like any other push.
+type MirrorDriver struct {
+	Primary Driver
+	Mirrors []Mirror
+	// Logger logs failures of BestEffort mirrors. By default a noop logger is used.
+	Logger log.Logger
+}
+
+// Push pushes the message onto the primary and then the mirrors.
+func (m *MirrorDriver) Push(ctx context.Context, message *PersistedEvent, delay time.Duration) error {
+	if err := m.Primary.Push(ctx, message, delay); err != nil {
+		return err
+	}
+	return m.mirror(ctx, message, delay)
+}
+
+// mirror pushes a copy of the message onto the mirrors.
+func (m *MirrorDriver) mirror(ctx context.Context, message *PersistedEvent, delay time.Duration) error {
+	for i, mirror := range m.Mirrors {
+		copied := *message
+		err := mirror.Driver.Push(ctx, &copied, delay)
+		if err == nil {
+			continue
+		}
+		err = errors.Wrapf(err, "failed to push event %s to mirror #%d", message.Key, i)
+		if mirror.Policy == MustSucceed {
+			return err
+		}
+		_ = level.Warn(m.logger()).Log("err", err)
+	}
+	return nil
+}
+
+// Pop pops the message out of the primary.
+func (m *MirrorDriver) Pop(ctx context.Context) (*PersistedEvent, error) {
+	return m.Primary.Pop(ctx)
+}
+
+// Ack acknowledges the message on the primary.
+func (m *MirrorDriver) Ack(ctx context.Context, message *PersistedEvent) error {
+	return m.Primary.Ack(ctx, message)
+}
+
+// Fail marks the message as failed on the primary.
+func (m *MirrorDriver) Fail(ctx context.Context, message *PersistedEvent) error {
+	return m.Primary.Fail(ctx, message)
+}
+
+// Reload reloads the channel on the primary.
+func (m *MirrorDriver) Reload(ctx context.Context, channel string) (int64, error) {
+	return m.Primary.Reload(ctx, channel)
+}
+
+// Flush flushes the channel on the primary.
+func (m *MirrorDriver) Flush(ctx context.Context, channel string) error {
+	return m.Primary.Flush(ctx, channel)
+}
+
+// Info returns the QueueInfo of the primary.
+func (m *MirrorDriver) Info(ctx context.Context) (QueueInfo, error) {
+	return m.Primary.Info(ctx)
+}
+
+// Retry retries the message on the primary.
+func (m *MirrorDriver) Retry(ctx context.Context, message *PersistedEvent) error {
+	return m.Primary.Retry(ctx, message)
+}
+
+// CancelDelayed implements DelayedCanceller. The delayed jobs are cancelled on the primary only.
+func (m *MirrorDriver) CancelDelayed(ctx context.Context, match func(*PersistedEvent) bool) (int64, error) {
+	canceller, ok := m.Primary.(DelayedCanceller)
+	if !ok {
+		return 0, fmt.Errorf("driver %T doesn't support cancelling delayed jobs", m.Primary)
+	}
+	return canceller.CancelDelayed(ctx, match)
+}
+
+// Position implements PositionFinder.
+func (m *MirrorDriver) Position(ctx context.Context, uniqueId string, limit int64) (int64, error) {
+	finder, ok := m.Primary.(PositionFinder)
+	if !ok {
+		return 0, fmt.Errorf("driver %T doesn't support finding the position of jobs", m.Primary)
+	}
+	return finder.Position(ctx, uniqueId, limit)
+}
+
+// SetProgress implements ProgressStore. The progress is dropped if the primary doesn't implement ProgressStore.
+func (m *MirrorDriver) SetProgress(ctx context.Context, uniqueId string, progress Progress, ttl time.Duration) error {
+	store, ok := m.Primary.(ProgressStore)
+	if !ok {
+		return nil
+	}
+	return store.SetProgress(ctx, uniqueId, progress, ttl)
+}
+
+// Progress implements ProgressStore.
+func (m *MirrorDriver) Progress(ctx context.Context, uniqueId string) (*Progress, error) {
+	store, ok := m.Primary.(ProgressStore)
+	if !ok {
+		return nil, fmt.Errorf("driver %T doesn't support the progress of jobs", m.Primary)
+	}
+	return store.Progress(ctx, uniqueId)
+}
+
+// ClearProgress implements ProgressStore.
+func (m *MirrorDriver) ClearProgress(ctx context.Context, uniqueId string) error {
+	store, ok := m.Primary.(ProgressStore)
+	if !ok {
+		return nil
+	}
+	return store.ClearProgress(ctx, uniqueId)
+}
+
+// AckWithFollowUps implements FollowUpPusher. The follow-ups are pushed atomically with the ack on the primary if it
+// implements FollowUpPusher, and after the ack otherwise. They are mirrored once the primary has them, and the
+// failures of the mirrors are only logged, as the ack can't be undone.
+func (m *MirrorDriver) AckWithFollowUps(ctx context.Context, message *PersistedEvent, followUps []FollowUpJob) error {
+	if pusher, ok := m.Primary.(FollowUpPusher); ok {
+		if err := pusher.AckWithFollowUps(ctx, message, followUps); err != nil {
+			return err
+		}
+	} else {
+		if err := m.Primary.Ack(ctx, message); err != nil {
+			return err
+		}
+		pushed := followUps[:0:0]
+		for _, job := range followUps {
+			if err := m.Primary.Push(ctx, job.Msg, job.Delay); err != nil {
+				_ = level.Warn(m.logger()).Log("err", errors.Wrapf(err, "follow-up %s of event %s lost", job.Msg.Key, message.Key))
+				continue
+			}
+			pushed = append(pushed, job)
+		}
+		followUps = pushed
+	}
+	for _, job := range followUps {
+		if err := m.mirror(ctx, job.Msg, job.Delay); err != nil {
+			_ = level.Warn(m.logger()).Log("err", err)
+		}
+	}
+	return nil
+}
+
+// Release implements Releaser. The released job stays on the primary, and is not mirrored again. If the primary
+// doesn't implement Releaser, a copy of the job is pushed before acking it, like the dispatcher does.
+func (m *MirrorDriver) Release(ctx context.Context, message *PersistedEvent, delay time.Duration) error {
+	if releaser, ok := m.Primary.(Releaser); ok {
+		return releaser.Release(ctx, message, delay)
+	}
+	if err := m.Primary.Push(ctx, unreserved(message), delay); err != nil {
+		return err
+	}
+	return m.Primary.Ack(ctx, message)
+}
+
+// PushBatch implements BatchPusher. The messages are pushed onto the primary in a single round trip if it implements
+// BatchPusher, and one by one otherwise. Those the primary accepted are then mirrored like any other push.
+func (m *MirrorDriver) PushBatch(ctx context.Context, messages []*PersistedEvent, delays []time.Duration) error {
+	var batchErr BatchError
+	if pusher, ok := m.Primary.(BatchPusher); ok {
+		err := pusher.PushBatch(ctx, messages, delays)
+		var pushErr *BatchError
+		switch {
+		case errors.As(err, &pushErr):
+			batchErr.Errors = pushErr.Errors
+		case err != nil:
+			return err
+		}
+	} else {
+		for i, message := range messages {
+			if err := m.Primary.Push(ctx, message, delays[i]); err != nil {
+				batchErr.add(i, err)
+			}
+		}
+	}
+	for i, message := range messages {
+		if _, failed := batchErr.Errors[i]; failed {
+			continue
+		}
+		if err := m.mirror(ctx, message, delays[i]); err != nil {
+			batchErr.add(i, err)
+		}
+	}
+	if len(batchErr.Errors) > 0 {
+		return &batchErr
+	}
+	return nil
+}
+
+// NotifyCompletion implements CompletionNotifier by reporting the outcome to the primary.
+func (m *MirrorDriver) NotifyCompletion(ctx context.Context, uniqueId string, jobErr error) error {
+	notifier, ok := completionNotifier(m.Primary)
+	if !ok {
+		return fmt.Errorf("driver %T doesn't support notifying the completion of jobs", m.Primary)
+	}
+	return notifier.NotifyCompletion(ctx, uniqueId, jobErr)
+}
+
+// AwaitCompletion implements CompletionNotifier by awaiting the outcome on the primary.
+func (m *MirrorDriver) AwaitCompletion(ctx context.Context, uniqueId string) (error, error) {
+	notifier, ok := completionNotifier(m.Primary)
+	if !ok {
+		return nil, fmt.Errorf("driver %T doesn't support awaiting the completion of jobs", m.Primary)
+	}
+	return notifier.AwaitCompletion(ctx, uniqueId)
+}
+
+// Drain saves the jobs of the primary to the file, if it supports UseDrainFile.
+func (m *MirrorDriver) Drain(ctx context.Context, path string) error {
+	driver, ok := m.Primary.(drainer)
+	if !ok {
+		return nil
+	}
+	return driver.Drain(ctx, path)
+}
+
+// Restore loads the jobs saved by Drain into the primary, if it supports UseDrainFile.
+func (m *MirrorDriver) Restore(ctx context.Context, path string) error {
+	driver, ok := m.Primary.(drainer)
+	if !ok {
+		return nil
+	}
+	return driver.Restore(ctx, path)
+}
+
+// Export writes the jobs of the primary to w, as RedisDriver.Export does.
+func (m *MirrorDriver) Export(ctx context.Context, w io.Writer) error {
+	exporter, ok := m.Primary.(interface {
+		Export(ctx context.Context, w io.Writer) error
+	})
+	if !ok {
+		return fmt.Errorf("driver %T doesn't support exporting jobs", m.Primary)
+	}
+	return exporter.Export(ctx, w)
+}
+
+// Import reads the jobs written by Export into the primary, as RedisDriver.Import does. The jobs are not mirrored.
+func (m *MirrorDriver) Import(ctx context.Context, reader io.Reader) error {
+	importer, ok := m.Primary.(interface {
+		Import(ctx context.Context, reader io.Reader) error
+	})
+	if !ok {
+		return fmt.Errorf("driver %T doesn't support importing jobs", m.Primary)
+	}
+	return importer.Import(ctx, reader)
+}
+
+// onBury implements buryNotifier for the jobs moved to the dead channel of the primary.
+func (m *MirrorDriver) onBury(hook func(message *PersistedEvent)) {
+	if notifier, ok := m.Primary.(buryNotifier); ok {
+		notifier.onBury(hook)
+	}
+}
+
+func (m *MirrorDriver) logger() log.Logger {
+	if m.Logger == nil {
+		return log.NewNopLogger()
+	}
+	return m.Logger
+}
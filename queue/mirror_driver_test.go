@@ -0,0 +1,155 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+type brokenDriver struct {
+	*InProcessDriver
+}
+
+func (b brokenDriver) Push(ctx context.Context, message *PersistedEvent, delay time.Duration) error {
+	return errors.New("broken")
+}
+
+func TestMirrorDriver_Push(t *testing.T) {
+	cases := []struct {
+		name        string
+		primary     Driver
+		mirrors     []Mirror
+		expectErr   bool
+		expectWrite []bool
+	}{
+		{
+			"all succeed",
+			NewInProcessDriver(),
+			[]Mirror{{Driver: NewInProcessDriver()}, {Driver: NewInProcessDriver(), Policy: MustSucceed}},
+			false,
+			[]bool{true, true},
+		},
+		{
+			"best effort mirror fails",
+			NewInProcessDriver(),
+			[]Mirror{{Driver: brokenDriver{NewInProcessDriver()}}, {Driver: NewInProcessDriver()}},
+			false,
+			[]bool{false, true},
+		},
+		{
+			"must succeed mirror fails",
+			NewInProcessDriver(),
+			[]Mirror{{Driver: brokenDriver{NewInProcessDriver()}, Policy: MustSucceed}, {Driver: NewInProcessDriver()}},
+			true,
+			[]bool{false, false},
+		},
+		{
+			"primary fails",
+			brokenDriver{NewInProcessDriver()},
+			[]Mirror{{Driver: NewInProcessDriver(), Policy: MustSucceed}},
+			true,
+			[]bool{false},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			driver := &MirrorDriver{Primary: c.primary, Mirrors: c.mirrors}
+			dispatcher := WithQueue(&events.SyncDispatcher{}, driver)
+			err := dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{Value: "hello"})))
+			if c.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			for i, mirror := range c.mirrors {
+				info, _ := mirror.Driver.Info(context.Background())
+				assert.Equal(t, c.expectWrite[i], info.Waiting == 1, "mirror #%d", i)
+			}
+		})
+	}
+}
+
+// plainDriver hides the optional capabilities of the driver it wraps.
+type plainDriver struct {
+	Driver
+}
+
+func TestMirrorDriver_forwarding(t *testing.T) {
+	ctx := context.Background()
+	primary := NewInProcessDriver()
+	mirror := NewInProcessDriver()
+	dispatcher := WithQueue(&events.SyncDispatcher{}, &MirrorDriver{Primary: primary, Mirrors: []Mirror{{Driver: mirror}}})
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		if event.Data().(MockEvent).Value != "A" {
+			return nil
+		}
+		return FollowUp(ctx, Persist(events.Of(MockEvent{Value: "B"}), Defer(time.Hour)))
+	}))
+
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "A"}))))
+	msg, err := dispatcher.driver.Pop(ctx)
+	assert.NoError(t, err)
+	dispatcher.work(ctx, msg)
+
+	info, _ := primary.Info(ctx)
+	assert.Equal(t, QueueInfo{Delayed: 1}, info)
+	info, _ = mirror.Info(ctx)
+	assert.Equal(t, QueueInfo{Waiting: 1, Delayed: 1}, info)
+
+	cancelled, err := dispatcher.CancelDelayed(ctx, func(*PersistedEvent) bool { return true })
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), cancelled)
+	info, _ = mirror.Info(ctx)
+	assert.Equal(t, int64(1), info.Delayed, "mirrors are not cancelled")
+
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "C"}))))
+	msg, err = dispatcher.driver.Pop(ctx)
+	assert.NoError(t, err)
+	dispatcher.deferJob(msg, 0)
+	info, _ = primary.Info(ctx)
+	assert.Equal(t, QueueInfo{Waiting: 1}, info)
+}
+
+func TestMirrorDriver_redis(t *testing.T) {
+	ctx := context.Background()
+	primary := setUpReleaseDriver(t)
+	dispatcher := WithQueue(&events.SyncDispatcher{}, &MirrorDriver{Primary: primary})
+
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), UniqueId("foo"))))
+	position, err := dispatcher.Position(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), position)
+
+	assert.NoError(t, dispatcher.driver.(ProgressStore).SetProgress(ctx, "foo", Progress{Percent: 50}, time.Minute))
+	progress, err := dispatcher.Progress(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 50, progress.Percent)
+	assert.NoError(t, dispatcher.driver.(ProgressStore).ClearProgress(ctx, "foo"))
+
+	var buf bytes.Buffer
+	assert.NoError(t, dispatcher.driver.(*MirrorDriver).Export(ctx, &buf))
+	assert.Contains(t, buf.String(), "waiting")
+}
+
+func TestMirrorDriver_unsupported(t *testing.T) {
+	ctx := context.Background()
+	driver := &MirrorDriver{Primary: plainDriver{NewInProcessDriver()}}
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver)
+
+	_, err := dispatcher.CancelDelayed(ctx, func(*PersistedEvent) bool { return true })
+	assert.Error(t, err)
+	_, err = dispatcher.Position(ctx, "foo")
+	assert.Error(t, err)
+	_, err = dispatcher.Progress(ctx, "foo")
+	assert.Error(t, err)
+	assert.Error(t, driver.Export(ctx, &bytes.Buffer{}))
+	assert.NoError(t, driver.SetProgress(ctx, "foo", Progress{}, time.Minute))
+	assert.NoError(t, driver.Drain(ctx, "foo"))
+}
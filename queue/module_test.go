@@ -27,7 +27,8 @@ func TestModule_ProvideCommand(t *testing.T) {
 		{
 			"flush",
 			[]string{"queue", "flush"},
-			QueueInfo{},
+			// the job reloaded by the previous case is still reserved.
+			QueueInfo{Reserved: 1},
 		},
 	}
 	for _, c := range cases {
@@ -3,9 +3,18 @@ package queue
 import (
 	"bytes"
 	"encoding/gob"
+	"encoding/json"
 	"reflect"
 )
 
+// packer is the default Packer. It serializes messages with encoding/gob.
+//
+// A time.Time is encoded with its instant and zone offset, so it is decoded to
+// the same instant in the same offset, regardless of the local zone of the
+// consumer. The monotonic clock reading is stripped and the location is decoded
+// as a fixed zone, so compare times with Equal rather than ==. Decimal types
+// keep their precision as long as they implement gob.GobEncoder or
+// encoding.BinaryMarshaler, as most decimal libraries do.
 type packer struct {
 }
 
@@ -26,3 +35,32 @@ func (p packer) Decompress(data []byte, message interface{}) error {
 	}
 	return gob.NewDecoder(buf).Decode(message)
 }
+
+// JSONPacker is a Packer that serializes messages with encoding/json. It is
+// useful when the payloads are shared with consumers or producers in other
+// languages.
+//
+// A time.Time is encoded in RFC3339Nano with its zone offset. Numbers are
+// decoded with json.Number in untyped fields, so that large integers and
+// decimals don't lose precision through float64. Decimal types keep their
+// precision as long as they implement json.Marshaler or encoding.TextMarshaler.
+//
+// To use it, pass it to both the dispatcher and the driver:
+//
+//  dispatcher := queue.WithQueue(base, &queue.RedisDriver{Packer: queue.JSONPacker{}}, queue.UsePacker(queue.JSONPacker{}))
+type JSONPacker struct{}
+
+// Compress serializes the message to bytes
+func (j JSONPacker) Compress(message interface{}) ([]byte, error) {
+	return json.Marshal(message)
+}
+
+// Decompress reverses the bytes to message
+func (j JSONPacker) Decompress(data []byte, message interface{}) error {
+	if rvalue, ok := message.(reflect.Value); ok {
+		message = rvalue.Interface()
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(message)
+}
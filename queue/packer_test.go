@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type timedPayload struct {
+	At     time.Time
+	Amount *big.Rat
+	Extra  interface{}
+}
+
+func TestPacker(t *testing.T) {
+	zone := time.FixedZone("UTC+8", 8*60*60)
+	at := time.Date(2021, 3, 4, 5, 6, 7, 123456789, zone)
+	amount, _ := new(big.Rat).SetString("12345678901234567890.0123456789")
+
+	cases := []struct {
+		name   string
+		packer Packer
+	}{
+		{"gob", packer{}},
+		{"json", JSONPacker{}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			data, err := c.packer.Compress(timedPayload{At: at, Amount: amount})
+			assert.NoError(t, err)
+
+			ptr := reflect.New(reflect.TypeOf(timedPayload{}))
+			assert.NoError(t, c.packer.Decompress(data, ptr))
+			decoded := ptr.Elem().Interface().(timedPayload)
+			assert.True(t, at.Equal(decoded.At))
+			_, offset := decoded.At.Zone()
+			assert.Equal(t, 8*60*60, offset)
+			assert.Equal(t, 0, amount.Cmp(decoded.Amount))
+		})
+	}
+}
+
+func TestJSONPacker_number(t *testing.T) {
+	var decoded timedPayload
+	assert.NoError(t, JSONPacker{}.Decompress([]byte(`{"Extra":9007199254740993}`), &decoded))
+	assert.Equal(t, json.Number("9007199254740993"), decoded.Extra)
+}
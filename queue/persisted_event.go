@@ -1,6 +1,11 @@
 package queue
 
-import "time"
+import (
+	"time"
+
+	"github.com/DoNewsCode/core/backoff"
+	"github.com/DoNewsCode/core/events"
+)
 
 // PersistedEvent represents a persisted event.
 type PersistedEvent struct {
@@ -12,16 +17,52 @@ type PersistedEvent struct {
 	// Value is the serialized bytes of the event.
 	Value []byte
 	// HandleTimeout sets the upper time limit for each run of the handler. If handleTimeout exceeds, the event will
-	// be put onto the timeout queue. Note: the timeout is shared among all listeners.
+	// be put onto the timeout queue. Note: the timeout is shared among all listeners. If it is zero, the handle timeout
+	// of the queue is set at dispatch, and DefaultHandleTimeout at reservation.
 	HandleTimeout time.Duration
 	// Backoff sets the duration before next retry.
 	Backoff time.Duration
+	// RetryBackoff computes the Backoff of each retry. If it is nil, the default backoff is used. See the Backoff
+	// option.
+	RetryBackoff *backoff.Exponential
 	// Attempts denotes how many retry has been attempted. It starts from 1.
 	Attempts int
 	// MaxAttempts denotes the maximum number of time the handler can retry before the event is put onto
 	// the failed queue.
 	// By default, MaxAttempts is 1.
 	MaxAttempts int
+	// Deadline is the absolute time after which the event should no longer be handled. Expired events are rejected
+	// at dispatch and skipped at reservation. Zero means no deadline.
+	Deadline time.Time
+	// RoutingKey routes the event to the RoutedHandler registered under the same key, regardless of Key. See
+	// QueueableDispatcher.Route.
+	RoutingKey string
+	// Metadata is the metadata of the event, if it is created by events.New. It is restored on the event delivered
+	// to the listeners.
+	Metadata *events.Metadata
+	// Tenant is the tenant the event belongs to. It is used to apply per tenant rate limits.
+	Tenant string
+	// Priority orders the waiting events if the driver is configured to, as with RedisDriver.PriorityOrder, so that
+	// events with higher priority are reserved first. It also selects the list of the event among the
+	// RedisDriver.PriorityLevels, and exempts it from load shedding from the LoadShedding.CriticalPriority up.
+	Priority int
+	// EnqueuedAt is the time the event was dispatched. It is kept across retries, and is used to measure the end-to-end
+	// latency. See UseLatencyHistogram.
+	EnqueuedAt time.Time
+	// Reservations counts the times the event has been reserved by the driver. Unlike Attempts, it also counts the
+	// reservations that timed out, and those after the event was reloaded. See RedisDriver.MaxAttempts.
+	Reservations int
+	// LeaseToken identifies the reservation of the event. It is set by drivers fencing reservations each time the
+	// event is popped, and is checked when the event is acked, failed or retried. See ErrLeaseLost.
+	LeaseToken string
+	// Awaited means the producer blocks until the event is handled. See QueueableDispatcher.DispatchSync.
+	Awaited bool
+	// UniqueKey suppresses duplicates of the event while it is guarded. See the Unique option.
+	UniqueKey string
+	// UniqueTTL is the time after which the guard of UniqueKey expires.
+	UniqueTTL time.Duration
+	// UniqueRelease is when the guard of UniqueKey is released.
+	UniqueRelease UniqueRelease
 }
 
 // Type implements contract.event. It returns the Key.
@@ -33,3 +74,25 @@ func (s *PersistedEvent) Type() string {
 func (s *PersistedEvent) Data() interface{} {
 	return s.Value
 }
+
+// DefaultHandleTimeout is the handle timeout of jobs, unless set by the Timeout option or UseHandleTimeout.
+const DefaultHandleTimeout = time.Hour
+
+// reserve fills in the default HandleTimeout of jobs dispatched without one, for example by other producers.
+func (s *PersistedEvent) reserve() {
+	if s.HandleTimeout <= 0 {
+		s.HandleTimeout = DefaultHandleTimeout
+	}
+}
+
+// retryDelay returns the backoff before the retry following the current attempt.
+func (s *PersistedEvent) retryDelay() time.Duration {
+	if s.RetryBackoff != nil {
+		return s.RetryBackoff.Duration(s.Attempts)
+	}
+	return retryStrategy.Duration(s.Attempts)
+}
+
+func (s *PersistedEvent) expired(at time.Time) bool {
+	return !s.Deadline.IsZero() && !at.Before(s.Deadline)
+}
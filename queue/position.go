@@ -0,0 +1,63 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultPositionScanLimit is the default maximum number of waiting jobs scanned by Position.
+const DefaultPositionScanLimit = 10000
+
+// PositionFinder is implemented by drivers that can locate a job in the waiting queue.
+type PositionFinder interface {
+	// Position returns the position of the job with the unique ID in the waiting queue, starting from 1 for the job
+	// to be reserved next. It scans at most limit jobs, and returns -1 if the job is not found within them.
+	Position(ctx context.Context, uniqueId string, limit int64) (int64, error)
+}
+
+// Position estimates the position of the job with the unique ID in the waiting queue, starting from 1 for the job to
+// be reserved next, for example to tell a user "your job is #42 in line". It returns -1 if the job is not waiting,
+// for example because it is delayed, already reserved, or not found within the first DefaultPositionScanLimit jobs.
+// The unique ID is set by the UniqueId option, or generated by Persist.
+//
+// The waiting queue is scanned from its head and each job is deserialized, so the cost is linear in the position.
+// The position is an estimation, as other consumers may reserve jobs in the meantime. An error is returned if the
+// driver doesn't implement PositionFinder.
+func (d *QueueableDispatcher) Position(ctx context.Context, uniqueId string) (int64, error) {
+	finder, ok := d.driver.(PositionFinder)
+	if !ok {
+		return 0, fmt.Errorf("driver %T doesn't support finding the position of jobs", d.driver)
+	}
+	return finder.Position(ctx, uniqueId, DefaultPositionScanLimit)
+}
+
+// Position implements PositionFinder. The waiting queue is scanned in the order of reservation, 100 jobs at a time.
+func (r *RedisDriver) Position(ctx context.Context, uniqueId string, limit int64) (int64, error) {
+	r.populateDefaults()
+	const page = 100
+	for scanned := int64(0); scanned < limit; scanned += page {
+		end := scanned + page
+		if end > limit {
+			end = limit
+		}
+		jobs, err := r.rangeWaiting(ctx, scanned, end-1)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to range the waiting queue while finding the position")
+		}
+		for i, job := range jobs {
+			var message PersistedEvent
+			if err := r.Packer.Decompress([]byte(job), &message); err != nil {
+				return 0, errors.Wrap(err, "failed to decompress message")
+			}
+			if message.UniqueId == uniqueId {
+				return scanned + int64(i) + 1, nil
+			}
+		}
+		if int64(len(jobs)) < end-scanned {
+			break
+		}
+	}
+	return -1, nil
+}
@@ -0,0 +1,56 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_Position(t *testing.T) {
+	dispatcher := setUp()
+	ctx := context.Background()
+	for i := 1; i <= 250; i++ {
+		assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), UniqueId(fmt.Sprintf("job-%d", i)))))
+	}
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), UniqueId("delayed"), Defer(time.Hour))))
+
+	for _, c := range []struct {
+		id       string
+		position int64
+	}{
+		{"job-1", 1},
+		{"job-100", 100},
+		{"job-101", 101},
+		{"job-250", 250},
+		{"delayed", -1},
+		{"unknown", -1},
+	} {
+		position, err := dispatcher.Position(ctx, c.id)
+		assert.NoError(t, err)
+		assert.Equal(t, c.position, position, c.id)
+	}
+
+	driver := dispatcher.driver.(*RedisDriver)
+	position, err := driver.Position(ctx, "job-150", 120)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), position)
+
+	_, err = driver.Pop(ctx)
+	assert.NoError(t, err)
+	position, err = dispatcher.Position(ctx, "job-1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), position)
+	position, err = dispatcher.Position(ctx, "job-2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), position)
+}
+
+func TestDispatcher_Position_unsupported(t *testing.T) {
+	dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriver())
+	_, err := dispatcher.Position(context.Background(), "foo")
+	assert.Error(t, err)
+}
@@ -0,0 +1,115 @@
+package queue
+
+import (
+	"context"
+	"sort"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// priorityLevel is a level of RedisDriver.PriorityLevels.
+type priorityLevel struct {
+	name string
+	min  int
+}
+
+// leveled reports whether the waiting jobs are split into the lists of PriorityLevels.
+func (r *RedisDriver) leveled() bool {
+	return !r.PriorityOrder && len(r.PriorityLevels) > 0
+}
+
+// levels returns the PriorityLevels, highest first.
+func (r *RedisDriver) levels() []priorityLevel {
+	levels := make([]priorityLevel, 0, len(r.PriorityLevels))
+	for name, min := range r.PriorityLevels {
+		levels = append(levels, priorityLevel{name: name, min: min})
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if levels[i].min != levels[j].min {
+			return levels[i].min > levels[j].min
+		}
+		return levels[i].name < levels[j].name
+	})
+	return levels
+}
+
+// levelKey returns the waiting list of the level. It shares the hash tag of the waiting queue.
+func (r *RedisDriver) levelKey(name string) string {
+	return r.ChannelConfig.Waiting + ":" + name
+}
+
+// waitingKeys returns the waiting lists in the order they are polled.
+func (r *RedisDriver) waitingKeys() []string {
+	if !r.leveled() {
+		return []string{r.ChannelConfig.Waiting}
+	}
+	keys := make([]string, 0, len(r.PriorityLevels)+1)
+	for _, level := range r.levels() {
+		keys = append(keys, r.levelKey(level.name))
+	}
+	return append(keys, r.ChannelConfig.Waiting)
+}
+
+// waitingKeyOf returns the waiting list of the message, which is that of the highest level it reaches.
+func (r *RedisDriver) waitingKeyOf(message *PersistedEvent) string {
+	if !r.leveled() {
+		return r.ChannelConfig.Waiting
+	}
+	for _, level := range r.levels() {
+		if message.Priority >= level.min {
+			return r.levelKey(level.name)
+		}
+	}
+	return r.ChannelConfig.Waiting
+}
+
+// levelsLen counts the jobs on all the waiting lists.
+func (r *RedisDriver) levelsLen(ctx context.Context) *redis.IntCmd {
+	p := r.RedisClient.Pipeline()
+	var cmds []*redis.IntCmd
+	for _, key := range r.waitingKeys() {
+		cmds = append(cmds, p.LLen(ctx, key))
+	}
+	if _, err := p.Exec(ctx); err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	var total int64
+	for _, cmd := range cmds {
+		total += cmd.Val()
+	}
+	return redis.NewIntResult(total, nil)
+}
+
+// rangeLevels returns the waiting jobs from the start-th to the stop-th to be popped across all the waiting lists,
+// both inclusive and starting from 0.
+func (r *RedisDriver) rangeLevels(ctx context.Context, start, stop int64) ([]string, error) {
+	var (
+		jobs   []string
+		offset int64
+	)
+	for _, key := range r.waitingKeys() {
+		if offset > stop {
+			break
+		}
+		n, err := r.RedisClient.LLen(ctx, key).Result()
+		if err != nil {
+			return jobs, err
+		}
+		if offset+n > start {
+			from, to := start-offset, stop-offset
+			if from < 0 {
+				from = 0
+			}
+			if to >= n {
+				to = n - 1
+			}
+			page, err := r.rangeList(ctx, key, from, to)
+			if err != nil {
+				return jobs, err
+			}
+			jobs = append(jobs, page...)
+		}
+		offset += n
+	}
+	return jobs, nil
+}
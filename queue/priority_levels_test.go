@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisDriver_PriorityLevels(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewUniversalClient(&redis.UniversalOptions{})
+	driver := &RedisDriver{
+		RedisClient: client,
+		ChannelConfig: ChannelConfig{
+			Delayed:  "{levels}:delayed",
+			Failed:   "{levels}:failed",
+			Reserved: "{levels}:reserved",
+			Waiting:  "{levels}:waiting",
+			Timeout:  "{levels}:timeout",
+		},
+		PopTimeout:     time.Second,
+		PriorityLevels: map[string]int{"high": 10, "low": -10},
+	}
+	keys := []string{"{levels}:delayed", "{levels}:failed", "{levels}:reserved", "{levels}:waiting", "{levels}:waiting:high", "{levels}:waiting:low", "{levels}:timeout"}
+	client.Del(ctx, keys...)
+	defer client.Del(ctx, keys...)
+
+	jobs := []struct {
+		id       string
+		priority int
+	}{
+		{"bulk", -20},
+		{"normal", 0},
+		{"urgent-1", 20},
+		{"urgent-2", 10},
+	}
+	for _, job := range jobs {
+		assert.NoError(t, driver.Push(ctx, &PersistedEvent{UniqueId: job.id, Priority: job.priority}, 0))
+	}
+	assert.Equal(t, int64(2), client.LLen(ctx, "{levels}:waiting:high").Val())
+	assert.Equal(t, int64(1), client.LLen(ctx, "{levels}:waiting:low").Val())
+	info, err := driver.Info(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), info.Waiting)
+	position, err := driver.Position(ctx, "bulk", DefaultPositionScanLimit)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), position)
+
+	for _, id := range []string{"urgent-1", "urgent-2", "normal"} {
+		msg, err := driver.Pop(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, id, msg.UniqueId)
+		if id == "urgent-1" {
+			assert.NoError(t, driver.Fail(ctx, msg))
+		}
+	}
+
+	// failed jobs are reloaded onto the list of their level.
+	reloaded, err := driver.Reload(ctx, driver.ChannelConfig.Failed)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), reloaded)
+	for _, id := range []string{"urgent-1", "bulk"} {
+		msg, err := driver.Pop(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, id, msg.UniqueId)
+	}
+
+	// flushing the waiting channel empties the lists of all levels.
+	for _, job := range jobs {
+		assert.NoError(t, driver.Push(ctx, &PersistedEvent{UniqueId: job.id, Priority: job.priority}, 0))
+	}
+	assert.NoError(t, driver.Flush(ctx, driver.ChannelConfig.Waiting))
+	info, err = driver.Info(ctx)
+	assert.NoError(t, err)
+	assert.Zero(t, info.Waiting)
+}
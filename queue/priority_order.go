@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// MaxPriority is the maximum absolute priority honored by RedisDriver.PriorityOrder. Higher priorities are clamped.
+const MaxPriority = 500
+
+// priorityScale separates the priorities in the score of the waiting sorted set. It exceeds any timestamp in
+// milliseconds, so that the enqueue time only orders jobs of equal priority.
+const priorityScale = 1e13
+
+// waitingScore is the score of the job in the waiting sorted set. Lower scores are popped first.
+func waitingScore(message *PersistedEvent, now time.Time) float64 {
+	priority := message.Priority
+	if priority > MaxPriority {
+		priority = MaxPriority
+	}
+	if priority < -MaxPriority {
+		priority = -MaxPriority
+	}
+	return -float64(priority)*priorityScale + float64(now.UnixNano()/int64(time.Millisecond))
+}
+
+// pushWaiting queues the commands pushing the serialized message onto the waiting queue.
+func (r *RedisDriver) pushWaiting(ctx context.Context, p redis.Cmdable, data string, now time.Time) error {
+	if !r.PriorityOrder && !r.leveled() {
+		p.LPush(ctx, r.ChannelConfig.Waiting, data)
+		return nil
+	}
+	var message PersistedEvent
+	if err := r.Packer.Decompress([]byte(data), &message); err != nil {
+		return errors.Wrap(err, "failed to decompress message")
+	}
+	if !r.PriorityOrder {
+		p.LPush(ctx, r.waitingKeyOf(&message), data)
+		return nil
+	}
+	p.ZAdd(ctx, r.ChannelConfig.Waiting, &redis.Z{Score: waitingScore(&message, now), Member: data})
+	return nil
+}
+
+// popWaiting blocks until a job is available on the waiting queue, or the PopTimeout is reached.
+func (r *RedisDriver) popWaiting(ctx context.Context) (string, error) {
+	if !r.PriorityOrder {
+		res, err := r.RedisClient.BRPop(ctx, r.PopTimeout, r.waitingKeys()...).Result()
+		if err != nil {
+			return "", err
+		}
+		return res[1], nil
+	}
+	res, err := r.RedisClient.BZPopMin(ctx, r.PopTimeout, r.ChannelConfig.Waiting).Result()
+	if err != nil {
+		return "", err
+	}
+	data, _ := res.Member.(string)
+	return data, nil
+}
+
+// waitingLen returns the command counting the jobs on the waiting queue.
+func (r *RedisDriver) waitingLen(ctx context.Context) *redis.IntCmd {
+	if r.PriorityOrder {
+		return r.RedisClient.ZCard(ctx, r.ChannelConfig.Waiting)
+	}
+	if r.leveled() {
+		return r.levelsLen(ctx)
+	}
+	return r.RedisClient.LLen(ctx, r.ChannelConfig.Waiting)
+}
+
+// rangeWaiting returns the waiting jobs from the start-th to the stop-th to be popped, both inclusive and starting
+// from 0.
+func (r *RedisDriver) rangeWaiting(ctx context.Context, start, stop int64) ([]string, error) {
+	if r.PriorityOrder {
+		return r.RedisClient.ZRange(ctx, r.ChannelConfig.Waiting, start, stop).Result()
+	}
+	if r.leveled() {
+		return r.rangeLevels(ctx, start, stop)
+	}
+	return r.rangeList(ctx, r.ChannelConfig.Waiting, start, stop)
+}
+
+// rangeList returns the jobs of the list from the start-th to the stop-th to be popped, both inclusive and starting
+// from 0.
+func (r *RedisDriver) rangeList(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	// Jobs are pushed to the left of the list and popped from the right.
+	jobs, err := r.RedisClient.LRange(ctx, key, -stop-1, -start-1).Result()
+	for i, j := 0, len(jobs)-1; i < j; i, j = i+1, j-1 {
+		jobs[i], jobs[j] = jobs[j], jobs[i]
+	}
+	return jobs, err
+}
+
+// reloadOne moves the job at the tail of the channel onto the waiting queue, resetting the Reservations of dead jobs.
+// It returns redis.Nil if the channel is empty.
+func (r *RedisDriver) reloadOne(ctx context.Context, channel string) error {
+	data, err := r.RedisClient.LIndex(ctx, channel, -1).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return err
+		}
+		return errors.Wrapf(err, "failed to lindex %s while reloading", channel)
+	}
+	if channel == r.ChannelConfig.Dead {
+		var message PersistedEvent
+		if err := r.Packer.Decompress([]byte(data), &message); err != nil {
+			return errors.Wrap(err, "failed to decompress message")
+		}
+		message.Reservations = 0
+		revived, err := r.Packer.Compress(&message)
+		if err != nil {
+			return errors.Wrap(err, "failed to compress message")
+		}
+		data = string(revived)
+	}
+	p := r.RedisClient.TxPipeline()
+	p.RPop(ctx, channel)
+	if err := r.pushWaiting(ctx, p, data, time.Now()); err != nil {
+		return err
+	}
+	if _, err := p.Exec(ctx); err != nil {
+		return errors.Wrapf(err, "failed to move %s to the waiting queue while reloading", channel)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func setUpPriorityDriver(t *testing.T) *RedisDriver {
+	client := redis.NewUniversalClient(&redis.UniversalOptions{})
+	driver := &RedisDriver{
+		RedisClient: client,
+		ChannelConfig: ChannelConfig{
+			Delayed:  "{priority}:delayed",
+			Failed:   "{priority}:failed",
+			Reserved: "{priority}:reserved",
+			Waiting:  "{priority}:waiting",
+			Timeout:  "{priority}:timeout",
+		},
+		PriorityOrder: true,
+	}
+	keys := []string{"{priority}:delayed", "{priority}:failed", "{priority}:reserved", "{priority}:waiting", "{priority}:timeout"}
+	client.Del(context.Background(), keys...)
+	t.Cleanup(func() { client.Del(context.Background(), keys...) })
+	return driver
+}
+
+func TestRedisDriver_PriorityOrder(t *testing.T) {
+	ctx := context.Background()
+	driver := setUpPriorityDriver(t)
+	jobs := []struct {
+		id       string
+		priority int
+	}{
+		{"low-1", 0},
+		{"high-1", 5},
+		{"low-2", 0},
+		{"high-2", 5},
+		{"lowest", -1},
+		{"clamped", MaxPriority + 1},
+	}
+	for _, job := range jobs {
+		assert.NoError(t, driver.Push(ctx, &PersistedEvent{UniqueId: job.id, Priority: job.priority}, 0))
+		// Enqueue times are in milliseconds.
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	info, err := driver.Info(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), info.Waiting)
+	position, err := driver.Position(ctx, "low-2", DefaultPositionScanLimit)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), position)
+
+	var order []string
+	for range jobs {
+		msg, err := driver.Pop(ctx)
+		assert.NoError(t, err)
+		order = append(order, msg.UniqueId)
+	}
+	assert.Equal(t, []string{"clamped", "high-1", "high-2", "low-1", "low-2", "lowest"}, order)
+	_, err = driver.Pop(ctx)
+	assert.Equal(t, ErrEmpty, err)
+}
+
+func TestRedisDriver_PriorityOrder_promoteAndReload(t *testing.T) {
+	ctx := context.Background()
+	driver := setUpPriorityDriver(t)
+	// The delayed job became due before the job pushed now, so it is older.
+	assert.NoError(t, driver.RedisClient.ZAdd(ctx, driver.ChannelConfig.Delayed, &redis.Z{
+		Score:  float64(time.Now().Add(-time.Minute).Unix()),
+		Member: mustCompress(t, driver, &PersistedEvent{UniqueId: "delayed"}),
+	}).Err())
+	assert.NoError(t, driver.Push(ctx, &PersistedEvent{UniqueId: "now"}, 0))
+	assert.NoError(t, driver.RedisClient.LPush(ctx, driver.ChannelConfig.Failed, mustCompress(t, driver, &PersistedEvent{UniqueId: "failed", Priority: 1})).Err())
+
+	n, err := driver.Reload(ctx, driver.ChannelConfig.Failed)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	var order []string
+	for i := 0; i < 3; i++ {
+		msg, err := driver.Pop(ctx)
+		assert.NoError(t, err)
+		order = append(order, msg.UniqueId)
+	}
+	assert.Equal(t, []string{"failed", "delayed", "now"}, order)
+}
+
+func mustCompress(t *testing.T, driver *RedisDriver, message *PersistedEvent) string {
+	driver.populateDefaults()
+	data, err := driver.Packer.Compress(message)
+	assert.NoError(t, err)
+	return string(data)
+}
@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"context"
+	"sync"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+)
+
+type jobIDKey struct{}
+
+// JobID returns the UniqueId of the job being handled in the context. It is available to the listeners of persisted
+// events, and stays the same across the retries and redeliveries of a job.
+func JobID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(jobIDKey{}).(string)
+	return id, ok
+}
+
+// ProcessedSet is a test utility that records how many times each job has been processed successfully. Wrap the
+// listener under test with it, simulate retries or crashes, and assert that every job is processed exactly once:
+//
+//  var processed queue.ProcessedSet
+//  dispatcher.Subscribe(processed.Wrap(listener))
+//  // ... dispatch, fail, redeliver
+//  assert.Empty(t, processed.Duplicates())
+//
+// Jobs are identified by JobID, or by the ID of the event metadata outside of the queue. Events without either are
+// not recorded. ProcessedSet is safe for concurrent use.
+type ProcessedSet struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// Wrap returns a listener that delegates to the listener, and records the jobs it processes without error.
+func (p *ProcessedSet) Wrap(listener contract.Listener) contract.Listener {
+	return events.Listen(listener.Listen(), func(ctx context.Context, event contract.Event) error {
+		if err := listener.Process(ctx, event); err != nil {
+			return err
+		}
+		id, ok := JobID(ctx)
+		if !ok {
+			metadata, hasMetadata := events.MetadataOf(event)
+			if !hasMetadata {
+				return nil
+			}
+			id = metadata.ID
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.counts == nil {
+			p.counts = make(map[string]int)
+		}
+		p.counts[id]++
+		return nil
+	})
+}
+
+// Count returns how many times the job has been processed.
+func (p *ProcessedSet) Count(id string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.counts[id]
+}
+
+// Counts returns a copy of the processed counts, keyed by job.
+func (p *ProcessedSet) Counts() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	counts := make(map[string]int, len(p.counts))
+	for id, n := range p.counts {
+		counts[id] = n
+	}
+	return counts
+}
+
+// Duplicates returns the counts of the jobs processed more than once.
+func (p *ProcessedSet) Duplicates() map[string]int {
+	duplicates := make(map[string]int)
+	for id, n := range p.Counts() {
+		if n > 1 {
+			duplicates[id] = n
+		}
+	}
+	return duplicates
+}
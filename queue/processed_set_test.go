@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessedSet(t *testing.T) {
+	var (
+		processed ProcessedSet
+		failed    bool
+	)
+	driver := NewInProcessDriver()
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseLogger(log.NewNopLogger()))
+	dispatcher.Subscribe(processed.Wrap(MockListener(func(ctx context.Context, event contract.Event) error {
+		if !failed {
+			failed = true
+			return errors.New("failed")
+		}
+		return nil
+	})))
+
+	ctx := context.Background()
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), MaxAttempts(2), UniqueId("1"))))
+	time.Sleep(10 * time.Millisecond)
+	msg, err := driver.Pop(ctx)
+	assert.NoError(t, err)
+
+	// the failed attempt is not recorded. The retry is handled without waiting for its backoff.
+	dispatcher.work(ctx, msg)
+	dispatcher.work(ctx, msg)
+	assert.Equal(t, map[string]int{"1": 1}, processed.Counts())
+	assert.Empty(t, processed.Duplicates())
+
+	// a redelivery after a crash is a duplicate.
+	dispatcher.work(ctx, msg)
+	assert.Equal(t, 2, processed.Count("1"))
+	assert.Equal(t, map[string]int{"1": 2}, processed.Duplicates())
+
+	// outside of the queue, the metadata ID is used.
+	assert.NoError(t, dispatcher.Dispatch(ctx, events.New(MockEvent{}, events.WithID("2"))))
+	assert.Equal(t, 1, processed.Count("2"))
+}
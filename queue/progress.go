@@ -0,0 +1,147 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// DefaultProgressTTL is the default time the progress of a job is kept after it is last reported.
+const DefaultProgressTTL = 24 * time.Hour
+
+// Progress is the progress of a job, as reported by its listeners.
+type Progress struct {
+	// Percent is the completed percentage of the job, from 0 to 100.
+	Percent int `json:"percent"`
+	// Status is an arbitrary description of the current stage, for example "transcoding".
+	Status string `json:"status"`
+	// UpdatedAt is the time the progress was reported.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ProgressStore is implemented by drivers that can store the progress of jobs.
+type ProgressStore interface {
+	// SetProgress saves the progress of the job with the unique ID. It expires after the ttl.
+	SetProgress(ctx context.Context, uniqueId string, progress Progress, ttl time.Duration) error
+	// Progress returns the progress of the job with the unique ID, or nil if none is saved.
+	Progress(ctx context.Context, uniqueId string) (*Progress, error)
+	// ClearProgress deletes the progress of the job with the unique ID.
+	ClearProgress(ctx context.Context, uniqueId string) error
+}
+
+// UseProgressTTL is an option for WithQueue that sets the time the progress of a job is kept after it is last
+// reported. By default it is DefaultProgressTTL.
+func UseProgressTTL(ttl time.Duration) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.progressTTL = ttl
+	}
+}
+
+type progressReporterKey struct{}
+
+// progressReporter saves the progress of the job being handled.
+type progressReporter struct {
+	store    ProgressStore
+	uniqueId string
+	ttl      time.Duration
+	reported bool
+}
+
+// ReportProgress reports the progress of the job being handled in the context, so that it can be polled with
+// QueueableDispatcher.Progress. It is a no-op outside of the listeners of persisted events, or if the driver doesn't
+// implement ProgressStore.
+func ReportProgress(ctx context.Context, progress Progress) error {
+	reporter, ok := ctx.Value(progressReporterKey{}).(*progressReporter)
+	if !ok {
+		return nil
+	}
+	if progress.UpdatedAt.IsZero() {
+		progress.UpdatedAt = time.Now()
+	}
+	reporter.reported = true
+	return reporter.store.SetProgress(ctx, reporter.uniqueId, progress, reporter.ttl)
+}
+
+// trackProgress makes the progress of msg reportable through the context, if the driver implements ProgressStore.
+func (d *QueueableDispatcher) trackProgress(ctx context.Context, msg *PersistedEvent) (context.Context, *progressReporter) {
+	store, ok := d.driver.(ProgressStore)
+	if !ok {
+		return ctx, nil
+	}
+	ttl := d.progressTTL
+	if ttl <= 0 {
+		ttl = DefaultProgressTTL
+	}
+	reporter := &progressReporter{store: store, uniqueId: msg.UniqueId, ttl: ttl}
+	return context.WithValue(ctx, progressReporterKey{}, reporter), reporter
+}
+
+// clearProgress deletes the progress of a completed job. Jobs that never reported progress cost nothing.
+func (d *QueueableDispatcher) clearProgress(reporter *progressReporter) {
+	if reporter == nil || !reporter.reported {
+		return
+	}
+	if err := reporter.store.ClearProgress(context.Background(), reporter.uniqueId); err != nil {
+		_ = level.Warn(d.logger).Log("err", errors.Wrapf(err, "failed to clear the progress of job %s", reporter.uniqueId))
+	}
+}
+
+// Progress returns the progress reported by the job with the unique ID, for example to render a progress bar. It
+// returns nil if the job hasn't reported any, or has completed. An error is returned if the driver doesn't implement
+// ProgressStore.
+func (d *QueueableDispatcher) Progress(ctx context.Context, uniqueId string) (*Progress, error) {
+	store, ok := d.driver.(ProgressStore)
+	if !ok {
+		return nil, fmt.Errorf("driver %T doesn't support the progress of jobs", d.driver)
+	}
+	return store.Progress(ctx, uniqueId)
+}
+
+// progressKey returns the key holding the progress of the job. It shares the hash tag of the waiting queue.
+func (r *RedisDriver) progressKey(uniqueId string) string {
+	return fmt.Sprintf("%s:progress:%s", r.ChannelConfig.Waiting, uniqueId)
+}
+
+// SetProgress implements ProgressStore. The progress is saved as JSON.
+func (r *RedisDriver) SetProgress(ctx context.Context, uniqueId string, progress Progress, ttl time.Duration) error {
+	r.populateDefaults()
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal progress")
+	}
+	if err := r.RedisClient.Set(ctx, r.progressKey(uniqueId), data, ttl).Err(); err != nil {
+		return errors.Wrap(err, "failed to set progress")
+	}
+	return nil
+}
+
+// Progress implements ProgressStore.
+func (r *RedisDriver) Progress(ctx context.Context, uniqueId string) (*Progress, error) {
+	r.populateDefaults()
+	data, err := r.RedisClient.Get(ctx, r.progressKey(uniqueId)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get progress")
+	}
+	var progress Progress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal progress")
+	}
+	return &progress, nil
+}
+
+// ClearProgress implements ProgressStore.
+func (r *RedisDriver) ClearProgress(ctx context.Context, uniqueId string) error {
+	r.populateDefaults()
+	if err := r.RedisClient.Del(ctx, r.progressKey(uniqueId)).Err(); err != nil {
+		return errors.Wrap(err, "failed to clear progress")
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	ctx := context.Background()
+	driver := &RedisDriver{
+		ChannelConfig: ChannelConfig{
+			Delayed:  "{progress}:delayed",
+			Failed:   "{progress}:failed",
+			Reserved: "{progress}:reserved",
+			Waiting:  "{progress}:waiting",
+			Timeout:  "{progress}:timeout",
+		},
+	}
+	for _, channel := range []string{"{progress}:delayed", "{progress}:failed", "{progress}:reserved", "{progress}:waiting", "{progress}:timeout"} {
+		driver.Flush(ctx, channel)
+		defer driver.Flush(ctx, channel)
+	}
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseLogger(log.NewNopLogger()), UseProgressTTL(time.Minute))
+
+	var polled *Progress
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		assert.NoError(t, ReportProgress(ctx, Progress{Percent: 50, Status: "transcoding"}))
+		var err error
+		polled, err = dispatcher.Progress(ctx, "video")
+		return err
+	}))
+
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), UniqueId("video"))))
+	msg, err := driver.Pop(ctx)
+	assert.NoError(t, err)
+	dispatcher.work(ctx, msg)
+
+	assert.NotNil(t, polled)
+	assert.Equal(t, 50, polled.Percent)
+	assert.Equal(t, "transcoding", polled.Status)
+	assert.False(t, polled.UpdatedAt.IsZero())
+
+	// the progress is cleared once the job has completed.
+	progress, err := dispatcher.Progress(ctx, "video")
+	assert.NoError(t, err)
+	assert.Nil(t, progress)
+
+	// reporting outside of a job is a no-op.
+	assert.NoError(t, ReportProgress(ctx, Progress{Percent: 100}))
+}
@@ -6,8 +6,12 @@ type QueueInfo struct {
 	Waiting int64
 	// Delayed is the length of the Delayed queue.
 	Delayed int64
+	// Reserved is the number of jobs being handled, or abandoned by crashed consumers until they time out.
+	Reserved int64
 	//Timeout is the length of the Timeout queue.
 	Timeout int64
 	// Failed is the length of the Failed queue.
 	Failed int64
+	// Dead is the length of the Dead queue.
+	Dead int64
 }
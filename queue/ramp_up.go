@@ -0,0 +1,22 @@
+package queue
+
+import "time"
+
+// UseRampUp is an option for WithQueue that starts the workers gradually, from
+// one to the configured parallelism, evenly over the window. It gives cold
+// downstream services time to warm up their connection pools and caches,
+// similar to the slow start of load balancers. By default all workers start
+// immediately.
+func UseRampUp(window time.Duration) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.rampUp = window
+	}
+}
+
+// rampUpDelay returns how long the i-th worker waits before starting.
+func (d *QueueableDispatcher) rampUpDelay(i int) time.Duration {
+	if d.rampUp <= 0 || d.parallelism <= 1 {
+		return 0
+	}
+	return d.rampUp * time.Duration(i) / time.Duration(d.parallelism-1)
+}
@@ -0,0 +1,49 @@
+package queue
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_rampUpDelay(t *testing.T) {
+	dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriver(), UseParallelism(5), UseRampUp(time.Minute))
+	var delays []time.Duration
+	for i := 0; i < 5; i++ {
+		delays = append(delays, dispatcher.rampUpDelay(i))
+	}
+	assert.Equal(t, []time.Duration{0, 15 * time.Second, 30 * time.Second, 45 * time.Second, time.Minute}, delays)
+
+	immediate := WithQueue(&events.SyncDispatcher{}, NewInProcessDriver(), UseParallelism(5))
+	assert.Zero(t, immediate.rampUpDelay(4))
+}
+
+func TestDispatcher_UseRampUp(t *testing.T) {
+	dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriverWithPopInterval(time.Millisecond), UseParallelism(3), UseRampUp(200*time.Millisecond))
+	var running int32
+	release := make(chan struct{})
+	dispatcher.Subscribe(events.Listen(events.From(MockEvent{}), func(ctx context.Context, event contract.Event) error {
+		atomic.AddInt32(&running, 1)
+		<-release
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Consume(ctx)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}))))
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&running))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&running) == 3
+	}, time.Second, time.Millisecond)
+	close(release)
+}
@@ -0,0 +1,32 @@
+package queue
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// UseRateLimit is an option for WithQueue that limits the number of jobs reserved per second by the consumer, in
+// addition to the parallelism. The limit applies before a job is reserved, so throttled jobs stay in the waiting
+// queue, and the wait doesn't count against their HandleTimeout. It is local to the consumer: with several replicas,
+// divide the rate among them. A non-positive rate means unlimited.
+func UseRateLimit(perSecond float64, burst int) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		if perSecond <= 0 {
+			dispatcher.rateLimiter = nil
+			return
+		}
+		if burst < 1 {
+			burst = 1
+		}
+		dispatcher.rateLimiter = rate.NewLimiter(rate.Limit(perSecond), burst)
+	}
+}
+
+// waitRate blocks until the rate limit allows the next reservation, or the context is canceled.
+func (d *QueueableDispatcher) waitRate(ctx context.Context) error {
+	if d.rateLimiter == nil {
+		return nil
+	}
+	return d.rateLimiter.Wait(ctx)
+}
@@ -0,0 +1,37 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_UseRateLimit(t *testing.T) {
+	driver := NewInProcessDriverWithPopInterval(time.Millisecond)
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseParallelism(5), UseRateLimit(20, 1))
+	done := make(chan struct{}, 5)
+	dispatcher.Subscribe(events.Listen(events.From(MockEvent{}), func(ctx context.Context, event contract.Event) error {
+		done <- struct{}{}
+		return nil
+	}))
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, dispatcher.Dispatch(context.Background(), Persist(events.Of(MockEvent{}))))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Now()
+	go dispatcher.Consume(ctx)
+	<-done
+	info, _ := driver.Info(ctx)
+	assert.Equal(t, int64(4), info.Waiting, "throttled jobs stay unreserved")
+	for i := 1; i < 5; i++ {
+		<-done
+	}
+	// The first job takes the burst, and the others come at 20 per second.
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(190*time.Millisecond))
+}
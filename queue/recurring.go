@@ -0,0 +1,129 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+// RecurringJob is a job dispatched repeatedly on a schedule.
+type RecurringJob struct {
+	// Name identifies the job in logs.
+	Name string
+	// Schedule computes the fire times of the job.
+	Schedule cron.Schedule
+	// Event creates the event dispatched at the fire time, usually a persisted one, eg.
+	// queue.Persist(events.Of(Report{Day: at}), queue.MaxAttempts(3)).
+	Event func(at time.Time) contract.Event
+}
+
+// Scheduler dispatches recurring jobs to a dispatcher, typically a *QueueableDispatcher, so that each firing is
+// handled by the queue with its retries and timeouts. Time is told by the Clock, so that tests can fire the jobs
+// deterministically with a ManualClock:
+//
+//  clock := queue.NewManualClock(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+//  scheduler := queue.Scheduler{Dispatcher: dispatcher, Clock: clock}
+//  scheduler.Add("report", "*/5 * * * *", newReport)
+//  go scheduler.Run(ctx)
+//  clock.Advance(5 * time.Minute) // fires the report once
+//
+// Each fire time is computed from the previous one, so a firing is never skipped because the scheduler is late, and
+// a jump of the clock fires all the times passed in order. Only one Scheduler should run per set of jobs, otherwise
+// each firing is dispatched by all of them.
+type Scheduler struct {
+	// Dispatcher receives the events of the jobs.
+	Dispatcher contract.Dispatcher
+	// Clock tells the time. By default it is SystemClock.
+	Clock Clock
+	// Logger logs the failed dispatches. By default it is a nop logger.
+	Logger log.Logger
+	jobs   []RecurringJob
+}
+
+// Add adds a job scheduled by the standard cron spec, such as "*/5 * * * *" or "@hourly". It must be called before
+// Run.
+func (s *Scheduler) Add(name string, spec string, event func(at time.Time) contract.Event) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return errors.Wrapf(err, "invalid schedule of recurring job %s", name)
+	}
+	s.jobs = append(s.jobs, RecurringJob{Name: name, Schedule: schedule, Event: event})
+	return nil
+}
+
+// AddJob adds the job. It must be called before Run.
+func (s *Scheduler) AddJob(job RecurringJob) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Run dispatches the jobs at their fire times, until the context is canceled. Dispatch errors are logged, and don't
+// stop the scheduler.
+func (s *Scheduler) Run(ctx context.Context) error {
+	clock := s.clock()
+	fireTimes := NextFireTimes(s.jobs, clock.Now())
+	for {
+		at, due := nextDue(fireTimes)
+		if due == nil {
+			<-ctx.Done()
+			return nil
+		}
+		select {
+		case <-clock.After(at.Sub(clock.Now())):
+		case <-ctx.Done():
+			return nil
+		}
+		for _, i := range due {
+			job := s.jobs[i]
+			if err := s.Dispatcher.Dispatch(ctx, job.Event(at)); err != nil {
+				_ = level.Warn(s.logger()).Log("err", errors.Wrapf(err, "failed to dispatch recurring job %s at %s", job.Name, at))
+			}
+			fireTimes[i] = job.Schedule.Next(at)
+		}
+	}
+}
+
+// NextFireTimes returns the next fire time of each job after the given time. A zero time means the job never fires.
+func NextFireTimes(jobs []RecurringJob, after time.Time) []time.Time {
+	fireTimes := make([]time.Time, len(jobs))
+	for i, job := range jobs {
+		fireTimes[i] = job.Schedule.Next(after)
+	}
+	return fireTimes
+}
+
+// nextDue returns the earliest fire time, and the indexes of the jobs firing at that time.
+func nextDue(fireTimes []time.Time) (time.Time, []int) {
+	var (
+		at  time.Time
+		due []int
+	)
+	for i, t := range fireTimes {
+		switch {
+		case t.IsZero():
+		case due == nil || t.Before(at):
+			at, due = t, []int{i}
+		case t.Equal(at):
+			due = append(due, i)
+		}
+	}
+	return at, due
+}
+
+func (s *Scheduler) clock() Clock {
+	if s.Clock == nil {
+		return SystemClock{}
+	}
+	return s.Clock
+}
+
+func (s *Scheduler) logger() log.Logger {
+	if s.Logger == nil {
+		return log.NewNopLogger()
+	}
+	return s.Logger
+}
@@ -0,0 +1,61 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 1, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	dispatcher := &events.SyncDispatcher{}
+	fired := make(chan time.Time, 10)
+	dispatcher.Subscribe(events.Listen(events.From(MockEvent{}), func(ctx context.Context, event contract.Event) error {
+		fired <- clock.Now()
+		return nil
+	}))
+
+	scheduler := Scheduler{Dispatcher: dispatcher, Clock: clock}
+	assert.NoError(t, scheduler.Add("mock", "*/5 * * * *", func(at time.Time) contract.Event {
+		return events.Of(MockEvent{Value: at.Format("15:04")})
+	}))
+	assert.Error(t, scheduler.Add("invalid", "every minute", nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go scheduler.Run(ctx)
+
+	advance := func(d time.Duration) {
+		assert.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+		clock.Advance(d)
+	}
+
+	advance(3 * time.Minute)
+	assert.Len(t, fired, 0)
+
+	advance(time.Minute)
+	assert.Equal(t, start.Add(4*time.Minute), <-fired)
+
+	// firings passed by a jump of the clock are not skipped.
+	advance(10 * time.Minute)
+	assert.Equal(t, start.Add(14*time.Minute), <-fired)
+	assert.Equal(t, start.Add(14*time.Minute), <-fired)
+	assert.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	assert.Len(t, fired, 0)
+}
+
+func TestNextFireTimes(t *testing.T) {
+	var scheduler Scheduler
+	assert.NoError(t, scheduler.Add("hourly", "@hourly", nil))
+	assert.NoError(t, scheduler.Add("daily", "@daily", nil))
+	now := time.Date(2021, 1, 1, 10, 30, 0, 0, time.UTC)
+	assert.Equal(t, []time.Time{
+		time.Date(2021, 1, 1, 11, 0, 0, 0, time.UTC),
+		time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+	}, NextFireTimes(scheduler.jobs, now))
+}
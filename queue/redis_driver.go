@@ -3,11 +3,12 @@ package queue
 import (
 	"context"
 	"fmt"
-	"math/rand"
 	"sync"
 	"time"
 
+	"github.com/DoNewsCode/core/backoff"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/go-redis/redis/v8"
 	"github.com/pkg/errors"
 )
@@ -27,8 +28,24 @@ type RedisDriver struct {
 	ChannelConfig ChannelConfig         // ChannelConfig holds the name of redis keys for all queues.
 	PopTimeout    time.Duration         // PopTimeout is the BRPOP timeout. ie. How long the pop action will block at most.
 	Packer        Packer                // Packer describes how to save the message in wire format
+	// PromotionBatchSize is the maximum number of due jobs moved from the delayed or reserved queue at a time. It
+	// bounds the cost of each redis command when many jobs are due at once. By default it is 100.
+	PromotionBatchSize int
+	// PriorityOrder makes the waiting queue a sorted set, so that jobs are reserved strictly in the order of their
+	// Priority, highest first, and then of the time they became waiting, oldest first, at millisecond resolution.
+	// Priorities are clamped to MaxPriority. The waiting queue must be empty when PriorityOrder is switched.
+	PriorityOrder bool
+	// PriorityLevels maps the names of priority levels to the minimum Priority of their jobs. Each level has its own
+	// waiting list, the Waiting key with the ":" and name suffix, and the lists are polled from the highest level to
+	// the lowest. Jobs below every level stay on the Waiting list, which is polled last. Unlike PriorityOrder, the
+	// jobs within a level are reserved in the order they were pushed. It is ignored if PriorityOrder is set.
+	PriorityLevels map[string]int
+	// MaxAttempts is the ceiling of reservations of a job, across retries, timeouts and reloads. A job reserved more
+	// times is moved to the Dead channel instead, unless its own MaxAttempts is higher. Zero means no ceiling.
+	MaxAttempts   int
 	lock          sync.Mutex
 	defaultLoaded bool
+	buryHooks     []func(message *PersistedEvent)
 }
 
 // Push pushes the message onto the queue. It is possible to specify a time delay. If so the message
@@ -39,14 +56,30 @@ func (r *RedisDriver) Push(ctx context.Context, message *PersistedEvent, delay t
 	if err != nil {
 		return errors.Wrap(err, "failed to compress message")
 	}
+	if err := r.guard(ctx, message); err != nil {
+		return err
+	}
+	if err := r.push(ctx, data, delay); err != nil {
+		if message.guarded() {
+			r.unguard(ctx, message)
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *RedisDriver) push(ctx context.Context, data []byte, delay time.Duration) error {
 	if delay <= time.Duration(0) {
-		_, err = r.RedisClient.LPush(ctx, r.ChannelConfig.Waiting, data).Result()
-		if err != nil {
-			return errors.Wrap(err, "failed to lpush while pushing")
+		p := r.RedisClient.TxPipeline()
+		if err := r.pushWaiting(ctx, p, string(data), time.Now()); err != nil {
+			return err
+		}
+		if _, err := p.Exec(ctx); err != nil {
+			return errors.Wrap(err, "failed to push onto the waiting queue")
 		}
 		return nil
 	}
-	_, err = r.RedisClient.ZAdd(ctx, r.ChannelConfig.Delayed, &redis.Z{
+	_, err := r.RedisClient.ZAdd(ctx, r.ChannelConfig.Delayed, &redis.Z{
 		Score:  float64(time.Now().Add(delay).Unix()),
 		Member: data,
 	}).Result()
@@ -67,58 +100,86 @@ func (r *RedisDriver) Pop(ctx context.Context) (*PersistedEvent, error) {
 		return nil, err
 	}
 
-	res, err := r.RedisClient.BRPop(ctx, r.PopTimeout, r.ChannelConfig.Waiting).Result()
-	if err == redis.Nil {
-		return nil, ErrEmpty
-	}
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to brpop while popping")
-	}
-	data := res[1]
 	var message PersistedEvent
-	err = r.Packer.Decompress([]byte(data), &message)
+	for {
+		data, err := r.popWaiting(ctx)
+		if err == redis.Nil {
+			return nil, ErrEmpty
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to pop the waiting queue")
+		}
+		message = PersistedEvent{}
+		err = r.Packer.Decompress([]byte(data), &message)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decompress message")
+		}
+		message.Reservations++
+		if !r.exhausted(&message) {
+			break
+		}
+		if err := r.bury(ctx, &message); err != nil {
+			return nil, err
+		}
+	}
+	// The reserved job carries a fresh token, so that a stale holder of an earlier reservation can't release it.
+	message.LeaseToken = randomId()
+	message.reserve()
+	reserved, err := r.Packer.Compress(&message)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to decompress message")
+		return nil, errors.Wrap(err, "failed to compress message")
 	}
 	_, err = r.RedisClient.ZAdd(ctx, r.ChannelConfig.Reserved, &redis.Z{
 		Score:  float64(time.Now().Add(message.HandleTimeout).Unix()),
-		Member: data,
+		Member: reserved,
 	}).Result()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to zadd while putting message on the reserved queue")
 	}
+	if message.releasedAt(ReleaseOnReserve) {
+		r.unguard(ctx, &message)
+	}
 	return &message, nil
 
 }
 
-// Ack acknowledges a message has been processed.
+// Ack acknowledges a message has been processed. It returns ErrLeaseLost if the message is no longer reserved under
+// its LeaseToken.
 func (r *RedisDriver) Ack(ctx context.Context, message *PersistedEvent) error {
 	r.populateDefaults()
 	data, err := r.Packer.Compress(message)
 	if err != nil {
 		return errors.Wrap(err, "failed to compress message")
 	}
-	return r.remove(ctx, r.ChannelConfig.Reserved, data)
+	if err := r.release(ctx, data, nil); err != nil {
+		return errors.Wrap(err, "failed to ack message")
+	}
+	if message.releasedAt(ReleaseOnCompletion) {
+		r.unguard(ctx, message)
+	}
+	return nil
 }
 
-// Fail marks a message has failed.
+// Fail marks a message has failed. It returns ErrLeaseLost if the message is no longer reserved under its
+// LeaseToken.
 func (r *RedisDriver) Fail(ctx context.Context, message *PersistedEvent) error {
 	r.populateDefaults()
-	p := r.RedisClient.TxPipeline()
 	data, err := r.Packer.Compress(message)
 	if err != nil {
 		return errors.Wrap(err, "failed to compress message")
 	}
-	p.ZRem(ctx, r.ChannelConfig.Reserved, data)
 	message.Attempts++
-	data, err = r.Packer.Compress(message)
+	released := *message
+	released.LeaseToken = ""
+	failed, err := r.Packer.Compress(&released)
 	if err != nil {
 		return errors.Wrap(err, "failed to compress message")
 	}
-	p.LPush(ctx, r.ChannelConfig.Failed, data)
-	_, err = p.Exec(ctx)
-	if err != nil {
-		return errors.Wrapf(err, "failed to lpush while failing message")
+	if err := r.release(ctx, data, []string{r.ChannelConfig.Failed}, failed); err != nil {
+		return errors.Wrap(err, "failed to lpush while failing message")
+	}
+	if message.releasedAt(ReleaseOnCompletion) {
+		r.unguard(ctx, message)
 	}
 	return nil
 }
@@ -127,13 +188,26 @@ func (r *RedisDriver) Fail(ctx context.Context, message *PersistedEvent) error {
 // messages can be tried again via Reload. Reload is not a normal retry.
 // It similarly gives otherwise dead messages one more chance,
 // but this chance is not subject to the limit of MaxAttempts, nor does it reset the number of time attempted.
+// Jobs reloaded from the Dead channel have their Reservations reset, so that they are reserved up to MaxAttempts times
+// again.
 func (r *RedisDriver) Reload(ctx context.Context, channel string) (int64, error) {
 	r.populateDefaults()
-	if channel != r.ChannelConfig.Failed && channel != r.ChannelConfig.Timeout {
+	if channel != r.ChannelConfig.Failed && channel != r.ChannelConfig.Timeout && channel != r.ChannelConfig.Dead {
 		return 0, fmt.Errorf("reloading %s is not allowed", channel)
 	}
 	var count int64 = 0
 	for {
+		if r.PriorityOrder || r.leveled() || channel == r.ChannelConfig.Dead {
+			err := r.reloadOne(ctx, channel)
+			if errors.Is(err, redis.Nil) {
+				break
+			}
+			if err != nil {
+				return count, err
+			}
+			count++
+			continue
+		}
 		_, err := r.RedisClient.RPopLPush(ctx, channel, r.ChannelConfig.Waiting).Result()
 		if errors.Is(err, redis.Nil) {
 			break
@@ -146,16 +220,45 @@ func (r *RedisDriver) Reload(ctx context.Context, channel string) (int64, error)
 	return count, nil
 }
 
-// Flush flushes a queue of choice by deleting all its data. Use with caution.
+// Flush flushes a queue of choice by deleting all its data. Use with caution. Flushing the waiting channel also
+// deletes the lists of the PriorityLevels. The unique guards of the flushed jobs are released.
 func (r *RedisDriver) Flush(ctx context.Context, channel string) error {
 	r.populateDefaults()
-	_, err := r.RedisClient.Del(ctx, channel).Result()
+	keys := []string{channel}
+	if channel == r.ChannelConfig.Waiting {
+		keys = r.waitingKeys()
+	}
+	var jobs []string
+	for _, key := range keys {
+		members, err := r.members(ctx, key)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s before flushing", key)
+		}
+		jobs = append(jobs, members...)
+	}
+	_, err := r.RedisClient.Del(ctx, keys...).Result()
 	if err != nil {
 		return errors.Wrapf(err, "failed to flush %s", channel)
 	}
+	r.unguardAll(ctx, jobs)
 	return nil
 }
 
+// members returns the jobs stored at the key, which is either a list or a sorted set.
+func (r *RedisDriver) members(ctx context.Context, key string) ([]string, error) {
+	kind, err := r.RedisClient.Type(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "list":
+		return r.RedisClient.LRange(ctx, key, 0, -1).Result()
+	case "zset":
+		return r.RedisClient.ZRange(ctx, key, 0, -1).Result()
+	}
+	return nil, nil
+}
+
 type attempt struct {
 	err error
 }
@@ -174,10 +277,12 @@ func (r *RedisDriver) Info(ctx context.Context) (QueueInfo, error) {
 		oneByOne attempt
 		info     QueueInfo
 	)
-	oneByOne.try(r.RedisClient.LLen(ctx, r.ChannelConfig.Waiting), &info.Waiting)
+	oneByOne.try(r.waitingLen(ctx), &info.Waiting)
 	oneByOne.try(r.RedisClient.LLen(ctx, r.ChannelConfig.Failed), &info.Failed)
 	oneByOne.try(r.RedisClient.LLen(ctx, r.ChannelConfig.Timeout), &info.Timeout)
 	oneByOne.try(r.RedisClient.ZCard(ctx, r.ChannelConfig.Delayed), &info.Delayed)
+	oneByOne.try(r.RedisClient.ZCard(ctx, r.ChannelConfig.Reserved), &info.Reserved)
+	oneByOne.try(r.RedisClient.LLen(ctx, r.ChannelConfig.Dead), &info.Dead)
 
 	if oneByOne.err != nil {
 		return info, errors.Wrap(oneByOne.err, "failed to collect queue info")
@@ -185,54 +290,82 @@ func (r *RedisDriver) Info(ctx context.Context) (QueueInfo, error) {
 	return info, nil
 }
 
-func (r *RedisDriver) remove(ctx context.Context, channel string, data []byte) error {
-	_, err := r.RedisClient.ZRem(ctx, channel, string(data)).Result()
+// releaseReserved removes the reserved job ARGV[1] from KEYS[1], and then pushes ARGV[2] onto the list KEYS[2], or adds
+// it to the sorted set KEYS[2] with the score ARGV[3] if given. If the job is not reserved, nothing is changed and 0 is
+// returned.
+var releaseReserved = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+if #KEYS == 1 then
+	return 1
+end
+if ARGV[3] then
+	redis.call('ZADD', KEYS[2], ARGV[3], ARGV[2])
+else
+	redis.call('LPUSH', KEYS[2], ARGV[2])
+end
+return 1
+`)
+
+// release removes the reserved job atomically with moving it to the key in to, if any. It returns ErrLeaseLost if the
+// job is not reserved, which means the reservation under its LeaseToken has expired.
+func (r *RedisDriver) release(ctx context.Context, reserved []byte, to []string, args ...interface{}) error {
+	keys := append([]string{r.ChannelConfig.Reserved}, to...)
+	released, err := releaseReserved.Run(ctx, r.RedisClient, keys, append([]interface{}{reserved}, args...)...).Int()
 	if err != nil {
-		return errors.Wrapf(err, "failed to zrem while removing from %s", channel)
+		return err
+	}
+	if released == 0 {
+		return ErrLeaseLost
 	}
 	return nil
 }
 
 // Retry put the message back onto the delayed queue. The message will be tried after a period of time specified
 // by Backoff. Note: if one listener failed, all listeners for this event will have to be retried. Make sure
-// your listeners are idempotent as always.
+// your listeners are idempotent as always. It returns ErrLeaseLost if the message is no longer reserved under its
+// LeaseToken.
 func (r *RedisDriver) Retry(ctx context.Context, message *PersistedEvent) error {
 	r.populateDefaults()
-	p := r.RedisClient.TxPipeline()
 	data, err := r.Packer.Compress(message)
 	if err != nil {
 		return errors.Wrap(err, "failed to compress message")
 	}
-	p.ZRem(ctx, r.ChannelConfig.Reserved, string(data))
-	message.Backoff = getRetryDuration(message.Backoff)
+	message.Backoff = message.retryDelay()
 	message.Attempts++
 	delay := time.Now().Add(message.Backoff)
-	data, err = r.Packer.Compress(message)
+	released := *message
+	released.LeaseToken = ""
+	delayed, err := r.Packer.Compress(&released)
 	if err != nil {
 		return errors.Wrap(err, "failed to compress message")
 	}
-	p.ZAdd(ctx, r.ChannelConfig.Delayed, &redis.Z{
-		Score:  float64(delay.Unix()),
-		Member: data,
-	})
-	_, err = p.Exec(ctx)
-	if err != nil {
+	if err := r.release(ctx, data, []string{r.ChannelConfig.Delayed}, delayed, delay.Unix()); err != nil {
 		return errors.Wrap(err, "failed to add zset while retrying")
 	}
 	return nil
 }
 
 func (r *RedisDriver) move(ctx context.Context, fromKey string, toKey string) error {
-	jobs, _ := r.RedisClient.ZRevRangeByScore(ctx, fromKey, &redis.ZRangeBy{
+	jobs, _ := r.RedisClient.ZRevRangeByScoreWithScores(ctx, fromKey, &redis.ZRangeBy{
 		Min:    "-INF",
 		Max:    fmt.Sprintf("%d", time.Now().Unix()),
 		Offset: 0,
-		Count:  100,
+		Count:  int64(r.PromotionBatchSize),
 	}).Result()
 	p := r.RedisClient.TxPipeline()
 	for _, job := range jobs {
-		p.ZRem(ctx, fromKey, job)
-		p.LPush(ctx, toKey, job)
+		member, _ := job.Member.(string)
+		p.ZRem(ctx, fromKey, member)
+		if toKey != r.ChannelConfig.Waiting {
+			p.LPush(ctx, toKey, member)
+			continue
+		}
+		// The job becomes waiting at the time it is due.
+		if err := r.pushWaiting(ctx, p, member, time.Unix(int64(job.Score), 0)); err != nil {
+			return err
+		}
 	}
 	_, err := p.Exec(ctx)
 	if err != nil {
@@ -241,6 +374,41 @@ func (r *RedisDriver) move(ctx context.Context, fromKey string, toKey string) er
 	return nil
 }
 
+// exhausted reports whether the reserved message is over the MaxAttempts of the driver, and of its own.
+func (r *RedisDriver) exhausted(message *PersistedEvent) bool {
+	return r.MaxAttempts > 0 && message.Reservations > r.MaxAttempts && message.Reservations > message.MaxAttempts
+}
+
+// bury moves the exhausted message to the Dead channel.
+func (r *RedisDriver) bury(ctx context.Context, message *PersistedEvent) error {
+	message.LeaseToken = ""
+	data, err := r.Packer.Compress(message)
+	if err != nil {
+		return errors.Wrap(err, "failed to compress message")
+	}
+	if err := r.RedisClient.LPush(ctx, r.ChannelConfig.Dead, data).Err(); err != nil {
+		return errors.Wrap(err, "failed to lpush while moving message to the dead queue")
+	}
+	_ = level.Warn(r.Logger).Log("err", fmt.Sprintf("event %s reserved %d times, moved to the dead queue", message.Key, message.Reservations))
+	if message.releasedAt(ReleaseOnCompletion) {
+		r.unguard(ctx, message)
+	}
+	r.lock.Lock()
+	hooks := r.buryHooks
+	r.lock.Unlock()
+	for _, hook := range hooks {
+		hook(message)
+	}
+	return nil
+}
+
+// onBury implements buryNotifier.
+func (r *RedisDriver) onBury(hook func(message *PersistedEvent)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.buryHooks = append(r.buryHooks, hook)
+}
+
 func (r *RedisDriver) populateDefaults() {
 	r.lock.Lock()
 	defer r.lock.Unlock()
@@ -265,23 +433,20 @@ func (r *RedisDriver) populateDefaults() {
 			Reserved: "{RedisDriver}:reserved",
 			Waiting:  "{RedisDriver}:waiting",
 			Timeout:  "{RedisDriver}:timeout",
+			Dead:     "{RedisDriver}:dead",
 		}
 	}
+	if r.ChannelConfig.Dead == "" {
+		r.ChannelConfig.Dead = r.ChannelConfig.Failed + ":dead"
+	}
 	if r.PopTimeout == time.Duration(0) {
 		r.PopTimeout = time.Second
 	}
+	if r.PromotionBatchSize <= 0 {
+		r.PromotionBatchSize = 100
+	}
 	r.defaultLoaded = true
 }
 
-func getRetryDuration(d time.Duration) time.Duration {
-	d *= 2
-	jitter := rand.Float64() + 0.5
-	d = time.Duration(int64(float64(d.Nanoseconds()) * jitter))
-	if d > 10*time.Minute {
-		d = 10 * time.Minute
-	}
-	if d < time.Second {
-		d = time.Second
-	}
-	return d
-}
+// retryStrategy is the default backoff between attempts of a failed job.
+var retryStrategy backoff.Strategy = backoff.Exponential{Base: time.Second, Max: 10 * time.Minute, Jitter: 0.5, Min: time.Second}
@@ -5,8 +5,11 @@ import (
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/events"
 	"github.com/DoNewsCode/core/queue"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
 	"sync"
 	"testing"
+	"time"
 )
 
 func setUpInProcessQueueBenchmark(wg *sync.WaitGroup) (*queue.QueueableDispatcher, func()) {
@@ -56,3 +59,219 @@ func BenchmarkInProcessQueue(b *testing.B) {
 	wg.Wait()
 	cancel()
 }
+
+func TestRedisDriver_PromotionBatchSize(t *testing.T) {
+	ctx := context.Background()
+	driver := &queue.RedisDriver{
+		ChannelConfig: queue.ChannelConfig{
+			Delayed:  "{promotion}:delayed",
+			Failed:   "{promotion}:failed",
+			Reserved: "{promotion}:reserved",
+			Waiting:  "{promotion}:waiting",
+			Timeout:  "{promotion}:timeout",
+		},
+		PromotionBatchSize: 2,
+	}
+	for _, channel := range []string{"{promotion}:delayed", "{promotion}:reserved", "{promotion}:waiting"} {
+		driver.Flush(ctx, channel)
+		defer driver.Flush(ctx, channel)
+	}
+	for i := 0; i < 5; i++ {
+		if err := driver.Push(ctx, &queue.PersistedEvent{Key: "foo", Value: []byte{byte(i)}}, time.Nanosecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := driver.Pop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	info, err := driver.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Delayed != 3 || info.Waiting != 1 {
+		t.Fatalf("want 3 delayed and 1 waiting, got %+v", info)
+	}
+}
+
+func TestRedisDriver_LeaseToken(t *testing.T) {
+	ctx := context.Background()
+	driver := &queue.RedisDriver{
+		ChannelConfig: queue.ChannelConfig{
+			Delayed:  "{lease}:delayed",
+			Failed:   "{lease}:failed",
+			Reserved: "{lease}:reserved",
+			Waiting:  "{lease}:waiting",
+			Timeout:  "{lease}:timeout",
+		},
+		PopTimeout: time.Second,
+	}
+	for _, channel := range []string{"{lease}:delayed", "{lease}:failed", "{lease}:reserved", "{lease}:waiting", "{lease}:timeout"} {
+		driver.Flush(ctx, channel)
+		defer driver.Flush(ctx, channel)
+	}
+	if err := driver.Push(ctx, &queue.PersistedEvent{Key: "foo", MaxAttempts: 2, HandleTimeout: time.Nanosecond}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// The handle timeout is a nanosecond, so that the lease of the first reservation expires at once.
+	stale, err := driver.Pop(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := driver.Pop(ctx); err != queue.ErrEmpty {
+		t.Fatalf("want ErrEmpty, got %v", err)
+	}
+	if _, err := driver.Reload(ctx, "{lease}:timeout"); err != nil {
+		t.Fatal(err)
+	}
+	current, err := driver.Pop(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.LeaseToken == "" || current.LeaseToken == stale.LeaseToken {
+		t.Fatalf("want a new lease token, got %q and %q", stale.LeaseToken, current.LeaseToken)
+	}
+
+	for name, release := range map[string]func(context.Context, *queue.PersistedEvent) error{
+		"ack":   driver.Ack,
+		"fail":  driver.Fail,
+		"retry": driver.Retry,
+	} {
+		stale := *stale
+		if err := release(ctx, &stale); !errors.Is(err, queue.ErrLeaseLost) {
+			t.Fatalf("want ErrLeaseLost from stale %s, got %v", name, err)
+		}
+	}
+	info, err := driver.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info != (queue.QueueInfo{Reserved: 1}) {
+		t.Fatalf("want the job to stay reserved only, got %+v", info)
+	}
+
+	if err := driver.Retry(ctx, current); err != nil {
+		t.Fatal(err)
+	}
+	info, err = driver.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Delayed != 1 {
+		t.Fatalf("want 1 delayed, got %+v", info)
+	}
+}
+
+func TestRedisDriver_MaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	driver := &queue.RedisDriver{
+		ChannelConfig: queue.ChannelConfig{
+			Delayed:  "{dead}:delayed",
+			Failed:   "{dead}:failed",
+			Reserved: "{dead}:reserved",
+			Waiting:  "{dead}:waiting",
+			Timeout:  "{dead}:timeout",
+			Dead:     "{dead}:dead",
+		},
+		PopTimeout:  time.Second,
+		MaxAttempts: 2,
+	}
+	for _, channel := range []string{"{dead}:delayed", "{dead}:failed", "{dead}:reserved", "{dead}:waiting", "{dead}:timeout", "{dead}:dead"} {
+		driver.Flush(ctx, channel)
+		defer driver.Flush(ctx, channel)
+	}
+	if err := driver.Push(ctx, &queue.PersistedEvent{Key: "foo", MaxAttempts: 1, HandleTimeout: time.Nanosecond}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// The handle timeout is a nanosecond, so that each reservation times out at once.
+	for i := 1; i <= 2; i++ {
+		message, err := driver.Pop(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if message.Reservations != i {
+			t.Fatalf("want %d reservations, got %d", i, message.Reservations)
+		}
+		if _, err := driver.Pop(ctx); err != queue.ErrEmpty {
+			t.Fatalf("want ErrEmpty, got %v", err)
+		}
+		if _, err := driver.Reload(ctx, "{dead}:timeout"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := driver.Pop(ctx); err != queue.ErrEmpty {
+		t.Fatalf("want ErrEmpty, got %v", err)
+	}
+	info, err := driver.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Dead != 1 || info.Waiting != 0 {
+		t.Fatalf("want 1 dead, got %+v", info)
+	}
+
+	if _, err := driver.Reload(ctx, "{dead}:dead"); err != nil {
+		t.Fatal(err)
+	}
+	message, err := driver.Pop(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Reservations != 1 {
+		t.Fatalf("want reservations reset, got %d", message.Reservations)
+	}
+}
+
+func TestRedisDriver_Backoff(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewUniversalClient(&redis.UniversalOptions{})
+	driver := &queue.RedisDriver{
+		RedisClient: client,
+		ChannelConfig: queue.ChannelConfig{
+			Delayed:  "{backoff}:delayed",
+			Failed:   "{backoff}:failed",
+			Reserved: "{backoff}:reserved",
+			Waiting:  "{backoff}:waiting",
+			Timeout:  "{backoff}:timeout",
+		},
+	}
+	for _, channel := range []string{"{backoff}:delayed", "{backoff}:reserved", "{backoff}:waiting"} {
+		driver.Flush(ctx, channel)
+		defer driver.Flush(ctx, channel)
+	}
+
+	var message queue.PersistedEvent
+	queue.Persist(events.Of(1), queue.MaxAttempts(3), queue.Backoff(time.Minute, time.Hour, 3), queue.BackoffJitter(0.1)).Decorate(&message)
+	if message.RetryBackoff == nil || message.RetryBackoff.Jitter != 0.1 {
+		t.Fatalf("want the backoff decorated, got %+v", message.RetryBackoff)
+	}
+	message.RetryBackoff.Jitter = 0
+	message.Attempts = 2
+	if err := driver.Push(ctx, &message, 0); err != nil {
+		t.Fatal(err)
+	}
+	reserved, err := driver.Pop(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.Retry(ctx, reserved); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second retry is deferred by 1m * 3^1.
+	if reserved.Backoff != 3*time.Minute {
+		t.Fatalf("want a backoff of 3m, got %s", reserved.Backoff)
+	}
+	delayed, err := client.ZRangeWithScores(ctx, "{backoff}:delayed", 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delayed) != 1 {
+		t.Fatalf("want 1 delayed job, got %d", len(delayed))
+	}
+	if due := time.Unix(int64(delayed[0].Score), 0); due.Before(time.Now().Add(2*time.Minute)) || due.After(time.Now().Add(4*time.Minute)) {
+		t.Fatalf("want the job due in 3m, got %s", due)
+	}
+}
@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// Releaser is implemented by drivers that can put a reserved job back to the queue without counting an attempt,
+// atomically with removing its reservation.
+type Releaser interface {
+	// Release moves the reserved message back to the waiting queue, or to the delayed queue if delay is positive. The
+	// reservation is not counted towards MaxAttempts, and the unique guard of the message is kept. It returns
+	// ErrLeaseLost if the message is no longer reserved under its LeaseToken.
+	Release(ctx context.Context, message *PersistedEvent, delay time.Duration) error
+}
+
+// deferJob puts the reserved job back to the queue after the delay, without counting an attempt. Drivers that don't
+// implement Releaser push a copy of the job before acking it, so the job may be handled twice if the ack fails, and
+// its unique guard is released by the ack.
+func (d *QueueableDispatcher) deferJob(msg *PersistedEvent, delay time.Duration) {
+	ctx := context.Background()
+	if releaser, ok := d.driver.(Releaser); ok {
+		if err := releaser.Release(ctx, msg, delay); err != nil {
+			_ = level.Warn(d.logger).Log("err", errors.Wrapf(err, "failed to defer event %s", msg.Key))
+		}
+		return
+	}
+	deferred := unreserved(msg)
+	if err := d.driver.Push(ctx, deferred, delay); err != nil {
+		_ = level.Warn(d.logger).Log("err", errors.Wrapf(err, "failed to defer event %s", msg.Key))
+		return
+	}
+	_ = d.driver.Ack(ctx, msg)
+}
+
+// unreserved returns a copy of the reserved message as it was before the reservation.
+func unreserved(msg *PersistedEvent) *PersistedEvent {
+	released := *msg
+	if released.Reservations > 0 {
+		released.Reservations--
+	}
+	released.LeaseToken = ""
+	return &released
+}
+
+// Release implements Releaser. The reservation is removed and the job is moved in a single script. The delay is
+// rounded up to the second, the resolution of the delayed queue.
+func (r *RedisDriver) Release(ctx context.Context, message *PersistedEvent, delay time.Duration) error {
+	r.populateDefaults()
+	data, err := r.Packer.Compress(message)
+	if err != nil {
+		return errors.Wrap(err, "failed to compress message")
+	}
+	released, err := r.Packer.Compress(unreserved(message))
+	if err != nil {
+		return errors.Wrap(err, "failed to compress message")
+	}
+	now := time.Now()
+	switch {
+	case delay > 0:
+		err = r.release(ctx, data, []string{r.ChannelConfig.Delayed}, released, delayedScore(now.Add(delay)))
+	case r.PriorityOrder:
+		err = r.release(ctx, data, []string{r.ChannelConfig.Waiting}, released, waitingScore(message, now))
+	default:
+		err = r.release(ctx, data, []string{r.waitingKeyOf(message)}, released)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to release message")
+	}
+	return nil
+}
+
+// delayedScore returns the score of a job due at the time in the delayed queue. The delayed queue is promoted by the
+// second, so the time is rounded up, lest the job is promoted before it is due.
+func delayedScore(due time.Time) int64 {
+	score := due.Unix()
+	if due.After(time.Unix(score, 0)) {
+		score++
+	}
+	return score
+}
+
+// Release implements Releaser.
+func (i *InProcessDriver) Release(ctx context.Context, message *PersistedEvent, delay time.Duration) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	delete(i.reserved, message)
+	if delay <= 0 {
+		i.reload(message)
+		return nil
+	}
+	heap.Push(i.delayed, &item{
+		event:    message,
+		priority: time.Now().Add(delay),
+	})
+	return nil
+}
@@ -0,0 +1,59 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func setUpReleaseDriver(t *testing.T) *RedisDriver {
+	client := redis.NewUniversalClient(&redis.UniversalOptions{})
+	driver := &RedisDriver{
+		RedisClient: client,
+		ChannelConfig: ChannelConfig{
+			Delayed:  "{release}:delayed",
+			Failed:   "{release}:failed",
+			Reserved: "{release}:reserved",
+			Waiting:  "{release}:waiting",
+			Timeout:  "{release}:timeout",
+			Dead:     "{release}:dead",
+		},
+		PopTimeout:  time.Millisecond,
+		MaxAttempts: DefaultMaxAttempts,
+	}
+	keys := []string{"{release}:delayed", "{release}:failed", "{release}:reserved", "{release}:waiting", "{release}:timeout", "{release}:dead", "{release}:waiting:unique:key"}
+	client.Del(context.Background(), keys...)
+	t.Cleanup(func() { client.Del(context.Background(), keys...) })
+	return driver
+}
+
+func TestRedisDriver_Release(t *testing.T) {
+	ctx := context.Background()
+	driver := setUpReleaseDriver(t)
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver)
+
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Unique("key", time.Minute), UniqueUntil(ReleaseOnCompletion))))
+	for i := 0; i < DefaultMaxAttempts+1; i++ {
+		msg, err := driver.Pop(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, msg.Reservations)
+		assert.NoError(t, driver.Release(ctx, msg, 0))
+		assert.True(t, errors.Is(driver.Release(ctx, msg, 0), ErrLeaseLost))
+
+		err = dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Unique("key", time.Minute), UniqueUntil(ReleaseOnCompletion)))
+		assert.True(t, errors.Is(err, ErrDuplicate), err)
+	}
+	info, _ := driver.Info(ctx)
+	assert.Equal(t, QueueInfo{Waiting: 1}, info)
+
+	msg, err := driver.Pop(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, driver.Release(ctx, msg, time.Hour))
+	info, _ = driver.Info(ctx)
+	assert.Equal(t, QueueInfo{Delayed: 1}, info)
+}
@@ -0,0 +1,44 @@
+package queue
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ErrConsumerOnly is returned when a persisted event is dispatched to a queue that is only consumed in this service.
+var ErrConsumerOnly = errors.New("queue is consumer only")
+
+// Role is the part a service plays for a queue. In split deployments, a queue is usually produced by one service and
+// consumed by another.
+type Role string
+
+const (
+	// ProducerAndConsumer both dispatches and consumes jobs. It is the default.
+	ProducerAndConsumer Role = ""
+	// ProducerOnly only dispatches jobs. No consumer is started by ProvideRunGroup.
+	ProducerOnly Role = "producer"
+	// ConsumerOnly only consumes jobs. Dispatching persisted events fails with ErrConsumerOnly.
+	ConsumerOnly Role = "consumer"
+)
+
+func (r Role) validate() error {
+	switch r {
+	case ProducerAndConsumer, ProducerOnly, ConsumerOnly:
+		return nil
+	}
+	return fmt.Errorf("unknown queue role %q, want %q, %q or empty", string(r), string(ProducerOnly), string(ConsumerOnly))
+}
+
+// UseRole is an option for WithQueue that sets the Role of the service for the queue. By default, the service both
+// produces and consumes.
+func UseRole(role Role) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.role = role
+	}
+}
+
+// Role returns the part the service plays for the queue.
+func (d *QueueableDispatcher) Role() Role {
+	return d.role
+}
@@ -0,0 +1,38 @@
+package queue
+
+import (
+	"context"
+)
+
+// RoutedHandler handles a persisted event routed by its routing key. The
+// payload is passed as is in PersistedEvent.Value, and it is up to the handler
+// to decode it. This allows heterogeneous payloads in one queue, including
+// those produced by other languages.
+type RoutedHandler func(ctx context.Context, event *PersistedEvent) error
+
+// Route registers the handler for persisted events carrying the routing key.
+// See RoutingKey.
+//
+// When an event is consumed, its routing key takes precedence: if a handler
+// is registered for the routing key, the event is handled by it exclusively
+// and the type based listeners are not called. Otherwise, including when the
+// event carries no routing key, the event is decoded and dispatched to the
+// listeners by its type as usual. Registering a handler for an existing
+// routing key replaces the previous one.
+func (d *QueueableDispatcher) Route(routingKey string, handler RoutedHandler) {
+	d.rwLock.Lock()
+	defer d.rwLock.Unlock()
+	if d.routes == nil {
+		d.routes = make(map[string]RoutedHandler)
+	}
+	d.routes[routingKey] = handler
+}
+
+func (d *QueueableDispatcher) route(routingKey string) RoutedHandler {
+	if routingKey == "" {
+		return nil
+	}
+	d.rwLock.RLock()
+	defer d.rwLock.RUnlock()
+	return d.routes[routingKey]
+}
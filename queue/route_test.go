@@ -0,0 +1,44 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_Route(t *testing.T) {
+	dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriver())
+	var typed, routed []string
+	assert.NoError(t, Handle(dispatcher, func(ctx context.Context, e MockEvent) error {
+		typed = append(typed, e.Value)
+		return nil
+	}))
+	dispatcher.Route("mock.routed", func(ctx context.Context, e *PersistedEvent) error {
+		routed = append(routed, string(e.Value))
+		return nil
+	})
+
+	typedMsg, err := dispatcher.packer.Compress(MockEvent{Value: "typed"})
+	assert.NoError(t, err)
+	cases := []struct {
+		name  string
+		event *PersistedEvent
+	}{
+		{"routed", &PersistedEvent{Key: "foreign", RoutingKey: "mock.routed", Value: []byte("raw")}},
+		{"no routing key", &PersistedEvent{Key: events.Of(MockEvent{}).Type(), Value: typedMsg}},
+		{"unknown routing key", &PersistedEvent{Key: events.Of(MockEvent{}).Type(), RoutingKey: "mock.unknown", Value: typedMsg}},
+	}
+	for _, c := range cases {
+		assert.NoError(t, dispatcher.Dispatch(context.Background(), c.event), c.name)
+	}
+	assert.Equal(t, []string{"raw"}, routed)
+	assert.Equal(t, []string{"typed", "typed"}, typed)
+}
+
+func TestRoutingKey(t *testing.T) {
+	var s PersistedEvent
+	Persist(events.Of(MockEvent{}), RoutingKey("foo")).Decorate(&s)
+	assert.Equal(t, "foo", s.RoutingKey)
+}
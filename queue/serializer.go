@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"fmt"
+)
+
+// PackerSetter is implemented by drivers whose Packer can be replaced after they are created. See UseSerializer.
+type PackerSetter interface {
+	// SetPacker makes the driver store the jobs with the Packer.
+	SetPacker(packer Packer)
+}
+
+// SetPacker implements PackerSetter.
+func (r *RedisDriver) SetPacker(packer Packer) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Packer = packer
+}
+
+// SetPacker implements PackerSetter.
+func (k *KafkaDriver) SetPacker(packer Packer) {
+	k.Packer = packer
+}
+
+// SetPacker implements PackerSetter. The jobs are kept in memory without being serialized, so there is nothing to
+// set.
+func (i *InProcessDriver) SetPacker(packer Packer) {}
+
+// SetPacker implements PackerSetter. The Packer is set on the primary and the mirrors that implement PackerSetter,
+// so that the jobs have the same format everywhere.
+func (m *MirrorDriver) SetPacker(packer Packer) {
+	drivers := []Driver{m.Primary}
+	for _, mirror := range m.Mirrors {
+		drivers = append(drivers, mirror.Driver)
+	}
+	for _, driver := range drivers {
+		if setter, ok := driver.(PackerSetter); ok {
+			setter.SetPacker(packer)
+		}
+	}
+}
+
+// UseSerializer returns an option for WithQueue that serializes both the payloads and the jobs stored by the driver
+// with the Packer, so that a single setting covers both the enqueue and the dequeue paths:
+//
+//  opt, err := queue.UseSerializer(driver, queue.JSONPacker{})
+//  if err != nil {
+//    return err
+//  }
+//  dispatcher := queue.WithQueue(&events.SyncDispatcher{}, driver, opt)
+//
+// The Packer of the driver is set at once. An error is returned if the driver doesn't implement PackerSetter, or is a
+// MirrorDriver whose primary doesn't.
+func UseSerializer(driver Driver, packer Packer) (func(*QueueableDispatcher), error) {
+	if !canSetPacker(driver) {
+		return nil, fmt.Errorf("driver %T doesn't support setting its packer", driver)
+	}
+	driver.(PackerSetter).SetPacker(packer)
+	return UsePacker(packer), nil
+}
+
+// canSetPacker reports whether the Packer of the driver can be set.
+func canSetPacker(driver Driver) bool {
+	if mirror, ok := driver.(*MirrorDriver); ok {
+		return canSetPacker(mirror.Primary)
+	}
+	_, ok := driver.(PackerSetter)
+	return ok
+}
+
+// serializerOf returns the Packer configured by name, or nil for the default.
+func serializerOf(name string) (Packer, error) {
+	switch name {
+	case "", "gob":
+		return nil, nil
+	case "json":
+		return JSONPacker{}, nil
+	}
+	return nil, fmt.Errorf("unknown queue serializer %q, want \"gob\" or \"json\"", name)
+}
@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUseSerializer(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewUniversalClient(&redis.UniversalOptions{})
+	driver := &RedisDriver{
+		RedisClient:   client,
+		ChannelConfig: NewChannelConfig("app", "testing", "serializer"),
+		PopTimeout:    time.Second,
+	}
+	opt, err := UseSerializer(driver, JSONPacker{})
+	assert.NoError(t, err)
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, opt)
+	assert.Equal(t, JSONPacker{}, driver.Packer)
+	for _, channel := range []string{driver.ChannelConfig.Waiting, driver.ChannelConfig.Reserved} {
+		driver.Flush(ctx, channel)
+		defer driver.Flush(ctx, channel)
+	}
+
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "hello"}))))
+	raw, err := client.LRange(ctx, driver.ChannelConfig.Waiting, 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Len(t, raw, 1)
+	var stored struct{ Value []byte }
+	assert.NoError(t, json.Unmarshal([]byte(raw[0]), &stored))
+	assert.JSONEq(t, `{"Value":"hello","Called":null}`, string(stored.Value))
+
+	received := make(chan MockEvent, 1)
+	dispatcher.Subscribe(events.Listen(events.From(MockEvent{}), func(ctx context.Context, event contract.Event) error {
+		received <- event.Data().(MockEvent)
+		return nil
+	}))
+	msg, err := driver.Pop(ctx)
+	assert.NoError(t, err)
+	dispatcher.work(ctx, msg)
+	assert.Equal(t, MockEvent{Value: "hello"}, <-received)
+}
+
+func TestUseSerializer_drivers(t *testing.T) {
+	kafkaDriver := &KafkaDriver{}
+	mirrorDriver := &MirrorDriver{Primary: kafkaDriver, Mirrors: []Mirror{{Driver: NewInProcessDriver()}}}
+	_, err := UseSerializer(mirrorDriver, JSONPacker{})
+	assert.NoError(t, err)
+	assert.Equal(t, JSONPacker{}, kafkaDriver.Packer)
+
+	_, err = UseSerializer(&MirrorDriver{Primary: plainDriver{NewInProcessDriver()}}, JSONPacker{})
+	assert.Error(t, err)
+	_, err = UseSerializer(plainDriver{NewInProcessDriver()}, JSONPacker{})
+	assert.Error(t, err)
+}
+
+func TestSerializerOf(t *testing.T) {
+	for name, want := range map[string]Packer{"": nil, "gob": nil, "json": JSONPacker{}} {
+		serializer, err := serializerOf(name)
+		assert.NoError(t, err)
+		assert.Equal(t, want, serializer)
+	}
+	_, err := serializerOf("xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// UseShutdownTimeout is an option for WithQueue that makes the consumer shut down gracefully. Once the context of
+// Consume is canceled, no more jobs are reserved, and the jobs in flight are given up to the timeout to finish before
+// their contexts are canceled. By default, the contexts of the jobs in flight are canceled at once, and the jobs are
+// retried later.
+func UseShutdownTimeout(timeout time.Duration) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.shutdownTimeout = timeout
+	}
+}
+
+// detachedContext carries the values of a context, but not its cancellation.
+type detachedContext struct{ context.Context }
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// gracefulContext returns the context the jobs are handled in. It is canceled no earlier than the shutdown timeout
+// after ctx, unless stop is called first. Call stop once the workers have returned.
+func (d *QueueableDispatcher) gracefulContext(ctx context.Context) (context.Context, func()) {
+	if d.shutdownTimeout <= 0 {
+		return ctx, func() {}
+	}
+	workCtx, cancel := context.WithCancel(detachedContext{ctx})
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stopped:
+			return
+		}
+		timer := time.NewTimer(d.shutdownTimeout)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			_ = level.Warn(d.logger).Log(
+				"msg", "shutdown timeout elapsed, abandoning the jobs in flight",
+				"abandoned", d.ActiveWorkers(),
+			)
+			cancel()
+		case <-stopped:
+		}
+	}()
+	return workCtx, func() {
+		close(stopped)
+		cancel()
+	}
+}
@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_UseShutdownTimeout(t *testing.T) {
+	cases := []struct {
+		name      string
+		timeout   time.Duration
+		handling  time.Duration
+		handleErr error
+	}{
+		{"finished", time.Second, 100 * time.Millisecond, nil},
+		{"abandoned", 50 * time.Millisecond, time.Minute, context.Canceled},
+		{"immediate", 0, time.Minute, context.Canceled},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			driver := NewInProcessDriverWithPopInterval(time.Millisecond)
+			dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseShutdownTimeout(c.timeout))
+			started := make(chan struct{})
+			handled := make(chan error, 1)
+			dispatcher.Subscribe(events.Listen(events.From(MockEvent{}), func(ctx context.Context, event contract.Event) error {
+				close(started)
+				select {
+				case <-time.After(c.handling):
+					handled <- nil
+				case <-ctx.Done():
+					handled <- ctx.Err()
+				}
+				return nil
+			}))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			consumed := make(chan struct{})
+			go func() {
+				dispatcher.Consume(ctx)
+				close(consumed)
+			}()
+			assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}))))
+			<-started
+			cancel()
+
+			select {
+			case <-consumed:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Consume didn't return")
+			}
+			assert.Equal(t, c.handleErr, <-handled)
+			info, _ := driver.Info(context.Background())
+			assert.Zero(t, info.Reserved)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// RateLimit is a token bucket: Rate tokens are added per second, up to Burst. Each job takes a token. A zero Rate
+// means unlimited.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate" json:"rate"`
+	Burst int     `yaml:"burst" json:"burst"`
+}
+
+// TenantLimits is the configuration of per tenant rate limits.
+type TenantLimits struct {
+	// Default applies to tenants not listed in Tenants.
+	Default RateLimit `yaml:"default" json:"default"`
+	// Tenants maps tenant IDs to their limits.
+	Tenants map[string]RateLimit `yaml:"tenants" json:"tenants"`
+}
+
+// Of returns the limit of the tenant.
+func (t TenantLimits) Of(tenant string) RateLimit {
+	if limit, ok := t.Tenants[tenant]; ok {
+		return limit
+	}
+	return t.Default
+}
+
+// TenantLimiter decides whether a job of a tenant can be handled now.
+type TenantLimiter interface {
+	// Allow takes a token of the tenant. It returns zero if the job can be handled now, or else how long it should
+	// be deferred.
+	Allow(ctx context.Context, tenant string) (time.Duration, error)
+}
+
+// RedisTenantLimiter is a TenantLimiter backed by a token bucket per tenant in redis, so that the limits are shared
+// fairly among all replicas.
+type RedisTenantLimiter struct {
+	// Client is used to communicate with redis.
+	Client redis.UniversalClient
+	// Limits returns the limit of a tenant, eg. TenantLimits.Of, or a lookup in a database.
+	Limits func(tenant string) RateLimit
+	// Prefix is prepended to the keys of the buckets. By default it is "queue:tenant".
+	Prefix string
+}
+
+// tokenBucket takes a token from the bucket at KEYS[1]. ARGV are the rate per second, the burst and the current time
+// in milliseconds. It returns 0 if a token is taken, or else the milliseconds to wait for the next token.
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return wait
+`)
+
+// Allow implements TenantLimiter.
+func (r *RedisTenantLimiter) Allow(ctx context.Context, tenant string) (time.Duration, error) {
+	limit := r.Limits(tenant)
+	if limit.Rate <= 0 {
+		return 0, nil
+	}
+	burst := int(math.Max(1, float64(limit.Burst)))
+	wait, err := tokenBucket.Run(ctx, r.Client, []string{r.key(tenant)}, limit.Rate, burst, time.Now().UnixNano()/int64(time.Millisecond)).Int64()
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to take a token of tenant %s", tenant)
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+func (r *RedisTenantLimiter) key(tenant string) string {
+	prefix := r.Prefix
+	if prefix == "" {
+		prefix = "queue:tenant"
+	}
+	return fmt.Sprintf("%s:%s", prefix, tenant)
+}
+
+// Tenant is a PersistOption that tags the event with the tenant it belongs to. See UseTenantLimiter.
+func Tenant(tenant string) PersistOption {
+	return func(event *DeferrablePersistentEvent) {
+		event.tenant = tenant
+	}
+}
+
+// UseTenantLimiter is an option for WithQueue that applies per tenant rate limits to the consumer, so that the burst
+// of a tenant doesn't take all the workers. A job over the limit of its tenant is deferred until the tenant has
+// capacity again, plus up to a second of jitter, without counting as an attempt. In FIFO mode, the consumer waits instead, to keep the order. Jobs
+// without a tenant are not limited. If the limiter fails, the error is logged and the job is handled anyway.
+func UseTenantLimiter(limiter TenantLimiter) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.tenantLimiter = limiter
+	}
+}
+
+// throttleJitter is the upper limit of the random time added to the deferral of throttled jobs, so that the jobs of a
+// tenant don't all become due at once.
+const throttleJitter = time.Second
+
+// throttle returns how long the job should be deferred because of the rate limit of its tenant.
+func (d *QueueableDispatcher) throttle(ctx context.Context, msg *PersistedEvent) time.Duration {
+	if d.tenantLimiter == nil || msg.Tenant == "" {
+		return 0
+	}
+	wait, err := d.tenantLimiter.Allow(ctx, msg.Tenant)
+	if err != nil {
+		_ = level.Warn(d.logger).Log("err", err)
+		return 0
+	}
+	return wait
+}
+
+// jitter adds a random time up to throttleJitter to the wait.
+func jitter(wait time.Duration) time.Duration {
+	return wait + time.Duration(rand.Int63n(int64(throttleJitter)))
+}
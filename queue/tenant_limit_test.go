@@ -0,0 +1,113 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubTenantLimiter map[string]time.Duration
+
+func (s stubTenantLimiter) Allow(ctx context.Context, tenant string) (time.Duration, error) {
+	return s[tenant], nil
+}
+
+func TestTenantLimits_Of(t *testing.T) {
+	limits := TenantLimits{
+		Default: RateLimit{Rate: 1, Burst: 1},
+		Tenants: map[string]RateLimit{"big": {Rate: 100, Burst: 10}},
+	}
+	assert.Equal(t, RateLimit{Rate: 100, Burst: 10}, limits.Of("big"))
+	assert.Equal(t, RateLimit{Rate: 1, Burst: 1}, limits.Of("small"))
+}
+
+func TestRedisTenantLimiter_Allow(t *testing.T) {
+	client := redis.NewUniversalClient(&redis.UniversalOptions{})
+	limiter := &RedisTenantLimiter{
+		Client: client,
+		Limits: TenantLimits{
+			Default: RateLimit{Rate: 1, Burst: 2},
+			Tenants: map[string]RateLimit{"unlimited": {}},
+		}.Of,
+		Prefix: "queue:test:tenant",
+	}
+	ctx := context.Background()
+	client.Del(ctx, limiter.key("a"), limiter.key("b"))
+	defer client.Del(ctx, limiter.key("a"), limiter.key("b"))
+
+	for i := 0; i < 2; i++ {
+		wait, err := limiter.Allow(ctx, "a")
+		assert.NoError(t, err)
+		assert.Zero(t, wait)
+	}
+	wait, err := limiter.Allow(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, wait > 0 && wait <= time.Second, "wait: %s", wait)
+
+	// buckets are per tenant.
+	wait, err = limiter.Allow(ctx, "b")
+	assert.NoError(t, err)
+	assert.Zero(t, wait)
+
+	for i := 0; i < 5; i++ {
+		wait, err = limiter.Allow(ctx, "unlimited")
+		assert.NoError(t, err)
+		assert.Zero(t, wait)
+	}
+}
+
+func TestDispatcher_UseTenantLimiter(t *testing.T) {
+	driver := NewInProcessDriverWithPopInterval(time.Millisecond)
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseTenantLimiter(stubTenantLimiter{"noisy": time.Hour}))
+
+	handled := make(chan string, 2)
+	dispatcher.Subscribe(events.Listen(events.From(MockEvent{}), func(ctx context.Context, event contract.Event) error {
+		handled <- event.Data().(MockEvent).Value
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Consume(ctx)
+
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "noisy"}), Tenant("noisy"))))
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{Value: "quiet"}), Tenant("quiet"))))
+	assert.Equal(t, "quiet", <-handled)
+
+	assert.Eventually(t, func() bool {
+		info, _ := driver.Info(ctx)
+		return info.Delayed == 1
+	}, time.Second, time.Millisecond)
+	info, _ := driver.Info(ctx)
+	assert.Zero(t, info.Failed)
+	assert.Zero(t, info.Waiting)
+	assert.Len(t, handled, 0)
+}
+
+func TestDispatcher_UseTenantLimiter_redis(t *testing.T) {
+	ctx := context.Background()
+	driver := setUpReleaseDriver(t)
+	dispatcher := WithQueue(&events.SyncDispatcher{}, driver, UseTenantLimiter(stubTenantLimiter{"noisy": 300 * time.Millisecond}))
+
+	assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Tenant("noisy"))))
+	msg, err := driver.Pop(ctx)
+	assert.NoError(t, err)
+	deferred := time.Now()
+	dispatcher.work(ctx, msg)
+
+	// the job is due no earlier than the wait, even if the wait is shorter than a second.
+	jobs, err := driver.RedisClient.ZRangeWithScores(ctx, driver.ChannelConfig.Delayed, 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.GreaterOrEqual(t, int64(jobs[0].Score), delayedScore(deferred.Add(300*time.Millisecond)))
+	_, err = driver.Pop(ctx)
+	assert.True(t, errors.Is(err, ErrEmpty), err)
+	info, _ := driver.Info(ctx)
+	assert.Equal(t, QueueInfo{Delayed: 1}, info)
+}
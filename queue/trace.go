@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// UseTracer is an option for WithQueue that handles each reserved job in a "queue.Handle" span, tagged with the key
+// and the attempt of the job. The span is available to the listeners through the context.
+func UseTracer(tracer opentracing.Tracer) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.tracer = tracer
+	}
+}
+
+// handle dispatches the reserved job to the listeners, through the middlewares, and returns the follow-ups of a
+// successful job. The progress reported by a successful job is cleared.
+func (d *QueueableDispatcher) handle(ctx context.Context, msg *PersistedEvent) ([]FollowUpJob, error) {
+	ctx = context.WithValue(ctx, jobIDKey{}, msg.UniqueId)
+	ctx, progress := d.trackProgress(ctx, msg)
+	ctx, collector := collectFollowUps(ctx)
+	defer d.observeDuration(time.Now())
+	if err := d.chain(d.dispatchTraced)(ctx, msg); err != nil {
+		return nil, err
+	}
+	next, err := d.persistFollowUps(ctx, collector)
+	if err != nil {
+		return nil, err
+	}
+	d.clearProgress(progress)
+	return next, nil
+}
+
+// dispatchTraced dispatches the reserved job to the listeners, within a span if a tracer is set.
+func (d *QueueableDispatcher) dispatchTraced(ctx context.Context, msg *PersistedEvent) error {
+	if d.tracer == nil {
+		return d.Dispatch(ctx, msg)
+	}
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, d.tracer, "queue.Handle")
+	defer span.Finish()
+	ext.Component.Set(span, "queue")
+	span.SetTag("key", msg.Key)
+	span.SetTag("attempt", msg.Attempts)
+
+	err := d.Dispatch(ctx, msg)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("error", err.Error())
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrQueueNotConfigured is returned by the default dispatcher of Provide if no
+// default queue is configured.
+var ErrQueueNotConfigured = errors.New("queue not configured")
+
+// unconfiguredDriver is the Driver of a queue missing from the configuration.
+// It fails every operation with ErrQueueNotConfigured, so that persisted events
+// sent to the queue fail loudly instead of panicking.
+type unconfiguredDriver struct {
+	name string
+}
+
+func (u unconfiguredDriver) err() error {
+	return errors.Wrapf(ErrQueueNotConfigured, "queue %s", u.name)
+}
+
+func (u unconfiguredDriver) Push(ctx context.Context, message *PersistedEvent, delay time.Duration) error {
+	return u.err()
+}
+
+func (u unconfiguredDriver) Pop(ctx context.Context) (*PersistedEvent, error) {
+	return nil, u.err()
+}
+
+func (u unconfiguredDriver) Ack(ctx context.Context, message *PersistedEvent) error {
+	return u.err()
+}
+
+func (u unconfiguredDriver) Fail(ctx context.Context, message *PersistedEvent) error {
+	return u.err()
+}
+
+func (u unconfiguredDriver) Reload(ctx context.Context, channel string) (int64, error) {
+	return 0, u.err()
+}
+
+func (u unconfiguredDriver) Flush(ctx context.Context, channel string) error {
+	return u.err()
+}
+
+func (u unconfiguredDriver) Info(ctx context.Context) (QueueInfo, error) {
+	return QueueInfo{}, u.err()
+}
+
+func (u unconfiguredDriver) Retry(ctx context.Context, message *PersistedEvent) error {
+	return u.err()
+}
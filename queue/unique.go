@@ -0,0 +1,156 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// ErrDuplicate is returned when an event is dispatched with the Unique option, while a job with the same unique key
+// is still guarded.
+var ErrDuplicate = errors.New("duplicate job")
+
+// UniqueRelease decides when the guard of a unique job is released, so that the next job with the same key can be
+// dispatched.
+type UniqueRelease int
+
+const (
+	// ReleaseOnReserve releases the guard as soon as the job is reserved. Duplicates dispatched while the job is being
+	// handled are enqueued, so no change is missed, but the job may then be handled again. It is the default.
+	ReleaseOnReserve UniqueRelease = iota
+	// ReleaseOnCompletion keeps the guard until the job succeeds or finally fails. Duplicates are dropped for the
+	// whole lifetime of the job, including its retries, so a change made during the handling may be missed.
+	ReleaseOnCompletion
+)
+
+// Unique is a PersistOption that suppresses duplicate jobs. Dispatching the event fails with ErrDuplicate while
+// another job with the same key is guarded. The guard is released when the job is reserved, see UniqueUntil, or after
+// the ttl, whichever comes first. The ttl bounds how long a lost guard blocks the key. A non-positive ttl keeps the
+// guard until it is released.
+func Unique(key string, ttl time.Duration) PersistOption {
+	return func(event *DeferrablePersistentEvent) {
+		event.uniqueKey = key
+		event.uniqueTTL = ttl
+	}
+}
+
+// UniqueUntil is a PersistOption that selects when the guard set by Unique is released.
+func UniqueUntil(release UniqueRelease) PersistOption {
+	return func(event *DeferrablePersistentEvent) {
+		event.uniqueRelease = release
+	}
+}
+
+// guarded reports whether the message needs a unique guard before it is enqueued. Retries and deferrals of a job
+// that has been reserved are never guarded again. A job may take its own guard again.
+func (s *PersistedEvent) guarded() bool {
+	return s.UniqueKey != "" && s.Reservations == 0
+}
+
+// releasedAt reports whether the unique guard of the message is released at the stage.
+func (s *PersistedEvent) releasedAt(at UniqueRelease) bool {
+	return s.UniqueKey != "" && s.UniqueRelease == at
+}
+
+// uniqueKey returns the key guarding the unique job. It shares the hash tag of the waiting queue.
+func (r *RedisDriver) uniqueKey(key string) string {
+	return fmt.Sprintf("%s:unique:%s", r.ChannelConfig.Waiting, key)
+}
+
+// acquireGuard sets KEYS[1] to the job ARGV[1] for ARGV[2] milliseconds, unless it is held by another job. A
+// non-positive ttl never expires.
+var acquireGuard = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if owner == ARGV[1] then
+	return 1
+end
+if owner then
+	return 0
+end
+if tonumber(ARGV[2]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// releaseGuard deletes KEYS[1] if it is still held by the job ARGV[1].
+var releaseGuard = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// guard sets the unique guard of the message, or returns ErrDuplicate.
+func (r *RedisDriver) guard(ctx context.Context, message *PersistedEvent) error {
+	if !message.guarded() {
+		return nil
+	}
+	keys := []string{r.uniqueKey(message.UniqueKey)}
+	acquired, err := acquireGuard.Run(ctx, r.RedisClient, keys, message.UniqueId, message.UniqueTTL.Milliseconds()).Int()
+	if err != nil {
+		return errors.Wrap(err, "failed to set unique guard")
+	}
+	if acquired == 0 {
+		return errors.Wrapf(ErrDuplicate, "job with unique key %s already enqueued", message.UniqueKey)
+	}
+	return nil
+}
+
+// unguard releases the unique guard of the message, unless it has been taken over by another job.
+func (r *RedisDriver) unguard(ctx context.Context, message *PersistedEvent) {
+	if err := releaseGuard.Run(ctx, r.RedisClient, []string{r.uniqueKey(message.UniqueKey)}, message.UniqueId).Err(); err != nil {
+		_ = level.Warn(r.Logger).Log("err", errors.Wrapf(err, "failed to release unique guard %s", message.UniqueKey))
+	}
+}
+
+// unguardAll releases the unique guards of the serialized jobs removed from the queue. The jobs that can't be
+// decompressed have no guard known to the driver and are skipped.
+func (r *RedisDriver) unguardAll(ctx context.Context, jobs []string) {
+	for _, job := range jobs {
+		var message PersistedEvent
+		if err := r.Packer.Decompress([]byte(job), &message); err != nil || message.UniqueKey == "" {
+			continue
+		}
+		r.unguard(ctx, &message)
+	}
+}
+
+// uniqueGuard is the unique guard of the InProcessDriver.
+type uniqueGuard struct {
+	owner   string
+	expires time.Time
+}
+
+// guard sets the unique guard of the message, or returns ErrDuplicate. The mutex must be held.
+func (i *InProcessDriver) guard(message *PersistedEvent) error {
+	if !message.guarded() {
+		return nil
+	}
+	if g, ok := i.unique[message.UniqueKey]; ok && g.owner != message.UniqueId && (g.expires.IsZero() || g.expires.After(time.Now())) {
+		return errors.Wrapf(ErrDuplicate, "job with unique key %s already enqueued", message.UniqueKey)
+	}
+	if i.unique == nil {
+		i.unique = make(map[string]uniqueGuard)
+	}
+	g := uniqueGuard{owner: message.UniqueId}
+	if message.UniqueTTL > 0 {
+		g.expires = time.Now().Add(message.UniqueTTL)
+	}
+	i.unique[message.UniqueKey] = g
+	return nil
+}
+
+// unguard releases the unique guard of the message, unless it has been taken over by another job. The mutex must be
+// held.
+func (i *InProcessDriver) unguard(message *PersistedEvent) {
+	if g, ok := i.unique[message.UniqueKey]; ok && g.owner == message.UniqueId {
+		delete(i.unique, message.UniqueKey)
+	}
+}
@@ -0,0 +1,107 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_Unique(t *testing.T) {
+	ctx := context.Background()
+	redisDriver := &RedisDriver{
+		RedisClient:   redis.NewUniversalClient(&redis.UniversalOptions{}),
+		ChannelConfig: NewChannelConfig("app", "testing", "unique"),
+	}
+	cases := []struct {
+		name   string
+		driver Driver
+	}{
+		{"in process", NewInProcessDriver()},
+		{"redis", redisDriver},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			dispatcher := WithQueue(&events.SyncDispatcher{}, c.driver)
+			if c.driver == redisDriver {
+				for _, channel := range []string{redisDriver.ChannelConfig.Waiting, redisDriver.ChannelConfig.Reserved} {
+					redisDriver.Flush(ctx, channel)
+					defer redisDriver.Flush(ctx, channel)
+				}
+				for _, key := range []string{"on-reserve", "on-completion"} {
+					defer redisDriver.RedisClient.Del(ctx, redisDriver.uniqueKey(key))
+				}
+			}
+
+			t.Run("release on reserve", func(t *testing.T) {
+				dispatch := func() error {
+					return dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Unique("on-reserve", time.Minute)))
+				}
+				assert.NoError(t, dispatch())
+				assert.True(t, errors.Is(dispatch(), ErrDuplicate))
+
+				msg, err := c.driver.Pop(ctx)
+				assert.NoError(t, err)
+				assert.NoError(t, dispatch())
+				assert.NoError(t, c.driver.Ack(ctx, msg))
+				msg, err = c.driver.Pop(ctx)
+				assert.NoError(t, err)
+				assert.NoError(t, c.driver.Ack(ctx, msg))
+			})
+
+			t.Run("release on completion", func(t *testing.T) {
+				dispatch := func() error {
+					return dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Unique("on-completion", time.Minute), UniqueUntil(ReleaseOnCompletion)))
+				}
+				assert.NoError(t, dispatch())
+
+				msg, err := c.driver.Pop(ctx)
+				assert.NoError(t, err)
+				assert.True(t, errors.Is(dispatch(), ErrDuplicate))
+				assert.NoError(t, c.driver.Ack(ctx, msg))
+				assert.NoError(t, dispatch())
+				msg, err = c.driver.Pop(ctx)
+				assert.NoError(t, err)
+				assert.NoError(t, c.driver.Ack(ctx, msg))
+			})
+		})
+	}
+}
+
+func TestDispatcher_UniqueExpires(t *testing.T) {
+	ctx := context.Background()
+	dispatcher := WithQueue(&events.SyncDispatcher{}, NewInProcessDriver())
+	dispatch := func() error {
+		return dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Unique("expires", time.Millisecond)))
+	}
+	assert.NoError(t, dispatch())
+	time.Sleep(2 * time.Millisecond)
+	assert.NoError(t, dispatch())
+}
+
+func TestDispatcher_UniqueFlushed(t *testing.T) {
+	ctx := context.Background()
+	dispatcher := setUp()
+	for _, channel := range []string{"waiting", "delayed"} {
+		t.Run(channel, func(t *testing.T) {
+			var delay time.Duration
+			if channel == "delayed" {
+				delay = time.Hour
+			}
+			dispatch := func() error {
+				return dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}), Defer(delay), Unique("flushed", 0)))
+			}
+			assert.NoError(t, dispatch())
+			assert.True(t, errors.Is(dispatch(), ErrDuplicate))
+
+			assert.NoError(t, dispatcher.driver.Flush(ctx, channel))
+			assert.NoError(t, dispatch())
+			assert.NoError(t, dispatcher.driver.Flush(ctx, channel))
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// WebhookNotifier posts a JSON payload to an external URL whenever a job in
+// the queue is aborted, ie. it has exhausted all its attempts, or is moved to
+// the dead channel by the driver, ie. it has been reserved more than
+// RedisDriver.MaxAttempts times. It is a lightweight alternative to metrics
+// based alerting for small deployments.
+//
+// The notification is best-effort: the notifications are sent one at a time
+// by a background goroutine, and failures are only logged. When Backlog
+// notifications are already waiting, new ones are dropped and logged, so
+// consumption is never blocked by the webhook.
+type WebhookNotifier struct {
+	// Queue is the name of the queue reported in the payload.
+	Queue string
+	// URL is the endpoint that receives the POST request.
+	URL string
+	// Client is used to send the request. By default a http.Client with Timeout is used.
+	Client contract.HttpDoer
+	// Logger logs failed notifications. By default a noop logger is used.
+	Logger log.Logger
+	// Timeout is the upper limit of each notification. By default it is 5 seconds.
+	Timeout time.Duration
+	// Backlog is the number of notifications that can wait to be sent. By default it is 100.
+	Backlog int
+
+	once    sync.Once
+	pending chan WebhookPayload
+}
+
+// buryNotifier is implemented by the drivers that move exhausted jobs to a dead channel on their own, without
+// aborting them through the dispatcher.
+type buryNotifier interface {
+	// onBury registers the hook called with each job moved to the dead channel.
+	onBury(hook func(message *PersistedEvent))
+}
+
+// WebhookPayload is the JSON body sent by WebhookNotifier.
+type WebhookPayload struct {
+	Queue    string `json:"queue"`
+	Event    string `json:"event"`
+	Error    string `json:"error"`
+	Attempts int    `json:"attempts"`
+}
+
+// Notify sends the aborted event to the webhook asynchronously.
+func (w *WebhookNotifier) Notify(event AbortedEvent) {
+	payload := WebhookPayload{
+		Queue:    w.Queue,
+		Event:    event.Msg.Key,
+		Attempts: event.Msg.Attempts,
+	}
+	if event.Err != nil {
+		payload.Error = event.Err.Error()
+	}
+	w.enqueue(payload)
+}
+
+// notifyBuried sends the job moved to the dead channel by the driver to the webhook asynchronously.
+func (w *WebhookNotifier) notifyBuried(msg *PersistedEvent) {
+	w.enqueue(WebhookPayload{
+		Queue:    w.Queue,
+		Event:    msg.Key,
+		Error:    fmt.Sprintf("reserved %d times, moved to the dead queue", msg.Reservations),
+		Attempts: msg.Attempts,
+	})
+}
+
+// enqueue hands the payload to the background goroutine, or drops it if the backlog is full.
+func (w *WebhookNotifier) enqueue(payload WebhookPayload) {
+	w.once.Do(func() {
+		backlog := w.Backlog
+		if backlog <= 0 {
+			backlog = 100
+		}
+		w.pending = make(chan WebhookPayload, backlog)
+		go w.run()
+	})
+	select {
+	case w.pending <- payload:
+	default:
+		_ = level.Warn(w.logger()).Log("err", fmt.Sprintf("webhook notification of event %s dropped, as the backlog is full", payload.Event))
+	}
+}
+
+func (w *WebhookNotifier) run() {
+	for payload := range w.pending {
+		if err := w.send(payload); err != nil {
+			_ = level.Warn(w.logger()).Log("err", errors.Wrapf(err, "failed to notify webhook of event %s", payload.Event))
+		}
+	}
+}
+
+func (w *WebhookNotifier) send(payload WebhookPayload) error {
+	timeout := w.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client(timeout).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return errors.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (w *WebhookNotifier) client(timeout time.Duration) contract.HttpDoer {
+	if w.Client == nil {
+		return &http.Client{Timeout: timeout}
+	}
+	return w.Client
+}
+
+func (w *WebhookNotifier) logger() log.Logger {
+	if w.Logger == nil {
+		return log.NewNopLogger()
+	}
+	return w.Logger
+}
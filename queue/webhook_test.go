@@ -0,0 +1,102 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	received := make(chan WebhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+	}))
+	defer server.Close()
+
+	dispatcher := WithQueue(
+		&events.SyncDispatcher{},
+		NewInProcessDriver(),
+		UseLogger(log.NewNopLogger()),
+		UseWebhookNotifier(&WebhookNotifier{Queue: "default", URL: server.URL}),
+	)
+	dispatcher.Subscribe(MockListener(func(ctx context.Context, event contract.Event) error {
+		return errors.New("foo")
+	}))
+	msg, _ := dispatcher.packer.Compress(MockEvent{Value: "hello"})
+	dispatcher.work(context.Background(), &PersistedEvent{
+		Key:         events.Of(MockEvent{}).Type(),
+		Value:       msg,
+		Attempts:    1,
+		MaxAttempts: 1,
+	})
+
+	select {
+	case payload := <-received:
+		assert.Equal(t, "default", payload.Queue)
+		assert.Equal(t, events.Of(MockEvent{}).Type(), payload.Event)
+		assert.Equal(t, "foo", payload.Error)
+		assert.Equal(t, 1, payload.Attempts)
+	case <-time.After(time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestWebhookNotifier_buried(t *testing.T) {
+	received := make(chan WebhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	driver := setUpReleaseDriver(t)
+	driver.MaxAttempts = 1
+	WithQueue(&events.SyncDispatcher{}, &MirrorDriver{Primary: driver}, UseWebhookNotifier(&WebhookNotifier{Queue: "default", URL: server.URL}))
+	// the job has already been reserved once, so the next reservation exceeds MaxAttempts.
+	assert.NoError(t, driver.Push(ctx, &PersistedEvent{Key: "foo", Reservations: 1}, 0))
+	_, err := driver.Pop(ctx)
+	assert.True(t, errors.Is(err, ErrEmpty), err)
+
+	select {
+	case payload := <-received:
+		assert.Equal(t, "default", payload.Queue)
+		assert.Equal(t, "foo", payload.Event)
+		assert.Contains(t, payload.Error, "dead queue")
+	case <-time.After(time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestWebhookNotifier_backlog(t *testing.T) {
+	release := make(chan struct{})
+	var sent int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		atomic.AddInt32(&sent, 1)
+	}))
+	defer server.Close()
+
+	notifier := &WebhookNotifier{URL: server.URL, Backlog: 1}
+	for i := 0; i < 10; i++ {
+		notifier.Notify(AbortedEvent{Msg: &PersistedEvent{Key: "foo"}})
+	}
+	close(release)
+	assert.Eventually(t, func() bool {
+		return len(notifier.pending) == 0
+	}, time.Second, time.Millisecond)
+	assert.LessOrEqual(t, atomic.LoadInt32(&sent), int32(2))
+}
@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// WorkerBudget caps the number of jobs handled at the same time across all the queues sharing it, on top of the
+// parallelism of each queue. It keeps a service consuming many queues from exhausting its goroutines, connections or
+// memory when all of them are busy at once.
+//
+//  budget := queue.NewWorkerBudget(64)
+//  c.Provide(func() *queue.WorkerBudget { return budget })
+//
+// A reserved job waits for the budget before it is handled, and the wait counts against its HandleTimeout.
+type WorkerBudget struct {
+	sem    chan struct{}
+	active int32
+}
+
+// NewWorkerBudget creates a WorkerBudget of size concurrent jobs.
+func NewWorkerBudget(size int) *WorkerBudget {
+	if size < 1 {
+		size = 1
+	}
+	return &WorkerBudget{sem: make(chan struct{}, size)}
+}
+
+// Size returns the maximum number of jobs handled at the same time.
+func (b *WorkerBudget) Size() int {
+	return cap(b.sem)
+}
+
+// Active returns the number of jobs being handled across all the queues sharing the budget.
+func (b *WorkerBudget) Active() int {
+	return int(atomic.LoadInt32(&b.active))
+}
+
+// acquire blocks until the budget allows one more job, or the context is canceled. A nil budget never blocks.
+func (b *WorkerBudget) acquire(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	select {
+	case b.sem <- struct{}{}:
+		atomic.AddInt32(&b.active, 1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *WorkerBudget) release() {
+	if b == nil {
+		return
+	}
+	atomic.AddInt32(&b.active, -1)
+	<-b.sem
+}
+
+// UseWorkerBudget is an option for WithQueue that makes the consumer share the budget with other queues. See
+// WorkerBudget.
+func UseWorkerBudget(budget *WorkerBudget) func(*QueueableDispatcher) {
+	return func(dispatcher *QueueableDispatcher) {
+		dispatcher.workerBudget = budget
+	}
+}
+
+// ActiveWorkers returns the number of jobs of this queue being handled.
+func (d *QueueableDispatcher) ActiveWorkers() int {
+	return int(atomic.LoadInt32(&d.activeWorkers))
+}
+
+// withinBudget runs fn once the budget allows, and counts it as an active worker meanwhile. If the context is
+// canceled first, fn is not run, and the job stays reserved until it times out.
+func (d *QueueableDispatcher) withinBudget(ctx context.Context, fn func()) {
+	if err := d.workerBudget.acquire(ctx); err != nil {
+		return
+	}
+	defer d.workerBudget.release()
+	atomic.AddInt32(&d.activeWorkers, 1)
+	defer atomic.AddInt32(&d.activeWorkers, -1)
+	fn()
+}
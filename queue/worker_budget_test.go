@@ -0,0 +1,61 @@
+package queue
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_UseWorkerBudget(t *testing.T) {
+	budget := NewWorkerBudget(2)
+	var running, peak int32
+	release := make(chan struct{})
+	listener := events.Listen(events.From(MockEvent{}), func(ctx context.Context, event contract.Event) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&running, -1)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var dispatchers []*QueueableDispatcher
+	for i := 0; i < 2; i++ {
+		dispatcher := WithQueue(
+			&events.SyncDispatcher{},
+			NewInProcessDriverWithPopInterval(time.Millisecond),
+			UseParallelism(3),
+			UseWorkerBudget(budget),
+		)
+		dispatcher.Subscribe(listener)
+		go dispatcher.Consume(ctx)
+		for j := 0; j < 3; j++ {
+			assert.NoError(t, dispatcher.Dispatch(ctx, Persist(events.Of(MockEvent{}))))
+		}
+		dispatchers = append(dispatchers, dispatcher)
+	}
+
+	assert.Eventually(t, func() bool {
+		return budget.Active() == 2
+	}, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&peak))
+	assert.Equal(t, 2, dispatchers[0].ActiveWorkers()+dispatchers[1].ActiveWorkers())
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		return budget.Active() == 0 && atomic.LoadInt32(&running) == 0
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 2, budget.Size())
+}
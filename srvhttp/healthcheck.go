@@ -8,7 +8,8 @@ import (
 // HealthCheckModule defines a http provider for container.Container.
 // It uses github.com/heptiolabs/healthcheck underneath. It doesn't do much out of box other than providing liveness
 // check at ``/live`` and readiness check at ``/ready``. End user should add health checking functionality by themself,
-// e.g. probe if database connection pool has exhausted at readiness check.
+// e.g. probe if database connection pool has exhausted at readiness check. For an aggregated readiness report of all
+// database connections and queues, mount health.Aggregator instead.
 type HealthCheckModule struct{}
 
 // ProvideHttp implements container.HttpProvider